@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"domain_watcher/internal/pkg/storage"
+	"domain_watcher/pkg/certwatch"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var checkCertCmd = &cobra.Command{
+	Use:   "check-cert [file]",
+	Short: "Check a certificate file against the watch list",
+	Long: `Parse a PEM or DER certificate and report whether it would match the
+current watch list, why, and the certificate entry that would be emitted.
+
+The watch list is the one monitor uses: --domains, the
+DOMAIN_WATCHER_MONITOR_DOMAINS environment variable or monitor.domains in
+the config file, along with the --domains-file, --domain-regex, --ip and
+keyword watches and the matching options set in the config file. No network
+access is needed.
+
+Examples:
+  domain_watcher check-cert cert.pem --domains example.com
+  domain_watcher check-cert cert.der --domains example.com --subdomains=false
+  domain_watcher check-cert cert.pem --domains-file ./domains.yaml`,
+	Args: cobra.ExactArgs(1),
+	Run:  runCheckCert,
+}
+
+func init() {
+	rootCmd.AddCommand(checkCertCmd)
+
+	checkCertCmd.Flags().StringSlice("domains", []string{}, "Domains to check against (default: monitor.domains from config or env)")
+	checkCertCmd.Flags().Bool("subdomains", true, "Match subdomains of the watched domains")
+	checkCertCmd.Flags().Bool("all-domains", false, "Check as if monitoring ALL certificates")
+}
+
+func runCheckCert(cmd *cobra.Command, args []string) {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading certificate: %v\n", err)
+		os.Exit(1)
+	}
+
+	cert, err := certwatch.ParseCertificate(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing certificate: %v\n", err)
+		os.Exit(1)
+	}
+
+	domains, _ := cmd.Flags().GetStringSlice("domains")
+	if len(domains) == 0 {
+		domains = configuredDomains()
+	}
+	includeSubdomains, _ := cmd.Flags().GetBool("subdomains")
+	allDomains, _ := cmd.Flags().GetBool("all-domains")
+
+	if !allDomains && !watchListConfigured(domains) {
+		fmt.Fprintln(os.Stderr, "No domains specified. Use --domains or --domains-file, or set DOMAIN_WATCHER_MONITOR_DOMAINS")
+		os.Exit(1)
+	}
+
+	// Match with the same watch list and filters as monitor, without its
+	// output and notification handlers
+	monitor, err := certwatch.NewMonitorWithConfig(monitorConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid monitor configuration: %v\n", err)
+		os.Exit(1)
+	}
+	monitor.SetAllDomainsMode(allDomains)
+	if !allDomains {
+		addWatchList(monitor, domains, includeSubdomains)
+		if err := configureKeywords(monitor, viper.GetStringMapStringSlice("monitor.keywords"), nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid keyword configuration: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	names := []string{cert.Subject.CommonName}
+	names = append(names, cert.DNSNames...)
+	if viper.GetBool("verbose") {
+		fmt.Printf("Certificate names: %s\n", strings.Join(names, ", "))
+	}
+
+	entry, reason, ok := monitor.CheckCertificate(cert)
+	if !ok {
+		fmt.Printf("NO MATCH: %s does not match any watched domain\n", cert.Subject.CommonName)
+		return
+	}
+
+	fmt.Printf("MATCH: %s matches watched domain %s (%s)\n", cert.Subject.CommonName, entry.Domain, reason)
+
+	if viper.GetString("output") == "yaml" {
+		data, err = storage.MarshalYAML(entry)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling YAML: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(string(data))
+		return
+	}
+
+	data, err = json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
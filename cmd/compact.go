@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"domain_watcher/internal/pkg/storage"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var compactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Prune stored certificates older than the retention period",
+	Long: `Remove stored certificates older than --store-retention from the output
+store, so long all-domains runs don't grow it without bound. Safe to run on a
+schedule (e.g. from cron) while a monitor writes to a jsonl-gz directory.
+
+The store is read from --store, or monitor.output-path from the config file.
+
+Examples:
+  domain_watcher compact --store ./certs --store-retention 30d
+  domain_watcher compact --store-retention 720h`,
+	Args: cobra.NoArgs,
+	Run:  runCompact,
+}
+
+func init() {
+	rootCmd.AddCommand(compactCmd)
+
+	compactCmd.Flags().String("store", "", "Stored output to compact (default: monitor.output-path)")
+	compactCmd.Flags().String("store-retention", "30d", "Keep certificates seen within this period (e.g. 30d, 12h)")
+	viper.BindPFlag("store.retention", compactCmd.Flags().Lookup("store-retention"))
+}
+
+func runCompact(cmd *cobra.Command, args []string) {
+	store, _ := cmd.Flags().GetString("store")
+	if store == "" {
+		store = viper.GetString("monitor.output-path")
+	}
+	if store == "" {
+		log.Fatal("No store to compact. Use --store or set monitor.output-path in the config file")
+	}
+
+	retention, err := parseRetention(viper.GetString("store.retention"))
+	if err != nil {
+		log.Fatalf("Invalid --store-retention: %v", err)
+	}
+
+	cutoff := time.Now().Add(-retention)
+	result, err := storage.Compact(store, cutoff)
+	if err != nil {
+		log.Fatalf("Failed to compact %s: %v", store, err)
+	}
+
+	fmt.Printf("Removed %d entries older than %s (%d files removed, %d rewritten)\n",
+		result.EntriesRemoved, cutoff.Format(time.RFC3339), result.FilesRemoved, result.FilesRewritten)
+}
+
+// parseRetention accepts a Go duration or a whole number of days such as
+// "30d".
+func parseRetention(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid number of days: %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	retention, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if retention <= 0 {
+		return 0, fmt.Errorf("retention must be positive: %q", value)
+	}
+	return retention, nil
+}
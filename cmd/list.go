@@ -1,12 +1,18 @@
 package cmd
 
 import (
-	"domain_watcher/internal/pkg/certwatch"
+	"domain_watcher/internal/pkg/storage"
+	"domain_watcher/pkg/certwatch"
 	"domain_watcher/pkg/models"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -18,7 +24,10 @@ var listCmd = &cobra.Command{
 	Long: `List all domains that are currently being monitored for certificate transparency events.
 
 This command shows the domains, whether subdomains are included, when monitoring started,
-and when certificates were last seen for each domain.`,
+and when certificates were last seen for each domain. Domains come from the configuration
+(monitor.domains or DOMAIN_WATCHER_MONITOR_DOMAINS) and from the monitor's state file, which
+also records the last-seen times; set monitor.state-file in the config if the monitor uses
+a different one.`,
 	Run: runList,
 }
 
@@ -30,9 +39,21 @@ var historyCmd = &cobra.Command{
 This command queries certificate transparency logs to find historical certificates
 for the given domain. Note: This feature connects to external CT log APIs.
 
+--since and --until (RFC3339 or YYYY-MM-DD, a date for --until including
+that whole day) select an absolute window instead of --days. crt.sh
+results are selected by when they were logged; Censys, which has no log
+time, by the start of their validity.
+
+Sources are chosen with --history-provider (crtsh, censys); results from
+several providers are merged and deduplicated. Censys needs API credentials
+via --censys-api-id and --censys-secret (or DOMAIN_WATCHER_HISTORY_CENSYS_API_ID
+and DOMAIN_WATCHER_HISTORY_CENSYS_SECRET).
+
 Examples:
   domain_watcher history example.com
-  domain_watcher history example.com --days 30`,
+  domain_watcher history example.com --days 30
+  domain_watcher history example.com --since 2025-03-01 --until 2025-03-15
+  domain_watcher history example.com --history-provider crtsh,censys`,
 	Args: cobra.ExactArgs(1),
 	Run:  runHistory,
 }
@@ -42,16 +63,39 @@ func init() {
 	rootCmd.AddCommand(historyCmd)
 
 	historyCmd.Flags().Int("days", 90, "Number of days to look back for historical data")
+	historyCmd.Flags().String("since", "", "Only certificates logged (crt.sh) or valid (censys) from this time on (RFC3339 or YYYY-MM-DD; overrides --days)")
+	historyCmd.Flags().String("until", "", "Only certificates logged (crt.sh) or valid (censys) by this time (RFC3339 or YYYY-MM-DD; overrides --days)")
+	historyCmd.Flags().StringSlice("history-provider", []string{"crtsh"}, "Historical data sources to query (crtsh, censys)")
+	historyCmd.Flags().String("censys-api-id", "", "Censys API ID for --history-provider censys")
+	historyCmd.Flags().String("censys-secret", "", "Censys API secret for --history-provider censys")
 	viper.BindPFlag("history.days", historyCmd.Flags().Lookup("days"))
+	viper.BindPFlag("history.since", historyCmd.Flags().Lookup("since"))
+	viper.BindPFlag("history.until", historyCmd.Flags().Lookup("until"))
+	viper.BindPFlag("history.provider", historyCmd.Flags().Lookup("history-provider"))
+	viper.BindPFlag("history.censys-api-id", historyCmd.Flags().Lookup("censys-api-id"))
+	viper.BindPFlag("history.censys-secret", historyCmd.Flags().Lookup("censys-secret"))
 }
 
 func runList(cmd *cobra.Command, args []string) {
-	// For now, we'll create a temporary monitor to demonstrate the structure
-	// In a real application, this would read from a persistent store
+	// The domains are those configured for the monitor plus any that matched
+	// in an earlier run, with last-seen times from the monitor's state file
 	monitor := certwatch.NewMonitor()
+	monitor.SetLogger(slog.New(slog.DiscardHandler))
+	if err := monitor.SetStateFile(stateFilePath()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading state file: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Add some example domains for demonstration
-	// In practice, this would read from configuration or a database
+	includeSubdomains := viper.GetBool("monitor.subdomains")
+	for _, domain := range configuredDomains() {
+		monitor.AddDomain(domain, includeSubdomains)
+	}
+	watched := monitor.GetWatchedDomains()
+	for domain := range monitor.SavedLastSeen() {
+		if _, exists := watched[domain]; !exists {
+			monitor.AddDomain(domain, includeSubdomains)
+		}
+	}
 	domains := monitor.GetWatchedDomains()
 
 	if len(domains) == 0 {
@@ -70,6 +114,15 @@ func runList(cmd *cobra.Command, args []string) {
 			os.Exit(1)
 		}
 		fmt.Println(string(data))
+	case "yaml":
+		data, err := storage.MarshalYAML(domains)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling YAML: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(string(data))
+	case "csv", "tsv":
+		printDomainsDelimited(domains, outputFormat)
 	case "table":
 		fallthrough
 	default:
@@ -110,25 +163,83 @@ func printDomainsTable(domains map[string]*models.DomainWatch) {
 	w.Flush()
 }
 
+// printDomainsDelimited prints domains as csv or tsv, sorted by name.
+func printDomainsDelimited(domains map[string]*models.DomainWatch, format string) {
+	names := make([]string, 0, len(domains))
+	for domain := range domains {
+		names = append(names, domain)
+	}
+	sort.Strings(names)
+
+	rows := make([][]string, 0, len(names))
+	for _, domain := range names {
+		config := domains[domain]
+		lastSeen := ""
+		if !config.LastSeen.IsZero() {
+			lastSeen = config.LastSeen.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{
+			domain,
+			strconv.FormatBool(config.IncludeSubdomains),
+			config.CreatedAt.Format(time.RFC3339),
+			lastSeen,
+			strconv.FormatBool(config.Active),
+		})
+	}
+	printDelimited(format, []string{"domain", "subdomains", "created", "last_seen", "active"}, rows)
+}
+
+// printDelimited writes header and rows to stdout as csv or tsv.
+func printDelimited(format string, header []string, rows [][]string) {
+	w := storage.NewDelimitedWriter(os.Stdout, format)
+	if err := w.WriteAll(append([][]string{header}, rows...)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", strings.ToUpper(format), err)
+		os.Exit(1)
+	}
+}
+
 func runHistory(cmd *cobra.Command, args []string) {
 	domain := args[0]
 	days := viper.GetInt("history.days")
+	window := certwatch.LastDays(days)
+	period := fmt.Sprintf("in the last %d days", days)
+
+	since, until := viper.GetString("history.since"), viper.GetString("history.until")
+	if since != "" || until != "" {
+		var err error
+		window, err = certwatch.ParseTimeRange(since, until)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		period = "between " + valueOr(since, "the beginning") + " and " + valueOr(until, "now")
+	}
 
 	if viper.GetBool("verbose") {
-		fmt.Printf("Querying historical certificate data for %s (last %d days)\n", domain, days)
+		fmt.Printf("Querying historical certificate data for %s %s\n", domain, period)
 	}
 
 	// Create monitor and query historical data
 	monitor := certwatch.NewMonitor()
-	certificates, err := monitor.GetHistoricalCertificates(domain, days)
+	for _, name := range viper.GetStringSlice("history.provider") {
+		provider, err := certwatch.NewHistoryProvider(name, viper.GetString("history.censys-api-id"), viper.GetString("history.censys-secret"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error configuring history provider: %v\n", err)
+			if strings.EqualFold(name, "censys") {
+				fmt.Fprintln(os.Stderr, "Set --censys-api-id and --censys-secret, or DOMAIN_WATCHER_HISTORY_CENSYS_API_ID and DOMAIN_WATCHER_HISTORY_CENSYS_SECRET")
+			}
+			os.Exit(1)
+		}
+		monitor.AddHistoryProvider(provider)
+	}
+	certificates, err := monitor.GetHistoricalCertificates(domain, window)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error retrieving historical data: %v\n", err)
 		os.Exit(1)
 	}
 
 	if len(certificates) == 0 {
-		fmt.Printf("No certificate data found for %s in the last %d days.\n", domain, days)
-		fmt.Println("Note: Historical lookup is not yet fully implemented.")
+		fmt.Printf("No certificate data found for %s %s.\n", domain, period)
 		return
 	}
 
@@ -142,6 +253,19 @@ func runHistory(cmd *cobra.Command, args []string) {
 			os.Exit(1)
 		}
 		fmt.Println(string(data))
+	case "yaml":
+		data, err := storage.MarshalYAML(certificates)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling YAML: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(string(data))
+	case "csv", "tsv":
+		rows := make([][]string, 0, len(certificates))
+		for _, cert := range certificates {
+			rows = append(rows, storage.EntryRecord(cert))
+		}
+		printDelimited(outputFormat, storage.EntryColumns, rows)
 	case "table":
 		fallthrough
 	default:
@@ -169,3 +293,11 @@ func printCertificatesTable(certificates []*models.CertificateEntry) {
 
 	w.Flush()
 }
+
+// valueOr returns value, or fallback if it is empty.
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"domain_watcher/pkg/certwatch"
+	"domain_watcher/pkg/models"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// setConfig sets key for the duration of the test.
+func setConfig(t *testing.T, key string, value interface{}) {
+	t.Helper()
+	previous := viper.Get(key)
+	viper.Set(key, value)
+	t.Cleanup(func() { viper.Set(key, previous) })
+}
+
+func TestOutputFlagRepeats(t *testing.T) {
+	flag := &outputFlag{value: "json"}
+	for _, value := range []string{"yaml", "table,csv"} {
+		if err := flag.Set(value); err != nil {
+			t.Fatalf("Set(%q) error: %v", value, err)
+		}
+	}
+	// The default is replaced, later values are appended
+	if flag.String() != "yaml,table,csv" {
+		t.Errorf("Expected yaml,table,csv, got %q", flag.String())
+	}
+
+	setConfig(t, "output", "json, table,json,,csv")
+	if formats := outputFormats(); !slices.Equal(formats, []string{"json", "table", "csv"}) {
+		t.Errorf("Expected each format once, got %v", formats)
+	}
+}
+
+func TestOutputDestinations(t *testing.T) {
+	dir := t.TempDir() + string(filepath.Separator)
+	file := filepath.Join(t.TempDir(), "certs.json")
+
+	tests := []struct {
+		name         string
+		outputPath   string
+		formats      []string
+		stdoutFormat string
+		fileFormat   string
+		stdout       string
+		files        []string
+		err          string
+	}{
+		{name: "single format printed", formats: []string{"json"}, stdout: "json"},
+		{name: "single format to file", outputPath: file, formats: []string{"json"}, files: []string{"json"}},
+		{name: "table falls back to json in files", outputPath: file, formats: []string{"table"}, files: []string{"json"}},
+		{name: "stdout format override", formats: []string{"json"}, stdoutFormat: "csv", stdout: "csv"},
+		{name: "file and stdout formats", outputPath: file, formats: []string{"json"}, fileFormat: "yaml", stdoutFormat: "table", stdout: "table", files: []string{"yaml"}},
+		{name: "table printed, others to a file", outputPath: file, formats: []string{"json", "table"}, stdout: "table", files: []string{"json"}},
+		{name: "several files in a directory", outputPath: dir, formats: []string{"json", "csv", "table"}, stdout: "table", files: []string{"json", "csv"}},
+		{name: "jsonl-gz alongside a table", outputPath: dir, formats: []string{"jsonl-gz", "table"}, stdout: "table", files: []string{"jsonl-gz"}},
+
+		{name: "several printed formats", formats: []string{"json", "csv"}, err: "only one of --output json,csv can be printed"},
+		{name: "several printed with a table", formats: []string{"table", "yaml"}, err: "only one of --output table,yaml"},
+		{name: "several files in a file", outputPath: file, formats: []string{"json", "csv"}, err: "--output-path must be a directory"},
+		{name: "stdout format with several formats", outputPath: dir, formats: []string{"json", "table"}, stdoutFormat: "csv", err: "cannot be combined"},
+		{name: "file format with several formats", outputPath: dir, formats: []string{"json", "table"}, fileFormat: "csv", err: "cannot be combined"},
+		{name: "jsonl-gz without a path", formats: []string{"jsonl-gz"}, err: "--output jsonl-gz requires --output-path"},
+		{name: "parquet without a path", formats: []string{"parquet"}, err: "--output parquet requires --output-path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfig(t, "monitor.stdout-format", tt.stdoutFormat)
+			setConfig(t, "monitor.file-format", tt.fileFormat)
+
+			stdout, files, err := outputDestinations(tt.outputPath, tt.formats)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected an error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("outputDestinations() error: %v", err)
+			}
+			if stdout != tt.stdout || !slices.Equal(files, tt.files) {
+				t.Errorf("Expected stdout %q and files %v, got %q and %v", tt.stdout, tt.files, stdout, files)
+			}
+		})
+	}
+}
+
+// recordingHandler keeps the entries it is given.
+type recordingHandler struct {
+	mutex   sync.Mutex
+	entries []*models.CertificateEntry
+}
+
+func (h *recordingHandler) Handle(entry *models.CertificateEntry) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.entries = append(h.entries, entry)
+	return nil
+}
+
+func (h *recordingHandler) count() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.entries)
+}
+
+func TestRunUntilStoppedFlushesAfterDuration(t *testing.T) {
+	setConfig(t, "monitor.quiet", true)
+
+	monitor, err := certwatch.NewMonitorWithConfig(certwatch.DefaultMonitorConfig())
+	if err != nil {
+		t.Fatalf("NewMonitorWithConfig() error: %v", err)
+	}
+	monitor.AddDomain("example.com", true)
+	delivered := &recordingHandler{}
+	digest := certwatch.NewDigestHandler(delivered, time.Hour, 0)
+	monitor.AddHandler(digest)
+
+	monitor.Replay(&models.CertificateEntry{
+		Subdomains: []string{"www.example.com"},
+		LeafCert:   models.LeafCertificate{SerialNumber: "01"},
+		Timestamp:  time.Now(),
+	})
+	if delivered.count() != 0 {
+		t.Fatalf("Expected the digest to hold the entry, got %d delivered", delivered.count())
+	}
+
+	// No signal arrives, so only the duration stops the run
+	done := make(chan struct{})
+	go func() {
+		runUntilStopped(monitor, make(chan os.Signal), 20*time.Millisecond, func() { digest.Close() })
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runUntilStopped did not return after the duration")
+	}
+	if delivered.count() != 1 {
+		t.Errorf("Expected the digest to be flushed on stop, got %d delivered", delivered.count())
+	}
+}
+
+func TestWaitForStopOnSignal(t *testing.T) {
+	setConfig(t, "monitor.quiet", true)
+
+	sigChan := make(chan os.Signal, 1)
+	sigChan <- os.Interrupt
+	done := make(chan struct{})
+	go func() {
+		waitForStop(sigChan, time.Hour)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitForStop did not return on a signal")
+	}
+}
+
+func TestConfigureKeywordsMostSpecificWins(t *testing.T) {
+	keywords := map[string][]string{
+		"bank":       {"pagerduty"},
+		"login":      {"discord"},
+		"bank-login": {"webhook"},
+	}
+	// Map order changes from run to run; the routing must not
+	for i := 0; i < 20; i++ {
+		monitor, err := certwatch.NewMonitorWithConfig(certwatch.DefaultMonitorConfig())
+		if err != nil {
+			t.Fatalf("NewMonitorWithConfig() error: %v", err)
+		}
+		notifiers := map[string]*recordingHandler{"pagerduty": {}, "discord": {}, "webhook": {}}
+		handlers := map[string]certwatch.CertificateHandler{}
+		for name, notifier := range notifiers {
+			handlers[name] = notifier
+		}
+		if err := configureKeywords(monitor, keywords, handlers); err != nil {
+			t.Fatalf("configureKeywords() error: %v", err)
+		}
+
+		monitor.Replay(&models.CertificateEntry{
+			Subdomains: []string{"bank-login.example"},
+			LeafCert:   models.LeafCertificate{SerialNumber: "01"},
+			Timestamp:  time.Now(),
+		})
+		if notifiers["webhook"].count() != 1 || notifiers["pagerduty"].count() != 0 || notifiers["discord"].count() != 0 {
+			t.Fatalf("Expected bank-login to route to the webhook, got pagerduty=%d discord=%d webhook=%d",
+				notifiers["pagerduty"].count(), notifiers["discord"].count(), notifiers["webhook"].count())
+		}
+	}
+}
+
+func TestAnomalyNotifierSendsToEachNotifier(t *testing.T) {
+	notifiers := map[string]*recordingHandler{"discord": {}, "webhook": {}}
+	handlers := map[string]certwatch.CertificateHandler{}
+	for name, notifier := range notifiers {
+		handlers[name] = notifier
+	}
+
+	alert := anomalyNotifier(handlers)
+	alert(certwatch.IssuanceAnomaly{Domain: "example.com", Count: 40, Baseline: 2, Window: time.Hour})
+
+	for name, notifier := range notifiers {
+		if notifier.count() != 1 {
+			t.Fatalf("Expected one alert for %s, got %d", name, notifier.count())
+		}
+		entry := notifier.entries[0]
+		if entry.Domain != "example.com" || entry.Anomaly == nil || entry.Anomaly.Count != 40 {
+			t.Errorf("Expected the anomaly for example.com at %s, got %+v", name, entry)
+		}
+	}
+}
+
+func TestReloadDomainsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.yaml")
+	if err := os.WriteFile(path, []byte("- example.org\n- domain: old.example.net\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	setConfig(t, "monitor.domains-file", path)
+	setConfig(t, "monitor.subdomains", true)
+
+	monitor, err := certwatch.NewMonitorWithConfig(certwatch.DefaultMonitorConfig())
+	if err != nil {
+		t.Fatalf("NewMonitorWithConfig() error: %v", err)
+	}
+	if err := reloadDomainsFile(monitor, []string{"example.com"}); err != nil {
+		t.Fatalf("reloadDomainsFile() error: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("- example.org\n- domain: new.example.net\n  include_subdomains: false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadDomainsFile(monitor, []string{"example.com"}); err != nil {
+		t.Fatalf("reloadDomainsFile() error: %v", err)
+	}
+
+	watched := monitor.GetWatchedDomains()
+	var names []string
+	for domain := range watched {
+		names = append(names, domain)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"example.com", "example.org", "new.example.net"}) {
+		t.Fatalf("Expected the command-line domain and the file's current domains, got %v", names)
+	}
+	if watched["new.example.net"].IncludeSubdomains || !watched["example.com"].IncludeSubdomains {
+		t.Error("Expected include_subdomains from the file, and --subdomains otherwise")
+	}
+
+	// A broken file keeps the current watch list
+	if err := os.WriteFile(path, []byte("- domain: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadDomainsFile(monitor, []string{"example.com"}); err == nil {
+		t.Error("Expected an error for an invalid file")
+	}
+	if len(monitor.GetWatchedDomains()) != 3 {
+		t.Errorf("Expected the watch list kept, got %d domains", len(monitor.GetWatchedDomains()))
+	}
+}
+
+func TestAddWatchListReadsDomainsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.yaml")
+	if err := os.WriteFile(path, []byte("- domain: example.org\n  include_subdomains: false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	setConfig(t, "monitor.domains-file", path)
+	if !watchListConfigured(nil) {
+		t.Fatal("Expected --domains-file to count as a watch list")
+	}
+
+	monitor, err := certwatch.NewMonitorWithConfig(certwatch.DefaultMonitorConfig())
+	if err != nil {
+		t.Fatalf("NewMonitorWithConfig() error: %v", err)
+	}
+	addWatchList(monitor, []string{"example.com"}, true)
+
+	watched := monitor.GetWatchedDomains()
+	if len(watched) != 2 || !watched["example.com"].IncludeSubdomains || watched["example.org"].IncludeSubdomains {
+		t.Errorf("Expected example.com with subdomains and example.org without, got %v", watched)
+	}
+}
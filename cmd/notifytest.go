@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"domain_watcher/pkg/certwatch"
+	"domain_watcher/pkg/models"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var notifyTestCmd = &cobra.Command{
+	Use:   "notify-test",
+	Short: "Send a test certificate through the configured notification handlers",
+	Long: `Create the notification handlers configured for monitor (webhook,
+PagerDuty, Discord, Telegram, email and exec) and send each of them a
+synthetic certificate, reporting whether delivery succeeded.
+
+Handlers are created exactly as monitor creates them, so a bad URL, token or
+SMTP setting fails here instead of on the first real match. Queued handlers
+such as the webhook, Discord and Telegram deliver the test right away.
+
+Examples:
+  domain_watcher notify-test
+  domain_watcher notify-test --webhook-url https://hooks.example.com/ct
+  domain_watcher notify-test --domain example.com --config prod.yaml`,
+	Args: cobra.NoArgs,
+	Run:  runNotifyTest,
+}
+
+func init() {
+	rootCmd.AddCommand(notifyTestCmd)
+
+	notifyTestCmd.Flags().String("domain", "example.com", "Domain of the test certificate")
+	// Accept every monitor flag, so handlers can be configured the same way
+	notifyTestCmd.Flags().AddFlagSet(monitorCmd.Flags())
+}
+
+// notifySender is implemented by notification handlers that queue entries,
+// to deliver one right away and report the outcome.
+type notifySender interface {
+	Send(entry *models.CertificateEntry) error
+}
+
+func runNotifyTest(cmd *cobra.Command, args []string) {
+	domain, _ := cmd.Flags().GetString("domain")
+	status, err := notifyTest(os.Stdout, certwatch.NormalizeDomain(domain))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if status != 0 {
+		os.Exit(status)
+	}
+}
+
+// notifyTest sends a test certificate for domain through each configured
+// notification handler, reports the outcomes to w and returns the exit
+// status: 1 if any handler failed.
+func notifyTest(w io.Writer, domain string) (int, error) {
+	notifiers, closers, err := notificationHandlers()
+	defer func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i].Close()
+		}
+	}()
+	if err != nil {
+		return 0, err
+	}
+	if len(notifiers) == 0 {
+		return 0, errors.New("no notification handlers configured; set e.g. --webhook-url, --discord-webhook, --telegram-token, --pagerduty-routing-key, --smtp-host or --exec-on-match")
+	}
+
+	names := sortedKeys(notifiers)
+	entry := notifyTestEntry(domain)
+	failed := 0
+	for _, name := range names {
+		notifier := notifiers[name]
+		start := time.Now()
+		if sender, ok := notifier.(notifySender); ok {
+			err = sender.Send(entry)
+		} else {
+			err = notifier.Handle(entry)
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "✗ %s: %v\n", name, err)
+			continue
+		}
+		fmt.Fprintf(w, "✓ %s: delivered in %v\n", name, time.Since(start).Round(time.Millisecond))
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(w, "%d of %d notification handler(s) failed\n", failed, len(names))
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// notifyTestEntry returns a synthetic match for domain, marked as a test in
+// its subject so it is not mistaken for a real certificate.
+func notifyTestEntry(domain string) *models.CertificateEntry {
+	now := time.Now().UTC()
+	name := "domain-watcher-test." + domain
+	entry := &models.CertificateEntry{
+		Domain:       domain,
+		Subdomains:   []string{name},
+		MatchedNames: []string{name},
+		LeafCert: models.LeafCertificate{
+			Subject: models.Subject{
+				CommonName:   name,
+				Organization: "domain_watcher notification test",
+			},
+			Extensions:              models.Extensions{SubjectAltName: []string{name}},
+			NotBefore:               now,
+			NotAfter:                now.Add(90 * 24 * time.Hour),
+			IssuerDistinguishedName: "domain_watcher test issuer",
+			SerialNumber:            fmt.Sprintf("%x", now.UnixNano()),
+		},
+		Chain:     []models.ChainCert{},
+		Timestamp: now,
+		LogURL:    "notify-test",
+	}
+	entry.IdempotencyKey = entry.ComputeIdempotencyKey()
+	return entry
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNotifyTestReportsEachHandler(t *testing.T) {
+	var mutex sync.Mutex
+	received := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		received[r.URL.Path] = string(body)
+		mutex.Unlock()
+		if r.URL.Path == "/rejected" {
+			http.Error(w, "invalid token", http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		webhook string
+		status  int
+		report  []string
+	}{
+		{
+			name:    "all delivered",
+			webhook: "/hook",
+			status:  0,
+			report:  []string{"✓ discord: delivered in", "✓ webhook: delivered in"},
+		},
+		{
+			name:    "webhook rejected",
+			webhook: "/rejected",
+			status:  1,
+			report: []string{
+				"✓ discord: delivered in",
+				"✗ webhook: webhook delivery failed after 1 attempt(s): webhook returned 403 Forbidden",
+				"1 of 2 notification handler(s) failed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfig(t, "monitor.webhook-url", server.URL+tt.webhook)
+			setConfig(t, "monitor.discord-webhook", server.URL+"/discord")
+
+			var out bytes.Buffer
+			status, err := notifyTest(&out, "example.com")
+			if err != nil {
+				t.Fatalf("notifyTest() error: %v", err)
+			}
+			if status != tt.status {
+				t.Errorf("Expected exit status %d, got %d", tt.status, status)
+			}
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(lines) != len(tt.report) {
+				t.Fatalf("Expected %d report lines, got %q", len(tt.report), out.String())
+			}
+			for i, prefix := range tt.report {
+				if !strings.HasPrefix(lines[i], prefix) {
+					t.Errorf("Expected line %d to start with %q, got %q", i, prefix, lines[i])
+				}
+			}
+
+			// Queued handlers deliver the test right away, not on Close
+			mutex.Lock()
+			defer mutex.Unlock()
+			for _, path := range []string{tt.webhook, "/discord"} {
+				if !strings.Contains(received[path], "domain-watcher-test.example.com") {
+					t.Errorf("Expected the test certificate at %s, got %q", path, received[path])
+				}
+			}
+		})
+	}
+}
+
+func TestNotifyTestWithoutHandlers(t *testing.T) {
+	if _, err := notifyTest(io.Discard, "example.com"); err == nil || !strings.Contains(err.Error(), "no notification handlers configured") {
+		t.Errorf("Expected an error without handlers, got %v", err)
+	}
+}
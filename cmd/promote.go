@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"bytes"
+	"domain_watcher/internal/pkg/storage"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "Promote domains seen in stored output to the watch list",
+	Long: `Scan certificates stored by a previous monitor run (for example an
+--all-domains discovery run) and promote the registrable domains they name to
+the watch list.
+
+The store is read from --store, or monitor.output-path from the config file.
+Directories of .json files, .jsonl files, json --log-file output and rotated
+jsonl-gz directories are all supported.
+
+Without --write the candidates are only printed. With --write they are
+added to monitor.domains in the config file.
+
+Examples:
+  domain_watcher promote --store ./certs --min-count 3 --tld bank
+  domain_watcher promote --min-count 5 --write`,
+	Args: cobra.NoArgs,
+	Run:  runPromote,
+}
+
+func init() {
+	rootCmd.AddCommand(promoteCmd)
+
+	promoteCmd.Flags().String("store", "", "Stored output to scan (default: monitor.output-path)")
+	promoteCmd.Flags().Int("min-count", 1, "Minimum number of distinct certificates naming a domain")
+	promoteCmd.Flags().StringSlice("tld", []string{}, "Only promote domains under these public suffixes (e.g. bank, co.uk)")
+	promoteCmd.Flags().Bool("write", false, "Add the promoted domains to monitor.domains in the config file")
+}
+
+func runPromote(cmd *cobra.Command, args []string) {
+	store, _ := cmd.Flags().GetString("store")
+	if store == "" {
+		store = viper.GetString("monitor.output-path")
+	}
+	if store == "" {
+		log.Fatal("No store to scan. Use --store or set monitor.output-path in the config file")
+	}
+	minCount, _ := cmd.Flags().GetInt("min-count")
+	tlds, _ := cmd.Flags().GetStringSlice("tld")
+	write, _ := cmd.Flags().GetBool("write")
+
+	promoted, err := storage.PromoteDomains(store, minCount, tlds)
+	if err != nil {
+		log.Fatalf("Failed to scan store: %v", err)
+	}
+	if len(promoted) == 0 {
+		fmt.Println("No domains matched.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DOMAIN\tCERTIFICATES")
+	for _, candidate := range promoted {
+		fmt.Fprintf(w, "%s\t%d\n", candidate.Domain, candidate.Count)
+	}
+	w.Flush()
+
+	if !write {
+		return
+	}
+
+	domains := make([]string, 0, len(promoted))
+	for _, candidate := range promoted {
+		domains = append(domains, candidate.Domain)
+	}
+
+	path := configFilePath()
+	added, err := addWatchedDomains(path, domains)
+	if err != nil {
+		log.Fatalf("Failed to update %s: %v", path, err)
+	}
+	fmt.Printf("\nAdded %d domain(s) to monitor.domains in %s\n", added, path)
+}
+
+// configFilePath returns the config file in use, or the default location
+// when none exists yet.
+func configFilePath() string {
+	if cfgFile != "" {
+		return cfgFile
+	}
+	if used := viper.ConfigFileUsed(); used != "" {
+		return used
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".domain_watcher.yaml"
+	}
+	return filepath.Join(home, ".domain_watcher.yaml")
+}
+
+// addWatchedDomains merges domains into monitor.domains in the YAML config
+// at path, keeping the rest of the file (including comments) as it is. It
+// returns how many domains were new.
+func addWatchedDomains(path string, domains []string) (int, error) {
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if len(data) > 0 {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return 0, fmt.Errorf("failed to parse config: %w", err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return 0, fmt.Errorf("config is not a mapping")
+	}
+
+	monitor := mappingValue(root, "monitor", yaml.MappingNode)
+	list := mappingValue(monitor, "domains", yaml.SequenceNode)
+	if list.Kind == yaml.ScalarNode {
+		// A comma-separated string, as accepted by --domains
+		var items []*yaml.Node
+		for _, domain := range strings.Split(list.Value, ",") {
+			if domain = strings.TrimSpace(domain); domain != "" {
+				items = append(items, &yaml.Node{Kind: yaml.ScalarNode, Value: domain})
+			}
+		}
+		*list = yaml.Node{Kind: yaml.SequenceNode, Content: items}
+	}
+
+	existing := make(map[string]bool)
+	for _, item := range list.Content {
+		existing[strings.ToLower(item.Value)] = true
+	}
+	added := 0
+	for _, domain := range domains {
+		if existing[domain] {
+			continue
+		}
+		existing[domain] = true
+		list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: domain})
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return 0, err
+	}
+	encoder.Close()
+	return added, os.WriteFile(path, out.Bytes(), 0644)
+}
+
+// mappingValue returns the value for key in a YAML mapping, adding an empty
+// node of the given kind if the key is missing.
+func mappingValue(mapping *yaml.Node, key string, kind yaml.Kind) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			value := mapping.Content[i+1]
+			if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+				*value = yaml.Node{Kind: kind}
+			}
+			return value
+		}
+	}
+	value := &yaml.Node{Kind: kind}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"domain_watcher/internal/pkg/storage"
+	"domain_watcher/pkg/models"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay FILE [domain...]",
+	Short: "Feed archived certificates through the monitor's filters and handlers",
+	Long: `Read certificates saved by a previous run and send each one through the
+configured filters and handlers as if it had just been seen, to backtest new
+domains, filters or notifiers against past data.
+
+FILE may be anything the monitor writes: an NDJSON file (.jsonl, .ndjson,
+optionally gzipped), a json --log-file or an --output-path directory. Matches
+are recomputed against the current watch list, and the issuer, validity,
+keyword and other monitor flags apply as in the monitor command. Don't point
+--output-path or --ndjson-path at FILE itself.
+
+Examples:
+  domain_watcher replay certs.jsonl example.com
+  domain_watcher replay ./certs --domains example.com --issuer-deny "Let's Encrypt"
+  domain_watcher replay certs.jsonl --all-domains --keyword paypal --webhook-url https://hooks.example.com/ct`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runReplay,
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	// Share the monitor flags, and with them their viper bindings
+	replayCmd.Flags().AddFlagSet(monitorCmd.Flags())
+}
+
+func runReplay(cmd *cobra.Command, args []string) {
+	path := args[0]
+	domains := args[1:]
+	if len(domains) == 0 {
+		domains = configuredDomains()
+	}
+
+	if !viper.GetBool("monitor.all-domains") && len(domains) == 0 && viper.GetString("monitor.domains-file") == "" &&
+		len(viper.GetStringMapStringSlice("monitor.keywords")) == 0 && len(viper.GetStringSlice("monitor.domain-regex")) == 0 &&
+		len(viper.GetStringSlice("monitor.ip")) == 0 {
+		log.Fatal("No domains specified. Provide domains as arguments, via --domains or --domains-file, or set DOMAIN_WATCHER_MONITOR_DOMAINS environment variable")
+	}
+	for _, output := range []string{viper.GetString("monitor.output-path"), viper.GetString("monitor.ndjson-path")} {
+		if output != "" && filepath.Clean(output) == filepath.Clean(path) {
+			log.Fatalf("Refusing to replay %s into itself", path)
+		}
+	}
+
+	monitor, closeHandlers := setupMonitor(domains)
+	defer closeHandlers()
+
+	read, matched := 0, 0
+	err := storage.ReadEntries(path, func(entry *models.CertificateEntry) error {
+		read++
+		if monitor.Replay(entry) {
+			matched++
+		}
+		return nil
+	})
+	if err != nil {
+		closeHandlers()
+		log.Fatalf("Failed to replay %s: %v", path, err)
+	}
+
+	fmt.Printf("Replayed %d entries from %s: %d matched\n", read, path, matched)
+}
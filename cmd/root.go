@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"strings"
 
@@ -31,12 +33,32 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.domain_watcher.yaml)")
 	rootCmd.PersistentFlags().Bool("verbose", false, "enable verbose output")
-	rootCmd.PersistentFlags().String("output", "json", "output format (json, yaml, table)")
+	rootCmd.PersistentFlags().String("log-level", "info", "log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().Var(&outputFlag{value: "json"}, "output", "output format (json, yaml, table, csv, tsv; monitor also accepts jsonl-gz, parquet and several formats, comma-separated or repeated)")
 
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("log-level", rootCmd.PersistentFlags().Lookup("log-level"))
 	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
 }
 
+// outputFlag is the --output value. Repeating the flag adds formats to a
+// comma-separated list, as if they were given as one --output json,table.
+type outputFlag struct {
+	value string
+	set   bool
+}
+
+func (f *outputFlag) String() string { return f.value }
+func (f *outputFlag) Type() string   { return "string" }
+
+func (f *outputFlag) Set(value string) error {
+	if f.set {
+		value = f.value + "," + value
+	}
+	f.value, f.set = value, true
+	return nil
+}
+
 func initConfig() {
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
@@ -55,9 +77,30 @@ func initConfig() {
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_", ".", "_"))
 
+	// A missing config file is fine, but not one that cannot be used
 	if err := viper.ReadInConfig(); err == nil {
 		if viper.GetBool("verbose") {
 			fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 		}
+		if err := validateConfigFile(viper.ConfigFileUsed()); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid config file %v\n", err)
+			os.Exit(1)
+		}
+	} else if !errors.As(err, &viper.ConfigFileNotFoundError{}) {
+		fmt.Fprintf(os.Stderr, "Failed to read config file: %v\n", err)
+		os.Exit(1)
+	}
+
+	setupLogging()
+}
+
+// setupLogging installs the default slog logger at the --log-level level.
+// Messages from the standard log package go through it at info level.
+func setupLogging() {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(viper.GetString("log-level"))); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --log-level %q: use debug, info, warn or error\n", viper.GetString("log-level"))
+		os.Exit(1)
 	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
 }
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"domain_watcher/internal/pkg/api"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [domain...]",
+	Short: "Monitor domains and manage the watch list over an HTTP API",
+	Long: `Run the monitor as a service with an HTTP API to inspect and change the
+watch list while it runs. Every monitor flag is accepted.
+
+The API serves JSON:
+  GET    /domains                 List watched domains
+  POST   /domains                 Watch a domain: {"domain": "example.com", "include_subdomains": true}
+  DELETE /domains/{domain}        Stop watching a domain
+  GET    /domains/{domain}/certs  Recent certificates matching a domain, newest first
+  POST   /pause                   Stop delivering matches to outputs and notifications
+  POST   /resume                  Deliver matches kept by --pause-buffer and resume
+
+The API has no authentication; it listens on localhost unless --listen says otherwise.
+
+Examples:
+  domain_watcher serve
+  domain_watcher serve example.com --live --listen :8080
+  curl -X POST localhost:8080/domains -d '{"domain": "example.org"}'`,
+	Run: runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().String("listen", "127.0.0.1:8080", "Address for the HTTP API")
+	serveCmd.Flags().Int("recent-certs", 100, "Recent matched certificates kept per domain for GET /domains/{domain}/certs")
+	serveCmd.Flags().Int("pause-buffer", 0, "Matches kept while paused with POST /pause and delivered on POST /resume, dropping the oldest when full (0 drops them all)")
+
+	viper.BindPFlag("serve.listen", serveCmd.Flags().Lookup("listen"))
+	viper.BindPFlag("serve.recent-certs", serveCmd.Flags().Lookup("recent-certs"))
+	viper.BindPFlag("serve.pause-buffer", serveCmd.Flags().Lookup("pause-buffer"))
+
+	// Share the monitor command's flags, and so their monitor.* bindings.
+	// monitor.go's init has run by now, files being initialized in name order.
+	serveCmd.Flags().AddFlagSet(monitorCmd.Flags())
+}
+
+func runServe(cmd *cobra.Command, args []string) {
+	domains := args
+	if len(domains) == 0 {
+		domains = configuredDomains()
+	}
+
+	// An empty watch list is fine here, domains can be added over the API
+	monitor, closeHandlers := setupMonitor(domains)
+	defer closeHandlers()
+	monitor.SetPauseBuffer(viper.GetInt("serve.pause-buffer"))
+
+	apiServer := api.NewServer(monitor, viper.GetInt("serve.recent-certs"))
+	monitor.AddHandler(apiServer)
+
+	httpServer := &http.Server{
+		Addr:              viper.GetString("serve.listen"),
+		Handler:           apiServer,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	stopHealth := startHealthServer(monitor)
+	defer stopHealth()
+
+	go func() {
+		if err := monitor.Start(); err != nil {
+			log.Fatalf("Monitor failed: %v", err)
+		}
+	}()
+
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("API server failed: %v", err)
+		}
+	}()
+
+	statusf("🔍 Serving the domain_watcher API on %s\n", httpServer.Addr)
+
+	waitForStop(sigChan, viper.GetDuration("monitor.duration"))
+	statusf("\nShutting down...\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("API server shutdown: %v", err)
+	}
+	monitor.Stop()
+}
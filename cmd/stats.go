@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"domain_watcher/internal/pkg/storage"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize stored certificates",
+	Long: `Print aggregates over certificates stored by previous monitor runs: the
+total, matches per watched domain, the top issuers and certificates per day.
+
+The store is read from --store, or else the first configured of
+monitor.sqlite-path, monitor.ndjson-path and monitor.output-path. SQLite
+databases (.db, .sqlite) and every file output the monitor writes are
+supported. The report follows --output (table, json, yaml).
+
+Examples:
+  domain_watcher stats --store ./certs.db
+  domain_watcher stats --store ./certs.ndjson --output json
+  domain_watcher stats --top-issuers 5`,
+	Args: cobra.NoArgs,
+	Run:  runStats,
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().String("store", "", "Store to summarize (default: the configured SQLite, NDJSON or output path)")
+	statsCmd.Flags().Int("top-issuers", 10, "Number of issuers to list (0 lists all)")
+}
+
+func runStats(cmd *cobra.Command, args []string) {
+	store, _ := cmd.Flags().GetString("store")
+	for _, key := range []string{"monitor.sqlite-path", "monitor.ndjson-path", "monitor.output-path"} {
+		if store != "" {
+			break
+		}
+		store = viper.GetString(key)
+	}
+	if store == "" {
+		log.Fatal("No store to summarize. Use --store or set monitor.sqlite-path, monitor.ndjson-path or monitor.output-path in the config file")
+	}
+	topIssuers, _ := cmd.Flags().GetInt("top-issuers")
+
+	summary, err := storage.SummarizeStore(store, topIssuers)
+	if err != nil {
+		log.Fatalf("Failed to summarize %s: %v", store, err)
+	}
+
+	switch viper.GetString("output") {
+	case "json":
+		data, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling JSON: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+	case "yaml":
+		data, err := storage.MarshalYAML(summary)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error marshaling YAML: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(string(data))
+	default:
+		printStatsTable(summary)
+	}
+}
+
+func printStatsTable(summary *storage.StoreSummary) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "TOTAL CERTIFICATES\t%d\n", summary.Total)
+
+	domains := make([]string, 0, len(summary.MatchesByDomain))
+	for domain := range summary.MatchesByDomain {
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if summary.MatchesByDomain[domains[i]] != summary.MatchesByDomain[domains[j]] {
+			return summary.MatchesByDomain[domains[i]] > summary.MatchesByDomain[domains[j]]
+		}
+		return domains[i] < domains[j]
+	})
+	fmt.Fprintln(w, "\nDOMAIN\tMATCHES")
+	for _, domain := range domains {
+		fmt.Fprintf(w, "%s\t%d\n", domain, summary.MatchesByDomain[domain])
+	}
+
+	fmt.Fprintln(w, "\nISSUER\tCERTIFICATES")
+	for _, issuer := range summary.TopIssuers {
+		fmt.Fprintf(w, "%s\t%d\n", issuer.Issuer, issuer.Count)
+	}
+
+	days := make([]string, 0, len(summary.PerDay))
+	for day := range summary.PerDay {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	fmt.Fprintln(w, "\nDAY\tCERTIFICATES")
+	for _, day := range days {
+		fmt.Fprintf(w, "%s\t%d\n", day, summary.PerDay[day])
+	}
+	w.Flush()
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"domain_watcher/internal/pkg/storage"
+	"domain_watcher/pkg/models"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [filter]",
+	Short: "Follow the monitor's output and print new matches as they arrive",
+	Long: `Follow the NDJSON file or json log file a running monitor writes to, like
+tail -f, and print each new match as a table, with colors when stdout is a
+terminal. With a filter, only matches whose domain or names contain it
+(ignoring case) are printed.
+
+The file is --file, or else monitor.ndjson-path or monitor.log-file from the
+config. A --log-file must use --log-format json. Rotated or truncated files
+are followed.
+
+Examples:
+  domain_watcher watch --file ./certs.ndjson
+  domain_watcher watch login --from-start`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runWatch,
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().String("file", "", "File to follow (default: the configured monitor.ndjson-path or monitor.log-file)")
+	watchCmd.Flags().Bool("from-start", false, "Print the matches already in the file before following it")
+	watchCmd.Flags().Bool("no-color", false, "Disable colors (also disabled by NO_COLOR or when stdout is not a terminal)")
+}
+
+func runWatch(cmd *cobra.Command, args []string) {
+	path, _ := cmd.Flags().GetString("file")
+	for _, key := range []string{"monitor.ndjson-path", "monitor.log-file"} {
+		if path != "" {
+			break
+		}
+		path = viper.GetString(key)
+	}
+	if path == "" {
+		log.Fatal("No file to watch. Use --file or set monitor.ndjson-path or monitor.log-file in the config file")
+	}
+	fromStart, _ := cmd.Flags().GetBool("from-start")
+	noColor, _ := cmd.Flags().GetBool("no-color")
+
+	var filter string
+	if len(args) > 0 {
+		filter = strings.ToLower(args[0])
+	}
+
+	printer := storage.NewStdoutHandler("table")
+	printer.SetColor(!noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err := storage.TailEntries(ctx, path, fromStart, func(entry *models.CertificateEntry) error {
+		if filter != "" && !entryContains(entry, filter) {
+			return nil
+		}
+		return printer.Handle(entry)
+	})
+	if err != nil {
+		log.Fatalf("Failed to watch %s: %v", path, err)
+	}
+}
+
+// entryContains reports whether the matched domain or one of the names of
+// entry contains filter, which must be lowercase.
+func entryContains(entry *models.CertificateEntry, filter string) bool {
+	if strings.Contains(strings.ToLower(entry.Domain), filter) {
+		return true
+	}
+	for _, name := range entry.Subdomains {
+		if strings.Contains(strings.ToLower(name), filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTerminal reports whether file is a character device such as a terminal.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
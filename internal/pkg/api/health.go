@@ -0,0 +1,35 @@
+package api
+
+import (
+	"domain_watcher/pkg/certwatch"
+	"net/http"
+	"time"
+)
+
+// NewHealthHandler serves liveness and readiness probes for monitor:
+//
+//	GET /healthz  200 while the process is up
+//	GET /readyz   200 while the monitor is getting certificates, 503 otherwise
+//	GET /stats    the monitor's counters
+//
+// /readyz reports certwatch.Monitor.Health(maxAge) as JSON, including the
+// state of each polled CT log. /stats reports certwatch.MonitorStats, such
+// as matches and dropped certstream messages.
+func NewHealthHandler(monitor *certwatch.Monitor, maxAge time.Duration) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		status := monitor.Health(maxAge)
+		code := http.StatusOK
+		if !status.Ready {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, status)
+	})
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, monitor.StatsSnapshot())
+	})
+	return mux
+}
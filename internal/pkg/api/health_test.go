@@ -0,0 +1,49 @@
+package api
+
+import (
+	"domain_watcher/pkg/certwatch"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	monitor := certwatch.NewMonitor()
+	monitor.SetLiveMode(true)
+
+	if resp := request(t, NewHealthHandler(monitor, time.Minute), http.MethodGet, "/healthz", ""); resp.Code != http.StatusOK {
+		t.Errorf("GET /healthz: expected 200, got %d", resp.Code)
+	}
+	if resp := request(t, NewHealthHandler(monitor, time.Minute), http.MethodGet, "/readyz", ""); resp.Code != http.StatusOK {
+		t.Errorf("GET /readyz within the grace period: expected 200, got %d: %s", resp.Code, resp.Body)
+	}
+
+	resp := request(t, NewHealthHandler(monitor, time.Nanosecond), http.MethodGet, "/readyz", "")
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Fatalf("GET /readyz without heartbeats: expected 503, got %d", resp.Code)
+	}
+	var status certwatch.HealthStatus
+	if err := json.Unmarshal(resp.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Invalid /readyz response: %v", err)
+	}
+	if status.Ready || status.Mode != "live" || status.Reason == "" {
+		t.Errorf("Unexpected readiness: %+v", status)
+	}
+}
+
+func TestStatsEndpoint(t *testing.T) {
+	monitor := certwatch.NewMonitor()
+
+	resp := request(t, NewHealthHandler(monitor, time.Minute), http.MethodGet, "/stats", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("GET /stats: expected 200, got %d", resp.Code)
+	}
+	var stats certwatch.MonitorStats
+	if err := json.Unmarshal(resp.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Invalid /stats response: %v", err)
+	}
+	if stats.StartedAt.IsZero() || stats.DroppedByReason == nil {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"domain_watcher/pkg/certwatch"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultRecentLimit is how many matches per watched domain are kept for
+// GET /domains/{domain}/certs.
+const defaultRecentLimit = 100
+
+// Server exposes a running Monitor's watch list over HTTP. It is also a
+// certwatch.CertificateHandler: registered on the same monitor, it keeps the
+// most recent matches of each watched domain in memory.
+//
+//	GET    /domains                 list watched domains
+//	POST   /domains                 add a domain ({"domain": ..., "include_subdomains": ...})
+//	DELETE /domains/{domain}        stop watching a domain
+//	GET    /domains/{domain}/certs  recent matches for a domain, newest first
+//	POST   /pause                   stop delivering matches to handlers
+//	POST   /resume                  deliver held matches and resume
+type Server struct {
+	monitor *certwatch.Monitor
+	mux     *http.ServeMux
+	limit   int
+
+	mutex  sync.Mutex
+	recent map[string][]*models.CertificateEntry
+}
+
+// NewServer creates an API server for monitor keeping up to recentLimit
+// matches per domain (100 if recentLimit is not positive). The server must
+// also be added to the monitor with AddHandler to record matches.
+func NewServer(monitor *certwatch.Monitor, recentLimit int) *Server {
+	if recentLimit <= 0 {
+		recentLimit = defaultRecentLimit
+	}
+
+	s := &Server{
+		monitor: monitor,
+		mux:     http.NewServeMux(),
+		limit:   recentLimit,
+		recent:  make(map[string][]*models.CertificateEntry),
+	}
+	s.mux.HandleFunc("GET /domains", s.listDomains)
+	s.mux.HandleFunc("POST /domains", s.addDomain)
+	s.mux.HandleFunc("DELETE /domains/{domain}", s.removeDomain)
+	s.mux.HandleFunc("GET /domains/{domain}/certs", s.domainCerts)
+	s.mux.HandleFunc("POST /pause", s.pause)
+	s.mux.HandleFunc("POST /resume", s.resume)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Handle records entry as a recent match of the watched domain it matched.
+// Regex, keyword and all-domains matches are not tied to a watched domain
+// and are not kept.
+func (s *Server) Handle(entry *models.CertificateEntry) error {
+	if _, ok := s.watch(entry.Domain); !ok {
+		return nil
+	}
+	domain := normalizeDomain(entry.Domain)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries := append(s.recent[domain], entry)
+	if len(entries) > s.limit {
+		entries = entries[len(entries)-s.limit:]
+	}
+	s.recent[domain] = entries
+	return nil
+}
+
+func (s *Server) listDomains(w http.ResponseWriter, r *http.Request) {
+	watches := []models.DomainWatch{}
+	for _, watch := range s.monitor.GetWatchedDomains() {
+		watches = append(watches, *watch)
+	}
+	sort.Slice(watches, func(i, j int) bool {
+		return watches[i].Domain < watches[j].Domain
+	})
+	writeJSON(w, http.StatusOK, watches)
+}
+
+func (s *Server) addDomain(w http.ResponseWriter, r *http.Request) {
+	// Subdomains are included unless the request says otherwise, as with
+	// the monitor command's --subdomains flag
+	request := models.DomainWatch{IncludeSubdomains: true}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	domain := normalizeDomain(request.Domain)
+	if domain == "" || strings.ContainsAny(domain, "/ ") {
+		writeError(w, http.StatusBadRequest, "a valid domain is required")
+		return
+	}
+	if err := certwatch.ValidateWatchDomain(domain); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.monitor.AddDomain(domain, request.IncludeSubdomains)
+	watch, ok := s.watch(domain)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "domain was not added")
+		return
+	}
+	writeJSON(w, http.StatusCreated, watch)
+}
+
+func (s *Server) removeDomain(w http.ResponseWriter, r *http.Request) {
+	domain := normalizeDomain(r.PathValue("domain"))
+	watch, ok := s.watch(domain)
+	if !ok {
+		writeError(w, http.StatusNotFound, "domain is not watched: "+domain)
+		return
+	}
+
+	s.monitor.RemoveDomain(watch.Domain)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) domainCerts(w http.ResponseWriter, r *http.Request) {
+	domain := normalizeDomain(r.PathValue("domain"))
+
+	s.mutex.Lock()
+	stored := s.recent[domain]
+	entries := make([]*models.CertificateEntry, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		entries = append(entries, stored[i])
+	}
+	s.mutex.Unlock()
+
+	// Matches of a domain that is no longer watched are still returned
+	if _, ok := s.watch(domain); !ok && len(entries) == 0 {
+		writeError(w, http.StatusNotFound, "domain is not watched: "+domain)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// pauseStatus is the response of POST /pause and POST /resume.
+type pauseStatus struct {
+	Paused bool `json:"paused"`
+}
+
+func (s *Server) pause(w http.ResponseWriter, r *http.Request) {
+	s.monitor.Pause()
+	writeJSON(w, http.StatusOK, pauseStatus{Paused: s.monitor.Paused()})
+}
+
+func (s *Server) resume(w http.ResponseWriter, r *http.Request) {
+	s.monitor.Resume()
+	writeJSON(w, http.StatusOK, pauseStatus{Paused: s.monitor.Paused()})
+}
+
+// watch looks up a watched domain ignoring case, since domains given on the
+// command line are watched as typed.
+func (s *Server) watch(domain string) (models.DomainWatch, bool) {
+	for name, watch := range s.monitor.GetWatchedDomains() {
+		if strings.EqualFold(name, domain) {
+			return *watch, true
+		}
+	}
+	return models.DomainWatch{}, false
+}
+
+// normalizeDomain puts a domain in the form the monitor stores watches in.
+func normalizeDomain(domain string) string {
+	return certwatch.NormalizeDomain(domain)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
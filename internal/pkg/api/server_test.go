@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bytes"
+	"domain_watcher/pkg/certwatch"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func request(t *testing.T, server http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+	return recorder
+}
+
+func TestDomainEndpoints(t *testing.T) {
+	monitor := certwatch.NewMonitor()
+	monitor.AddDomain("example.com", true)
+	server := NewServer(monitor, 0)
+
+	resp := request(t, server, http.MethodPost, "/domains", `{"domain": "Example.org", "include_subdomains": false}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("POST /domains: expected 201, got %d: %s", resp.Code, resp.Body)
+	}
+	var added models.DomainWatch
+	if err := json.Unmarshal(resp.Body.Bytes(), &added); err != nil {
+		t.Fatalf("Invalid POST response: %v", err)
+	}
+	if added.Domain != "example.org" || added.IncludeSubdomains || !added.Active {
+		t.Errorf("Unexpected added domain: %+v", added)
+	}
+
+	resp = request(t, server, http.MethodGet, "/domains", "")
+	var watches []models.DomainWatch
+	if err := json.Unmarshal(resp.Body.Bytes(), &watches); err != nil {
+		t.Fatalf("Invalid GET response: %v", err)
+	}
+	if len(watches) != 2 || watches[0].Domain != "example.com" || watches[1].Domain != "example.org" {
+		t.Errorf("Unexpected watch list: %+v", watches)
+	}
+
+	if resp := request(t, server, http.MethodDelete, "/domains/example.com", ""); resp.Code != http.StatusNoContent {
+		t.Errorf("DELETE /domains/example.com: expected 204, got %d", resp.Code)
+	}
+	if resp := request(t, server, http.MethodDelete, "/domains/example.com", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("Second DELETE: expected 404, got %d", resp.Code)
+	}
+	if _, ok := monitor.GetWatchedDomains()["example.com"]; ok {
+		t.Error("Expected example.com to be removed from the monitor")
+	}
+
+	if resp := request(t, server, http.MethodPost, "/domains", `{"include_subdomains": true}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("POST without domain: expected 400, got %d", resp.Code)
+	}
+	if resp := request(t, server, http.MethodPost, "/domains", `{"domain": "co.uk"}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("POST with a public suffix: expected 400, got %d", resp.Code)
+	}
+}
+
+func TestDomainCerts(t *testing.T) {
+	monitor := certwatch.NewMonitor()
+	monitor.AddDomain("Example.com", true)
+	server := NewServer(monitor, 2)
+
+	server.Handle(&models.CertificateEntry{Domain: "^login-.*\\.com$"})
+	for _, cn := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		entry := &models.CertificateEntry{Domain: "example.com"}
+		entry.LeafCert.Subject.CommonName = cn
+		server.Handle(entry)
+	}
+
+	resp := request(t, server, http.MethodGet, "/domains/example.com/certs", "")
+	var entries []models.CertificateEntry
+	if err := json.Unmarshal(resp.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("Invalid certs response: %v", err)
+	}
+	if len(entries) != 2 || entries[0].LeafCert.Subject.CommonName != "c.example.com" || entries[1].LeafCert.Subject.CommonName != "b.example.com" {
+		t.Errorf("Expected the 2 newest matches, newest first, got %+v", entries)
+	}
+
+	if resp := request(t, server, http.MethodGet, "/domains/unknown.com/certs", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unwatched domain, got %d", resp.Code)
+	}
+}
+
+func TestPauseEndpoints(t *testing.T) {
+	monitor := certwatch.NewMonitor()
+	server := NewServer(monitor, 0)
+
+	for _, tt := range []struct {
+		path   string
+		paused bool
+	}{{"/pause", true}, {"/pause", true}, {"/resume", false}} {
+		resp := request(t, server, http.MethodPost, tt.path, "")
+		var status struct {
+			Paused bool `json:"paused"`
+		}
+		if err := json.Unmarshal(resp.Body.Bytes(), &status); err != nil || resp.Code != http.StatusOK {
+			t.Fatalf("POST %s: expected 200 with JSON, got %d: %s", tt.path, resp.Code, resp.Body)
+		}
+		if status.Paused != tt.paused || monitor.Paused() != tt.paused {
+			t.Errorf("POST %s: expected paused %v, got %v", tt.path, tt.paused, status.Paused)
+		}
+	}
+}
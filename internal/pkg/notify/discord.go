@@ -0,0 +1,354 @@
+package notify
+
+import (
+	"bytes"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Discord embed limits, see https://discord.com/developers/docs/resources/message#embed-object-embed-limits
+const (
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+	discordFieldValueLimit  = 1024
+	discordContentLimit     = 2000
+
+	// discordQueueSize bounds messages waiting for delivery; beyond it new
+	// ones are dropped rather than blocking the monitor.
+	discordQueueSize = 1000
+
+	discordMaxAttempts = 3
+	discordColor       = 0xE67E22
+	discordExpiryColor = 0xE74C3C
+)
+
+type discordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []discordEmbed `json:"embeds,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+	Fields      []discordField `json:"fields"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// DiscordHandler posts an embed per matched certificate to a Discord
+// webhook. Entries are queued and sent in order by a single worker, which
+// waits out Discord's rate limits instead of dropping messages; delivery
+// failures are logged. A digest is posted as one summary embed.
+type DiscordHandler struct {
+	url        string
+	httpClient *http.Client
+	format     *Format
+	backoff    time.Duration
+	queue      chan []*models.CertificateEntry
+	done       chan struct{}
+	mutex      sync.Mutex // guards queue against sends after Close
+	closed     bool
+}
+
+// NewDiscordHandler creates a handler posting to webhookURL and starts its
+// delivery worker. Close sends what is still queued.
+func NewDiscordHandler(webhookURL string) (*DiscordHandler, error) {
+	if !strings.HasPrefix(webhookURL, "https://") && !strings.HasPrefix(webhookURL, "http://") {
+		return nil, fmt.Errorf("discord webhook URL must be http(s): %q", webhookURL)
+	}
+
+	h := &DiscordHandler{
+		url:        webhookURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		backoff:    time.Second,
+		queue:      make(chan []*models.CertificateEntry, discordQueueSize),
+		done:       make(chan struct{}),
+	}
+	go h.run()
+	return h, nil
+}
+
+// SetFormat posts entries as message text in format instead of an embed.
+// It must be called before the first entry is handled.
+func (h *DiscordHandler) SetFormat(format *Format) {
+	h.format = format
+}
+
+// Handle queues entry for delivery. It fails once the handler is closed.
+func (h *DiscordHandler) Handle(entry *models.CertificateEntry) error {
+	return h.enqueue([]*models.CertificateEntry{entry}, entry.Domain)
+}
+
+// HandleBatch queues entries for delivery as one summary message, for
+// DigestHandler.
+func (h *DiscordHandler) HandleBatch(entries []*models.CertificateEntry) error {
+	return h.enqueue(entries, fmt.Sprintf("a digest of %d certificates", len(entries)))
+}
+
+func (h *DiscordHandler) enqueue(entries []*models.CertificateEntry, what string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		return fmt.Errorf("discord handler closed, dropping %s", what)
+	}
+	select {
+	case h.queue <- entries:
+		return nil
+	default:
+		return fmt.Errorf("discord queue full, dropping %s", what)
+	}
+}
+
+// Send posts entry right away, bypassing the queue, and returns the outcome
+// that Handle only logs.
+func (h *DiscordHandler) Send(entry *models.CertificateEntry) error {
+	return h.send([]*models.CertificateEntry{entry})
+}
+
+// Close stops accepting entries and waits for the queued ones to be sent.
+func (h *DiscordHandler) Close() error {
+	h.mutex.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mutex.Unlock()
+	<-h.done
+	return nil
+}
+
+func (h *DiscordHandler) run() {
+	defer close(h.done)
+	for entries := range h.queue {
+		if err := h.send(entries); err != nil {
+			if len(entries) == 1 {
+				log.Printf("Discord delivery failed for %s: %v", entries[0].Domain, err)
+			} else {
+				log.Printf("Discord delivery failed for a digest of %d certificates: %v", len(entries), err)
+			}
+		}
+	}
+}
+
+// send posts one message for entries: an embed for a single entry, a
+// summary embed for several.
+func (h *DiscordHandler) send(entries []*models.CertificateEntry) error {
+	var message discordMessage
+	switch {
+	case h.format != nil:
+		texts := make([]string, len(entries))
+		for i, entry := range entries {
+			text, err := h.format.Render(entry)
+			if err != nil {
+				return err
+			}
+			texts[i] = text
+		}
+		message.Content = truncate(strings.Join(texts, "\n"), discordContentLimit)
+	case len(entries) == 1:
+		message.Embeds = []discordEmbed{discordEntryEmbed(entries[0])}
+	default:
+		message.Embeds = []discordEmbed{discordDigestEmbed(entries)}
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal discord message: %w", err)
+	}
+
+	delay := h.backoff
+	for attempt := 1; ; attempt++ {
+		wait, retry, err := h.post(data)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt == discordMaxAttempts {
+			return fmt.Errorf("discord delivery failed after %d attempt(s): %w", attempt, err)
+		}
+
+		// Rate limits say how long to wait; other failures back off
+		if wait <= 0 {
+			wait = delay
+			delay *= 2
+		}
+		log.Printf("Discord attempt %d failed, retrying in %v: %v", attempt, wait, err)
+		time.Sleep(wait)
+	}
+}
+
+// post makes one delivery attempt. It reports how long Discord asked to
+// wait, and whether a failure is worth retrying.
+func (h *DiscordHandler) post(data []byte) (time.Duration, bool, error) {
+	resp, err := h.httpClient.Post(h.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		// Wait for the bucket to refill rather than hitting a 429
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			time.Sleep(discordHeaderSeconds(resp.Header.Get("X-RateLimit-Reset-After")))
+		}
+		return 0, false, nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("discord returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	if resp.StatusCode == http.StatusTooManyRequests {
+		var limited struct {
+			RetryAfter float64 `json:"retry_after"`
+		}
+		wait := discordHeaderSeconds(resp.Header.Get("Retry-After"))
+		if json.Unmarshal(body, &limited) == nil && limited.RetryAfter > 0 {
+			wait = time.Duration(limited.RetryAfter * float64(time.Second))
+		}
+		return wait, true, err
+	}
+	return 0, resp.StatusCode >= 500, err
+}
+
+// discordHeaderSeconds parses a rate limit header given in (fractional)
+// seconds.
+func discordHeaderSeconds(value string) time.Duration {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func discordEntryEmbed(entry *models.CertificateEntry) discordEmbed {
+	if entry.Anomaly != nil {
+		return discordEmbed{
+			Title:     truncate(anomalySummary(entry), discordTitleLimit),
+			Color:     discordExpiryColor,
+			Timestamp: entry.Timestamp.UTC().Format(time.RFC3339),
+		}
+	}
+
+	leaf := entry.LeafCert
+	embed := discordEmbed{
+		Title: truncate("New certificate for "+entry.Domain, discordTitleLimit),
+		Color: discordColor,
+		Fields: []discordField{
+			{Name: "Domain", Value: discordValue(entry.Domain), Inline: true},
+			{Name: "Common name", Value: discordValue(leaf.Subject.CommonName), Inline: true},
+			{Name: "Issuer", Value: discordValue(leaf.IssuerDistinguishedName), Inline: true},
+			{Name: "Validity", Value: discordValue(fmt.Sprintf("%s → %s",
+				leaf.NotBefore.UTC().Format(time.RFC3339), leaf.NotAfter.UTC().Format(time.RFC3339)))},
+			{Name: fmt.Sprintf("Names (%d)", len(entry.Subdomains)), Value: discordNames(entry.Subdomains)},
+		},
+	}
+	if !entry.Timestamp.IsZero() {
+		embed.Timestamp = entry.Timestamp.UTC().Format(time.RFC3339)
+	}
+	if entry.Wildcard {
+		embed.Fields = append(embed.Fields, discordField{Name: "Wildcard", Value: "Yes, covers any host under " + discordValue(entry.Domain)})
+	}
+	if entry.Expiry != nil {
+		embed.Title = truncate(fmt.Sprintf("Certificate for %s expires in %d days", entry.Domain, entry.Expiry.DaysRemaining), discordTitleLimit)
+		embed.Color = discordExpiryColor
+	}
+	return embed
+}
+
+// discordDigestEmbed summarizes entries in one embed, a line per
+// certificate, cut off with a count of the rest at the description limit.
+func discordDigestEmbed(entries []*models.CertificateEntry) discordEmbed {
+	// Room kept for the "and N more" line
+	const reserve = 32
+
+	domains := map[string]bool{}
+	for _, entry := range entries {
+		domains[entry.Domain] = true
+	}
+
+	var b strings.Builder
+	for i, entry := range entries {
+		line := fmt.Sprintf("• `%s` for %s (%s)", entry.LeafCert.Subject.CommonName, entry.Domain, entry.LeafCert.IssuerDistinguishedName)
+		if entry.Expiry != nil {
+			line += fmt.Sprintf(", expires in %d days", entry.Expiry.DaysRemaining)
+		}
+		if entry.Anomaly != nil {
+			line = "• " + anomalySummary(entry)
+		}
+		if i > 0 {
+			line = "\n" + line
+		}
+		if b.Len()+len(line) > discordDescriptionLimit-reserve {
+			fmt.Fprintf(&b, "\n… and %d more", len(entries)-i)
+			break
+		}
+		b.WriteString(line)
+	}
+
+	embed := discordEmbed{
+		Title:       fmt.Sprintf("%d new certificates for %d domain(s)", len(entries), len(domains)),
+		Description: b.String(),
+		Color:       discordColor,
+	}
+	if last := entries[len(entries)-1]; !last.Timestamp.IsZero() {
+		embed.Timestamp = last.Timestamp.UTC().Format(time.RFC3339)
+	}
+	return embed
+}
+
+// discordNames lists names one per line, cutting the list off with a count
+// of the rest when it would exceed the field value limit.
+func discordNames(names []string) string {
+	// Room kept for the "and N more" line
+	const reserve = 32
+
+	var b strings.Builder
+	for i, name := range names {
+		line := "`" + name + "`"
+		if i > 0 {
+			line = "\n" + line
+		}
+		if b.Len()+len(line) > discordFieldValueLimit-reserve {
+			fmt.Fprintf(&b, "\n… and %d more", len(names)-i)
+			break
+		}
+		b.WriteString(line)
+	}
+	return discordValue(b.String())
+}
+
+// discordValue keeps a field value within Discord's limits; empty values
+// are rejected by Discord.
+func discordValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return truncate(value, discordFieldValueLimit)
+}
+
+// truncate cuts s to at most limit bytes, ending with an ellipsis, without
+// splitting a UTF-8 sequence.
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	const ellipsis = "…"
+	cut := limit - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + ellipsis
+}
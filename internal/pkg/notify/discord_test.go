@@ -0,0 +1,166 @@
+package notify
+
+import (
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestDiscordHandlerPostsEmbed(t *testing.T) {
+	var mutex sync.Mutex
+	var messages []discordMessage
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		// The first request is rate limited
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 0.01, "global": false}`))
+			return
+		}
+
+		var message discordMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("Invalid Discord body: %v", err)
+		}
+		messages = append(messages, message)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	handler, err := NewDiscordHandler(server.URL)
+	if err != nil {
+		t.Fatalf("NewDiscordHandler() error: %v", err)
+	}
+	handler.backoff = time.Millisecond
+	if err := handler.Handle(testEntry()); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+	handler.Close()
+
+	if requests != 2 || len(messages) != 1 || len(messages[0].Embeds) != 1 {
+		t.Fatalf("Expected one embed delivered after a rate limit, got %d requests and %+v", requests, messages)
+	}
+	embed := messages[0].Embeds[0]
+	if embed.Title != "New certificate for example.com" {
+		t.Errorf("Unexpected title: %q", embed.Title)
+	}
+	fields := map[string]string{}
+	for _, field := range embed.Fields {
+		fields[field.Name] = field.Value
+	}
+	if fields["Issuer"] != "R3" || fields["Names (1)"] != "`login.example.com`" ||
+		fields["Validity"] != "2025-01-01T00:00:00Z → 2025-04-01T00:00:00Z" {
+		t.Errorf("Unexpected fields: %+v", fields)
+	}
+}
+
+func TestDiscordNamesFitFieldLimit(t *testing.T) {
+	var names []string
+	for i := 0; i < 200; i++ {
+		names = append(names, fmt.Sprintf("host-%d.example.com", i))
+	}
+
+	value := discordNames(names)
+	if len(value) > discordFieldValueLimit {
+		t.Errorf("Names value is %d bytes, over the %d limit", len(value), discordFieldValueLimit)
+	}
+	if !strings.HasPrefix(value, "`host-0.example.com`\n") || !strings.Contains(value, "more") {
+		t.Errorf("Expected a truncated list ending with a count, got %q", value)
+	}
+
+	if got := truncate(strings.Repeat("é", 200), 255); len(got) > 255 || !utf8.ValidString(got) {
+		t.Errorf("truncate() split a UTF-8 sequence or overran the limit: %d bytes", len(got))
+	}
+}
+
+func TestDiscordSendDeliversRightAway(t *testing.T) {
+	var mutex sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if r.URL.Path == "/deleted" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Unknown Webhook", "code": 10015}`))
+			return
+		}
+		requests++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	handler, err := NewDiscordHandler(server.URL + "/hook")
+	if err != nil {
+		t.Fatalf("NewDiscordHandler() error: %v", err)
+	}
+	defer handler.Close()
+
+	// Send bypasses the queue, so the message is out when it returns
+	if err := handler.Send(testEntry()); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	mutex.Lock()
+	if requests != 1 {
+		t.Errorf("Expected the message to be sent before Close, got %d requests", requests)
+	}
+	mutex.Unlock()
+
+	deleted, err := NewDiscordHandler(server.URL + "/deleted")
+	if err != nil {
+		t.Fatalf("NewDiscordHandler() error: %v", err)
+	}
+	defer deleted.Close()
+	if err := deleted.Send(testEntry()); err == nil || !strings.Contains(err.Error(), "Unknown Webhook") {
+		t.Errorf("Expected Send to report the rejection, got %v", err)
+	}
+}
+
+func TestDiscordHandlerBatchPostsSummary(t *testing.T) {
+	var messages []discordMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message discordMessage
+		json.NewDecoder(r.Body).Decode(&message)
+		messages = append(messages, message)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	handler, _ := NewDiscordHandler(server.URL)
+	other := testEntry()
+	other.Domain = "example.org"
+	other.LeafCert.Subject.CommonName = "mail.example.org"
+	if err := handler.HandleBatch([]*models.CertificateEntry{testEntry(), other}); err != nil {
+		t.Fatalf("HandleBatch() error: %v", err)
+	}
+	handler.Close()
+
+	if len(messages) != 1 || len(messages[0].Embeds) != 1 {
+		t.Fatalf("Expected one summary embed, got %+v", messages)
+	}
+	embed := messages[0].Embeds[0]
+	if embed.Title != "2 new certificates for 2 domain(s)" ||
+		embed.Description != "• `login.example.com` for example.com (R3)\n• `mail.example.org` for example.org (R3)" {
+		t.Errorf("Unexpected summary: %q\n%s", embed.Title, embed.Description)
+	}
+
+	// A large digest is cut off with a count of the rest
+	many := make([]*models.CertificateEntry, 500)
+	for i := range many {
+		many[i] = testEntry()
+	}
+	embed = discordDigestEmbed(many)
+	if utf8.RuneCountInString(embed.Description) > discordDescriptionLimit || !strings.Contains(embed.Description, "more") {
+		t.Errorf("Expected the list cut off within the limit, got %d characters", utf8.RuneCountInString(embed.Description))
+	}
+}
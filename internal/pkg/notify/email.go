@@ -0,0 +1,286 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPConfig is where and how EmailHandler sends mail.
+type SMTPConfig struct {
+	Host     string
+	Port     int // 587 if zero
+	Username string
+	Password string
+	From     string
+	To       []string
+
+	// TLS is "starttls", "tls" for implicit TLS or "none". Empty picks tls
+	// on port 465 and starttls otherwise.
+	TLS string
+
+	// AttachJSON attaches each entry as certificate.json (an array of
+	// entries for a digest).
+	AttachJSON bool
+}
+
+// EmailHandler emails each matched certificate over SMTP.
+type EmailHandler struct {
+	cfg       SMTPConfig
+	format    *Format
+	timeout   time.Duration
+	tlsConfig *tls.Config
+}
+
+// NewEmailHandler creates a handler sending mail as cfg describes.
+func NewEmailHandler(cfg SMTPConfig) (*EmailHandler, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("smtp host is required")
+	}
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("email sender and at least one recipient are required")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+
+	cfg.TLS = strings.ToLower(cfg.TLS)
+	switch cfg.TLS {
+	case "":
+		cfg.TLS = "starttls"
+		if cfg.Port == 465 {
+			cfg.TLS = "tls"
+		}
+	case "starttls", "tls", "none":
+	default:
+		return nil, fmt.Errorf("unsupported smtp TLS mode: %s", cfg.TLS)
+	}
+
+	return &EmailHandler{
+		cfg:       cfg,
+		timeout:   30 * time.Second,
+		tlsConfig: &tls.Config{ServerName: cfg.Host},
+	}, nil
+}
+
+// SetFormat replaces the plain text body of each entry with format; the
+// subject is unchanged.
+func (h *EmailHandler) SetFormat(format *Format) {
+	h.format = format
+}
+
+func (h *EmailHandler) Handle(entry *models.CertificateEntry) error {
+	body, err := h.body(entry)
+	if err != nil {
+		return err
+	}
+	message, err := h.message(emailSubject(entry), body, entry)
+	if err != nil {
+		return err
+	}
+	if err := h.send(message); err != nil {
+		return fmt.Errorf("failed to send email for %s: %w", entry.Domain, err)
+	}
+	return nil
+}
+
+// HandleBatch sends entries as a single digest email, for DigestHandler.
+func (h *EmailHandler) HandleBatch(entries []*models.CertificateEntry) error {
+	if len(entries) == 1 {
+		return h.Handle(entries[0])
+	}
+
+	domains := map[string]bool{}
+	var body strings.Builder
+	for i, entry := range entries {
+		domains[entry.Domain] = true
+		if i > 0 {
+			body.WriteString("\r\n----\r\n\r\n")
+		}
+		text, err := h.body(entry)
+		if err != nil {
+			return err
+		}
+		body.WriteString(text)
+	}
+	subject := fmt.Sprintf("[domain_watcher] %d new certificates for %d domain(s)", len(entries), len(domains))
+
+	message, err := h.message(subject, body.String(), entries)
+	if err != nil {
+		return err
+	}
+	if err := h.send(message); err != nil {
+		return fmt.Errorf("failed to send digest email: %w", err)
+	}
+	return nil
+}
+
+func (h *EmailHandler) send(message []byte) error {
+	addr := net.JoinHostPort(h.cfg.Host, strconv.Itoa(h.cfg.Port))
+	dialer := &net.Dialer{Timeout: h.timeout}
+
+	var conn net.Conn
+	var err error
+	if h.cfg.TLS == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, h.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(h.timeout))
+
+	client, err := smtp.NewClient(conn, h.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if h.cfg.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS", addr)
+		}
+		if err := client.StartTLS(h.tlsConfig); err != nil {
+			return err
+		}
+	}
+	if h.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", h.cfg.Username, h.cfg.Password, h.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(h.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range h.cfg.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message builds an email with a plain text body, and attachment marshalled
+// as JSON when configured.
+func (h *EmailHandler) message(subject, body string, attachment interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", h.cfg.From)
+	header("To", strings.Join(h.cfg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	if !h.cfg.AttachJSON {
+		header("Content-Type", "text/plain; charset=utf-8")
+		buf.WriteString("\r\n")
+		buf.WriteString(body)
+		return buf.Bytes(), nil
+	}
+
+	data, err := json.MarshalIndent(attachment, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal email attachment: %w", err)
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/mixed; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+
+	text, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	text.Write([]byte(body))
+
+	file, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {"application/json"},
+		"Content-Disposition": {`attachment; filename="certificate.json"`},
+	})
+	if err != nil {
+		return nil, err
+	}
+	file.Write(data)
+
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// body returns the text of entry in the email body.
+func (h *EmailHandler) body(entry *models.CertificateEntry) (string, error) {
+	if h.format == nil {
+		return emailBody(entry), nil
+	}
+	return h.format.Render(entry)
+}
+
+func emailSubject(entry *models.CertificateEntry) string {
+	if entry.Anomaly != nil {
+		return "[domain_watcher] " + anomalySummary(entry)
+	}
+	if entry.Expiry != nil {
+		return fmt.Sprintf("[domain_watcher] Certificate for %s expires in %d days: %s",
+			entry.Domain, entry.Expiry.DaysRemaining, entry.LeafCert.Subject.CommonName)
+	}
+	return fmt.Sprintf("[domain_watcher] New certificate for %s: %s", entry.Domain, entry.LeafCert.Subject.CommonName)
+}
+
+func emailBody(entry *models.CertificateEntry) string {
+	leaf := entry.LeafCert
+	var b strings.Builder
+	if entry.Anomaly != nil {
+		fmt.Fprintf(&b, "Domain:       %s\r\n", entry.Domain)
+		fmt.Fprintf(&b, "Certificates: %d since %s\r\n", entry.Anomaly.Count, entry.Anomaly.WindowStart.UTC().Format(time.RFC3339))
+		fmt.Fprintf(&b, "Baseline:     %.1f per %s\r\n", entry.Anomaly.Baseline, entry.Anomaly.Window)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Domain:      %s\r\n", entry.Domain)
+	fmt.Fprintf(&b, "Common name: %s\r\n", leaf.Subject.CommonName)
+	fmt.Fprintf(&b, "Issuer:      %s\r\n", leaf.IssuerDistinguishedName)
+	fmt.Fprintf(&b, "Valid from:  %s\r\n", leaf.NotBefore.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "Valid until: %s\r\n", leaf.NotAfter.UTC().Format(time.RFC3339))
+	if entry.Wildcard {
+		fmt.Fprintf(&b, "Wildcard:    yes, covers any host under %s\r\n", entry.Domain)
+	}
+	if entry.Expiry != nil {
+		fmt.Fprintf(&b, "Expires in:  %d days\r\n", entry.Expiry.DaysRemaining)
+	}
+	if leaf.SerialNumber != "" {
+		fmt.Fprintf(&b, "Serial:      %s\r\n", leaf.SerialNumber)
+	}
+	if entry.LogURL != "" {
+		fmt.Fprintf(&b, "Seen in:     %s\r\n", entry.LogURL)
+	}
+	fmt.Fprintf(&b, "\r\nNames (%d):\r\n", len(entry.Subdomains))
+	for _, name := range entry.Subdomains {
+		fmt.Fprintf(&b, "  %s\r\n", name)
+	}
+	return b.String()
+}
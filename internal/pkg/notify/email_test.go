@@ -0,0 +1,181 @@
+package notify
+
+import (
+	"bufio"
+	"domain_watcher/pkg/models"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer accepts one message over plain SMTP with AUTH PLAIN and
+// sends it on the returned channel.
+func fakeSMTPServer(t *testing.T) (string, int, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(command, "AUTH PLAIN"):
+				reply("235 Authenticated")
+			case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 Go ahead")
+				var data strings.Builder
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- data.String()
+				reply("250 Queued")
+			case command == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("502 Unknown command")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return host, portNumber, received
+}
+
+func TestEmailHandlerSendsMessage(t *testing.T) {
+	host, port, received := fakeSMTPServer(t)
+	handler, err := NewEmailHandler(SMTPConfig{
+		Host:       host,
+		Port:       port,
+		Username:   "watcher",
+		Password:   "secret",
+		From:       "watcher@example.com",
+		To:         []string{"security@example.com"},
+		TLS:        "none",
+		AttachJSON: true,
+	})
+	if err != nil {
+		t.Fatalf("NewEmailHandler() error: %v", err)
+	}
+	if err := handler.Handle(testEntry()); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+
+	message, err := mail.ReadMessage(strings.NewReader(<-received))
+	if err != nil {
+		t.Fatalf("Invalid email: %v", err)
+	}
+	if subject := message.Header.Get("Subject"); subject != "[domain_watcher] New certificate for example.com: login.example.com" {
+		t.Errorf("Unexpected subject: %q", subject)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Expected a multipart email, got %q (%v)", mediaType, err)
+	}
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	text, err := parts.NextPart()
+	if err != nil {
+		t.Fatalf("Missing text part: %v", err)
+	}
+	body, _ := io.ReadAll(text)
+	for _, want := range []string{"Common name: login.example.com", "Issuer:      R3", "Valid until: 2025-04-01T00:00:00Z"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Email body missing %q:\n%s", want, body)
+		}
+	}
+	attachment, err := parts.NextPart()
+	if err != nil || attachment.FileName() != "certificate.json" {
+		t.Fatalf("Expected a certificate.json attachment, got %v", err)
+	}
+}
+
+func TestEmailHandlerBatchDigest(t *testing.T) {
+	host, port, received := fakeSMTPServer(t)
+	handler, err := NewEmailHandler(SMTPConfig{
+		Host: host,
+		Port: port,
+		From: "watcher@example.com",
+		To:   []string{"security@example.com"},
+		TLS:  "none",
+	})
+	if err != nil {
+		t.Fatalf("NewEmailHandler() error: %v", err)
+	}
+
+	other := testEntry()
+	other.Domain = "example.org"
+	other.LeafCert.Subject.CommonName = "www.example.org"
+	if err := handler.HandleBatch([]*models.CertificateEntry{testEntry(), other}); err != nil {
+		t.Fatalf("HandleBatch() error: %v", err)
+	}
+
+	message, err := mail.ReadMessage(strings.NewReader(<-received))
+	if err != nil {
+		t.Fatalf("Invalid email: %v", err)
+	}
+	if subject := message.Header.Get("Subject"); subject != "[domain_watcher] 2 new certificates for 2 domain(s)" {
+		t.Errorf("Unexpected digest subject: %q", subject)
+	}
+	body, _ := io.ReadAll(message.Body)
+	if !strings.Contains(string(body), "login.example.com") || !strings.Contains(string(body), "www.example.org") {
+		t.Errorf("Expected both certificates in the digest:\n%s", body)
+	}
+}
+
+func TestNewEmailHandlerTLSMode(t *testing.T) {
+	tests := []struct {
+		port int
+		mode string
+		want string
+	}{
+		{0, "", "starttls"},
+		{465, "", "tls"},
+		{25, "none", "none"},
+	}
+	for _, tt := range tests {
+		handler, err := NewEmailHandler(SMTPConfig{Host: "smtp.example.com", Port: tt.port, TLS: tt.mode, From: "a@example.com", To: []string{"b@example.com"}})
+		if err != nil {
+			t.Fatalf("NewEmailHandler() error: %v", err)
+		}
+		if handler.cfg.TLS != tt.want {
+			t.Errorf("port %d, mode %q: expected %s, got %s", tt.port, tt.mode, tt.want, handler.cfg.TLS)
+		}
+	}
+
+	if _, err := NewEmailHandler(SMTPConfig{Host: "smtp.example.com", TLS: "ssl", From: "a@example.com", To: []string{"b@example.com"}}); err == nil {
+		t.Error("Expected an error for an unknown TLS mode")
+	}
+}
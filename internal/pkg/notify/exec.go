@@ -0,0 +1,252 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// execMaxRunning bounds how many commands run at once; matches arriving
+	// while all are busy are dropped rather than queued.
+	execMaxRunning = 4
+
+	// execOutputLimit bounds how much command output is logged.
+	execOutputLimit = 4096
+)
+
+// ExecHandler runs a command for each matched certificate, e.g. to start a
+// scan of a new host. Arguments may contain the placeholders {domain}, {cn},
+// {fingerprint} and {names} (the matched names, comma-separated); the same
+// values are set in the DOMAIN_WATCHER_DOMAIN, DOMAIN_WATCHER_CN,
+// DOMAIN_WATCHER_FINGERPRINT and DOMAIN_WATCHER_NAMES environment variables,
+// and the entry is written to the command's stdin as JSON. The command is
+// run directly, not through a shell.
+//
+// A digest runs the command once, with the entries as a JSON array on stdin
+// and each placeholder's values, deduplicated, joined by commas.
+//
+// Commands run in the background, at most four at a time, and are killed
+// after the timeout, so a slow command never holds up the monitor. Their
+// output is logged.
+type ExecHandler struct {
+	command string
+	args    []string
+	timeout time.Duration
+	format  *Format
+
+	slots   chan struct{}
+	running sync.WaitGroup
+}
+
+// NewExecHandler creates a handler running command with args, killing it
+// after timeout (30s if zero).
+func NewExecHandler(command string, args []string, timeout time.Duration) (*ExecHandler, error) {
+	if command == "" {
+		return nil, fmt.Errorf("exec command is required")
+	}
+	if _, err := exec.LookPath(command); err != nil {
+		return nil, fmt.Errorf("exec command not found: %w", err)
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &ExecHandler{
+		command: command,
+		args:    args,
+		timeout: timeout,
+		slots:   make(chan struct{}, execMaxRunning),
+	}, nil
+}
+
+// SetFormat replaces the JSON entry written to the command's stdin with
+// format.
+func (h *ExecHandler) SetFormat(format *Format) {
+	h.format = format
+}
+
+// Handle starts the command for entry and returns without waiting for it.
+// It fails if too many commands are already running.
+func (h *ExecHandler) Handle(entry *models.CertificateEntry) error {
+	return h.start([]*models.CertificateEntry{entry})
+}
+
+// HandleBatch starts the command once for entries, for DigestHandler.
+func (h *ExecHandler) HandleBatch(entries []*models.CertificateEntry) error {
+	return h.start(entries)
+}
+
+func (h *ExecHandler) start(entries []*models.CertificateEntry) error {
+	select {
+	case h.slots <- struct{}{}:
+	default:
+		return fmt.Errorf("%d exec commands already running, skipped %s", execMaxRunning, execSubject(entries))
+	}
+
+	input, err := h.input(entries)
+	if err != nil {
+		<-h.slots
+		return err
+	}
+
+	h.running.Add(1)
+	go func() {
+		defer h.running.Done()
+		defer func() { <-h.slots }()
+		message, _ := h.execute(entries, input)
+		log.Print(message)
+	}()
+	return nil
+}
+
+// Send runs the command for entry and waits for it, returning an error with
+// the command's output if it fails or times out.
+func (h *ExecHandler) Send(entry *models.CertificateEntry) error {
+	entries := []*models.CertificateEntry{entry}
+	input, err := h.input(entries)
+	if err != nil {
+		return err
+	}
+	message, err := h.execute(entries, input)
+	if err != nil {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// input returns what is written to the command's stdin for entries: one
+// entry as a JSON object, several as an array, or each in the handler's
+// format on its own line.
+func (h *ExecHandler) input(entries []*models.CertificateEntry) ([]byte, error) {
+	if h.format != nil {
+		texts := make([]string, len(entries))
+		for i, entry := range entries {
+			text, err := h.format.Render(entry)
+			if err != nil {
+				return nil, err
+			}
+			texts[i] = text
+		}
+		return []byte(strings.Join(texts, "\n")), nil
+	}
+
+	var payload interface{} = entries
+	if len(entries) == 1 {
+		payload = entries[0]
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return data, nil
+}
+
+// execute runs the command for entries and describes its outcome, with its
+// output.
+func (h *ExecHandler) execute(entries []*models.CertificateEntry, input []byte) (string, error) {
+	values := execValues(entries)
+	subject := execSubject(entries)
+	replacer := strings.NewReplacer(
+		"{domain}", values["DOMAIN"],
+		"{cn}", values["CN"],
+		"{fingerprint}", values["FINGERPRINT"],
+		"{names}", values["NAMES"],
+	)
+	args := make([]string, len(h.args))
+	for i, arg := range h.args {
+		args[i] = replacer.Replace(arg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.command, args...)
+	cmd.Env = os.Environ()
+	for name, value := range values {
+		cmd.Env = append(cmd.Env, "DOMAIN_WATCHER_"+name+"="+value)
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Don't wait on children that keep the output open past the timeout
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+
+	var message string
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		err = ctx.Err()
+		message = fmt.Sprintf("Exec %s for %s killed after %v", h.command, subject, h.timeout)
+	case err != nil:
+		message = fmt.Sprintf("Exec %s for %s failed: %v", h.command, subject, err)
+	default:
+		message = fmt.Sprintf("Exec %s for %s finished in %v", h.command, subject, time.Since(start).Round(time.Millisecond))
+	}
+	if out := strings.TrimSpace(truncate(output.String(), execOutputLimit)); out != "" {
+		message += ":\n" + out
+	}
+	return message, err
+}
+
+// execSubject names entries in log messages.
+func execSubject(entries []*models.CertificateEntry) string {
+	if len(entries) == 1 {
+		return entries[0].Domain
+	}
+	return fmt.Sprintf("a digest of %d certificates", len(entries))
+}
+
+// execValues returns the values passed to a command for entries, keyed by
+// the environment variable suffix. Values of several entries are
+// deduplicated and joined by commas.
+func execValues(entries []*models.CertificateEntry) map[string]string {
+	var domains, cns, fingerprints, names []string
+	for _, entry := range entries {
+		domains = append(domains, entry.Domain)
+		cns = append(cns, entry.LeafCert.Subject.CommonName)
+		fingerprints = append(fingerprints, entry.LeafCert.Fingerprint)
+		if len(entry.MatchedNames) > 0 {
+			names = append(names, entry.MatchedNames...)
+		} else {
+			names = append(names, entry.Subdomains...)
+		}
+	}
+	return map[string]string{
+		"DOMAIN":      joinUnique(domains),
+		"CN":          joinUnique(cns),
+		"FINGERPRINT": joinUnique(fingerprints),
+		"NAMES":       joinUnique(names),
+	}
+}
+
+// joinUnique joins the non-empty values, each once, with commas.
+func joinUnique(values []string) string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, value := range values {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return strings.Join(unique, ",")
+}
+
+// Close waits for running commands to finish or time out.
+func (h *ExecHandler) Close() error {
+	h.running.Wait()
+	return nil
+}
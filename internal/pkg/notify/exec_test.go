@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecHandlerRunsCommand(t *testing.T) {
+	dir := t.TempDir()
+	script := `echo "$1 $DOMAIN_WATCHER_CN $DOMAIN_WATCHER_NAMES" > "$2/args"; cat > "$2/stdin"`
+	handler, err := NewExecHandler("sh", []string{"-c", script, "sh", "{domain}", dir}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewExecHandler() error: %v", err)
+	}
+
+	entry := testEntry()
+	entry.MatchedNames = []string{"login.example.com"}
+	if err := handler.Handle(entry); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+	handler.Close()
+
+	args, err := os.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatalf("Expected the command to run: %v", err)
+	}
+	if got := string(args); got != "example.com login.example.com login.example.com\n" {
+		t.Errorf("Unexpected arguments and environment: %q", got)
+	}
+
+	stdin, _ := os.ReadFile(filepath.Join(dir, "stdin"))
+	var received map[string]interface{}
+	if err := json.Unmarshal(stdin, &received); err != nil || received["domain"] != "example.com" {
+		t.Errorf("Expected the entry as JSON on stdin, got %q (%v)", stdin, err)
+	}
+}
+
+func TestExecHandlerTimeout(t *testing.T) {
+	handler, err := NewExecHandler("sleep", []string{"10"}, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewExecHandler() error: %v", err)
+	}
+
+	start := time.Now()
+	if err := handler.Handle(testEntry()); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected Handle not to wait for the command, took %v", elapsed)
+	}
+	handler.Close()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the command to be killed after the timeout, took %v", elapsed)
+	}
+
+	if _, err := NewExecHandler("domain-watcher-missing-command", nil, 0); err == nil {
+		t.Error("Expected an error for a missing command")
+	}
+}
+
+func TestExecHandlerSendReportsFailure(t *testing.T) {
+	handler, err := NewExecHandler("sh", []string{"-c", "echo no route to scanner; exit 3"}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewExecHandler() error: %v", err)
+	}
+
+	err = handler.Send(testEntry())
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") || !strings.Contains(err.Error(), "no route to scanner") {
+		t.Errorf("Expected the exit status and output, got %v", err)
+	}
+
+	handler, _ = NewExecHandler("true", nil, 5*time.Second)
+	if err := handler.Send(testEntry()); err != nil {
+		t.Errorf("Send() error: %v", err)
+	}
+}
+
+func TestExecHandlerBatchRunsOnce(t *testing.T) {
+	dir := t.TempDir()
+	script := `echo "$1" >> "$2/runs"; cat > "$2/stdin"`
+	handler, err := NewExecHandler("sh", []string{"-c", script, "sh", "{names}", dir}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewExecHandler() error: %v", err)
+	}
+
+	www := testEntry()
+	www.Subdomains = []string{"www.example.com", "login.example.com"}
+	if err := handler.HandleBatch([]*models.CertificateEntry{testEntry(), www}); err != nil {
+		t.Fatalf("HandleBatch() error: %v", err)
+	}
+	handler.Close()
+
+	runs, _ := os.ReadFile(filepath.Join(dir, "runs"))
+	if got := string(runs); got != "login.example.com,www.example.com\n" {
+		t.Errorf("Expected one run with each name once, got %q", got)
+	}
+	stdin, _ := os.ReadFile(filepath.Join(dir, "stdin"))
+	var received []map[string]interface{}
+	if err := json.Unmarshal(stdin, &received); err != nil || len(received) != 2 {
+		t.Errorf("Expected the entries as a JSON array on stdin, got %q (%v)", stdin, err)
+	}
+}
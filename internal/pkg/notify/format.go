@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"bytes"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Format is how a notification handler renders an entry, set with the
+// handler's SetFormat. The nil Format is the handler's own message.
+type Format struct {
+	json     bool
+	template *template.Template
+}
+
+// formatFuncs are available to format templates, e.g.
+// {"text": {{json .Domain}}} for a JSON payload.
+var formatFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// ParseFormat parses a handler format: "default" (or empty) for the
+// handler's own message, "json" for the entry as JSON, or a Go text/template
+// executed on the entry, e.g. "{{.Domain}}: {{.LeafCert.Subject.CommonName}}".
+func ParseFormat(format string) (*Format, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "default":
+		return nil, nil
+	case "json":
+		return &Format{json: true}, nil
+	}
+	if !strings.Contains(format, "{{") {
+		return nil, fmt.Errorf("unsupported format %q: use default, json or a template such as \"{{.Domain}}\"", format)
+	}
+
+	tmpl, err := template.New("format").Funcs(formatFuncs).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format template: %w", err)
+	}
+	return &Format{template: tmpl}, nil
+}
+
+// Render returns entry in format f.
+func (f *Format) Render(entry *models.CertificateEntry) (string, error) {
+	if f.json {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		return string(data), nil
+	}
+
+	var buf bytes.Buffer
+	if err := f.template.Execute(&buf, entry); err != nil {
+		return "", fmt.Errorf("failed to render format template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// anomalySummary describes the issuance spike entry.Anomaly reports.
+func anomalySummary(entry *models.CertificateEntry) string {
+	anomaly := entry.Anomaly
+	return fmt.Sprintf("Issuance spike for %s: %d certificates since %s UTC, baseline %.1f per %s",
+		entry.Domain, anomaly.Count, anomaly.WindowStart.UTC().Format("2006-01-02 15:04"), anomaly.Baseline, anomaly.Window)
+}
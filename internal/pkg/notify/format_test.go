@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFormat(t *testing.T) {
+	for _, format := range []string{"", "default", "DEFAULT"} {
+		if f, err := ParseFormat(format); err != nil || f != nil {
+			t.Errorf("ParseFormat(%q) = %v, %v; expected the default format", format, f, err)
+		}
+	}
+	for _, format := range []string{"xml", "{{.Domain", "{{.Domain | nosuchfunc}}"} {
+		if _, err := ParseFormat(format); err == nil {
+			t.Errorf("Expected an error for %q", format)
+		}
+	}
+}
+
+func TestHandlersUseTheirOwnFormat(t *testing.T) {
+	type request struct {
+		contentType string
+		body        string
+	}
+	received := map[string]request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received[r.URL.Path] = request{r.Header.Get("Content-Type"), string(body)}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	jsonFormat, err := ParseFormat("json")
+	if err != nil {
+		t.Fatalf("ParseFormat() error: %v", err)
+	}
+	textFormat, err := ParseFormat(`New certificate {{.LeafCert.Subject.CommonName}} for {{.Domain}} ({{join .Subdomains ", "}})`)
+	if err != nil {
+		t.Fatalf("ParseFormat() error: %v", err)
+	}
+
+	archive, _ := NewWebhookHandler(server.URL+"/archive", time.Second)
+	archive.SetFormat(jsonFormat)
+	chat, _ := NewDiscordHandler(server.URL + "/chat")
+	chat.SetFormat(textFormat)
+
+	entry := testEntry()
+	for _, handler := range []interface {
+		Handle(*models.CertificateEntry) error
+		Close() error
+	}{archive, chat} {
+		if err := handler.Handle(entry); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+		handler.Close()
+	}
+
+	var stored models.CertificateEntry
+	if err := json.Unmarshal([]byte(received["/archive"].body), &stored); err != nil || stored.Domain != "example.com" {
+		t.Errorf("Expected the entry as JSON, got %q (%v)", received["/archive"].body, err)
+	}
+	if contentType := received["/archive"].contentType; contentType != "application/json" {
+		t.Errorf("Expected a JSON content type, got %q", contentType)
+	}
+
+	var message discordMessage
+	if err := json.Unmarshal([]byte(received["/chat"].body), &message); err != nil {
+		t.Fatalf("Invalid discord body %q: %v", received["/chat"].body, err)
+	}
+	if message.Content != "New certificate login.example.com for example.com (login.example.com)" || len(message.Embeds) != 0 {
+		t.Errorf("Expected the templated text instead of an embed, got %+v", message)
+	}
+}
+
+func TestWebhookTemplateContentType(t *testing.T) {
+	var contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		contentType, body = r.Header.Get("Content-Type"), string(data)
+	}))
+	defer server.Close()
+
+	handler, _ := NewWebhookHandler(server.URL, time.Second)
+	for _, tt := range []struct {
+		format      string
+		contentType string
+		body        string
+	}{
+		{`{"text": {{json .Domain}}}`, "application/json", `{"text": "example.com"}`},
+		{`{{upper .Domain}}`, "text/plain; charset=utf-8", "EXAMPLE.COM"},
+	} {
+		format, err := ParseFormat(tt.format)
+		if err != nil {
+			t.Fatalf("ParseFormat(%q) error: %v", tt.format, err)
+		}
+		handler.SetFormat(format)
+		if err := handler.Send(testEntry()); err != nil {
+			t.Fatalf("Send() error: %v", err)
+		}
+		if contentType != tt.contentType || strings.TrimSpace(body) != tt.body {
+			t.Errorf("Format %q: expected %q as %s, got %q as %s", tt.format, tt.body, tt.contentType, body, contentType)
+		}
+	}
+}
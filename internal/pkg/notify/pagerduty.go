@@ -0,0 +1,268 @@
+package notify
+
+import (
+	"bytes"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+	// pagerDutySummaryLimit is the Events API's maximum summary length.
+	pagerDutySummaryLimit = 1024
+)
+
+// pagerDutyEvent is the Events API v2 trigger payload.
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyPayload `json:"payload"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Timestamp     string                 `json:"timestamp"`
+	Component     string                 `json:"component"`
+	Class         string                 `json:"class"`
+	CustomDetails map[string]interface{} `json:"custom_details"`
+}
+
+// PagerDutyHandler triggers a PagerDuty Events API v2 alert for each matched
+// certificate. Alerts are deduplicated per matched domain, so a renewal storm
+// for one domain updates a single incident instead of opening many. A digest
+// triggers one alert per domain, listing its certificates.
+type PagerDutyHandler struct {
+	routingKey string
+	severity   string
+	eventsURL  string
+	source     string
+	format     *Format
+	httpClient *http.Client
+}
+
+// NewPagerDutyHandler creates a handler sending to the service behind
+// routingKey. severity is one of critical, error, warning or info.
+func NewPagerDutyHandler(routingKey, severity string) (*PagerDutyHandler, error) {
+	if routingKey == "" {
+		return nil, fmt.Errorf("pagerduty routing key is required")
+	}
+
+	severity = strings.ToLower(severity)
+	switch severity {
+	case "critical", "error", "warning", "info":
+	default:
+		return nil, fmt.Errorf("unsupported pagerduty severity: %s", severity)
+	}
+
+	source, err := os.Hostname()
+	if err != nil || source == "" {
+		source = "domain_watcher"
+	}
+
+	return &PagerDutyHandler{
+		routingKey: routingKey,
+		severity:   severity,
+		eventsURL:  defaultPagerDutyEventsURL,
+		source:     source,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// SetFormat renders the alert summary with format instead of the built-in
+// one; it is cut to the 1024 characters PagerDuty accepts.
+func (h *PagerDutyHandler) SetFormat(format *Format) {
+	h.format = format
+}
+
+func (h *PagerDutyHandler) Handle(entry *models.CertificateEntry) error {
+	event := pagerDutyEvent{
+		RoutingKey:  h.routingKey,
+		EventAction: "trigger",
+		DedupKey:    pagerDutyDedupKey(entry.Domain),
+		Payload: pagerDutyPayload{
+			Summary: fmt.Sprintf("New certificate for %s: %s (issuer %s)",
+				entry.Domain, entry.LeafCert.Subject.CommonName, entry.LeafCert.IssuerDistinguishedName),
+			Source:    h.source,
+			Severity:  h.severity,
+			Timestamp: entry.Timestamp.UTC().Format(time.RFC3339),
+			Component: entry.Domain,
+			Class:     "certificate",
+			CustomDetails: map[string]interface{}{
+				"common_name":   entry.LeafCert.Subject.CommonName,
+				"names":         entry.Subdomains,
+				"issuer":        entry.LeafCert.IssuerDistinguishedName,
+				"not_before":    entry.LeafCert.NotBefore.UTC().Format(time.RFC3339),
+				"not_after":     entry.LeafCert.NotAfter.UTC().Format(time.RFC3339),
+				"serial_number": entry.LeafCert.SerialNumber,
+				"log_url":       entry.LogURL,
+			},
+		},
+	}
+	if entry.IdempotencyKey != "" {
+		event.Payload.CustomDetails["idempotency_key"] = entry.IdempotencyKey
+	}
+	if entry.Keyword != "" {
+		event.Payload.CustomDetails["keyword"] = entry.Keyword
+	}
+	if entry.Wildcard {
+		event.Payload.CustomDetails["wildcard"] = true
+	}
+	if entry.Expiry != nil {
+		// Expiry is its own alert, kept apart from new-issuance alerts for
+		// the domain
+		event.DedupKey = pagerDutyDedupKey(entry.Domain) + "/expiry"
+		event.Payload.Summary = fmt.Sprintf("Certificate for %s expires in %d days: %s (issuer %s)",
+			entry.Domain, entry.Expiry.DaysRemaining, entry.LeafCert.Subject.CommonName, entry.LeafCert.IssuerDistinguishedName)
+		event.Payload.Class = "certificate_expiry"
+		event.Payload.CustomDetails["days_remaining"] = entry.Expiry.DaysRemaining
+	}
+	if entry.Anomaly != nil {
+		// An anomaly reports no certificate, only the spike
+		event.DedupKey = pagerDutyDedupKey(entry.Domain) + "/anomaly"
+		event.Payload.Summary = anomalySummary(entry)
+		event.Payload.Class = "issuance_anomaly"
+		event.Payload.CustomDetails = map[string]interface{}{
+			"count":        entry.Anomaly.Count,
+			"baseline":     entry.Anomaly.Baseline,
+			"window_start": entry.Anomaly.WindowStart.UTC().Format(time.RFC3339),
+			"window":       entry.Anomaly.Window.String(),
+		}
+	}
+	if h.format != nil {
+		summary, err := h.format.Render(entry)
+		if err != nil {
+			return err
+		}
+		event.Payload.Summary = truncate(strings.TrimSpace(summary), pagerDutySummaryLimit)
+	}
+
+	return h.send(event)
+}
+
+// HandleBatch triggers one alert per domain for entries, summarizing its
+// certificates, for DigestHandler. Expiry and anomaly alerts stay separate.
+func (h *PagerDutyHandler) HandleBatch(entries []*models.CertificateEntry) error {
+	var keys []string
+	groups := map[string][]*models.CertificateEntry{}
+	for _, entry := range entries {
+		key := pagerDutyDedupKey(entry.Domain)
+		switch {
+		case entry.Expiry != nil:
+			key += "/expiry"
+		case entry.Anomaly != nil:
+			key += "/anomaly"
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], entry)
+	}
+
+	failed := 0
+	var lastErr error
+	for _, key := range keys {
+		group := groups[key]
+		var err error
+		if len(group) == 1 || group[0].Expiry != nil || group[0].Anomaly != nil {
+			for _, entry := range group {
+				if err = h.Handle(entry); err != nil {
+					break
+				}
+			}
+		} else {
+			err = h.handleGroup(key, group)
+		}
+		if err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d pagerduty alerts failed: %w", failed, len(keys), lastErr)
+	}
+	return nil
+}
+
+// handleGroup triggers one alert for several new certificates of a domain.
+func (h *PagerDutyHandler) handleGroup(dedupKey string, entries []*models.CertificateEntry) error {
+	domain := entries[0].Domain
+	names := make([]string, 0, len(entries))
+	certificates := make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.LeafCert.Subject.CommonName)
+		certificates = append(certificates, map[string]interface{}{
+			"common_name":   entry.LeafCert.Subject.CommonName,
+			"names":         entry.Subdomains,
+			"issuer":        entry.LeafCert.IssuerDistinguishedName,
+			"not_before":    entry.LeafCert.NotBefore.UTC().Format(time.RFC3339),
+			"not_after":     entry.LeafCert.NotAfter.UTC().Format(time.RFC3339),
+			"serial_number": entry.LeafCert.SerialNumber,
+		})
+	}
+
+	event := pagerDutyEvent{
+		RoutingKey:  h.routingKey,
+		EventAction: "trigger",
+		DedupKey:    dedupKey,
+		Payload: pagerDutyPayload{
+			Summary:   fmt.Sprintf("%d new certificates for %s: %s", len(entries), domain, strings.Join(names, ", ")),
+			Source:    h.source,
+			Severity:  h.severity,
+			Timestamp: entries[len(entries)-1].Timestamp.UTC().Format(time.RFC3339),
+			Component: domain,
+			Class:     "certificate",
+			CustomDetails: map[string]interface{}{
+				"count":        len(entries),
+				"certificates": certificates,
+			},
+		},
+	}
+	if h.format != nil {
+		summaries := make([]string, len(entries))
+		for i, entry := range entries {
+			summary, err := h.format.Render(entry)
+			if err != nil {
+				return err
+			}
+			summaries[i] = strings.TrimSpace(summary)
+		}
+		event.Payload.Summary = strings.Join(summaries, "; ")
+	}
+	event.Payload.Summary = truncate(event.Payload.Summary, pagerDutySummaryLimit)
+	return h.send(event)
+}
+
+// send triggers event.
+func (h *PagerDutyHandler) send(event pagerDutyEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pagerduty event: %w", err)
+	}
+
+	resp, err := h.httpClient.Post(h.eventsURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to send pagerduty event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pagerduty returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// pagerDutyDedupKey groups alerts for the same watched domain.
+func pagerDutyDedupKey(domain string) string {
+	return "domain_watcher/" + strings.ToLower(domain)
+}
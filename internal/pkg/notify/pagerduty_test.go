@@ -0,0 +1,200 @@
+package notify
+
+import (
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEntry() *models.CertificateEntry {
+	return &models.CertificateEntry{
+		Domain:     "example.com",
+		Subdomains: []string{"login.example.com"},
+		LeafCert: models.LeafCertificate{
+			Subject:                 models.Subject{CommonName: "login.example.com"},
+			IssuerDistinguishedName: "R3",
+			NotBefore:               time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:                time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Timestamp: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestPagerDutyHandlerPayload(t *testing.T) {
+	var events []pagerDutyEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event pagerDutyEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Invalid event body: %v", err)
+		}
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	handler, err := NewPagerDutyHandler("routing-key", "critical")
+	if err != nil {
+		t.Fatalf("NewPagerDutyHandler() error: %v", err)
+	}
+	handler.eventsURL = server.URL
+
+	first := testEntry()
+	renewal := testEntry()
+	renewal.Domain = "EXAMPLE.com"
+	renewal.LeafCert.Subject.CommonName = "www.example.com"
+	for _, entry := range []*models.CertificateEntry{first, renewal} {
+		if err := handler.Handle(entry); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	event := events[0]
+	if event.RoutingKey != "routing-key" || event.EventAction != "trigger" {
+		t.Errorf("Unexpected routing key/action: %q/%q", event.RoutingKey, event.EventAction)
+	}
+	if event.DedupKey != "domain_watcher/example.com" {
+		t.Errorf("Unexpected dedup_key: %q", event.DedupKey)
+	}
+	if events[1].DedupKey != event.DedupKey {
+		t.Errorf("Expected renewals for the same domain to share a dedup_key, got %q and %q", event.DedupKey, events[1].DedupKey)
+	}
+	if event.Payload.Severity != "critical" || event.Payload.Component != "example.com" {
+		t.Errorf("Unexpected payload: %+v", event.Payload)
+	}
+	if event.Payload.CustomDetails["common_name"] != "login.example.com" {
+		t.Errorf("Expected common_name in custom_details, got %v", event.Payload.CustomDetails)
+	}
+}
+
+func TestPagerDutyHandlerReturnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"status":"invalid event"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	handler, err := NewPagerDutyHandler("routing-key", "warning")
+	if err != nil {
+		t.Fatalf("NewPagerDutyHandler() error: %v", err)
+	}
+	handler.eventsURL = server.URL
+
+	if err := handler.Handle(testEntry()); err == nil {
+		t.Error("Expected an error for a rejected event")
+	}
+}
+
+func TestNewPagerDutyHandlerValidation(t *testing.T) {
+	if _, err := NewPagerDutyHandler("", "critical"); err == nil {
+		t.Error("Expected an error without a routing key")
+	}
+	if _, err := NewPagerDutyHandler("key", "urgent"); err == nil {
+		t.Error("Expected an error for an unknown severity")
+	}
+}
+
+func TestPagerDutyHandlerExpiryAlert(t *testing.T) {
+	var event pagerDutyEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Invalid event body: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	handler, err := NewPagerDutyHandler("routing-key", "warning")
+	if err != nil {
+		t.Fatalf("NewPagerDutyHandler() error: %v", err)
+	}
+	handler.eventsURL = server.URL
+
+	entry := testEntry()
+	entry.Expiry = &models.ExpiryAlert{DaysRemaining: 3, NotAfter: entry.LeafCert.NotAfter}
+	if err := handler.Handle(entry); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+
+	if event.DedupKey != "domain_watcher/example.com/expiry" || event.Payload.Class != "certificate_expiry" {
+		t.Errorf("Expected a distinct expiry alert, got dedup_key %q class %q", event.DedupKey, event.Payload.Class)
+	}
+	if event.Payload.CustomDetails["days_remaining"] != float64(3) {
+		t.Errorf("Expected days_remaining in custom_details, got %v", event.Payload.CustomDetails)
+	}
+}
+
+func TestPagerDutyHandlerAnomalyAlert(t *testing.T) {
+	var event pagerDutyEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Invalid event body: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	handler, _ := NewPagerDutyHandler("routing-key", "warning")
+	handler.eventsURL = server.URL
+
+	entry := &models.CertificateEntry{
+		Domain:    "example.com",
+		Anomaly:   &models.AnomalyAlert{Count: 40, Baseline: 2, WindowStart: time.Now(), Window: time.Hour},
+		Timestamp: time.Now(),
+	}
+	if err := handler.Handle(entry); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+
+	if event.DedupKey != "domain_watcher/example.com/anomaly" || event.Payload.Class != "issuance_anomaly" {
+		t.Errorf("Expected a distinct anomaly alert, got dedup_key %q class %q", event.DedupKey, event.Payload.Class)
+	}
+	if !strings.HasPrefix(event.Payload.Summary, "Issuance spike for example.com: 40 certificates") {
+		t.Errorf("Expected the spike in the summary, got %q", event.Payload.Summary)
+	}
+	if event.Payload.CustomDetails["count"] != float64(40) {
+		t.Errorf("Expected count in custom_details, got %v", event.Payload.CustomDetails)
+	}
+}
+
+func TestPagerDutyHandlerBatchAlertsPerDomain(t *testing.T) {
+	var events []pagerDutyEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event pagerDutyEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	handler, _ := NewPagerDutyHandler("routing-key", "warning")
+	handler.eventsURL = server.URL
+
+	www := testEntry()
+	www.LeafCert.Subject.CommonName = "www.example.com"
+	other := testEntry()
+	other.Domain = "example.org"
+	if err := handler.HandleBatch([]*models.CertificateEntry{testEntry(), other, www}); err != nil {
+		t.Fatalf("HandleBatch() error: %v", err)
+	}
+
+	// One alert summarizing example.com's two certificates, one for example.org
+	if len(events) != 2 {
+		t.Fatalf("Expected one alert per domain, got %d", len(events))
+	}
+	if events[0].DedupKey != "domain_watcher/example.com" ||
+		events[0].Payload.Summary != "2 new certificates for example.com: login.example.com, www.example.com" {
+		t.Errorf("Unexpected digest alert: %q %q", events[0].DedupKey, events[0].Payload.Summary)
+	}
+	if count, _ := events[0].Payload.CustomDetails["count"].(float64); count != 2 {
+		t.Errorf("Expected the count in custom_details, got %v", events[0].Payload.CustomDetails)
+	}
+	if events[1].DedupKey != "domain_watcher/example.org" || events[1].Payload.CustomDetails["common_name"] != "login.example.com" {
+		t.Errorf("Expected a single certificate's own alert, got %+v", events[1])
+	}
+}
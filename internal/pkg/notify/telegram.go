@@ -0,0 +1,314 @@
+package notify
+
+import (
+	"bytes"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTelegramAPIURL = "https://api.telegram.org"
+
+	// telegramMessageLimit is the Bot API's maximum message length.
+	telegramMessageLimit = 4096
+
+	// telegramCoalesceWindow is how long matches are collected before they
+	// are sent together, keeping bursts under Telegram's flood limits.
+	telegramCoalesceWindow = 2 * time.Second
+
+	telegramMaxAttempts = 3
+)
+
+type telegramMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// TelegramHandler sends matched certificates to a Telegram chat through the
+// Bot API. Matches arriving within a short window are coalesced into one
+// message, as is a digest, and 429 responses are retried after the
+// retry_after Telegram asks for.
+type TelegramHandler struct {
+	token      string
+	chatID     string
+	apiURL     string
+	window     time.Duration
+	format     *Format
+	httpClient *http.Client
+
+	mutex   sync.Mutex
+	pending []*models.CertificateEntry
+	timer   *time.Timer
+
+	sendMutex sync.Mutex
+}
+
+// NewTelegramHandler creates a handler sending messages as the bot with
+// token to chatID.
+func NewTelegramHandler(token, chatID string) (*TelegramHandler, error) {
+	if token == "" || chatID == "" {
+		return nil, fmt.Errorf("telegram bot token and chat ID are required")
+	}
+
+	return &TelegramHandler{
+		token:      token,
+		chatID:     chatID,
+		apiURL:     defaultTelegramAPIURL,
+		window:     telegramCoalesceWindow,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// SetFormat sends entries as plain text in format instead of the HTML
+// summary; coalesced entries are separated by blank lines.
+func (h *TelegramHandler) SetFormat(format *Format) {
+	h.format = format
+}
+
+// Handle queues entry; it is sent with any other match arriving within the
+// coalescing window.
+func (h *TelegramHandler) Handle(entry *models.CertificateEntry) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.pending = append(h.pending, entry)
+	if h.timer == nil {
+		h.timer = time.AfterFunc(h.window, h.flush)
+	}
+	return nil
+}
+
+// Send sends entry right away, without waiting for the coalescing window,
+// and returns the outcome that Handle only logs.
+func (h *TelegramHandler) Send(entry *models.CertificateEntry) error {
+	return h.HandleBatch([]*models.CertificateEntry{entry})
+}
+
+// HandleBatch sends entries right away as one summary, split only where
+// it exceeds Telegram's message length, for DigestHandler.
+func (h *TelegramHandler) HandleBatch(entries []*models.CertificateEntry) error {
+	h.sendMutex.Lock()
+	defer h.sendMutex.Unlock()
+	messages, err := h.messages(entries)
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		if err := h.send(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close sends any matches still waiting for the coalescing window.
+func (h *TelegramHandler) Close() error {
+	h.mutex.Lock()
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	h.mutex.Unlock()
+
+	h.flush()
+	return nil
+}
+
+func (h *TelegramHandler) flush() {
+	h.sendMutex.Lock()
+	defer h.sendMutex.Unlock()
+
+	h.mutex.Lock()
+	entries := h.pending
+	h.pending = nil
+	h.timer = nil
+	h.mutex.Unlock()
+
+	if len(entries) == 0 {
+		return
+	}
+	messages, err := h.messages(entries)
+	if err != nil {
+		log.Printf("Telegram delivery failed for %d certificate(s): %v", len(entries), err)
+		return
+	}
+	for _, text := range messages {
+		if err := h.send(text); err != nil {
+			log.Printf("Telegram delivery failed for %d certificate(s): %v", len(entries), err)
+		}
+	}
+}
+
+// messages returns the messages to send for entries.
+func (h *TelegramHandler) messages(entries []*models.CertificateEntry) ([]string, error) {
+	if h.format == nil {
+		return telegramMessages(entries), nil
+	}
+	var messages []string
+	current := ""
+	for _, entry := range entries {
+		text, err := h.format.Render(entry)
+		if err != nil {
+			return nil, err
+		}
+		text = truncate(text, telegramMessageLimit)
+		if current != "" && len(current)+2+len(text) > telegramMessageLimit {
+			messages = append(messages, current)
+			current = ""
+		}
+		if current != "" {
+			current += "\n\n"
+		}
+		current += text
+	}
+	return append(messages, current), nil
+}
+
+func (h *TelegramHandler) send(text string) error {
+	message := telegramMessage{
+		ChatID:                h.chatID,
+		Text:                  text,
+		ParseMode:             "HTML",
+		DisableWebPagePreview: true,
+	}
+	if h.format != nil {
+		message.ParseMode = ""
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telegram message: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", h.apiURL, h.token)
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := h.post(endpoint, data)
+		if err == nil {
+			return nil
+		}
+		if retryAfter <= 0 || attempt == telegramMaxAttempts {
+			return err
+		}
+
+		log.Printf("Telegram rate limited, retrying in %v", retryAfter)
+		time.Sleep(retryAfter)
+	}
+}
+
+// post makes one sendMessage call, returning how long to wait before a retry
+// when Telegram rate limited it.
+func (h *TelegramHandler) post(endpoint string, data []byte) (time.Duration, error) {
+	resp, err := h.httpClient.Post(endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		// Drop the URL from the error, it holds the bot token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, fmt.Errorf("failed to reach telegram: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode == http.StatusOK {
+		return 0, nil
+	}
+
+	var result telegramResponse
+	json.Unmarshal(body, &result)
+	err = fmt.Errorf("telegram returned %s: %s", resp.Status, result.Description)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter := time.Duration(result.Parameters.RetryAfter) * time.Second
+		if retryAfter <= 0 {
+			retryAfter = time.Second
+		}
+		return retryAfter, err
+	}
+	return 0, err
+}
+
+// telegramMessages formats entries as HTML messages, one line per
+// certificate, split to stay within the message length limit.
+func telegramMessages(entries []*models.CertificateEntry) []string {
+	if len(entries) == 1 {
+		return []string{telegramEntryText(entries[0])}
+	}
+
+	var messages []string
+	header := fmt.Sprintf("🔐 <b>%d new certificates</b>\n", len(entries))
+	current := header
+	for _, entry := range entries {
+		line := "\n" + telegramEntryLine(entry)
+		if len(current)+len(line) > telegramMessageLimit {
+			messages = append(messages, current)
+			current = header
+		}
+		current += line
+	}
+	return append(messages, current)
+}
+
+func telegramEntryText(entry *models.CertificateEntry) string {
+	if entry.Anomaly != nil {
+		return "📈 <b>" + html.EscapeString(anomalySummary(entry)) + "</b>"
+	}
+
+	leaf := entry.LeafCert
+	title := "New certificate for " + entry.Domain
+	if entry.Expiry != nil {
+		title = fmt.Sprintf("Certificate for %s expires in %d days", entry.Domain, entry.Expiry.DaysRemaining)
+	}
+
+	names := strings.Join(entry.Subdomains, ", ")
+	if len(names) > 1000 {
+		names = truncate(names, 1000)
+	}
+
+	if entry.Wildcard {
+		title += " (wildcard)"
+	}
+
+	return fmt.Sprintf("🔐 <b>%s</b>\nCN: <code>%s</code>\nIssuer: %s\nValid: %s → %s\nNames (%d): %s",
+		html.EscapeString(title),
+		html.EscapeString(leaf.Subject.CommonName),
+		html.EscapeString(leaf.IssuerDistinguishedName),
+		leaf.NotBefore.UTC().Format("2006-01-02"),
+		leaf.NotAfter.UTC().Format("2006-01-02"),
+		len(entry.Subdomains),
+		html.EscapeString(names),
+	)
+}
+
+func telegramEntryLine(entry *models.CertificateEntry) string {
+	if entry.Anomaly != nil {
+		return "• 📈 " + html.EscapeString(anomalySummary(entry))
+	}
+	line := fmt.Sprintf("• <b>%s</b>: <code>%s</code> (%s, until %s)",
+		html.EscapeString(entry.Domain),
+		html.EscapeString(entry.LeafCert.Subject.CommonName),
+		html.EscapeString(entry.LeafCert.IssuerDistinguishedName),
+		entry.LeafCert.NotAfter.UTC().Format("2006-01-02"),
+	)
+	if entry.Expiry != nil {
+		line += fmt.Sprintf(" ⚠️ expires in %d days", entry.Expiry.DaysRemaining)
+	}
+	return line
+}
@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTelegramHandlerCoalescesBurst(t *testing.T) {
+	var mutex sync.Mutex
+	var messages []telegramMessage
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if r.URL.Path != "/botsecret-token/sendMessage" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"ok": false, "error_code": 429, "description": "Too Many Requests: retry after 1", "parameters": {"retry_after": 1}}`))
+			return
+		}
+
+		var message telegramMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("Invalid sendMessage body: %v", err)
+		}
+		messages = append(messages, message)
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	handler, err := NewTelegramHandler("secret-token", "-100123")
+	if err != nil {
+		t.Fatalf("NewTelegramHandler() error: %v", err)
+	}
+	handler.apiURL = server.URL
+	handler.window = 50 * time.Millisecond
+
+	for i := 0; i < 3; i++ {
+		entry := testEntry()
+		entry.LeafCert.Subject.CommonName = fmt.Sprintf("host-%d.example.com", i)
+		if err := handler.Handle(entry); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+
+	// The burst goes out as one message once the window has passed
+	time.Sleep(200 * time.Millisecond)
+	handler.Close()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if requests != 2 || len(messages) != 1 {
+		t.Fatalf("Expected one message after a rate limited attempt, got %d requests and %d messages", requests, len(messages))
+	}
+	message := messages[0]
+	if message.ChatID != "-100123" || message.ParseMode != "HTML" {
+		t.Errorf("Unexpected message settings: %+v", message)
+	}
+	if !strings.Contains(message.Text, "3 new certificates") || strings.Count(message.Text, "•") != 3 {
+		t.Errorf("Expected the 3 matches in one message, got %q", message.Text)
+	}
+}
+
+func TestTelegramHandlerFlushesOnClose(t *testing.T) {
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message telegramMessage
+		json.NewDecoder(r.Body).Decode(&message)
+		texts = append(texts, message.Text)
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	handler, err := NewTelegramHandler("token", "42")
+	if err != nil {
+		t.Fatalf("NewTelegramHandler() error: %v", err)
+	}
+	handler.apiURL = server.URL
+	handler.window = time.Hour
+
+	entry := testEntry()
+	entry.LeafCert.IssuerDistinguishedName = "<Evil & Co>"
+	handler.Handle(entry)
+	handler.Close()
+
+	if len(texts) != 1 {
+		t.Fatalf("Expected Close to send the pending match, got %d messages", len(texts))
+	}
+	if !strings.Contains(texts[0], "New certificate for example.com") || !strings.Contains(texts[0], "&lt;Evil &amp; Co&gt;") {
+		t.Errorf("Unexpected message text: %q", texts[0])
+	}
+}
+
+func TestTelegramMessagesSplitAtLimit(t *testing.T) {
+	var entries []*models.CertificateEntry
+	for i := 0; i < 100; i++ {
+		entry := testEntry()
+		entry.LeafCert.Subject.CommonName = fmt.Sprintf("%s-%d.example.com", strings.Repeat("a", 40), i)
+		entries = append(entries, entry)
+	}
+
+	messages := telegramMessages(entries)
+	if len(messages) < 2 {
+		t.Fatalf("Expected the matches to be split over several messages, got %d", len(messages))
+	}
+	lines := 0
+	for _, message := range messages {
+		if len(message) > telegramMessageLimit {
+			t.Errorf("Message of %d bytes is over the limit", len(message))
+		}
+		lines += strings.Count(message, "•")
+	}
+	if lines != 100 {
+		t.Errorf("Expected all 100 matches across messages, got %d", lines)
+	}
+}
+
+func TestTelegramSendDeliversRightAway(t *testing.T) {
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/botrevoked/sendMessage" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"ok": false, "error_code": 401, "description": "Unauthorized"}`))
+			return
+		}
+		var message telegramMessage
+		json.NewDecoder(r.Body).Decode(&message)
+		texts = append(texts, message.Text)
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	handler, err := NewTelegramHandler("token", "42")
+	if err != nil {
+		t.Fatalf("NewTelegramHandler() error: %v", err)
+	}
+	handler.apiURL = server.URL
+	handler.window = time.Hour
+	defer handler.Close()
+
+	// Send skips the coalescing window and reports the outcome
+	if err := handler.Send(testEntry()); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	if len(texts) != 1 || !strings.Contains(texts[0], "New certificate for example.com") {
+		t.Errorf("Expected the match to be sent before Close, got %q", texts)
+	}
+
+	revoked, err := NewTelegramHandler("revoked", "42")
+	if err != nil {
+		t.Fatalf("NewTelegramHandler() error: %v", err)
+	}
+	revoked.apiURL = server.URL
+	defer revoked.Close()
+	if err := revoked.Send(testEntry()); err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("Expected Send to report the rejection, got %v", err)
+	}
+}
+
+func TestTelegramHandlerBatchSendsOneMessage(t *testing.T) {
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message telegramMessage
+		json.NewDecoder(r.Body).Decode(&message)
+		texts = append(texts, message.Text)
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	handler, _ := NewTelegramHandler("token", "42")
+	handler.apiURL = server.URL
+	defer handler.Close()
+
+	format, _ := ParseFormat("{{.LeafCert.Subject.CommonName}}")
+	for _, f := range []*Format{nil, format} {
+		texts = nil
+		handler.SetFormat(f)
+		if err := handler.HandleBatch([]*models.CertificateEntry{testEntry(), testEntry()}); err != nil {
+			t.Fatalf("HandleBatch() error: %v", err)
+		}
+		if len(texts) != 1 {
+			t.Fatalf("Expected one message for the digest, got %q", texts)
+		}
+	}
+	if texts[0] != "login.example.com\n\nlogin.example.com" {
+		t.Errorf("Expected formatted entries separated by a blank line, got %q", texts[0])
+	}
+}
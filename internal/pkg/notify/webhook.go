@@ -0,0 +1,217 @@
+package notify
+
+import (
+	"bytes"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	webhookMaxAttempts = 3
+
+	// webhookQueueSize bounds deliveries waiting to be sent; beyond it new
+	// ones are dropped rather than blocking the monitor.
+	webhookQueueSize = 1000
+)
+
+// WebhookHandler POSTs each matched certificate entry as JSON to a
+// user-supplied URL. Entries are queued and sent in order by a single
+// worker, so a slow or failing endpoint never holds up the monitor. A
+// digest is posted as one JSON array of entries. Server errors and
+// transport failures are retried with exponential backoff; delivery
+// failures are logged.
+type WebhookHandler struct {
+	url           string
+	authorization string
+	format        *Format
+	backoff       time.Duration
+	httpClient    *http.Client
+	queue         chan []*models.CertificateEntry
+	done          chan struct{}
+	mutex         sync.Mutex // guards queue against sends after Close
+	closed        bool
+}
+
+// NewWebhookHandler creates a handler posting to url, with timeout bounding
+// each attempt.
+func NewWebhookHandler(url string, timeout time.Duration) (*WebhookHandler, error) {
+	return NewWebhookHandlerWithAuth(url, timeout, "")
+}
+
+// NewWebhookHandlerWithAuth creates a webhook handler that sends
+// authorization as the Authorization header of every request, and starts
+// its delivery worker. Close sends what is still queued.
+func NewWebhookHandlerWithAuth(url string, timeout time.Duration, authorization string) (*WebhookHandler, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("webhook URL must be http(s): %q", url)
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	h := &WebhookHandler{
+		url:           url,
+		authorization: authorization,
+		backoff:       time.Second,
+		httpClient:    &http.Client{Timeout: timeout},
+		queue:         make(chan []*models.CertificateEntry, webhookQueueSize),
+		done:          make(chan struct{}),
+	}
+	go h.run()
+	return h, nil
+}
+
+// SetFormat replaces the JSON entry posted with format. A template
+// rendering valid JSON, e.g. a chat service payload, is sent as JSON and
+// anything else as plain text.
+func (h *WebhookHandler) SetFormat(format *Format) {
+	h.format = format
+}
+
+// Handle queues entry for delivery. It fails once the handler is closed.
+func (h *WebhookHandler) Handle(entry *models.CertificateEntry) error {
+	return h.enqueue([]*models.CertificateEntry{entry}, entry.Domain)
+}
+
+// HandleBatch queues entries for delivery as one request, for
+// DigestHandler.
+func (h *WebhookHandler) HandleBatch(entries []*models.CertificateEntry) error {
+	return h.enqueue(entries, fmt.Sprintf("a digest of %d certificates", len(entries)))
+}
+
+func (h *WebhookHandler) enqueue(entries []*models.CertificateEntry, what string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		return fmt.Errorf("webhook handler closed, dropping %s", what)
+	}
+	select {
+	case h.queue <- entries:
+		return nil
+	default:
+		return fmt.Errorf("webhook queue full, dropping %s", what)
+	}
+}
+
+// Send posts entry right away, bypassing the queue, and returns the outcome
+// that Handle only logs.
+func (h *WebhookHandler) Send(entry *models.CertificateEntry) error {
+	return h.send([]*models.CertificateEntry{entry})
+}
+
+// Close stops accepting entries and waits for the queued ones to be sent.
+func (h *WebhookHandler) Close() error {
+	h.mutex.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mutex.Unlock()
+	<-h.done
+	return nil
+}
+
+func (h *WebhookHandler) run() {
+	defer close(h.done)
+	for entries := range h.queue {
+		if err := h.send(entries); err != nil {
+			if len(entries) == 1 {
+				log.Printf("Webhook delivery failed for %s: %v", entries[0].Domain, err)
+			} else {
+				log.Printf("Webhook delivery failed for a digest of %d certificates: %v", len(entries), err)
+			}
+		}
+	}
+}
+
+// send posts one entry as a JSON object, or several as a JSON array.
+func (h *WebhookHandler) send(entries []*models.CertificateEntry) error {
+	var payload interface{} = entries
+	if len(entries) == 1 {
+		payload = entries[0]
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	contentType := "application/json"
+	if h.format != nil {
+		data, err = h.render(entries)
+		if err != nil {
+			return err
+		}
+		if !json.Valid(data) {
+			contentType = "text/plain; charset=utf-8"
+		}
+	}
+
+	delay := h.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := h.post(data, contentType)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt == webhookMaxAttempts {
+			return fmt.Errorf("webhook delivery failed after %d attempt(s): %w", attempt, err)
+		}
+
+		log.Printf("Webhook attempt %d failed, retrying in %v: %v", attempt, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// render returns entries in the handler's format: a JSON array for json,
+// otherwise one rendered template per line.
+func (h *WebhookHandler) render(entries []*models.CertificateEntry) ([]byte, error) {
+	if h.format.json && len(entries) > 1 {
+		data, err := json.Marshal(entries)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+		}
+		return data, nil
+	}
+	texts := make([]string, len(entries))
+	for i, entry := range entries {
+		text, err := h.format.Render(entry)
+		if err != nil {
+			return nil, err
+		}
+		texts[i] = text
+	}
+	return []byte(strings.Join(texts, "\n")), nil
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (h *WebhookHandler) post(data []byte, contentType string) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "domain_watcher")
+	if h.authorization != "" {
+		req.Header.Set("Authorization", h.authorization)
+	}
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return resp.StatusCode >= 500, fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
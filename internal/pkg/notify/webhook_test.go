@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookHandlerPostsEntry(t *testing.T) {
+	var received models.CertificateEntry
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Invalid webhook body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	handler, err := NewWebhookHandlerWithAuth(server.URL, time.Second, "Bearer secret")
+	if err != nil {
+		t.Fatalf("NewWebhookHandlerWithAuth() error: %v", err)
+	}
+	if err := handler.Send(testEntry()); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+
+	if authorization != "Bearer secret" {
+		t.Errorf("Expected Authorization header, got %q", authorization)
+	}
+	if received.Domain != "example.com" || received.LeafCert.Subject.CommonName != "login.example.com" {
+		t.Errorf("Unexpected payload: %+v", received)
+	}
+}
+
+func TestWebhookHandlerRetries(t *testing.T) {
+	tests := []struct {
+		status   int
+		attempts int
+		ok       bool
+	}{
+		{http.StatusServiceUnavailable, 3, false},
+		{http.StatusBadRequest, 1, false},
+	}
+	for _, tt := range tests {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(tt.status)
+		}))
+
+		handler, err := NewWebhookHandler(server.URL, time.Second)
+		if err != nil {
+			t.Fatalf("NewWebhookHandler() error: %v", err)
+		}
+		handler.backoff = time.Millisecond
+
+		err = handler.Send(testEntry())
+		server.Close()
+		if (err == nil) != tt.ok {
+			t.Errorf("status %d: expected ok=%v, got error %v", tt.status, tt.ok, err)
+		}
+		if attempts != tt.attempts {
+			t.Errorf("status %d: expected %d attempts, got %d", tt.status, tt.attempts, attempts)
+		}
+	}
+}
+
+func TestWebhookHandlerRecoversAfterServerError(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	handler, err := NewWebhookHandler(server.URL, time.Second)
+	if err != nil {
+		t.Fatalf("NewWebhookHandler() error: %v", err)
+	}
+	handler.backoff = time.Millisecond
+
+	if err := handler.Send(testEntry()); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestWebhookHandlerDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	received := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		mutex.Lock()
+		received++
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	handler, err := NewWebhookHandler(server.URL, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewWebhookHandler() error: %v", err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := handler.Handle(testEntry()); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected Handle not to wait for the endpoint, took %v", elapsed)
+	}
+
+	close(release)
+	handler.Close()
+	mutex.Lock()
+	defer mutex.Unlock()
+	if received != 3 {
+		t.Errorf("Expected Close to deliver the 3 queued entries, got %d", received)
+	}
+}
+
+func TestQueuedHandlersRejectEntriesAfterClose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	webhook, _ := NewWebhookHandler(server.URL, time.Second)
+	discord, _ := NewDiscordHandler(server.URL)
+	for _, handler := range []interface {
+		Handle(*models.CertificateEntry) error
+		Close() error
+	}{webhook, discord} {
+		// A delivery racing shutdown gets an error instead of a panic
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				handler.Handle(testEntry())
+			}()
+		}
+		handler.Close()
+		wg.Wait()
+
+		if err := handler.Handle(testEntry()); err == nil {
+			t.Errorf("Expected %T to reject an entry after Close", handler)
+		}
+		handler.Close()
+	}
+}
+
+func TestWebhookHandlerBatchPostsOnce(t *testing.T) {
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, body)
+	}))
+	defer server.Close()
+
+	handler, _ := NewWebhookHandler(server.URL, time.Second)
+	if err := handler.HandleBatch([]*models.CertificateEntry{testEntry(), testEntry(), testEntry()}); err != nil {
+		t.Fatalf("HandleBatch() error: %v", err)
+	}
+	handler.Close()
+
+	var entries []models.CertificateEntry
+	if len(bodies) != 1 {
+		t.Fatalf("Expected the digest in one request, got %d", len(bodies))
+	}
+	if err := json.Unmarshal(bodies[0], &entries); err != nil || len(entries) != 3 {
+		t.Errorf("Expected a JSON array of 3 entries, got %q (%v)", bodies[0], err)
+	}
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CompactResult summarizes what Compact removed.
+type CompactResult struct {
+	EntriesRemoved int `json:"entries_removed"`
+	FilesRemoved   int `json:"files_removed"`
+	FilesRewritten int `json:"files_rewritten"`
+}
+
+// Compact removes entries older than cutoff from the store at path, which
+// may be any output this package writes (see ReadEntries):
+//
+//   - per-entry .json files are deleted when their entry is too old;
+//   - JSON lines files are rewritten without old entries, keeping any line
+//     that isn't an entry;
+//   - rotated .jsonl.gz files listed in a manifest are deleted, along with
+//     their manifest record, once they were closed before cutoff.
+//
+// Rotated directories are safe to compact while a monitor writes to them.
+// A JSON lines file may lose entries appended while it is being rewritten.
+func Compact(path string, cutoff time.Time) (CompactResult, error) {
+	var result CompactResult
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return result, fmt.Errorf("failed to open store %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return result, compactFile(path, cutoff, &result)
+	}
+
+	// Directories holding rotated files are compacted through their manifests
+	rotated := make(map[string]bool)
+	err = filepath.WalkDir(path, func(filePath string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != manifestName {
+			return nil
+		}
+		return compactManifest(filepath.Dir(filePath), cutoff, &result, rotated)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	err = filepath.WalkDir(path, func(filePath string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() == manifestName || strings.HasSuffix(d.Name(), ".tmp") || rotated[filePath] {
+			return nil
+		}
+		return compactFile(filePath, cutoff, &result)
+	})
+	return result, err
+}
+
+// compactManifest deletes rotated files closed before cutoff and rewrites the
+// manifest without them. Every file the manifest lists is added to rotated.
+func compactManifest(dir string, cutoff time.Time, result *CompactResult, rotated map[string]bool) error {
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	kept := manifest.Files[:0]
+	for _, file := range manifest.Files {
+		filePath := filepath.Join(dir, file.Name)
+		rotated[filePath] = true
+		if !file.ClosedAt.Before(cutoff) {
+			kept = append(kept, file)
+			continue
+		}
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", filePath, err)
+		}
+		result.FilesRemoved++
+		result.EntriesRemoved += file.Entries
+	}
+
+	if len(kept) == len(manifest.Files) {
+		return nil
+	}
+	manifest.Files = kept
+	return writeManifest(dir, manifest)
+}
+
+func compactFile(path string, cutoff time.Time, result *CompactResult) error {
+	name := strings.ToLower(filepath.Base(path))
+	gzipped := strings.HasSuffix(name, ".gz")
+
+	switch filepath.Ext(strings.TrimSuffix(name, ".gz")) {
+	case ".json":
+		if gzipped {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		var entry models.CertificateEntry
+		if err := json.Unmarshal(data, &entry); err != nil || entry.Domain == "" || !entry.Timestamp.Before(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		result.FilesRemoved++
+		result.EntriesRemoved++
+		return nil
+	case ".jsonl", ".ndjson", ".log", ".txt":
+		return compactLines(path, gzipped, cutoff, result)
+	}
+	return nil
+}
+
+// compactLines rewrites a JSON lines file (optionally gzipped) without the
+// entries older than cutoff. The file is replaced atomically and left
+// untouched when nothing needs removing.
+func compactLines(path string, gzipped bool, cutoff time.Time, result *CompactResult) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if gzipped {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var kept bytes.Buffer
+	removed := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		data := line
+		if i := bytes.IndexByte(data, '{'); i > 0 {
+			data = data[i:]
+		}
+
+		var entry models.CertificateEntry
+		if err := json.Unmarshal(data, &entry); err == nil && entry.Domain != "" && entry.Timestamp.Before(cutoff) {
+			removed++
+			continue
+		}
+		kept.Write(line)
+		kept.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	file.Close()
+	if removed == 0 {
+		return nil
+	}
+
+	out := kept.Bytes()
+	if gzipped {
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		gz.Write(out)
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to compress %s: %w", path, err)
+		}
+		out = compressed.Bytes()
+	}
+
+	if err := os.WriteFile(path+".tmp", out, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	result.FilesRewritten++
+	result.EntriesRemoved += removed
+	return nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"domain_watcher/pkg/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompactPrunesOldEntries(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	cutoff := now.Add(-30 * 24 * time.Hour)
+
+	entryAt := func(domain string, ts time.Time) *models.CertificateEntry {
+		entry := testEntry()
+		entry.Domain = domain
+		entry.Timestamp = ts
+		return entry
+	}
+	old := now.Add(-60 * 24 * time.Hour)
+	recent := now.Add(-time.Hour)
+
+	// Per-entry files
+	perEntry := NewFileHandler(filepath.Join(dir, "entries")+"/", "json")
+	for _, entry := range []*models.CertificateEntry{entryAt("old.example.com", old), entryAt("new.example.com", recent)} {
+		if err := perEntry.Handle(entry); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+
+	// JSON lines file, with a line that isn't an entry
+	linesPath := filepath.Join(dir, "certs.jsonl")
+	lines := NewFileHandler(linesPath, "json")
+	for _, entry := range []*models.CertificateEntry{entryAt("old.example.com", old), entryAt("new.example.com", recent)} {
+		if err := lines.Handle(entry); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+	f, _ := os.OpenFile(linesPath, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("not an entry\n")
+	f.Close()
+
+	// Rotated files: one closed before the cutoff, one still recent
+	rotatedDir := filepath.Join(dir, "rotated")
+	rotating, err := NewRotatingNDJSONHandler(rotatedDir, 0, 1)
+	if err != nil {
+		t.Fatalf("NewRotatingNDJSONHandler() error: %v", err)
+	}
+	rotating.Handle(entryAt("old.example.com", old))
+	rotating.Handle(entryAt("new.example.com", recent))
+	manifest, _ := readManifest(rotatedDir)
+	if len(manifest.Files) != 2 {
+		t.Fatalf("Expected 2 rotated files, got %d", len(manifest.Files))
+	}
+	manifest.Files[0].ClosedAt = old
+	if err := writeManifest(rotatedDir, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Compact(dir, cutoff)
+	if err != nil {
+		t.Fatalf("Compact() error: %v", err)
+	}
+	if result.EntriesRemoved != 3 || result.FilesRemoved != 2 || result.FilesRewritten != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	var domains []string
+	if err := ReadEntries(dir, func(entry *models.CertificateEntry) error {
+		domains = append(domains, entry.Domain)
+		return nil
+	}); err != nil {
+		t.Fatalf("ReadEntries() error: %v", err)
+	}
+	if len(domains) != 3 {
+		t.Fatalf("Expected the 3 recent entries to remain, got %v", domains)
+	}
+	for _, domain := range domains {
+		if domain != "new.example.com" {
+			t.Errorf("Old entry survived compaction: %s", domain)
+		}
+	}
+
+	data, _ := os.ReadFile(linesPath)
+	if want := "not an entry\n"; len(data) < len(want) || string(data[len(data)-len(want):]) != want {
+		t.Error("Expected non-entry lines to be kept")
+	}
+	manifest, _ = readManifest(rotatedDir)
+	if len(manifest.Files) != 1 {
+		t.Errorf("Expected 1 file left in the manifest, got %d", len(manifest.Files))
+	}
+
+	// A second pass has nothing left to do
+	result, err = Compact(dir, cutoff)
+	if err != nil || result != (CompactResult{}) {
+		t.Errorf("Expected an idempotent second pass, got %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"domain_watcher/pkg/models"
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+)
+
+// EntryColumns is the header row of csv and tsv entry output.
+var EntryColumns = []string{
+	"timestamp", "domain", "subject_cn", "issuer", "not_before", "not_after",
+	"serial_number", "fingerprint", "wildcard", "subdomains",
+}
+
+// IsDelimitedFormat reports whether format is csv or tsv.
+func IsDelimitedFormat(format string) bool {
+	return format == "csv" || format == "tsv"
+}
+
+// NewDelimitedWriter returns a writer of comma-separated records for csv, or
+// tab-separated ones for tsv. Fields containing the separator, a quote or a
+// line break are quoted, so subjects with commas or tabs keep their columns.
+func NewDelimitedWriter(w io.Writer, format string) *csv.Writer {
+	writer := csv.NewWriter(w)
+	if format == "tsv" {
+		writer.Comma = '\t'
+	}
+	return writer
+}
+
+// EntryRecord returns the fields of entry in EntryColumns order. Subdomains
+// are joined with semicolons.
+func EntryRecord(entry *models.CertificateEntry) []string {
+	wildcard := "false"
+	if entry.Wildcard {
+		wildcard = "true"
+	}
+	return []string{
+		entry.Timestamp.Format(time.RFC3339),
+		entry.Domain,
+		entry.LeafCert.Subject.CommonName,
+		entry.LeafCert.IssuerDistinguishedName,
+		entry.LeafCert.NotBefore.Format(time.RFC3339),
+		entry.LeafCert.NotAfter.Format(time.RFC3339),
+		entry.LeafCert.SerialNumber,
+		entry.LeafCert.Fingerprint,
+		wildcard,
+		strings.Join(entry.Subdomains, ";"),
+	}
+}
+
+// writeDelimited writes entry as one csv or tsv record, preceded by the
+// header row if header is set.
+func writeDelimited(w io.Writer, format string, entry *models.CertificateEntry, header bool) error {
+	writer := NewDelimitedWriter(w, format)
+	if header {
+		writer.Write(EntryColumns)
+	}
+	writer.Write(EntryRecord(entry))
+	writer.Flush()
+	return writer.Error()
+}
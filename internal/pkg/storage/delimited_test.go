@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileHandlerTSVStdout(t *testing.T) {
+	var stdout bytes.Buffer
+	handler := NewStdoutHandler("tsv")
+	handler.stdout = &stdout
+
+	entry := testEntry()
+	entry.LeafCert.IssuerDistinguishedName = "CN=R3, O=Let's Encrypt\tC=US"
+	for i := 0; i < 2; i++ {
+		if err := handler.Handle(entry); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+
+	// The header is written once, and the tab in the issuer is quoted
+	reader := csv.NewReader(&stdout)
+	reader.Comma = '\t'
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read TSV output: %v", err)
+	}
+	if len(records) != 3 || strings.Join(records[0], ",") != strings.Join(EntryColumns, ",") {
+		t.Fatalf("Expected a header and 2 records, got %q", records)
+	}
+	for _, record := range records[1:] {
+		if record[3] != entry.LeafCert.IssuerDistinguishedName || record[9] != "example.com;www.example.com" {
+			t.Errorf("Unexpected record %q", record)
+		}
+	}
+}
+
+func TestFileHandlerAppendsCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "certs.csv")
+	for i := 0; i < 2; i++ {
+		// A new handler, as after a restart, still writes the header once
+		if err := NewFileHandler(path, "csv").Handle(testEntry()); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV output: %v", err)
+	}
+	if len(records) != 3 || records[0][0] != "timestamp" || records[1][1] != "example.com" {
+		t.Errorf("Expected a header and 2 records, got %q", records)
+	}
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSpaceCheckInterval bounds how often Handle re-checks free space.
+const defaultSpaceCheckInterval = 30 * time.Second
+
+// SpaceChecker reports the number of bytes available to unprivileged users
+// on the filesystem holding path.
+type SpaceChecker func(path string) (uint64, error)
+
+// SetMinFreeSpace pauses file output while the filesystem holding the output
+// path has fewer than minFree bytes available. Entries arriving while paused
+// are dropped and counted; output resumes once space recovers. Zero disables
+// the guard.
+func (h *FileHandler) SetMinFreeSpace(minFree uint64) {
+	h.spaceMutex.Lock()
+	defer h.spaceMutex.Unlock()
+	h.minFree = minFree
+	h.lastCheck = time.Time{}
+}
+
+// Verify checks the output format, that the output path is writable and,
+// when a free-space threshold is set, that enough space is available. It is
+// meant to be called once at startup so misconfiguration fails fast instead
+// of per entry.
+func (h *FileHandler) Verify() error {
+	if err := h.checkFormat(); err != nil {
+		return err
+	}
+	if h.outputPath == "" {
+		return nil
+	}
+
+	isDir, err := OutputPathIsDir(h.outputPath)
+	if err != nil {
+		return err
+	}
+	dir := h.outputPath
+	if !isDir {
+		dir = filepath.Dir(h.outputPath)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	probe, err := os.CreateTemp(dir, ".domain_watcher-write-check-*")
+	if err != nil {
+		return fmt.Errorf("output path %s is not writable: %w", h.outputPath, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	h.spaceMutex.Lock()
+	defer h.spaceMutex.Unlock()
+	if h.minFree == 0 {
+		return nil
+	}
+	free, err := h.freeSpace(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check free space on %s: %w", dir, err)
+	}
+	h.lastCheck = time.Now()
+	if free < h.minFree {
+		h.paused = true
+		log.Printf("Warning: only %s free on %s (minimum %s), file output paused until space recovers",
+			formatByteSize(free), dir, formatByteSize(h.minFree))
+	}
+	return nil
+}
+
+// outputPaused re-checks free space when the check interval has elapsed and
+// reports whether the entry should be dropped.
+func (h *FileHandler) outputPaused() bool {
+	h.spaceMutex.Lock()
+	defer h.spaceMutex.Unlock()
+
+	if h.minFree == 0 {
+		return false
+	}
+	if !h.lastCheck.IsZero() && time.Since(h.lastCheck) < h.checkInterval {
+		if h.paused {
+			h.dropped++
+		}
+		return h.paused
+	}
+	h.lastCheck = time.Now()
+
+	free, err := h.freeSpace(existingParent(h.outputPath))
+	if err != nil {
+		// Don't stop writing because the check itself failed
+		log.Printf("Warning: failed to check free space on %s: %v", h.outputPath, err)
+		return false
+	}
+
+	switch {
+	case free < h.minFree && !h.paused:
+		h.paused = true
+		log.Printf("Warning: only %s free on %s (minimum %s), pausing file output",
+			formatByteSize(free), h.outputPath, formatByteSize(h.minFree))
+	case free >= h.minFree && h.paused:
+		h.paused = false
+		log.Printf("Free space on %s recovered (%s), resuming file output after dropping %d entries",
+			h.outputPath, formatByteSize(free), h.dropped)
+		h.dropped = 0
+	}
+	if h.paused {
+		h.dropped++
+	}
+	return h.paused
+}
+
+// existingParent walks up from path to the nearest directory that exists, so
+// free space can be checked before the output path has been created.
+func existingParent(path string) string {
+	dir := filepath.Clean(path)
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// ParseByteSize parses sizes such as "512MB", "1GB" or "1048576". Units are
+// binary (1KB = 1024 bytes) and case-insensitive; a trailing "iB" is accepted.
+func ParseByteSize(s string) (uint64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.TrimSuffix(value, "IB")
+	value = strings.TrimSuffix(value, "B")
+
+	multiplier := uint64(1)
+	if n := len(value); n > 0 {
+		switch value[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			value = value[:n-1]
+		}
+	}
+
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return uint64(number * float64(multiplier)), nil
+}
+
+func formatByteSize(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
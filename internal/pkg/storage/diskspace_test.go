@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileHandlerPausesBelowMinFreeSpace(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "certs.jsonl")
+
+	free := uint64(10 << 30)
+	handler := NewFileHandler(outputPath, "json")
+	handler.freeSpace = func(string) (uint64, error) { return free, nil }
+	handler.checkInterval = 0
+	handler.SetMinFreeSpace(1 << 30)
+
+	if err := handler.Verify(); err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+
+	countLines := func() int {
+		data, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatalf("Failed to read output: %v", err)
+		}
+		lines := 0
+		for _, b := range data {
+			if b == '\n' {
+				lines++
+			}
+		}
+		return lines
+	}
+
+	if err := handler.Handle(testEntry()); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+	if got := countLines(); got != 1 {
+		t.Fatalf("Expected 1 line with enough space, got %d", got)
+	}
+
+	free = 512 << 20
+	for i := 0; i < 3; i++ {
+		if err := handler.Handle(testEntry()); err != nil {
+			t.Fatalf("Handle() while paused error: %v", err)
+		}
+	}
+	if got := countLines(); got != 1 {
+		t.Errorf("Expected output to pause below threshold, got %d lines", got)
+	}
+	if handler.dropped != 3 {
+		t.Errorf("Expected 3 dropped entries, got %d", handler.dropped)
+	}
+
+	free = 2 << 30
+	if err := handler.Handle(testEntry()); err != nil {
+		t.Fatalf("Handle() after recovery error: %v", err)
+	}
+	if got := countLines(); got != 2 {
+		t.Errorf("Expected output to resume once space recovered, got %d lines", got)
+	}
+}
+
+func TestFileHandlerVerifyUnwritablePath(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "not-a-dir")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewFileHandler(filepath.Join(blocker, "certs")+"/", "json")
+	if err := handler.Verify(); err == nil {
+		t.Error("Expected Verify() to fail when the output directory cannot be created")
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]uint64{
+		"1GB":     1 << 30,
+		"512mb":   512 << 20,
+		"1.5GiB":  3 << 29,
+		"2K":      2048,
+		"1048576": 1 << 20,
+	}
+	for input, want := range tests {
+		got, err := ParseByteSize(input)
+		if err != nil {
+			t.Errorf("ParseByteSize(%q) error: %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseByteSize(%q) = %d, want %d", input, got, want)
+		}
+	}
+
+	if _, err := ParseByteSize("lots"); err == nil {
+		t.Error("Expected an error for an invalid size")
+	}
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"bufio"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeElastic records index creation and the documents of each _bulk
+// request, rejecting documents whose domain is "rejected.example".
+type fakeElastic struct {
+	mutex   sync.Mutex
+	mapping string
+	bulks   [][]map[string]interface{}
+}
+
+func (f *fakeElastic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch {
+	case r.Method == http.MethodPut && r.URL.Path == "/certs":
+		var body strings.Builder
+		bufio.NewReader(r.Body).WriteTo(&body)
+		f.mapping = body.String()
+		w.Write([]byte(`{"acknowledged": true}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+		var docs []map[string]interface{}
+		var items []string
+		errors := false
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var action map[string]map[string]string
+			json.Unmarshal(scanner.Bytes(), &action)
+			scanner.Scan()
+			var doc map[string]interface{}
+			json.Unmarshal(scanner.Bytes(), &doc)
+			docs = append(docs, doc)
+
+			if doc["domain"] == "rejected.example" {
+				errors = true
+				items = append(items, `{"index": {"_id": "`+action["index"]["_id"]+`", "status": 400, "error": {"type": "mapper_parsing_exception", "reason": "bad"}}}`)
+			} else {
+				items = append(items, `{"index": {"_id": "`+action["index"]["_id"]+`", "status": 201}}`)
+			}
+		}
+		f.bulks = append(f.bulks, docs)
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": errors, "items": json.RawMessage("[" + strings.Join(items, ",") + "]")})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// documents returns how many documents were sent in _bulk requests.
+func (f *fakeElastic) documents() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	n := 0
+	for _, docs := range f.bulks {
+		n += len(docs)
+	}
+	return n
+}
+
+// waitFor polls condition for up to 5 seconds.
+func waitFor(condition func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return condition()
+}
+
+func TestElasticHandlerBulkIndexes(t *testing.T) {
+	fake := &fakeElastic{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	handler, err := NewElasticHandler(server.URL, "certs", 2)
+	if err != nil {
+		t.Fatalf("NewElasticHandler() error: %v", err)
+	}
+	if !strings.Contains(fake.mapping, `"timestamp": {"type": "date"}`) {
+		t.Errorf("Expected the index to be created with date mappings, got %s", fake.mapping)
+	}
+
+	// The rejected document is logged, not returned
+	rejected := testEntry()
+	rejected.Domain = "rejected.example"
+	for _, entry := range []*models.CertificateEntry{testEntry(), testEntry(), testEntry(), rejected} {
+		if err := handler.Handle(entry); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+
+	// Full batches go out from the flush loop, before the interval
+	if !waitFor(func() bool { return fake.documents() == 4 }) {
+		t.Fatalf("Expected the 4 entries sent before Close, got %d", fake.documents())
+	}
+
+	last := testEntry()
+	last.Domain = "last.example"
+	handler.Handle(last)
+
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	final := fake.bulks[len(fake.bulks)-1]
+	if fake.documents() != 5 || len(final) != 1 || final[0]["domain"] != "last.example" {
+		t.Errorf("Expected Close to send the remaining entry, got %v", fake.bulks)
+	}
+}
+
+func TestNewElasticHandlerValidates(t *testing.T) {
+	if _, err := NewElasticHandler("localhost:9200", "certs", 0); err == nil {
+		t.Error("Expected an error for a URL without scheme")
+	}
+	if _, err := NewElasticHandler("http://localhost:9200", "Certs", 0); err == nil {
+		t.Error("Expected an error for an uppercase index name")
+	}
+}
+
+func TestElasticHandlerDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			<-release
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"acknowledged": true}`))
+	}))
+	defer server.Close()
+
+	handler, err := NewElasticHandler(server.URL, "certs", 2)
+	if err != nil {
+		t.Fatalf("NewElasticHandler() error: %v", err)
+	}
+
+	// A stalled cluster holds the flush loop, not the caller
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		handler.Handle(testEntry())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Handle blocked for %v on a stalled cluster", elapsed)
+	}
+
+	handler.mutex.Lock()
+	pending := len(handler.pending)
+	handler.mutex.Unlock()
+	if pending > elasticMaxPendingBatches*2 {
+		t.Errorf("Expected at most %d pending entries, got %d", elasticMaxPendingBatches*2, pending)
+	}
+
+	close(release)
+	if err := handler.Close(); err == nil {
+		t.Error("Expected Close to report the failed flush")
+	}
+}
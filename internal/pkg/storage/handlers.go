@@ -1,25 +1,85 @@
 package storage
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"domain_watcher/pkg/models"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 type FileHandler struct {
-	outputPath   string
-	outputFormat string
+	outputPath    string
+	outputFormat  string
+	stdout        io.Writer
+	shardByDomain bool
+	color         bool
+	headerOnce    sync.Once // csv and tsv header on stdout
+
+	// Free-space guard, see SetMinFreeSpace
+	spaceMutex    sync.Mutex
+	minFree       uint64
+	freeSpace     SpaceChecker
+	checkInterval time.Duration
+	lastCheck     time.Time
+	paused        bool
+	dropped       int
 }
 
+// NewFileHandler writes entries to outputPath in outputFormat: json, yaml,
+// csv or tsv files, or any of those or table on stdout when outputPath is
+// empty.
 func NewFileHandler(outputPath, outputFormat string) *FileHandler {
 	return &FileHandler{
-		outputPath:   outputPath,
-		outputFormat: outputFormat,
+		outputPath:    outputPath,
+		outputFormat:  outputFormat,
+		stdout:        os.Stdout,
+		freeSpace:     diskFree,
+		checkInterval: defaultSpaceCheckInterval,
+	}
+}
+
+// NewStdoutHandler writes entries to stdout in format (json, yaml, csv, tsv
+// or table),
+// independently of any file output.
+func NewStdoutHandler(format string) *FileHandler {
+	return NewFileHandler("", format)
+}
+
+// SetShardByDomain makes directory output write each entry under a
+// subdirectory named after its matched domain, e.g. certs/example_com/.
+func (h *FileHandler) SetShardByDomain(enabled bool) {
+	h.shardByDomain = enabled
+}
+
+// SetColor highlights the domain, issuer, validity and wildcard flag with
+// ANSI colors in table output, for terminals.
+func (h *FileHandler) SetColor(enabled bool) {
+	h.color = enabled
+}
+
+// checkFormat reports an output format the handler cannot write.
+func (h *FileHandler) checkFormat() error {
+	switch h.outputFormat {
+	case "json", "yaml", "csv", "tsv":
+		return nil
+	case "table":
+		if h.outputPath == "" {
+			return nil
+		}
+		return fmt.Errorf("table format is only supported on stdout")
 	}
+	return fmt.Errorf("unsupported output format: %s", h.outputFormat)
 }
 
 func (h *FileHandler) Handle(entry *models.CertificateEntry) error {
@@ -28,17 +88,53 @@ func (h *FileHandler) Handle(entry *models.CertificateEntry) error {
 		return h.writeToStdout(entry)
 	}
 
+	if h.outputPaused() {
+		return nil
+	}
+
+	isDir, err := OutputPathIsDir(h.outputPath)
+	if err != nil {
+		return err
+	}
+	if !isDir {
+		return h.appendToFile(entry, h.outputPath)
+	}
+
+	dir := h.outputPath
+	if h.shardByDomain {
+		dir = filepath.Join(dir, sanitizeDomain(entry.Domain))
+	}
+
 	// Ensure output directory exists
-	if err := os.MkdirAll(filepath.Dir(h.outputPath), 0755); err != nil {
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// Create filename with timestamp and domain
-	timestamp := entry.Timestamp.Format("20060102_150405")
-	filename := fmt.Sprintf("%s_%s.json", timestamp, sanitizeDomain(entry.Domain))
-	fullPath := filepath.Join(h.outputPath, filename)
+	return h.writeToFile(entry, filepath.Join(dir, entryFilename(entry, h.outputFormat)))
+}
+
+// entryFilename names the file of an entry from its timestamp, domain and a
+// short hash of the certificate, so distinct certificates seen in the same
+// second get distinct files while the same certificate keeps its name.
+func entryFilename(entry *models.CertificateEntry, format string) string {
+	ext := "json"
+	if format == "yaml" || IsDelimitedFormat(format) {
+		ext = format
+	}
+
+	key := entry.IdempotencyKey
+	if key == "" {
+		key = entry.ComputeIdempotencyKey()
+	}
+	if key == "" {
+		// Without a fingerprint or serial, hash the names and validity
+		data, _ := json.Marshal([]interface{}{entry.Subdomains, entry.LeafCert.Subject, entry.LeafCert.NotBefore, entry.LeafCert.NotAfter})
+		sum := sha256.Sum256(data)
+		key = hex.EncodeToString(sum[:])
+	}
 
-	return h.writeToFile(entry, fullPath)
+	timestamp := entry.Timestamp.Format("20060102_150405")
+	return fmt.Sprintf("%s_%s_%s.%s", timestamp, sanitizeDomain(entry.Domain), key[:12], ext)
 }
 
 func (h *FileHandler) writeToStdout(entry *models.CertificateEntry) error {
@@ -48,11 +144,17 @@ func (h *FileHandler) writeToStdout(entry *models.CertificateEntry) error {
 		if err != nil {
 			return fmt.Errorf("failed to marshal JSON: %w", err)
 		}
-		fmt.Println(string(data))
+		fmt.Fprintln(h.stdout, string(data))
 	case "yaml":
-		// For simplicity, we'll use JSON for now. YAML library can be added later
-		log.Println("YAML output format not yet implemented, using JSON")
-		fallthrough
+		data, err := MarshalYAML(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal YAML: %w", err)
+		}
+		fmt.Fprint(h.stdout, "---\n", string(data))
+	case "csv", "tsv":
+		header := false
+		h.headerOnce.Do(func() { header = true })
+		return writeDelimited(h.stdout, h.outputFormat, entry, header)
 	case "table":
 		h.printTable(entry)
 	default:
@@ -62,9 +164,19 @@ func (h *FileHandler) writeToStdout(entry *models.CertificateEntry) error {
 }
 
 func (h *FileHandler) writeToFile(entry *models.CertificateEntry, filename string) error {
-	data, err := json.MarshalIndent(entry, "", "  ")
+	var data []byte
+	var err error
+	if h.outputFormat == "yaml" {
+		data, err = MarshalYAML(entry)
+	} else if IsDelimitedFormat(h.outputFormat) {
+		var buf bytes.Buffer
+		err = writeDelimited(&buf, h.outputFormat, entry, true)
+		data = buf.Bytes()
+	} else {
+		data, err = json.MarshalIndent(entry, "", "  ")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
+		return fmt.Errorf("failed to marshal entry: %w", err)
 	}
 
 	file, err := os.Create(filename)
@@ -81,28 +193,165 @@ func (h *FileHandler) writeToFile(entry *models.CertificateEntry, filename strin
 	return nil
 }
 
+// OutputPathIsDir decides whether path names a directory (one file per
+// entry) or a single file (entries appended as JSON lines). An existing path
+// is taken as-is; otherwise a trailing separator means a directory and a
+// known file extension means a file.
+func OutputPathIsDir(path string) (bool, error) {
+	wantsDir := strings.HasSuffix(path, "/") || strings.HasSuffix(path, string(os.PathSeparator))
+
+	info, err := os.Stat(filepath.Clean(path))
+	if err == nil {
+		if !info.IsDir() && wantsDir {
+			return false, fmt.Errorf("output path %s exists as a file but a directory was requested", path)
+		}
+		return info.IsDir(), nil
+	}
+	if !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to stat output path %s: %w", path, err)
+	}
+
+	if wantsDir {
+		return true, nil
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".jsonl", ".ndjson", ".log", ".txt", ".yaml", ".yml", ".csv", ".tsv":
+		return false, nil
+	}
+	return true, nil
+}
+
+func (h *FileHandler) appendToFile(entry *models.CertificateEntry, filename string) error {
+	if IsDelimitedFormat(h.outputFormat) {
+		return h.appendDelimited(entry, filename)
+	}
+
+	var data []byte
+	var err error
+	if h.outputFormat == "yaml" {
+		// One YAML document per entry
+		if data, err = MarshalYAML(entry); err == nil {
+			data = append([]byte("---\n"), bytes.TrimSuffix(data, []byte("\n"))...)
+		}
+	} else {
+		data, err = json.Marshal(entry)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal entry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to file %s: %w", filename, err)
+	}
+
+	return nil
+}
+
+// appendDelimited appends entry to a csv or tsv file, starting a new or
+// empty file with the header row.
+func (h *FileHandler) appendDelimited(entry *models.CertificateEntry, filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file %s: %w", filename, err)
+	}
+	if err := writeDelimited(file, h.outputFormat, entry, info.Size() == 0); err != nil {
+		return fmt.Errorf("failed to write to file %s: %w", filename, err)
+	}
+	return nil
+}
+
 func (h *FileHandler) printTable(entry *models.CertificateEntry) {
-	fmt.Printf("┌─────────────────────────────────────────────────────────────┐\n")
-	fmt.Printf("│ Certificate Transparency Entry                              │\n")
-	fmt.Printf("├─────────────────────────────────────────────────────────────┤\n")
-	fmt.Printf("│ Domain:        %-44s │\n", entry.Domain)
-	fmt.Printf("│ Timestamp:     %-44s │\n", entry.Timestamp.Format(time.RFC3339))
-	fmt.Printf("│ Subject CN:    %-44s │\n", entry.LeafCert.Subject.CommonName)
-	fmt.Printf("│ Issuer:        %-44s │\n", entry.LeafCert.IssuerDistinguishedName)
-	fmt.Printf("│ Not Before:    %-44s │\n", entry.LeafCert.NotBefore.Format(time.RFC3339))
-	fmt.Printf("│ Not After:     %-44s │\n", entry.LeafCert.NotAfter.Format(time.RFC3339))
+	fmt.Fprintf(h.stdout, "┌─────────────────────────────────────────────────────────────┐\n")
+	fmt.Fprintf(h.stdout, "│ Certificate Transparency Entry                              │\n")
+	fmt.Fprintf(h.stdout, "├─────────────────────────────────────────────────────────────┤\n")
+	fmt.Fprintf(h.stdout, "│ Domain:        %s │\n", h.paint(ansiBold+ansiGreen, entry.Domain))
+	fmt.Fprintf(h.stdout, "│ Timestamp:     %-44s │\n", entry.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(h.stdout, "│ Subject CN:    %-44s │\n", entry.LeafCert.Subject.CommonName)
+	fmt.Fprintf(h.stdout, "│ Issuer:        %s │\n", h.paint(ansiCyan, entry.LeafCert.IssuerDistinguishedName))
+	fmt.Fprintf(h.stdout, "│ Not Before:    %-44s │\n", entry.LeafCert.NotBefore.Format(time.RFC3339))
+	notAfterColor := ""
+	switch {
+	case !entry.LeafCert.NotAfter.IsZero() && entry.LeafCert.NotAfter.Before(time.Now()):
+		notAfterColor = ansiRed
+	case entry.Expiry != nil:
+		notAfterColor = ansiYellow
+	}
+	fmt.Fprintf(h.stdout, "│ Not After:     %s │\n", h.paint(notAfterColor, entry.LeafCert.NotAfter.Format(time.RFC3339)))
+	if key := keyDescription(entry.LeafCert); key != "" {
+		fmt.Fprintf(h.stdout, "│ Key:           %-44s │\n", key)
+	}
+	if entry.Wildcard {
+		fmt.Fprintf(h.stdout, "│ Wildcard:      %s │\n", h.paint(ansiYellow, "yes (covers any host)"))
+	}
 	if len(entry.Subdomains) > 0 {
-		fmt.Printf("│ Subdomains:    %-44s │\n", fmt.Sprintf("(%d found)", len(entry.Subdomains)))
+		fmt.Fprintf(h.stdout, "│ Subdomains:    %-44s │\n", fmt.Sprintf("(%d found)", len(entry.Subdomains)))
 		for i, subdomain := range entry.Subdomains {
 			if i < 3 { // Limit display to first 3 subdomains
-				fmt.Printf("│   - %-51s │\n", subdomain)
+				fmt.Fprintf(h.stdout, "│   - %-51s │\n", subdomain)
 			} else if i == 3 {
-				fmt.Printf("│   - %-51s │\n", "... and more")
+				fmt.Fprintf(h.stdout, "│   - %-51s │\n", "... and more")
 				break
 			}
 		}
 	}
-	fmt.Printf("└─────────────────────────────────────────────────────────────┘\n\n")
+	fmt.Fprintf(h.stdout, "└─────────────────────────────────────────────────────────────┘\n\n")
+}
+
+// ANSI escape codes used by table output with SetColor.
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+)
+
+// paint pads text to the table's value column and, with color enabled,
+// wraps it in the ANSI code.
+func (h *FileHandler) paint(code, text string) string {
+	text = fmt.Sprintf("%-44s", text)
+	if !h.color || code == "" {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+// keyDescription summarizes the key and signature algorithm of leaf, e.g.
+// "RSA 2048, SHA256-RSA", or returns "" if neither is known.
+func keyDescription(leaf models.LeafCertificate) string {
+	var parts []string
+	if leaf.PublicKeyAlgorithm != "" {
+		key := leaf.PublicKeyAlgorithm
+		if leaf.KeyBits > 0 {
+			key += fmt.Sprintf(" %d", leaf.KeyBits)
+		}
+		parts = append(parts, key)
+	}
+	if leaf.SignatureAlgorithm != "" {
+		parts = append(parts, leaf.SignatureAlgorithm)
+	}
+	return strings.Join(parts, ", ")
 }
 
 func sanitizeDomain(domain string) string {
@@ -118,36 +367,112 @@ func sanitizeDomain(domain string) string {
 	return safe
 }
 
-// LogHandler writes certificate entries to a rotating log file
+// LogHandler writes certificate entries to a log file, optionally rotated
+// by size and age
 type LogHandler struct {
-	logFile *os.File
+	logFile io.WriteCloser
+	format  string
+}
+
+// LogRotation limits a LogHandler's file. Rotation is enabled when any field
+// is positive; rotated files are gzip-compressed.
+type LogRotation struct {
+	MaxSizeMB  int // size at which the file rotates, 100 MB if zero
+	MaxBackups int // rotated files kept, all if zero
+	MaxAgeDays int // days rotated files are kept, forever if zero
+}
+
+func (r LogRotation) enabled() bool {
+	return r.MaxSizeMB > 0 || r.MaxBackups > 0 || r.MaxAgeDays > 0
 }
 
 func NewLogHandler(logPath string) (*LogHandler, error) {
+	return NewLogHandlerWithFormat(logPath, "json")
+}
+
+// NewLogHandlerWithFormat opens logPath for appending and writes each entry
+// using format: "json" for a timestamped JSON line, or "text" for a compact
+// key=value summary.
+func NewLogHandlerWithFormat(logPath, format string) (*LogHandler, error) {
+	return NewLogHandlerWithOptions(logPath, format, LogRotation{})
+}
+
+// NewLogHandlerWithRotation writes JSON log lines to logPath, rotating it at
+// maxSizeMB and keeping maxBackups compressed files for up to maxAgeDays.
+func NewLogHandlerWithRotation(logPath string, maxSizeMB, maxBackups, maxAgeDays int) (*LogHandler, error) {
+	return NewLogHandlerWithOptions(logPath, "json", LogRotation{
+		MaxSizeMB:  maxSizeMB,
+		MaxBackups: maxBackups,
+		MaxAgeDays: maxAgeDays,
+	})
+}
+
+// NewLogHandlerWithOptions writes entries to logPath in format, rotating
+// the file as set by rotation.
+func NewLogHandlerWithOptions(logPath, format string, rotation LogRotation) (*LogHandler, error) {
+	switch format {
+	case "json", "text":
+	default:
+		return nil, fmt.Errorf("unsupported log format: %s", format)
+	}
+
 	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create log directory: %w", err)
 	}
 
+	if rotation.enabled() {
+		return &LogHandler{
+			logFile: &lumberjack.Logger{
+				Filename:   logPath,
+				MaxSize:    rotation.MaxSizeMB,
+				MaxBackups: rotation.MaxBackups,
+				MaxAge:     rotation.MaxAgeDays,
+				LocalTime:  true,
+				Compress:   true,
+			},
+			format: format,
+		}, nil
+	}
+
 	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open log file: %w", err)
 	}
 
-	return &LogHandler{logFile: file}, nil
+	return &LogHandler{logFile: file, format: format}, nil
 }
 
 func (h *LogHandler) Handle(entry *models.CertificateEntry) error {
-	data, err := json.Marshal(entry)
-	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
+	var logLine string
+	switch h.format {
+	case "text":
+		logLine = fmt.Sprintf("%s domain=%s cn=%q issuer=%q not_before=%s not_after=%s names=%d wildcard=%t\n",
+			time.Now().Format(time.RFC3339),
+			entry.Domain,
+			entry.LeafCert.Subject.CommonName,
+			entry.LeafCert.IssuerDistinguishedName,
+			entry.LeafCert.NotBefore.Format(time.RFC3339),
+			entry.LeafCert.NotAfter.Format(time.RFC3339),
+			len(entry.Subdomains),
+			entry.Wildcard,
+		)
+	default:
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		logLine = fmt.Sprintf("%s %s\n", time.Now().Format(time.RFC3339), string(data))
 	}
 
-	logLine := fmt.Sprintf("%s %s\n", time.Now().Format(time.RFC3339), string(data))
-	if _, err := h.logFile.WriteString(logLine); err != nil {
+	if _, err := io.WriteString(h.logFile, logLine); err != nil {
 		return fmt.Errorf("failed to write to log file: %w", err)
 	}
 
-	return h.logFile.Sync()
+	// The rotating writer manages its own files
+	if file, ok := h.logFile.(*os.File); ok {
+		return file.Sync()
+	}
+	return nil
 }
 
 func (h *LogHandler) Close() error {
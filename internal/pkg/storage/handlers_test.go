@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"bytes"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEntry() *models.CertificateEntry {
+	return &models.CertificateEntry{
+		Domain:     "example.com",
+		Subdomains: []string{"example.com", "www.example.com"},
+		LeafCert: models.LeafCertificate{
+			Subject:                 models.Subject{CommonName: "example.com"},
+			IssuerDistinguishedName: "R3",
+			NotBefore:               time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:                time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Timestamp: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestHandlersUseIndependentFormats(t *testing.T) {
+	var stdout bytes.Buffer
+	fileHandler := NewFileHandler("", "json")
+	fileHandler.stdout = &stdout
+
+	logPath := filepath.Join(t.TempDir(), "certs.log")
+	logHandler, err := NewLogHandlerWithFormat(logPath, "text")
+	if err != nil {
+		t.Fatalf("NewLogHandlerWithFormat() error: %v", err)
+	}
+	defer logHandler.Close()
+
+	entry := testEntry()
+	if err := fileHandler.Handle(entry); err != nil {
+		t.Fatalf("FileHandler.Handle() error: %v", err)
+	}
+	if err := logHandler.Handle(entry); err != nil {
+		t.Fatalf("LogHandler.Handle() error: %v", err)
+	}
+
+	var decoded models.CertificateEntry
+	if err := json.Unmarshal(stdout.Bytes(), &decoded); err != nil {
+		t.Fatalf("FileHandler output is not JSON: %v", err)
+	}
+	if decoded.Domain != entry.Domain {
+		t.Errorf("Expected domain %s, got %s", entry.Domain, decoded.Domain)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	line := string(data)
+	if strings.Contains(line, "{") {
+		t.Errorf("Expected text log line, got JSON: %s", line)
+	}
+	for _, want := range []string{"domain=example.com", `issuer="R3"`, "names=2"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Log line %q missing %q", line, want)
+		}
+	}
+}
+
+func TestLogHandlerRotation(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "certs.log")
+	logHandler, err := NewLogHandlerWithRotation(logPath, 1, 2, 0)
+	if err != nil {
+		t.Fatalf("NewLogHandlerWithRotation() error: %v", err)
+	}
+
+	// Write about 3 MB so the file rotates at least twice
+	entry := testEntry()
+	entry.Subdomains = []string{strings.Repeat("a", 1000) + ".example.com"}
+	for written := 0; written < 3<<20; written += 1100 {
+		if err := logHandler.Handle(entry); err != nil {
+			t.Fatalf("LogHandler.Handle() error: %v", err)
+		}
+	}
+	if err := logHandler.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	// Old files are compressed in the background
+	var backups []string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		backups, _ = filepath.Glob(filepath.Join(dir, "certs-*.log.gz"))
+		if len(backups) == 2 {
+			break
+		}
+	}
+	if len(backups) != 2 {
+		files, _ := os.ReadDir(dir)
+		t.Fatalf("Expected 2 compressed backups, got %v (dir: %v)", backups, files)
+	}
+	if info, err := os.Stat(logPath); err != nil || info.Size() > 1<<20 {
+		t.Errorf("Expected the current log file under 1 MB, got %v (err %v)", info, err)
+	}
+}
+
+func TestNewLogHandlerWithFormatRejectsUnknown(t *testing.T) {
+	if _, err := NewLogHandlerWithFormat(filepath.Join(t.TempDir(), "certs.log"), "xml"); err == nil {
+		t.Error("Expected error for unsupported log format")
+	}
+}
+
+func TestFileHandlerDirectoryOutputPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "certs")
+	handler := NewFileHandler(dir, "json")
+
+	if err := handler.Handle(testEntry()); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Expected output directory to be created: %v", err)
+	}
+	if len(files) != 1 || !strings.HasPrefix(files[0].Name(), "20250101_120000_example_com_") || !strings.HasSuffix(files[0].Name(), ".json") {
+		t.Errorf("Expected one per-entry JSON file, got %v", files)
+	}
+}
+
+func TestFileHandlerDistinctFilesInSameSecond(t *testing.T) {
+	dir := t.TempDir()
+	handler := NewFileHandler(dir, "json")
+	handler.SetShardByDomain(true)
+
+	first, second := testEntry(), testEntry()
+	first.LeafCert.Fingerprint = "AA:BB"
+	second.LeafCert.Fingerprint = "CC:DD"
+	for _, entry := range []*models.CertificateEntry{first, second, first} {
+		if err := handler.Handle(entry); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+
+	files, err := os.ReadDir(filepath.Join(dir, "example_com"))
+	if err != nil {
+		t.Fatalf("Expected a per-domain directory: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected one file per certificate, got %v", files)
+	}
+}
+
+func TestFileHandlerSingleFileOutputPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "certs.jsonl")
+	handler := NewFileHandler(path, "json")
+
+	for i := 0; i < 2; i++ {
+		if err := handler.Handle(testEntry()); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected output file to be created: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 appended lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		var decoded models.CertificateEntry
+		if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+			t.Errorf("Line is not valid JSON: %v", err)
+		}
+	}
+}
+
+func TestFileHandlerExistingFileWithTrailingSlash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "certs")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewFileHandler(path+"/", "json")
+	err := handler.Handle(testEntry())
+	if err == nil || !strings.Contains(err.Error(), "exists as a file") {
+		t.Errorf("Expected conflict error, got %v", err)
+	}
+}
+
+func TestFileHandlerTableColor(t *testing.T) {
+	var plain, colored bytes.Buffer
+	handler := NewStdoutHandler("table")
+	handler.stdout = &plain
+	handler.Handle(testEntry())
+
+	handler.stdout = &colored
+	handler.SetColor(true)
+	handler.Handle(testEntry())
+
+	if strings.Contains(plain.String(), "\033[") {
+		t.Errorf("Expected no ANSI codes without color, got:\n%s", plain.String())
+	}
+	if !strings.Contains(colored.String(), ansiBold+ansiGreen+fmt.Sprintf("%-44s", "example.com")+ansiReset) {
+		t.Errorf("Expected the domain to be highlighted, got:\n%s", colored.String())
+	}
+	// Colors don't change the text or its alignment
+	stripped := strings.NewReplacer(ansiReset, "", ansiBold, "", ansiGreen, "", ansiCyan, "", ansiYellow, "", ansiRed, "").Replace(colored.String())
+	if stripped != plain.String() {
+		t.Errorf("Expected the same table with colors removed, got:\n%s\nwant:\n%s", stripped, plain.String())
+	}
+}
+
+func TestFileHandlerYAMLOutput(t *testing.T) {
+	var stdout bytes.Buffer
+	handler := NewFileHandler("", "yaml")
+	handler.stdout = &stdout
+	if err := handler.Handle(testEntry()); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+
+	out := stdout.String()
+	for _, want := range []string{"---\n", "domain: example.com\n", "  common_name: example.com\n", "  - www.example.com\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected YAML output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "{") || strings.Contains(out, `"domain"`) {
+		t.Errorf("Expected block-style YAML, got:\n%s", out)
+	}
+
+	// Directory output writes one .yaml file per entry
+	dir := t.TempDir() + "/"
+	if err := NewFileHandler(dir, "yaml").Handle(testEntry()); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 .yaml file, got %v", matches)
+	}
+	data, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", matches[0], err)
+	}
+	if !strings.HasPrefix(string(data), "domain: example.com\n") {
+		t.Errorf("Unexpected file contents:\n%s", data)
+	}
+}
+
+func TestFileHandlerVerifyFormat(t *testing.T) {
+	dir := t.TempDir() + "/"
+	tests := []struct {
+		handler *FileHandler
+		wantErr bool
+	}{
+		{NewStdoutHandler("table"), false},
+		{NewStdoutHandler("yaml"), false},
+		{NewStdoutHandler("xml"), true},
+		{NewFileHandler(dir, "json"), false},
+		{NewFileHandler(dir, "table"), true},
+	}
+	for _, tt := range tests {
+		err := tt.handler.Verify()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Verify() for %q output to %q: got error %v, wantErr %v", tt.handler.outputFormat, tt.handler.outputPath, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"bufio"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// NDJSONHandler appends each entry as one JSON object per line to a single
+// file, ready for jq or any line-oriented consumer. Unlike LogHandler, lines
+// carry no timestamp prefix.
+type NDJSONHandler struct {
+	path   string
+	mutex  sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// NewNDJSONHandler opens path for appending, creating it and its directory
+// if needed.
+func NewNDJSONHandler(path string) (*NDJSONHandler, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create NDJSON directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open NDJSON file: %w", err)
+	}
+
+	return &NDJSONHandler{path: path, file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// Handle writes entry as a line and flushes it, so readers tailing the file
+// never see a partial line from this handler.
+func (h *NDJSONHandler) Handle(entry *models.CertificateEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	data = append(data, '\n')
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, err := h.writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", h.path, err)
+	}
+	if err := h.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", h.path, err)
+	}
+	return nil
+}
+
+// Close flushes any buffered data and closes the file.
+func (h *NDJSONHandler) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+	flushErr := h.writer.Flush()
+	closeErr := h.file.Close()
+	h.file = nil
+	if flushErr != nil {
+		return fmt.Errorf("failed to write to %s: %w", h.path, flushErr)
+	}
+	return closeErr
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"bufio"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestNDJSONHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "certs.ndjson")
+
+	// Entries from a previous run are kept
+	for run := 0; run < 2; run++ {
+		handler, err := NewNDJSONHandler(path)
+		if err != nil {
+			t.Fatalf("NewNDJSONHandler() error: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := handler.Handle(testEntry()); err != nil {
+					t.Errorf("Handle() error: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if err := handler.Close(); err != nil {
+			t.Fatalf("Close() error: %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open output: %v", err)
+	}
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry models.CertificateEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Line %d is not a JSON entry: %v", lines+1, err)
+		}
+		if entry.Domain != "example.com" {
+			t.Errorf("Line %d: unexpected domain %q", lines+1, entry.Domain)
+		}
+		lines++
+	}
+	if lines != 20 {
+		t.Errorf("Expected 20 lines, got %d", lines)
+	}
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"domain_watcher/pkg/models"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const defaultParquetRowGroupSize = 10000
+
+// ParquetRow is the column schema of ParquetHandler files: one row per entry,
+// with the leaf certificate's subject, issuer and key flattened into columns
+// and the name lists stored as repeated columns.
+type ParquetRow struct {
+	Timestamp          time.Time `parquet:"timestamp,timestamp(millisecond)"`
+	Domain             string    `parquet:"domain"`
+	Subdomains         []string  `parquet:"subdomains"`
+	MatchedNames       []string  `parquet:"matched_names"`
+	IPAddresses        []string  `parquet:"ip_addresses"`
+	Wildcard           bool      `parquet:"wildcard"`
+	EntryType          string    `parquet:"entry_type"`
+	LogURL             string    `parquet:"log_url"`
+	LogIndex           int64     `parquet:"log_index"`
+	SubjectCN          string    `parquet:"subject_cn"`
+	SubjectO           string    `parquet:"subject_o"`
+	SubjectOU          string    `parquet:"subject_ou"`
+	SubjectC           string    `parquet:"subject_c"`
+	Issuer             string    `parquet:"issuer"`
+	IssuerOrganization string    `parquet:"issuer_organization"`
+	IssuerCanonical    string    `parquet:"issuer_canonical"`
+	NotBefore          time.Time `parquet:"not_before,timestamp(millisecond)"`
+	NotAfter           time.Time `parquet:"not_after,timestamp(millisecond)"`
+	SerialNumber       string    `parquet:"serial_number"`
+	Fingerprint        string    `parquet:"fingerprint"`
+	PublicKeyAlgorithm string    `parquet:"public_key_algorithm"`
+	KeyBits            int32     `parquet:"key_bits"`
+	SignatureAlgorithm string    `parquet:"signature_algorithm"`
+	Keyword            string    `parquet:"keyword"`
+	Lookalike          string    `parquet:"lookalike"`
+	IdempotencyKey     string    `parquet:"idempotency_key"`
+}
+
+// NewParquetRow flattens entry into the ParquetHandler schema.
+func NewParquetRow(entry *models.CertificateEntry) ParquetRow {
+	leaf := entry.LeafCert
+	return ParquetRow{
+		Timestamp:          entry.Timestamp,
+		Domain:             entry.Domain,
+		Subdomains:         entry.Subdomains,
+		MatchedNames:       entry.MatchedNames,
+		IPAddresses:        entry.IPAddresses,
+		Wildcard:           entry.Wildcard,
+		EntryType:          entry.EntryType,
+		LogURL:             entry.LogURL,
+		LogIndex:           int64(entry.Index),
+		SubjectCN:          leaf.Subject.CommonName,
+		SubjectO:           leaf.Subject.Organization,
+		SubjectOU:          leaf.Subject.OrganizationalUnit,
+		SubjectC:           leaf.Subject.Country,
+		Issuer:             leaf.IssuerDistinguishedName,
+		IssuerOrganization: leaf.IssuerOrganization,
+		IssuerCanonical:    leaf.IssuerCanonical,
+		NotBefore:          leaf.NotBefore,
+		NotAfter:           leaf.NotAfter,
+		SerialNumber:       leaf.SerialNumber,
+		Fingerprint:        leaf.Fingerprint,
+		PublicKeyAlgorithm: leaf.PublicKeyAlgorithm,
+		KeyBits:            int32(leaf.KeyBits),
+		SignatureAlgorithm: leaf.SignatureAlgorithm,
+		Keyword:            entry.Keyword,
+		Lookalike:          entry.Lookalike,
+		IdempotencyKey:     entry.IdempotencyKey,
+	}
+}
+
+// ParquetHandler writes entries as Snappy-compressed Parquet files in a
+// directory, partitioned by the hour they were handled in, so data lake
+// engines such as Athena and Spark discover the partitions:
+//
+//	<dir>/dt=YYYY-MM-DD/hour=HH/certs_<start time>_<sequence>.parquet
+//
+// A row group is written every rowGroupSize entries. The active file has a
+// .tmp suffix until it is closed, at the end of its hour or when the handler
+// is closed, so readers never see a file without its footer.
+type ParquetHandler struct {
+	dir          string
+	rowGroupSize int
+	now          func() time.Time
+
+	mutex    sync.Mutex
+	hour     time.Time // partition of the active file
+	path     string    // final path of the active file
+	file     *os.File
+	writer   *parquet.GenericWriter[ParquetRow]
+	rows     int // rows in the active file
+	buffered int // rows since the last row group
+	sequence int
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewParquetHandler creates dir if needed. rowGroupSize is the number of
+// entries per row group; zero or less uses 10000.
+func NewParquetHandler(dir string, rowGroupSize int) (*ParquetHandler, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if rowGroupSize <= 0 {
+		rowGroupSize = defaultParquetRowGroupSize
+	}
+	h := &ParquetHandler{
+		dir:          dir,
+		rowGroupSize: rowGroupSize,
+		now:          time.Now,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go h.rolloverLoop()
+	return h, nil
+}
+
+func (h *ParquetHandler) Handle(entry *models.CertificateEntry) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := h.now().UTC()
+	hour := now.Truncate(time.Hour)
+	if h.file != nil && !hour.Equal(h.hour) {
+		if err := h.finalize(); err != nil {
+			return err
+		}
+	}
+	if h.file == nil {
+		if err := h.open(now); err != nil {
+			return err
+		}
+	}
+
+	if _, err := h.writer.Write([]ParquetRow{NewParquetRow(entry)}); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", h.path, err)
+	}
+	h.rows++
+	h.buffered++
+	if h.buffered >= h.rowGroupSize {
+		if err := h.writer.Flush(); err != nil {
+			return fmt.Errorf("failed to write row group to %s: %w", h.path, err)
+		}
+		h.buffered = 0
+	}
+	return nil
+}
+
+// rolloverLoop finalizes the active file at the end of each hour, so the
+// partition is complete even if no entry arrives in the next hour, until
+// Close.
+func (h *ParquetHandler) rolloverLoop() {
+	defer close(h.done)
+
+	for {
+		now := time.Now()
+		timer := time.NewTimer(now.Truncate(time.Hour).Add(time.Hour).Sub(now))
+		select {
+		case <-timer.C:
+			if err := h.rollover(); err != nil {
+				log.Printf("Failed to finalize Parquet file: %v", err)
+			}
+		case <-h.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// rollover finalizes the active file if its hour is over.
+func (h *ParquetHandler) rollover() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.file == nil || !h.now().UTC().Truncate(time.Hour).After(h.hour) {
+		return nil
+	}
+	return h.finalize()
+}
+
+// Close writes the buffered rows and the footer of the active file, if any.
+func (h *ParquetHandler) Close() error {
+	h.closeOnce.Do(func() {
+		close(h.stop)
+		<-h.done
+	})
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+	return h.finalize()
+}
+
+func (h *ParquetHandler) open(now time.Time) error {
+	partition := filepath.Join(h.dir, now.Format("dt=2006-01-02"), now.Format("hour=15"))
+	if err := os.MkdirAll(partition, 0755); err != nil {
+		return fmt.Errorf("failed to create partition %s: %w", partition, err)
+	}
+
+	h.sequence++
+	path := filepath.Join(partition, fmt.Sprintf("certs_%s_%04d.parquet", now.Format("20060102T150405"), h.sequence))
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+
+	h.hour = now.Truncate(time.Hour)
+	h.path = path
+	h.file = file
+	h.writer = parquet.NewGenericWriter[ParquetRow](file, parquet.Compression(&parquet.Snappy))
+	h.rows, h.buffered = 0, 0
+	return nil
+}
+
+func (h *ParquetHandler) finalize() error {
+	file, writer, path, rows := h.file, h.writer, h.path, h.rows
+	h.file, h.writer = nil, nil
+
+	tmpPath := path + ".tmp"
+	if err := writer.Close(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to flush %s: %w", tmpPath, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync %s: %w", tmpPath, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to finalize %s: %w", tmpPath, err)
+	}
+
+	log.Printf("Finalized %s (%d entries)", path, rows)
+	return nil
+}
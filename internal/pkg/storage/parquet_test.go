@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetHandlerPartitionsByHour(t *testing.T) {
+	dir := t.TempDir()
+	handler, err := NewParquetHandler(dir, 2)
+	if err != nil {
+		t.Fatalf("NewParquetHandler() error: %v", err)
+	}
+	now := time.Date(2024, 1, 2, 15, 30, 0, 0, time.UTC)
+	handler.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		if err := handler.Handle(testEntry()); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+	// The active file is only visible once finalized
+	if files, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*.parquet")); len(files) != 0 {
+		t.Errorf("Expected no finalized file before the hour changes, got %v", files)
+	}
+
+	now = now.Add(time.Hour)
+	if err := handler.Handle(testEntry()); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	first, err := filepath.Glob(filepath.Join(dir, "dt=2024-01-02", "hour=15", "*.parquet"))
+	if err != nil || len(first) != 1 {
+		t.Fatalf("Expected one file in the 15:00 partition, got %v", first)
+	}
+	second, err := filepath.Glob(filepath.Join(dir, "dt=2024-01-02", "hour=16", "*.parquet"))
+	if err != nil || len(second) != 1 {
+		t.Fatalf("Expected one file in the 16:00 partition, got %v", second)
+	}
+
+	rows, err := parquet.ReadFile[ParquetRow](first[0])
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", first[0], err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("Expected 5 rows, got %d", len(rows))
+	}
+	entry := testEntry()
+	row := rows[0]
+	if row.Domain != entry.Domain || row.SubjectCN != entry.LeafCert.Subject.CommonName ||
+		!slices.Equal(row.Subdomains, entry.Subdomains) || !row.NotAfter.Equal(entry.LeafCert.NotAfter) {
+		t.Errorf("Unexpected row %+v for entry %+v", row, entry)
+	}
+
+	// Five rows in groups of two
+	file, err := os.Open(first[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, _ := file.Stat()
+	pf, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		t.Fatalf("OpenFile() error: %v", err)
+	}
+	if groups := len(pf.RowGroups()); groups != 3 {
+		t.Errorf("Expected 3 row groups, got %d", groups)
+	}
+}
+
+func TestParquetHandlerFinalizesAtTheHour(t *testing.T) {
+	dir := t.TempDir()
+	handler, err := NewParquetHandler(dir, 0)
+	if err != nil {
+		t.Fatalf("NewParquetHandler() error: %v", err)
+	}
+	defer handler.Close()
+	now := time.Date(2024, 1, 2, 15, 30, 0, 0, time.UTC)
+	handler.now = func() time.Time { return now }
+
+	if err := handler.Handle(testEntry()); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+	if err := handler.rollover(); err != nil {
+		t.Fatalf("rollover() error: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*.parquet")); len(files) != 0 {
+		t.Errorf("Expected the file to stay open within its hour, got %v", files)
+	}
+
+	// No entry arrives in the next hour; the file is finalized anyway
+	now = now.Add(30 * time.Minute)
+	if err := handler.rollover(); err != nil {
+		t.Fatalf("rollover() error: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "dt=2024-01-02", "hour=15", "*.parquet"))
+	if len(files) != 1 {
+		t.Fatalf("Expected the 15:00 file finalized at the hour, got %v", files)
+	}
+	if rows, err := parquet.ReadFile[ParquetRow](files[0]); err != nil || len(rows) != 1 {
+		t.Errorf("Expected 1 row in %s, got %d (%v)", files[0], len(rows), err)
+	}
+}
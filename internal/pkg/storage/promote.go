@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"domain_watcher/pkg/models"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// DomainCount is a registrable domain found in a store and the number of
+// distinct certificates that named it.
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
+
+// PromoteDomains scans the entries stored at path and returns the registrable
+// domains (e.g. example.co.uk for www.example.co.uk) named by at least
+// minCount distinct certificates, most frequent first. When tlds is non-empty
+// only domains under one of those public suffixes are returned.
+func PromoteDomains(path string, minCount int, tlds []string) ([]DomainCount, error) {
+	counts := make(map[string]int)
+	seen := make(map[string]bool)
+
+	err := ReadEntries(path, func(entry *models.CertificateEntry) error {
+		// The same certificate may be stored more than once
+		if key := entry.IdempotencyKey; key != "" {
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+		}
+
+		names := entry.Subdomains
+		if len(names) == 0 {
+			names = []string{entry.Domain}
+		}
+
+		registrable := make(map[string]bool)
+		for _, name := range names {
+			name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "*."))
+			domain, err := publicsuffix.EffectiveTLDPlusOne(name)
+			if err != nil {
+				continue
+			}
+			registrable[domain] = true
+		}
+		for domain := range registrable {
+			counts[domain]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var promoted []DomainCount
+	for domain, count := range counts {
+		if count < minCount || !hasSuffixIn(domain, tlds) {
+			continue
+		}
+		promoted = append(promoted, DomainCount{Domain: domain, Count: count})
+	}
+
+	sort.Slice(promoted, func(i, j int) bool {
+		if promoted[i].Count != promoted[j].Count {
+			return promoted[i].Count > promoted[j].Count
+		}
+		return promoted[i].Domain < promoted[j].Domain
+	})
+	return promoted, nil
+}
+
+// hasSuffixIn reports whether domain's public suffix is one of tlds, or any
+// domain when tlds is empty.
+func hasSuffixIn(domain string, tlds []string) bool {
+	if len(tlds) == 0 {
+		return true
+	}
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	for _, tld := range tlds {
+		tld = strings.ToLower(strings.Trim(strings.TrimSpace(tld), "."))
+		if suffix == tld || strings.HasSuffix(suffix, "."+tld) {
+			return true
+		}
+	}
+	return false
+}
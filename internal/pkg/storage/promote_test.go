@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"domain_watcher/pkg/models"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPromoteDomains(t *testing.T) {
+	dir := t.TempDir()
+
+	seen := func(key string, names ...string) *models.CertificateEntry {
+		entry := testEntry()
+		entry.Domain = names[0]
+		entry.Subdomains = names
+		entry.IdempotencyKey = key
+		return entry
+	}
+	entries := []*models.CertificateEntry{
+		seen("a", "login.secure.bank", "www.secure.bank"),
+		seen("b", "secure.bank"),
+		seen("c", "*.secure.bank"),
+		seen("c", "*.secure.bank"), // duplicate of the previous certificate
+		seen("d", "pay.other.bank"),
+		seen("e", "pay.other.bank"),
+		seen("f", "shop.example.co.uk"),
+		seen("g", "www.example.co.uk"),
+		seen("h", "mail.example.co.uk"),
+	}
+
+	// Spread the store across the formats the handlers write
+	fileHandler := NewFileHandler(filepath.Join(dir, "certs.jsonl"), "json")
+	rotating, err := NewRotatingNDJSONHandler(filepath.Join(dir, "rotated"), 0, 2)
+	if err != nil {
+		t.Fatalf("NewRotatingNDJSONHandler() error: %v", err)
+	}
+	for i, entry := range entries {
+		var handler interface {
+			Handle(*models.CertificateEntry) error
+		} = fileHandler
+		if i%2 == 1 {
+			handler = rotating
+		}
+		if err := handler.Handle(entry); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+	if err := rotating.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	promoted, err := PromoteDomains(dir, 2, []string{"bank"})
+	if err != nil {
+		t.Fatalf("PromoteDomains() error: %v", err)
+	}
+	want := []DomainCount{{Domain: "secure.bank", Count: 3}, {Domain: "other.bank", Count: 2}}
+	if !reflect.DeepEqual(promoted, want) {
+		t.Errorf("PromoteDomains(bank) = %+v, want %+v", promoted, want)
+	}
+
+	promoted, err = PromoteDomains(dir, 3, nil)
+	if err != nil {
+		t.Fatalf("PromoteDomains() error: %v", err)
+	}
+	want = []DomainCount{{Domain: "example.co.uk", Count: 3}, {Domain: "secure.bank", Count: 3}}
+	if !reflect.DeepEqual(promoted, want) {
+		t.Errorf("PromoteDomains(min 3) = %+v, want %+v", promoted, want)
+	}
+}
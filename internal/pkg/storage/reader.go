@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadEntries calls fn for every certificate entry stored at path, which may
+// be any output this package writes: a directory of per-entry .json files, a
+// JSON lines file (.jsonl, .ndjson), a --log-file in json format, a
+// directory of rotated .jsonl.gz files, or a mix of these. Lines and files
+// that don't decode as entries are skipped.
+func ReadEntries(path string, fn func(*models.CertificateEntry) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to open store %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return readEntryFile(path, fn)
+	}
+
+	return filepath.WalkDir(path, func(filePath string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() == manifestName || strings.HasSuffix(d.Name(), ".tmp") {
+			return nil
+		}
+		return readEntryFile(filePath, fn)
+	})
+}
+
+func readEntryFile(path string, fn func(*models.CertificateEntry) error) error {
+	name := strings.ToLower(filepath.Base(path))
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+		name = strings.TrimSuffix(name, ".gz")
+	}
+
+	switch filepath.Ext(name) {
+	case ".json":
+		// One indented entry per file, as written by FileHandler
+		var entry models.CertificateEntry
+		if err := json.NewDecoder(r).Decode(&entry); err != nil || entry.Domain == "" {
+			return nil
+		}
+		return fn(&entry)
+	case ".jsonl", ".ndjson", ".log", ".txt":
+		return readEntryLines(r, fn)
+	}
+	return nil
+}
+
+// readEntryLines decodes one entry per line. Lines prefixed with a timestamp
+// (LogHandler's json format) are accepted.
+func readEntryLines(r io.Reader, fn func(*models.CertificateEntry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		entry, ok := parseEntryLine(scanner.Bytes())
+		if !ok {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parseEntryLine decodes an entry from a JSON line, optionally prefixed with
+// a timestamp, reporting false for lines that aren't entries.
+func parseEntryLine(line []byte) (*models.CertificateEntry, bool) {
+	if i := bytes.IndexByte(line, '{'); i > 0 {
+		line = line[i:]
+	}
+
+	var entry models.CertificateEntry
+	if err := json.Unmarshal(line, &entry); err != nil || entry.Domain == "" {
+		return nil, false
+	}
+	return &entry, true
+}
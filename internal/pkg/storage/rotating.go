@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"compress/gzip"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const manifestName = "manifest.json"
+
+// ManifestFile describes one finalized file written by RotatingNDJSONHandler.
+type ManifestFile struct {
+	Name      string    `json:"name"`
+	Entries   int       `json:"entries"`
+	Bytes     int64     `json:"bytes"`
+	CreatedAt time.Time `json:"created_at"`
+	ClosedAt  time.Time `json:"closed_at"`
+}
+
+// Manifest lists the finalized files in a RotatingNDJSONHandler directory.
+type Manifest struct {
+	Files []ManifestFile `json:"files"`
+}
+
+// RotatingNDJSONHandler writes entries as gzip-compressed NDJSON files in a
+// directory. The active file is written with a .tmp suffix and renamed to its
+// final .jsonl.gz name when it rotates or the handler is closed, after which
+// it is recorded in manifest.json.
+type RotatingNDJSONHandler struct {
+	dir        string
+	maxBytes   int64
+	maxEntries int
+	mutex      sync.Mutex
+	file       *os.File
+	gz         *gzip.Writer
+	current    ManifestFile
+	sequence   int
+	manifest   Manifest
+}
+
+// NewRotatingNDJSONHandler creates dir if needed and loads any existing
+// manifest. A file rotates once it holds maxBytes of uncompressed data or
+// maxEntries entries; zero disables either limit.
+func NewRotatingNDJSONHandler(dir string, maxBytes int64, maxEntries int) (*RotatingNDJSONHandler, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	h := &RotatingNDJSONHandler{
+		dir:        dir,
+		maxBytes:   maxBytes,
+		maxEntries: maxEntries,
+	}
+
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	h.manifest = manifest
+
+	// Continue numbering after files from previous runs
+	h.sequence = len(h.manifest.Files)
+
+	return h, nil
+}
+
+func (h *RotatingNDJSONHandler) Handle(entry *models.CertificateEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	data = append(data, '\n')
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.file == nil {
+		if err := h.open(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := h.gz.Write(data); err != nil {
+		return fmt.Errorf("failed to write to %s: %w", h.current.Name, err)
+	}
+	h.current.Entries++
+	h.current.Bytes += int64(len(data))
+
+	if (h.maxBytes > 0 && h.current.Bytes >= h.maxBytes) ||
+		(h.maxEntries > 0 && h.current.Entries >= h.maxEntries) {
+		return h.finalize()
+	}
+	return nil
+}
+
+// Close finalizes the active file, if any.
+func (h *RotatingNDJSONHandler) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+	return h.finalize()
+}
+
+func (h *RotatingNDJSONHandler) open() error {
+	now := time.Now().UTC()
+	h.sequence++
+	name := fmt.Sprintf("certs_%s_%04d.jsonl.gz", now.Format("20060102T150405"), h.sequence)
+
+	file, err := os.Create(filepath.Join(h.dir, name+".tmp"))
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", name, err)
+	}
+
+	h.file = file
+	h.gz = gzip.NewWriter(file)
+	h.current = ManifestFile{Name: name, CreatedAt: now}
+	return nil
+}
+
+func (h *RotatingNDJSONHandler) finalize() error {
+	file, gz, current := h.file, h.gz, h.current
+	h.file, h.gz = nil, nil
+
+	tmpPath := filepath.Join(h.dir, current.Name+".tmp")
+	if err := gz.Close(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to flush %s: %w", tmpPath, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync %s: %w", tmpPath, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(h.dir, current.Name)); err != nil {
+		return fmt.Errorf("failed to finalize %s: %w", tmpPath, err)
+	}
+
+	// Reload first so files removed by a concurrent compaction stay removed
+	if manifest, err := readManifest(h.dir); err == nil {
+		h.manifest = manifest
+	}
+	current.ClosedAt = time.Now().UTC()
+	h.manifest.Files = append(h.manifest.Files, current)
+	if err := writeManifest(h.dir, h.manifest); err != nil {
+		return err
+	}
+
+	log.Printf("Finalized %s (%d entries)", current.Name, current.Entries)
+	return nil
+}
+
+func readManifest(dir string) (Manifest, error) {
+	var manifest Manifest
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err == nil {
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return manifest, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return manifest, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func writeManifest(dir string, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	path := filepath.Join(dir, manifestName)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to finalize manifest: %w", err)
+	}
+	return nil
+}
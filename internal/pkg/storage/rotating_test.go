@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"bufio"
+	"compress/gzip"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingNDJSONHandlerRotation(t *testing.T) {
+	dir := t.TempDir()
+	handler, err := NewRotatingNDJSONHandler(dir, 0, 2)
+	if err != nil {
+		t.Fatalf("NewRotatingNDJSONHandler() error: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := handler.Handle(testEntry()); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Manifest is not valid JSON: %v", err)
+	}
+
+	expectedCounts := []int{2, 2, 1}
+	if len(manifest.Files) != len(expectedCounts) {
+		t.Fatalf("Expected %d files in manifest, got %d", len(expectedCounts), len(manifest.Files))
+	}
+
+	for i, file := range manifest.Files {
+		if file.Entries != expectedCounts[i] {
+			t.Errorf("File %s: expected %d entries in manifest, got %d", file.Name, expectedCounts[i], file.Entries)
+		}
+		if got := readGzipNDJSON(t, filepath.Join(dir, file.Name)); got != expectedCounts[i] {
+			t.Errorf("File %s: expected %d lines, got %d", file.Name, expectedCounts[i], got)
+		}
+	}
+
+	files, _ := os.ReadDir(dir)
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".tmp") {
+			t.Errorf("Temporary file left behind: %s", f.Name())
+		}
+	}
+}
+
+func TestRotatingNDJSONHandlerAppendsToManifest(t *testing.T) {
+	dir := t.TempDir()
+	for run := 0; run < 2; run++ {
+		handler, err := NewRotatingNDJSONHandler(dir, 0, 0)
+		if err != nil {
+			t.Fatalf("NewRotatingNDJSONHandler() error: %v", err)
+		}
+		if err := handler.Handle(testEntry()); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+		if err := handler.Close(); err != nil {
+			t.Fatalf("Close() error: %v", err)
+		}
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, manifestName))
+	var manifest Manifest
+	json.Unmarshal(data, &manifest)
+	if len(manifest.Files) != 2 {
+		t.Fatalf("Expected manifest to list files from both runs, got %d", len(manifest.Files))
+	}
+	if manifest.Files[0].Name == manifest.Files[1].Name {
+		t.Errorf("Expected distinct file names across runs, got %s twice", manifest.Files[0].Name)
+	}
+}
+
+func readGzipNDJSON(t *testing.T, path string) int {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("%s is not valid gzip: %v", path, err)
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var entry models.CertificateEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Errorf("%s: invalid JSON line: %v", path, err)
+		}
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return lines
+}
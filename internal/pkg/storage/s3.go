@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"domain_watcher/pkg/models"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const (
+	defaultS3FlushInterval = 5 * time.Minute
+	defaultS3Region        = "us-east-1"
+
+	// s3MaxObjectBytes is the size at which buffered entries are uploaded
+	// without waiting for the flush interval.
+	s3MaxObjectBytes = 64 << 20
+
+	// s3MaxPendingObjects bounds how many objects are kept for retry while
+	// the bucket is unreachable.
+	s3MaxPendingObjects = 10
+
+	s3RequestTimeout = time.Minute
+)
+
+// S3Config configures an S3Handler.
+type S3Config struct {
+	// Bucket receives the objects; it must already exist.
+	Bucket string
+
+	// Prefix is prepended to object keys, e.g. "ct/".
+	Prefix string
+
+	// Endpoint replaces the AWS endpoint for S3-compatible stores such as
+	// MinIO, e.g. http://localhost:9000. Path-style addressing is used with
+	// it.
+	Endpoint string
+
+	// Region overrides the region from the AWS configuration; us-east-1 is
+	// used if neither sets one.
+	Region string
+
+	// FlushInterval is how often buffered entries are uploaded; zero uses
+	// five minutes.
+	FlushInterval time.Duration
+}
+
+// s3Object is an upload waiting to be sent, or to be retried.
+type s3Object struct {
+	key  string
+	data []byte
+}
+
+// S3Handler archives entries as NDJSON objects in an S3 bucket. Entries are
+// buffered and uploaded every flush interval, when the buffer reaches 64 MiB
+// or when the hour changes, under keys partitioned by hour:
+//
+//	<prefix>YYYY/MM/DD/HH/<upload time>-<random>.ndjson
+//
+// Credentials come from the usual AWS sources (environment, shared config
+// or instance role). A failed upload is retried with the same key at the
+// next flush, so a retry never duplicates entries. Uploads run on a
+// background goroutine, so a slow or unreachable bucket never blocks Handle.
+type S3Handler struct {
+	client        *s3.Client
+	bucket        string
+	prefix        string
+	flushInterval time.Duration
+	now           func() time.Time
+
+	mutex   sync.Mutex
+	hour    time.Time // partition of the buffered entries
+	buffer  bytes.Buffer
+	pending []s3Object
+
+	// flushMutex serializes flushes, so an object is uploaded once
+	flushMutex sync.Mutex
+	sealed     chan struct{}
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewS3Handler creates a handler uploading to cfg.Bucket. It fails if the
+// bucket can't be reached with the configured credentials.
+func NewS3Handler(cfg S3Config) (*S3Handler, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultS3FlushInterval
+	}
+	prefix := strings.TrimPrefix(cfg.Prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+	defer cancel()
+
+	var options []func(*config.LoadOptions) error
+	if cfg.Region != "" {
+		options = append(options, config.WithRegion(cfg.Region))
+	}
+	awsConfig, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if awsConfig.Region == "" {
+		awsConfig.Region = defaultS3Region
+	}
+	client := s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	if _, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(cfg.Bucket)}); err != nil {
+		return nil, fmt.Errorf("failed to access S3 bucket %s: %w", cfg.Bucket, err)
+	}
+
+	h := &S3Handler{
+		client:        client,
+		bucket:        cfg.Bucket,
+		prefix:        prefix,
+		flushInterval: cfg.FlushInterval,
+		now:           time.Now,
+		sealed:        make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go h.flushLoop()
+	return h, nil
+}
+
+// Handle buffers entry, sealing the buffer for upload first if it belongs
+// to an earlier hour and afterwards if it is full. Sealed objects are
+// uploaded by the flush loop.
+func (h *S3Handler) Handle(entry *models.CertificateEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	h.mutex.Lock()
+	hour := h.now().UTC().Truncate(time.Hour)
+	sealed := false
+	if h.buffer.Len() > 0 && !hour.Equal(h.hour) {
+		h.seal()
+		sealed = true
+	}
+	h.hour = hour
+	h.buffer.Write(line)
+	h.buffer.WriteByte('\n')
+	if h.buffer.Len() >= s3MaxObjectBytes {
+		h.seal()
+		sealed = true
+	}
+	h.mutex.Unlock()
+
+	if sealed {
+		select {
+		case h.sealed <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// seal moves the buffered entries into an object waiting for upload. The
+// caller holds h.mutex.
+func (h *S3Handler) seal() {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	key := h.prefix + h.hour.Format("2006/01/02/15/") +
+		h.now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix) + ".ndjson"
+
+	h.pending = append(h.pending, s3Object{key: key, data: bytes.Clone(h.buffer.Bytes())})
+	h.buffer.Reset()
+	h.trimPending()
+}
+
+// trimPending drops the oldest objects past s3MaxPendingObjects. The caller
+// holds h.mutex.
+func (h *S3Handler) trimPending() {
+	if len(h.pending) > s3MaxPendingObjects {
+		log.Printf("S3 unreachable, dropping %d queued objects", len(h.pending)-s3MaxPendingObjects)
+		h.pending = h.pending[len(h.pending)-s3MaxPendingObjects:]
+	}
+}
+
+// Flush uploads the buffered entries and any objects left by failed
+// uploads. Objects that fail are kept for the next flush, up to a bound
+// past which the oldest are dropped.
+func (h *S3Handler) Flush() error {
+	h.mutex.Lock()
+	if h.buffer.Len() > 0 {
+		h.seal()
+	}
+	h.mutex.Unlock()
+	return h.uploadPending()
+}
+
+// uploadPending uploads the sealed objects. They are taken under the lock
+// and uploaded without it, so Handle never waits for S3.
+func (h *S3Handler) uploadPending() error {
+	h.flushMutex.Lock()
+	defer h.flushMutex.Unlock()
+
+	h.mutex.Lock()
+	objects := h.pending
+	h.pending = nil
+	h.mutex.Unlock()
+
+	for i, object := range objects {
+		if err := h.upload(object); err != nil {
+			h.mutex.Lock()
+			h.pending = append(objects[i:len(objects):len(objects)], h.pending...)
+			h.trimPending()
+			h.mutex.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *S3Handler) upload(object s3Object) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3RequestTimeout)
+	defer cancel()
+
+	_, err := h.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(h.bucket),
+		Key:         aws.String(object.key),
+		Body:        bytes.NewReader(object.data),
+		ContentType: aws.String("application/x-ndjson"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload s3://%s/%s: %w", h.bucket, object.key, err)
+	}
+	return nil
+}
+
+// flushLoop uploads buffered entries every flush interval, and sealed
+// objects as soon as Handle seals them, until Close.
+func (h *S3Handler) flushLoop() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := h.Flush(); err != nil {
+				log.Printf("Failed to flush entries to S3: %v", err)
+			}
+		case <-h.sealed:
+			if err := h.uploadPending(); err != nil {
+				log.Printf("Failed to upload entries to S3: %v", err)
+			}
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+// Close stops the periodic flush and uploads the remaining entries.
+func (h *S3Handler) Close() error {
+	h.closeOnce.Do(func() {
+		close(h.stop)
+		<-h.done
+	})
+	return h.Flush()
+}
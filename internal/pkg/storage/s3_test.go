@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 stores the objects put into bucket "certs", refusing the next
+// failPuts uploads with AccessDenied. Uploads wait for stall to close, if
+// set.
+type fakeS3 struct {
+	mutex    sync.Mutex
+	objects  map[string][]byte
+	failPuts int
+	stall    chan struct{}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.stall != nil && r.Method == http.MethodPut {
+		<-f.stall
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch {
+	case r.Method == http.MethodHead && r.URL.Path == "/certs":
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/certs/"):
+		if f.failPuts > 0 {
+			f.failPuts--
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>denied</Message></Error>`))
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.objects[strings.TrimPrefix(r.URL.Path, "/certs/")] = data
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeS3) keys() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newTestS3Handler(t *testing.T, fake *fakeS3) *S3Handler {
+	t.Helper()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	handler, err := NewS3Handler(S3Config{Bucket: "certs", Prefix: "ct", Endpoint: server.URL, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewS3Handler() error: %v", err)
+	}
+	return handler
+}
+
+func TestS3HandlerPartitionsByHour(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	handler := newTestS3Handler(t, fake)
+
+	now := time.Date(2025, 3, 1, 12, 59, 0, 0, time.UTC)
+	handler.now = func() time.Time { return now }
+	for i := 0; i < 2; i++ {
+		if err := handler.Handle(testEntry()); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+	if keys := fake.keys(); len(keys) != 0 {
+		t.Fatalf("Expected entries to be buffered, got uploads %v", keys)
+	}
+
+	// The next hour's first entry uploads the previous hour's
+	now = now.Add(2 * time.Minute)
+	if err := handler.Handle(testEntry()); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	keys := fake.keys()
+	if len(keys) != 2 || !strings.HasPrefix(keys[0], "ct/2025/03/01/12/") || !strings.HasPrefix(keys[1], "ct/2025/03/01/13/") {
+		t.Fatalf("Expected one object per hour under the prefix, got %v", keys)
+	}
+	for i, want := range []int{2, 1} {
+		var read int
+		scanner := bufio.NewScanner(bytes.NewReader(fake.objects[keys[i]]))
+		for scanner.Scan() {
+			read++
+		}
+		if read != want || !strings.HasSuffix(keys[i], ".ndjson") {
+			t.Errorf("Expected %d entries in %s, got %d", want, keys[i], read)
+		}
+	}
+}
+
+func TestS3HandlerRetriesFailedUploads(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string][]byte), failPuts: 1}
+	handler := newTestS3Handler(t, fake)
+	defer handler.Close()
+
+	handler.Handle(testEntry())
+	if err := handler.Flush(); err == nil {
+		t.Fatal("Expected the refused upload to fail")
+	}
+	handler.Handle(testEntry())
+	if err := handler.Flush(); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+
+	// The failed object is retried as it was, and the new entry follows
+	keys := fake.keys()
+	if len(keys) != 2 {
+		t.Fatalf("Expected the retried object and a new one, got %v", keys)
+	}
+	for _, key := range keys {
+		if lines := bytes.Count(fake.objects[key], []byte("\n")); lines != 1 {
+			t.Errorf("Expected 1 entry in %s, got %d", key, lines)
+		}
+	}
+}
+
+func TestS3HandlerDoesNotBlock(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string][]byte), stall: make(chan struct{})}
+	handler := newTestS3Handler(t, fake)
+
+	// Each hour seals an object; a stalled bucket holds the flush loop only
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	handler.now = func() time.Time { return now }
+	start := time.Now()
+	for i := 0; i < 3*s3MaxPendingObjects; i++ {
+		handler.Handle(testEntry())
+		handler.mutex.Lock()
+		now = now.Add(time.Hour)
+		handler.mutex.Unlock()
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Handle blocked for %v on a stalled bucket", elapsed)
+	}
+
+	handler.mutex.Lock()
+	pending := len(handler.pending)
+	handler.mutex.Unlock()
+	if pending > s3MaxPendingObjects {
+		t.Errorf("Expected at most %d pending objects, got %d", s3MaxPendingObjects, pending)
+	}
+
+	close(fake.stall)
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+}
+
+func TestNewS3HandlerRequiresBucket(t *testing.T) {
+	if _, err := NewS3Handler(S3Config{}); err == nil {
+		t.Error("Expected an error without a bucket")
+	}
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"database/sql"
+	"domain_watcher/pkg/models"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS certificates (
+	id               INTEGER PRIMARY KEY AUTOINCREMENT,
+	fingerprint      TEXT,
+	idempotency_key  TEXT,
+	domain           TEXT NOT NULL,
+	common_name      TEXT,
+	organization     TEXT,
+	issuer           TEXT,
+	issuer_canonical TEXT,
+	serial_number    TEXT,
+	not_before       TEXT,
+	not_after        TEXT,
+	seen_at          TEXT NOT NULL,
+	log_url          TEXT,
+	log_index        INTEGER,
+	keyword          TEXT,
+	cn_not_in_san    INTEGER NOT NULL DEFAULT 0
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_certificates_fingerprint ON certificates(fingerprint);
+CREATE INDEX IF NOT EXISTS idx_certificates_domain ON certificates(domain);
+CREATE INDEX IF NOT EXISTS idx_certificates_seen_at ON certificates(seen_at);
+
+CREATE TABLE IF NOT EXISTS subdomains (
+	certificate_id INTEGER NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+	name           TEXT NOT NULL,
+	PRIMARY KEY (certificate_id, name)
+);
+CREATE INDEX IF NOT EXISTS idx_subdomains_name ON subdomains(name);
+
+CREATE TABLE IF NOT EXISTS chain_certs (
+	certificate_id INTEGER NOT NULL REFERENCES certificates(id) ON DELETE CASCADE,
+	position       INTEGER NOT NULL,
+	common_name    TEXT,
+	organization   TEXT,
+	issuer         TEXT,
+	serial_number  TEXT,
+	not_before     TEXT,
+	not_after      TEXT,
+	PRIMARY KEY (certificate_id, position)
+);
+`
+
+// SQLiteHandler stores entries in a SQLite database with one table each for
+// certificates, their names and their chain, so history can be queried with
+// plain SQL. Certificates are unique on fingerprint, which makes storing the
+// same certificate again a no-op.
+type SQLiteHandler struct {
+	db              *sql.DB
+	insertCert      *sql.Stmt
+	insertSubdomain *sql.Stmt
+	insertChain     *sql.Stmt
+}
+
+// NewSQLiteHandler opens (or creates) the database at path and creates the
+// schema if it is missing.
+func NewSQLiteHandler(path string) (*SQLiteHandler, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() +
+		"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite allows a single writer; one connection avoids SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	h := &SQLiteHandler{db: db}
+	statements := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&h.insertCert, `INSERT INTO certificates (
+			fingerprint, idempotency_key, domain, common_name, organization, issuer, issuer_canonical,
+			serial_number, not_before, not_after, seen_at, log_url, log_index, keyword, cn_not_in_san
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(fingerprint) DO NOTHING`},
+		{&h.insertSubdomain, `INSERT OR IGNORE INTO subdomains (certificate_id, name) VALUES (?, ?)`},
+		{&h.insertChain, `INSERT OR IGNORE INTO chain_certs (
+			certificate_id, position, common_name, organization, issuer, serial_number, not_before, not_after
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`},
+	}
+	for _, s := range statements {
+		if *s.stmt, err = db.Prepare(s.query); err != nil {
+			h.Close()
+			return nil, fmt.Errorf("failed to prepare statement: %w", err)
+		}
+	}
+
+	return h, nil
+}
+
+func (h *SQLiteHandler) Handle(entry *models.CertificateEntry) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	leaf := entry.LeafCert
+	result, err := tx.Stmt(h.insertCert).Exec(
+		nullString(leaf.Fingerprint),
+		nullString(entry.IdempotencyKey),
+		entry.Domain,
+		leaf.Subject.CommonName,
+		leaf.Subject.Organization,
+		leaf.IssuerDistinguishedName,
+		nullString(leaf.IssuerCanonical),
+		leaf.SerialNumber,
+		sqliteTime(leaf.NotBefore),
+		sqliteTime(leaf.NotAfter),
+		sqliteTime(entry.Timestamp),
+		entry.LogURL,
+		entry.Index,
+		nullString(entry.Keyword),
+		entry.CNNotInSAN,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert certificate: %w", err)
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		return nil // Already stored
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to read certificate id: %w", err)
+	}
+
+	insertSubdomain := tx.Stmt(h.insertSubdomain)
+	for _, name := range entry.Subdomains {
+		if _, err := insertSubdomain.Exec(id, name); err != nil {
+			return fmt.Errorf("failed to insert subdomain: %w", err)
+		}
+	}
+
+	insertChain := tx.Stmt(h.insertChain)
+	for i, cert := range entry.Chain {
+		if _, err := insertChain.Exec(id, i,
+			cert.Subject.CommonName,
+			cert.Subject.Organization,
+			cert.IssuerDistinguishedName,
+			cert.SerialNumber,
+			sqliteTime(cert.NotBefore),
+			sqliteTime(cert.NotAfter),
+		); err != nil {
+			return fmt.Errorf("failed to insert chain certificate: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit certificate: %w", err)
+	}
+	return nil
+}
+
+// Close releases the prepared statements and closes the database.
+func (h *SQLiteHandler) Close() error {
+	for _, stmt := range []*sql.Stmt{h.insertCert, h.insertSubdomain, h.insertChain} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+	return h.db.Close()
+}
+
+// sqliteTime stores times as sortable RFC 3339 UTC text, or NULL when unset.
+func sqliteTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
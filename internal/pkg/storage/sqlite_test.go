@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"domain_watcher/pkg/models"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "certs.db")
+	handler, err := NewSQLiteHandler(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteHandler() error: %v", err)
+	}
+	defer func() { handler.Close() }()
+
+	entry := testEntry()
+	entry.LeafCert.Fingerprint = "AA:BB:CC"
+	entry.Chain = []models.ChainCert{{
+		Subject:                 models.Subject{CommonName: "R3", Organization: "Let's Encrypt"},
+		IssuerDistinguishedName: "ISRG Root X1",
+		NotBefore:               time.Date(2020, 9, 4, 0, 0, 0, 0, time.UTC),
+	}}
+
+	// Storing the same certificate twice is a no-op
+	for i := 0; i < 2; i++ {
+		if err := handler.Handle(entry); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+	other := testEntry()
+	other.LeafCert.Fingerprint = "DD:EE:FF"
+	other.Subdomains = []string{"api.example.com"}
+	if err := handler.Handle(other); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+
+	count := func(query string, args ...interface{}) int {
+		var n int
+		if err := handler.db.QueryRow(query, args...).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return n
+	}
+	if n := count(`SELECT COUNT(*) FROM certificates`); n != 2 {
+		t.Errorf("Expected 2 certificates, got %d", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM subdomains`); n != 3 {
+		t.Errorf("Expected 3 subdomain rows, got %d", n)
+	}
+	if n := count(`SELECT COUNT(*) FROM chain_certs WHERE issuer = ?`, "ISRG Root X1"); n != 1 {
+		t.Errorf("Expected 1 chain row, got %d", n)
+	}
+	if n := count(`SELECT COUNT(DISTINCT c.id) FROM certificates c JOIN subdomains s ON s.certificate_id = c.id WHERE s.name = ?`, "www.example.com"); n != 1 {
+		t.Errorf("Expected 1 certificate naming www.example.com, got %d", n)
+	}
+
+	// Reopening keeps the data and the schema
+	handler.Close()
+	handler, err = NewSQLiteHandler(path)
+	if err != nil {
+		t.Fatalf("Reopen error: %v", err)
+	}
+	if n := count(`SELECT COUNT(*) FROM certificates WHERE domain = ?`, "example.com"); n != 2 {
+		t.Errorf("Expected 2 certificates after reopening, got %d", n)
+	}
+}
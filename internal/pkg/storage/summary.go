@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"database/sql"
+	"domain_watcher/pkg/models"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IssuerCount is an issuer and the number of stored certificates it signed.
+type IssuerCount struct {
+	Issuer string `json:"issuer"`
+	Count  int    `json:"count"`
+}
+
+// StoreSummary aggregates the certificates in a store. Days are UTC dates
+// (2006-01-02) of when each certificate was seen.
+type StoreSummary struct {
+	Total           int            `json:"total"`
+	MatchesByDomain map[string]int `json:"matches_by_domain"`
+	TopIssuers      []IssuerCount  `json:"top_issuers"`
+	PerDay          map[string]int `json:"per_day"`
+}
+
+func newStoreSummary() *StoreSummary {
+	return &StoreSummary{
+		MatchesByDomain: make(map[string]int),
+		TopIssuers:      []IssuerCount{},
+		PerDay:          make(map[string]int),
+	}
+}
+
+// IsSQLiteStore reports whether path names a SQLite database written by
+// SQLiteHandler rather than file output, judging by its extension.
+func IsSQLiteStore(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".db", ".sqlite", ".sqlite3":
+		return true
+	}
+	return false
+}
+
+// SummarizeStore aggregates the store at path, a SQLite database or any
+// file output ReadEntries understands, keeping the topIssuers most frequent
+// issuers (all of them when topIssuers is not positive).
+func SummarizeStore(path string, topIssuers int) (*StoreSummary, error) {
+	if IsSQLiteStore(path) {
+		return summarizeSQLite(path, topIssuers)
+	}
+	return summarizeEntries(path, topIssuers)
+}
+
+func summarizeEntries(path string, topIssuers int) (*StoreSummary, error) {
+	summary := newStoreSummary()
+	issuers := make(map[string]int)
+	seen := make(map[string]bool)
+
+	err := ReadEntries(path, func(entry *models.CertificateEntry) error {
+		// The same certificate may be stored more than once
+		if key := entry.IdempotencyKey; key != "" {
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+		}
+
+		summary.Total++
+		summary.MatchesByDomain[entry.Domain]++
+		if issuer := summaryIssuer(entry.LeafCert); issuer != "" {
+			issuers[issuer]++
+		}
+		if !entry.Timestamp.IsZero() {
+			summary.PerDay[entry.Timestamp.UTC().Format("2006-01-02")]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for issuer, count := range issuers {
+		summary.TopIssuers = append(summary.TopIssuers, IssuerCount{Issuer: issuer, Count: count})
+	}
+	sortIssuers(summary.TopIssuers)
+	if topIssuers > 0 && len(summary.TopIssuers) > topIssuers {
+		summary.TopIssuers = summary.TopIssuers[:topIssuers]
+	}
+	return summary, nil
+}
+
+// summaryIssuer groups by the canonical CA name when the entry has one, as
+// the monitor's own stats do.
+func summaryIssuer(leaf models.LeafCertificate) string {
+	if leaf.IssuerCanonical != "" {
+		return leaf.IssuerCanonical
+	}
+	return leaf.IssuerDistinguishedName
+}
+
+func sortIssuers(issuers []IssuerCount) {
+	sort.Slice(issuers, func(i, j int) bool {
+		if issuers[i].Count != issuers[j].Count {
+			return issuers[i].Count > issuers[j].Count
+		}
+		return issuers[i].Issuer < issuers[j].Issuer
+	})
+}
+
+func summarizeSQLite(path string, topIssuers int) (*StoreSummary, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open store %s: %w", path, err)
+	}
+
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?mode=ro&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	summary := newStoreSummary()
+	if err := db.QueryRow(`SELECT COUNT(*) FROM certificates`).Scan(&summary.Total); err != nil {
+		return nil, fmt.Errorf("failed to count certificates: %w", err)
+	}
+
+	groups := []struct {
+		query string
+		add   func(key string, count int)
+	}{
+		{`SELECT domain, COUNT(*) FROM certificates GROUP BY domain`,
+			func(key string, count int) { summary.MatchesByDomain[key] = count }},
+		{`SELECT COALESCE(issuer_canonical, issuer), COUNT(*) FROM certificates
+			WHERE COALESCE(issuer_canonical, issuer, '') != '' GROUP BY 1`,
+			func(key string, count int) {
+				summary.TopIssuers = append(summary.TopIssuers, IssuerCount{Issuer: key, Count: count})
+			}},
+		{`SELECT substr(seen_at, 1, 10), COUNT(*) FROM certificates GROUP BY 1`,
+			func(key string, count int) { summary.PerDay[key] = count }},
+	}
+	for _, group := range groups {
+		if err := queryCounts(db, group.query, group.add); err != nil {
+			return nil, err
+		}
+	}
+
+	sortIssuers(summary.TopIssuers)
+	if topIssuers > 0 && len(summary.TopIssuers) > topIssuers {
+		summary.TopIssuers = summary.TopIssuers[:topIssuers]
+	}
+	return summary, nil
+}
+
+func queryCounts(db *sql.DB, query string, add func(key string, count int)) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return fmt.Errorf("failed to query certificates: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var count int
+		if err := rows.Scan(&key, &count); err != nil {
+			return fmt.Errorf("failed to read counts: %w", err)
+		}
+		add(key, count)
+	}
+	return rows.Err()
+}
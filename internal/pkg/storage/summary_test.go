@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"domain_watcher/pkg/models"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSummarizeStore(t *testing.T) {
+	dir := t.TempDir()
+	ndjson, err := NewNDJSONHandler(filepath.Join(dir, "certs.ndjson"))
+	if err != nil {
+		t.Fatalf("NewNDJSONHandler() error: %v", err)
+	}
+	sqlite, err := NewSQLiteHandler(filepath.Join(dir, "certs.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteHandler() error: %v", err)
+	}
+
+	day := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	specs := []struct {
+		domain, issuer, fingerprint string
+		seen                        time.Time
+	}{
+		{"example.com", "R3", "01", day},
+		{"example.com", "R3", "02", day.Add(time.Hour)},
+		{"example.com", "E1", "03", day.AddDate(0, 0, 1)},
+		{"example.org", "R3", "04", day.AddDate(0, 0, 1)},
+		{"example.org", "R3", "04", day.AddDate(0, 0, 1)}, // stored twice
+	}
+	for _, spec := range specs {
+		entry := testEntry()
+		entry.Domain = spec.domain
+		entry.LeafCert.IssuerDistinguishedName = spec.issuer
+		entry.LeafCert.Fingerprint = spec.fingerprint
+		entry.IdempotencyKey = entry.ComputeIdempotencyKey()
+		entry.Timestamp = spec.seen
+		for _, handler := range []interface {
+			Handle(*models.CertificateEntry) error
+		}{ndjson, sqlite} {
+			if err := handler.Handle(entry); err != nil {
+				t.Fatalf("Handle() error: %v", err)
+			}
+		}
+	}
+	ndjson.Close()
+	sqlite.Close()
+
+	expected := &StoreSummary{
+		Total:           4,
+		MatchesByDomain: map[string]int{"example.com": 3, "example.org": 1},
+		TopIssuers:      []IssuerCount{{Issuer: "R3", Count: 3}},
+		PerDay:          map[string]int{"2025-03-01": 2, "2025-03-02": 2},
+	}
+	for _, store := range []string{"certs.ndjson", "certs.db"} {
+		summary, err := SummarizeStore(filepath.Join(dir, store), 1)
+		if err != nil {
+			t.Fatalf("SummarizeStore(%s) error: %v", store, err)
+		}
+		if !reflect.DeepEqual(summary, expected) {
+			t.Errorf("SummarizeStore(%s) = %+v, expected %+v", store, summary, expected)
+		}
+	}
+}
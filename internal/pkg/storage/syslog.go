@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syslogNotice is the severity used for certificate entries.
+const syslogNotice = 5
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3,
+	"auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// localSyslogSockets are tried in order when writing to the local daemon.
+var localSyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogHandler sends each entry as JSON in an RFC 5424 message to a local
+// or remote syslog server. Lost connections are re-established on the next
+// write.
+type SyslogHandler struct {
+	network  string
+	addr     string
+	facility int
+	hostname string
+	mutex    sync.Mutex
+	conn     net.Conn
+}
+
+// NewSyslogHandler connects to addr, which is "local" for the local syslog
+// socket or [udp://|tcp://]host:port for a remote server (UDP by default).
+// facility is a syslog facility name such as "daemon" or "local0".
+func NewSyslogHandler(addr, facility string) (*SyslogHandler, error) {
+	code, ok := syslogFacilities[strings.ToLower(facility)]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility: %s", facility)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	h := &SyslogHandler{
+		facility: code,
+		hostname: hostname,
+	}
+
+	switch {
+	case addr == "local":
+		h.network = "unixgram"
+	case strings.HasPrefix(addr, "udp://"):
+		h.network, h.addr = "udp", strings.TrimPrefix(addr, "udp://")
+	case strings.HasPrefix(addr, "tcp://"):
+		h.network, h.addr = "tcp", strings.TrimPrefix(addr, "tcp://")
+	default:
+		h.network, h.addr = "udp", addr
+	}
+
+	if err := h.connect(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *SyslogHandler) Handle(entry *models.CertificateEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	message := h.format(data)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.conn == nil {
+		if err := h.connect(); err != nil {
+			return err
+		}
+	}
+	if _, err := h.conn.Write(message); err == nil {
+		return nil
+	}
+
+	// Reconnect once and retry, e.g. after the server restarted
+	h.conn.Close()
+	h.conn = nil
+	if err := h.connect(); err != nil {
+		return err
+	}
+	if _, err := h.conn.Write(message); err != nil {
+		h.conn.Close()
+		h.conn = nil
+		return fmt.Errorf("failed to write to syslog: %w", err)
+	}
+	return nil
+}
+
+func (h *SyslogHandler) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.conn != nil {
+		err := h.conn.Close()
+		h.conn = nil
+		return err
+	}
+	return nil
+}
+
+// format builds an RFC 5424 message, using octet-counting framing over TCP.
+func (h *SyslogHandler) format(msg []byte) []byte {
+	header := fmt.Sprintf("<%d>1 %s %s domain_watcher %d certificate - ",
+		h.facility*8+syslogNotice,
+		time.Now().UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		h.hostname,
+		os.Getpid(),
+	)
+	message := append([]byte(header), msg...)
+
+	if h.network == "tcp" {
+		return append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+	}
+	return message
+}
+
+func (h *SyslogHandler) connect() error {
+	if h.network == "unixgram" {
+		var lastErr error
+		for _, socket := range localSyslogSockets {
+			conn, err := net.Dial("unixgram", socket)
+			if err == nil {
+				h.conn = conn
+				return nil
+			}
+			lastErr = err
+		}
+		return fmt.Errorf("failed to connect to local syslog: %w", lastErr)
+	}
+
+	conn, err := net.DialTimeout(h.network, h.addr, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog at %s: %w", h.addr, err)
+	}
+	h.conn = conn
+	return nil
+}
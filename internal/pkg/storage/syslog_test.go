@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogHandlerUDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	handler, err := NewSyslogHandler("udp://"+listener.LocalAddr().String(), "local0")
+	if err != nil {
+		t.Fatalf("NewSyslogHandler() error: %v", err)
+	}
+	defer handler.Close()
+
+	if err := handler.Handle(testEntry()); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+
+	buf := make([]byte, 64*1024)
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := listener.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Failed to read syslog message: %v", err)
+	}
+	message := string(buf[:n])
+
+	// local0 (16) * 8 + notice (5)
+	if !strings.HasPrefix(message, "<133>1 ") {
+		t.Errorf("Expected RFC 5424 header with PRI 133, got %q", message)
+	}
+	if !strings.Contains(message, " domain_watcher ") || !strings.Contains(message, `"domain":"example.com"`) {
+		t.Errorf("Expected app name and JSON entry in message, got %q", message)
+	}
+}
+
+func TestNewSyslogHandlerRejectsUnknownFacility(t *testing.T) {
+	if _, err := NewSyslogHandler("127.0.0.1:514", "nonsense"); err == nil {
+		t.Error("Expected error for unknown facility")
+	}
+}
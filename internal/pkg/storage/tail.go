@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"domain_watcher/pkg/models"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// tailPollInterval is how often TailEntries checks for new lines.
+const tailPollInterval = 500 * time.Millisecond
+
+// TailEntries follows the JSON lines file at path like tail -f, calling fn
+// for every entry appended to it until ctx is done. With fromStart the
+// entries already in the file are read first. Lines that aren't entries
+// are skipped, as in ReadEntries. A file that is truncated or replaced, as
+// when a log is rotated, is reopened and read from its start.
+func TailEntries(ctx context.Context, path string, fromStart bool, fn func(*models.CertificateEntry) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer func() { file.Close() }()
+
+	if !fromStart {
+		if _, err := file.Seek(0, io.SeekEnd); err != nil {
+			return fmt.Errorf("failed to seek %s: %w", path, err)
+		}
+	}
+
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+
+	reader := bufio.NewReader(file)
+	var line []byte
+	for {
+		// Read every complete line, keeping a partly written one for later
+		for {
+			chunk, err := reader.ReadBytes('\n')
+			line = append(line, chunk...)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			if entry, ok := parseEntryLine(bytes.TrimSpace(line)); ok {
+				if err := fn(entry); err != nil {
+					return err
+				}
+			}
+			line = line[:0]
+		}
+
+		reopened, err := reopenIfRotated(file, path)
+		if err != nil {
+			return err
+		}
+		if reopened != nil {
+			file.Close()
+			file = reopened
+			reader.Reset(file)
+			line = line[:0]
+		} else if truncated(file) {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to seek %s: %w", path, err)
+			}
+			reader.Reset(file)
+			line = line[:0]
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// reopenIfRotated opens path again if it is no longer the open file. While
+// path is missing, between a rotation's rename and the new file being
+// created, the open file is kept.
+func reopenIfRotated(file *os.File, path string) (*os.File, error) {
+	current, err := os.Stat(path)
+	if err != nil {
+		return nil, nil
+	}
+	open, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if os.SameFile(open, current) {
+		return nil, nil
+	}
+
+	reopened, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reopen %s: %w", path, err)
+	}
+	return reopened, nil
+}
+
+// truncated reports whether the open file has shrunk below the position
+// already read.
+func truncated(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	offset, err := file.Seek(0, io.SeekCurrent)
+	return err == nil && info.Size() < offset
+}
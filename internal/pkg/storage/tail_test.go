@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTailEntriesFollowsAndReopens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "certs.ndjson")
+	line := func(domain string) []byte {
+		entry := testEntry()
+		entry.Domain = domain
+		data, _ := json.Marshal(entry)
+		return append(data, '\n')
+	}
+	if err := os.WriteFile(path, line("old.example"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	domains := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- TailEntries(ctx, path, false, func(entry *models.CertificateEntry) error {
+			domains <- entry.Domain
+			return nil
+		})
+	}()
+	next := func() string {
+		select {
+		case domain := <-domains:
+			return domain
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for an entry")
+			return ""
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// A line written in two parts is read once complete
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := line("new.example")
+	file.Write(first[:20])
+	time.Sleep(2 * tailPollInterval)
+	file.Write(first[20:])
+	file.Write([]byte("not an entry\n"))
+	file.Close()
+	if got := next(); got != "new.example" {
+		t.Fatalf("Expected the appended entry, got %s", got)
+	}
+
+	// After rotation the new file is read from its start
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, line("rotated.example"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(); got != "rotated.example" {
+		t.Fatalf("Expected the entry of the rotated file, got %s", got)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("TailEntries() error: %v", err)
+	}
+	if len(domains) != 0 {
+		t.Errorf("Unexpected extra entries: %d", len(domains))
+	}
+}
@@ -0,0 +1,107 @@
+package certwatch
+
+import (
+	"sync"
+	"time"
+)
+
+// IssuanceAnomaly describes a window in which a watched domain saw far more
+// new certificates than its recent baseline.
+type IssuanceAnomaly struct {
+	Domain      string        `json:"domain"`
+	Count       int           `json:"count"`
+	Baseline    float64       `json:"baseline"`
+	WindowStart time.Time     `json:"window_start"`
+	Window      time.Duration `json:"window"`
+}
+
+// anomalyDetector keeps per-domain issuance counts in fixed rolling windows
+// and flags a window whose count exceeds multiplier times the mean of the
+// previous windows.
+type anomalyDetector struct {
+	window     time.Duration
+	multiplier float64
+	minCount   int
+	history    int
+	mutex      sync.Mutex
+	domains    map[string]*issuanceWindow
+}
+
+type issuanceWindow struct {
+	start   time.Time
+	count   int
+	past    []int
+	alerted bool
+}
+
+func newAnomalyDetector(window time.Duration, multiplier float64, minCount int) *anomalyDetector {
+	return &anomalyDetector{
+		window:     window,
+		multiplier: multiplier,
+		minCount:   minCount,
+		history:    24,
+		domains:    make(map[string]*issuanceWindow),
+	}
+}
+
+// record counts one issuance for domain at now and returns an anomaly the
+// first time the current window crosses the threshold. Issuance older than
+// the current window, e.g. replayed out of order, is not counted.
+func (d *anomalyDetector) record(domain string, now time.Time) (IssuanceAnomaly, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	w, exists := d.domains[domain]
+	if !exists {
+		w = &issuanceWindow{start: now}
+		d.domains[domain] = w
+	}
+	if now.Before(w.start) {
+		return IssuanceAnomaly{}, false
+	}
+
+	// Roll forward, recording empty windows for any gap
+	if elapsed := now.Sub(w.start); elapsed >= d.window {
+		windows := int(elapsed / d.window)
+		w.past = append(w.past, w.count)
+		for i := 1; i < windows && i <= d.history; i++ {
+			w.past = append(w.past, 0)
+		}
+		if len(w.past) > d.history {
+			w.past = w.past[len(w.past)-d.history:]
+		}
+		w.start = w.start.Add(time.Duration(windows) * d.window)
+		w.count = 0
+		w.alerted = false
+	}
+
+	w.count++
+
+	// Without a previous window there is no baseline to compare against
+	if w.alerted || len(w.past) == 0 || w.count < d.minCount {
+		return IssuanceAnomaly{}, false
+	}
+
+	total := 0
+	for _, count := range w.past {
+		total += count
+	}
+	baseline := float64(total) / float64(len(w.past))
+
+	threshold := baseline
+	if threshold < 1 {
+		threshold = 1
+	}
+	if float64(w.count) <= d.multiplier*threshold {
+		return IssuanceAnomaly{}, false
+	}
+
+	w.alerted = true
+	return IssuanceAnomaly{
+		Domain:      domain,
+		Count:       w.count,
+		Baseline:    baseline,
+		WindowStart: w.start,
+		Window:      d.window,
+	}, true
+}
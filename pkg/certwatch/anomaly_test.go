@@ -0,0 +1,117 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAnomalyDetectorFiresOnBurst(t *testing.T) {
+	detector := newAnomalyDetector(time.Hour, 3, 5)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Baseline: two certificates per hour for three hours
+	for hour := 0; hour < 3; hour++ {
+		for i := 0; i < 2; i++ {
+			now := start.Add(time.Duration(hour)*time.Hour + time.Duration(i)*time.Minute)
+			if _, fired := detector.record("example.com", now); fired {
+				t.Fatalf("Unexpected anomaly during baseline at %v", now)
+			}
+		}
+	}
+
+	// Burst: twenty certificates in the fourth hour
+	burstStart := start.Add(3 * time.Hour)
+	var fired int
+	var anomaly IssuanceAnomaly
+	for i := 0; i < 20; i++ {
+		if a, ok := detector.record("example.com", burstStart.Add(time.Duration(i)*time.Second)); ok {
+			fired++
+			anomaly = a
+		}
+	}
+
+	if fired != 1 {
+		t.Fatalf("Expected exactly one anomaly for the burst, got %d", fired)
+	}
+	if anomaly.Domain != "example.com" || anomaly.Baseline != 2 {
+		t.Errorf("Unexpected anomaly: %+v", anomaly)
+	}
+	if anomaly.Count != 7 {
+		t.Errorf("Expected anomaly when count first exceeds 3x baseline (7), got %d", anomaly.Count)
+	}
+}
+
+func TestAnomalyDetectorNeedsBaseline(t *testing.T) {
+	detector := newAnomalyDetector(time.Hour, 3, 5)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 50; i++ {
+		if _, fired := detector.record("example.com", now.Add(time.Duration(i)*time.Second)); fired {
+			t.Fatal("Expected no anomaly without a previous window")
+		}
+	}
+}
+
+func TestMonitorAnomalyAlert(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+
+	var alerts []IssuanceAnomaly
+	monitor.SetAnomalyDetection(time.Hour, 2, 3, func(a IssuanceAnomaly) {
+		alerts = append(alerts, a)
+	})
+
+	// Seed a quiet previous window directly
+	monitor.anomalies.domains["example.com"] = &issuanceWindow{
+		start: time.Now().Add(-time.Minute),
+		past:  []int{1},
+	}
+
+	for i := 0; i < 5; i++ {
+		monitor.recordIssuance("example.com", time.Now())
+	}
+
+	if len(alerts) != 1 || alerts[0].Domain != "example.com" {
+		t.Errorf("Expected one anomaly alert for example.com, got %+v", alerts)
+	}
+}
+
+func TestMonitorAnomalyCountsEachCertificateOnce(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+
+	var alerts []IssuanceAnomaly
+	monitor.SetAnomalyDetection(time.Hour, 2, 3, func(a IssuanceAnomaly) {
+		alerts = append(alerts, a)
+	})
+	monitor.anomalies.domains["example.com"] = &issuanceWindow{
+		start: time.Now().Add(-time.Minute),
+		past:  []int{1},
+	}
+
+	// One certificate seen in several logs, and as precert and final
+	// certificate, then a backfill of older certificates
+	for i := 0; i < 5; i++ {
+		monitor.dispatch(&models.CertificateEntry{
+			Domain:    "example.com",
+			LeafCert:  models.LeafCertificate{SerialNumber: "01", IssuerDistinguishedName: "R3"},
+			Timestamp: time.Now(),
+		}, false)
+	}
+	for i := 0; i < 5; i++ {
+		monitor.dispatch(&models.CertificateEntry{
+			Domain:    "example.com",
+			LeafCert:  models.LeafCertificate{SerialNumber: fmt.Sprintf("%02x", i+2), IssuerDistinguishedName: "R3"},
+			Timestamp: time.Now(),
+		}, true)
+	}
+
+	if len(alerts) != 0 {
+		t.Errorf("Expected no anomaly, got %+v", alerts)
+	}
+	if count := monitor.anomalies.domains["example.com"].count; count != 1 {
+		t.Errorf("Expected the certificate to be counted once, got %d", count)
+	}
+}
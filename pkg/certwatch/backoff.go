@@ -0,0 +1,51 @@
+package certwatch
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	initialReconnectBackoff = time.Second
+	defaultMaxBackoff       = 60 * time.Second
+
+	// stableStreamPeriod is how long the live stream must stay up before a
+	// failure starts the backoff over.
+	stableStreamPeriod = time.Minute
+)
+
+// reconnectBackoff produces exponentially growing reconnect delays with
+// jitter, so many monitors losing the same certstream server do not all
+// reconnect at once.
+type reconnectBackoff struct {
+	initial time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+func newReconnectBackoff(max time.Duration) *reconnectBackoff {
+	if max < initialReconnectBackoff {
+		max = initialReconnectBackoff
+	}
+	return &reconnectBackoff{initial: initialReconnectBackoff, max: max, current: initialReconnectBackoff}
+}
+
+// next returns the delay before the next attempt, a random duration in the
+// upper half of the current step, and doubles the step up to max.
+func (b *reconnectBackoff) next() time.Duration {
+	step := b.current
+	b.current = min(b.current*2, b.max)
+
+	half := step / 2
+	return half + rand.N(half+1)
+}
+
+func (b *reconnectBackoff) reset() {
+	b.current = b.initial
+}
+
+// SetMaxReconnectBackoff caps the delay between live stream reconnect
+// attempts. Delays start at one second and double on each failure.
+func (m *Monitor) SetMaxReconnectBackoff(d time.Duration) {
+	m.maxBackoff = d
+}
@@ -0,0 +1,35 @@
+package certwatch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	backoff := newReconnectBackoff(10 * time.Second)
+
+	// Steps double from 1s up to the cap; each delay falls in the upper half
+	for _, step := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		step *= time.Second
+		delay := backoff.next()
+		if delay < step/2 || delay > step {
+			t.Errorf("Expected delay in [%v, %v], got %v", step/2, step, delay)
+		}
+	}
+
+	backoff.reset()
+	if delay := backoff.next(); delay > time.Second {
+		t.Errorf("Expected reset backoff to start at 1s, got %v", delay)
+	}
+}
+
+func TestReconnectBackoffJitter(t *testing.T) {
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 20; i++ {
+		backoff := newReconnectBackoff(time.Minute)
+		seen[backoff.next()] = true
+	}
+	if len(seen) < 2 {
+		t.Error("Expected reconnect delays to be jittered")
+	}
+}
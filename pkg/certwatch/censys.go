@@ -0,0 +1,194 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultCensysURL = "https://search.censys.io/api"
+
+	// censysMaxPages bounds how many result pages one lookup may fetch.
+	censysMaxPages = 10
+
+	// censysMaxAttempts bounds how often a rate-limited request is sent.
+	censysMaxAttempts = 4
+)
+
+// errCensysCredentials explains a missing or rejected Censys API ID and
+// secret, which Censys otherwise reports as a bare 401.
+var errCensysCredentials = errors.New("censys requires a valid API ID and secret from https://search.censys.io/account/api")
+
+// censysSearchResponse is the subset of Censys' v2 certificate search
+// response that is mapped into entries.
+type censysSearchResponse struct {
+	Result struct {
+		Hits []struct {
+			FingerprintSHA256 string   `json:"fingerprint_sha256"`
+			Names             []string `json:"names"`
+			Parsed            struct {
+				SubjectDN      string `json:"subject_dn"`
+				IssuerDN       string `json:"issuer_dn"`
+				SerialNumber   string `json:"serial_number"`
+				ValidityPeriod struct {
+					NotBefore time.Time `json:"not_before"`
+					NotAfter  time.Time `json:"not_after"`
+				} `json:"validity_period"`
+			} `json:"parsed"`
+		} `json:"hits"`
+		Links struct {
+			Next string `json:"next"`
+		} `json:"links"`
+	} `json:"result"`
+}
+
+// CensysProvider looks up historical certificates with the Censys Search v2
+// API, authenticated with an API ID and secret.
+type CensysProvider struct {
+	baseURL    string
+	apiID      string
+	secret     string
+	backoff    time.Duration // wait after a 429 without Retry-After, doubled each time
+	httpClient *http.Client
+}
+
+// NewCensysProvider returns a provider using the given Censys API
+// credentials.
+func NewCensysProvider(apiID, secret string) (*CensysProvider, error) {
+	if apiID == "" || secret == "" {
+		return nil, errCensysCredentials
+	}
+	return &CensysProvider{
+		baseURL:    defaultCensysURL,
+		apiID:      apiID,
+		secret:     secret,
+		backoff:    5 * time.Second,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Lookup returns certificates naming domain or its subdomains that became
+// valid within window. The search is narrowed to whole days and the results
+// filtered to the exact range.
+func (p *CensysProvider) Lookup(domain string, window TimeRange) ([]*models.CertificateEntry, error) {
+	q := fmt.Sprintf("names: %s", domain)
+	if window != (TimeRange{}) {
+		since, until := "*", "*"
+		if !window.Since.IsZero() {
+			since = window.Since.UTC().Format(time.DateOnly)
+		}
+		if !window.Until.IsZero() {
+			until = window.Until.UTC().AddDate(0, 0, 1).Format(time.DateOnly)
+		}
+		q += fmt.Sprintf(" and parsed.validity_period.not_before: [%s TO %s]", since, until)
+	}
+
+	entries := []*models.CertificateEntry{}
+	cursor := ""
+	for page := 0; page < censysMaxPages; page++ {
+		query := url.Values{}
+		query.Set("q", q)
+		query.Set("per_page", "100")
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+
+		result, err := p.search(query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hit := range result.Result.Hits {
+			if !window.Contains(hit.Parsed.ValidityPeriod.NotBefore) {
+				continue
+			}
+			commonName := dnField(hit.Parsed.SubjectDN, "CN")
+			entries = append(entries, &models.CertificateEntry{
+				Domain:     domain,
+				Subdomains: hit.Names,
+				LeafCert: models.LeafCertificate{
+					Subject:                 models.Subject{CommonName: commonName},
+					Extensions:              models.Extensions{SubjectAltName: hit.Names},
+					NotBefore:               hit.Parsed.ValidityPeriod.NotBefore,
+					NotAfter:                hit.Parsed.ValidityPeriod.NotAfter,
+					SerialNumber:            censysSerialHex(hit.Parsed.SerialNumber),
+					Fingerprint:             fingerprintFromHex(hit.FingerprintSHA256),
+					IssuerDistinguishedName: dnField(hit.Parsed.IssuerDN, "CN"),
+				},
+				Chain:      []models.ChainCert{},
+				Timestamp:  hit.Parsed.ValidityPeriod.NotBefore,
+				LogURL:     "https://search.censys.io/certificates/" + hit.FingerprintSHA256,
+				CNNotInSAN: cnNotInSAN(commonName, hit.Names),
+			})
+		}
+
+		cursor = result.Result.Links.Next
+		if cursor == "" {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// search fetches one page of results, waiting and retrying while Censys
+// rate-limits the account.
+func (p *CensysProvider) search(query url.Values) (*censysSearchResponse, error) {
+	wait := p.backoff
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, p.baseURL+"/v2/certificates/search?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(p.apiID, p.secret)
+
+		resp, err := p.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("censys request failed: %w", err)
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			var result censysSearchResponse
+			err := json.NewDecoder(resp.Body).Decode(&result)
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode censys response: %w", err)
+			}
+			return &result, nil
+		case http.StatusUnauthorized, http.StatusForbidden:
+			resp.Body.Close()
+			return nil, fmt.Errorf("censys returned %s: %w", resp.Status, errCensysCredentials)
+		case http.StatusTooManyRequests:
+			resp.Body.Close()
+			if attempt == censysMaxAttempts {
+				return nil, fmt.Errorf("censys rate limit still exceeded after %d attempts", attempt)
+			}
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+				wait = time.Duration(seconds) * time.Second
+			}
+			time.Sleep(wait)
+			wait *= 2
+		default:
+			resp.Body.Close()
+			return nil, fmt.Errorf("censys returned %s", resp.Status)
+		}
+	}
+}
+
+// censysSerialHex converts the decimal serial number Censys reports to the
+// hex form crt.sh and certstream use, so the same certificate has the same
+// serial from every provider. Anything else is returned as it is.
+func censysSerialHex(serial string) string {
+	n, ok := new(big.Int).SetString(serial, 10)
+	if !ok {
+		return serial
+	}
+	return n.Text(16)
+}
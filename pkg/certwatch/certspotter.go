@@ -0,0 +1,218 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	SourceCTLogs         = "ct-logs"
+	SourceCertspotterAPI = "certspotter-api"
+
+	defaultCertspotterAPI = "https://api.certspotter.com"
+)
+
+// certspotterIssuance is one result from certspotter's /v1/issuances API
+// with dns_names and issuer expanded.
+type certspotterIssuance struct {
+	ID         string    `json:"id"`
+	CertSHA256 string    `json:"cert_sha256"`
+	DNSNames   []string  `json:"dns_names"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+	Issuer     struct {
+		FriendlyName string `json:"friendly_name"`
+		Name         string `json:"name"`
+	} `json:"issuer"`
+}
+
+// SetSource selects where certificates come from in polling mode: SourceCTLogs
+// scans CT logs directly, SourceCertspotterAPI asks certspotter's hosted API
+// about each watched domain, which is far lighter for small watch lists.
+func (m *Monitor) SetSource(source string) error {
+	switch source {
+	case SourceCTLogs, SourceCertspotterAPI:
+		m.source = source
+		return nil
+	default:
+		return fmt.Errorf("unknown source: %s", source)
+	}
+}
+
+// SetCertspotterAPI overrides the certspotter API base URL and sets an
+// optional API token. Empty baseURL keeps the default.
+func (m *Monitor) SetCertspotterAPI(baseURL, token string) {
+	if baseURL != "" {
+		m.certspotterURL = strings.TrimRight(baseURL, "/")
+	}
+	m.certspotterToken = token
+}
+
+func (m *Monitor) startCertspotterMode() error {
+	m.logger.Info("Starting certificate transparency monitor using the certspotter API", "poll_interval", m.pollInterval)
+
+	// The first pass only records where each domain's issuances end
+	if !m.beginCycle() {
+		return nil
+	}
+	m.pollCertspotter()
+	m.endCycle()
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopChan:
+			m.logger.Info("Monitor stopped")
+			return nil
+		case <-m.ctx.Done():
+			m.logger.Info("Monitor stopped")
+			return nil
+		case <-ticker.C:
+			if !m.beginCycle() {
+				m.logger.Info("Monitor stopped")
+				return nil
+			}
+			m.pollCertspotter()
+			m.endCycle()
+		}
+	}
+}
+
+// pollCertspotter fetches new issuances for every watched domain and
+// dispatches matches once. A domain's first poll only establishes its cursor.
+func (m *Monitor) pollCertspotter() {
+	seen := make(map[string]bool)
+
+	for domain, config := range m.GetWatchedDomains() {
+		cursor, known := m.certspotterCursors[domain]
+
+		// The first poll pages through the history without keeping it
+		var handle func(certspotterIssuance)
+		if known {
+			handle = func(issuance certspotterIssuance) {
+				if seen[issuance.ID] {
+					return
+				}
+				seen[issuance.ID] = true
+				m.processCertspotterIssuance(issuance)
+			}
+		}
+
+		last, err := m.fetchCertspotterIssuances(domain, config.IncludeSubdomains, cursor, handle)
+		// Issuances up to last were handled even if a later page failed, but
+		// a partial first poll would leave the rest of the history to dispatch
+		if last != "" && (known || err == nil) {
+			m.certspotterCursors[domain] = last
+		}
+		if err != nil {
+			m.logger.Error("certspotter query failed", "domain", domain, "error", err)
+			continue
+		}
+		if !known {
+			m.logger.Debug("certspotter cursor initialized", "domain", domain, "issuance", last)
+		}
+	}
+}
+
+// fetchCertspotterIssuances pages through the issuances for domain after the
+// given one, passing each to handle as its page arrives, unless handle is nil.
+// It returns the ID of the last issuance received, or after if there were
+// none.
+func (m *Monitor) fetchCertspotterIssuances(domain string, includeSubdomains bool, after string, handle func(certspotterIssuance)) (string, error) {
+	last := after
+
+	for {
+		query := url.Values{}
+		query.Set("domain", domain)
+		query.Set("include_subdomains", fmt.Sprintf("%t", includeSubdomains))
+		query.Add("expand", "dns_names")
+		query.Add("expand", "issuer")
+		if last != "" {
+			query.Set("after", last)
+		}
+
+		req, err := http.NewRequestWithContext(m.ctx, http.MethodGet, m.certspotterURL+"/v1/issuances?"+query.Encode(), nil)
+		if err != nil {
+			return last, err
+		}
+		if m.certspotterToken != "" {
+			req.Header.Set("Authorization", "Bearer "+m.certspotterToken)
+		}
+
+		resp, err := m.httpClient.Do(req)
+		if err != nil {
+			return last, fmt.Errorf("request failed: %w", err)
+		}
+
+		var page []certspotterIssuance
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return last, fmt.Errorf("unexpected status: %s", resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return last, fmt.Errorf("failed to decode issuances: %w", err)
+		}
+
+		if len(page) == 0 {
+			return last, nil
+		}
+		if handle != nil {
+			for _, issuance := range page {
+				handle(issuance)
+			}
+		}
+		last = page[len(page)-1].ID
+	}
+}
+
+func (m *Monitor) processCertspotterIssuance(issuance certspotterIssuance) {
+	m.stats.recordSeen()
+
+	names := uniqueNames(issuance.DNSNames)
+	matchedDomain, reason, ok := m.MatchCertificate(names)
+	if !ok {
+		m.reportNearMiss(names)
+		return
+	}
+	m.updateLastSeen(matchedDomain)
+
+	issuer := issuance.Issuer.FriendlyName
+	if issuer == "" {
+		issuer = issuance.Issuer.Name
+	}
+
+	leaf := models.LeafCertificate{
+		Extensions:              models.Extensions{SubjectAltName: issuance.DNSNames},
+		NotBefore:               issuance.NotBefore,
+		NotAfter:                issuance.NotAfter,
+		IssuerDistinguishedName: issuer,
+		Fingerprint:             fingerprintFromHex(issuance.CertSHA256),
+	}
+	if m.canonIssuer {
+		leaf.IssuerCanonical = CanonicalIssuer(issuer, "")
+	}
+
+	entry := &models.CertificateEntry{
+		Domain:       matchedDomain,
+		Subdomains:   names,
+		MatchedNames: m.matchedNames(names, matchedDomain, reason),
+		LeafCert:     leaf,
+		Chain:        []models.ChainCert{},
+		Timestamp:    time.Now(),
+		LogURL:       m.certspotterURL,
+	}
+	entry.Wildcard = hasWildcardName(entry.MatchedNames)
+	entry.IdempotencyKey = entry.ComputeIdempotencyKey()
+
+	m.logger.Info("Found matching certificate", "domain", matchedDomain, "source", "certspotter", "issuance", issuance.ID)
+	m.dispatch(entry, false)
+}
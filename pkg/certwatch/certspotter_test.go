@@ -0,0 +1,180 @@
+package certwatch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockCertspotter serves /v1/issuances from a fixed, ordered issuance list,
+// honoring the after cursor and filtering by domain suffix. With pageSize
+// set, pages hold at most that many issuances; a request after failAfter
+// fails.
+type mockCertspotter struct {
+	mutex     sync.Mutex
+	issuances []map[string]interface{}
+	pageSize  int
+	failAfter string
+}
+
+func (s *mockCertspotter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	domain := r.URL.Query().Get("domain")
+	after := r.URL.Query().Get("after")
+	if s.failAfter != "" && after == s.failAfter {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	page := []map[string]interface{}{}
+	started := after == ""
+	for _, issuance := range s.issuances {
+		if !started {
+			started = issuance["id"] == after
+			continue
+		}
+		if s.pageSize > 0 && len(page) == s.pageSize {
+			break
+		}
+		for _, name := range issuance["dns_names"].([]string) {
+			if name == domain || strings.HasSuffix(name, "."+domain) {
+				page = append(page, issuance)
+				break
+			}
+		}
+	}
+	json.NewEncoder(w).Encode(page)
+}
+
+func (s *mockCertspotter) add(id string, names ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.issuances = append(s.issuances, map[string]interface{}{
+		"id":          id,
+		"cert_sha256": "sha-" + id,
+		"dns_names":   names,
+		"not_before":  "2025-01-01T00:00:00Z",
+		"not_after":   "2025-04-01T00:00:00Z",
+		"issuer":      map[string]string{"friendly_name": "Let's Encrypt", "name": "C=US, O=Let's Encrypt, CN=R3"},
+	})
+}
+
+func TestCertspotterSource(t *testing.T) {
+	api := &mockCertspotter{}
+	api.add("100", "old.example.com")
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+	monitor.AddDomain("example.net", true)
+	if err := monitor.SetSource(SourceCertspotterAPI); err != nil {
+		t.Fatalf("SetSource() error: %v", err)
+	}
+	monitor.SetCertspotterAPI(server.URL, "")
+
+	// First poll establishes cursors without dispatching history
+	monitor.pollCertspotter()
+	if len(handler.entries) != 0 {
+		t.Fatalf("Expected no dispatch on the first poll, got %d entries", len(handler.entries))
+	}
+
+	// A certificate covering both watched domains is dispatched once
+	api.add("101", "www.example.com", "www.example.net")
+	api.add("102", "api.example.com")
+	monitor.pollCertspotter()
+
+	if len(handler.entries) != 2 {
+		t.Fatalf("Expected 2 deduplicated entries, got %d", len(handler.entries))
+	}
+	for _, entry := range handler.entries {
+		if entry.LeafCert.IssuerDistinguishedName != "Let's Encrypt" || entry.LeafCert.Fingerprint == "" {
+			t.Errorf("Unexpected entry mapping: %+v", entry.LeafCert)
+		}
+	}
+
+	// Nothing new means nothing dispatched
+	monitor.pollCertspotter()
+	if len(handler.entries) != 2 {
+		t.Errorf("Expected no new entries, got %d total", len(handler.entries))
+	}
+}
+
+func TestCertspotterSourcePages(t *testing.T) {
+	api := &mockCertspotter{pageSize: 2}
+	for _, id := range []string{"100", "101", "102", "103", "104"} {
+		api.add(id, id+".example.com")
+	}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+	monitor.SetSource(SourceCertspotterAPI)
+	monitor.SetCertspotterAPI(server.URL, "")
+
+	// The first poll pages to the end of the history for its cursor
+	monitor.pollCertspotter()
+	if cursor := monitor.certspotterCursors["example.com"]; cursor != "104" || len(handler.entries) != 0 {
+		t.Fatalf("Expected cursor 104 and nothing dispatched, got %q and %d entries", cursor, len(handler.entries))
+	}
+
+	// A failed page keeps the cursor at the issuances already dispatched
+	for _, id := range []string{"105", "106", "107"} {
+		api.add(id, id+".example.com")
+	}
+	api.failAfter = "106"
+	monitor.pollCertspotter()
+	if cursor := monitor.certspotterCursors["example.com"]; cursor != "106" || len(handler.entries) != 2 {
+		t.Fatalf("Expected cursor 106 after 2 entries, got %q and %d entries", cursor, len(handler.entries))
+	}
+
+	api.failAfter = ""
+	monitor.pollCertspotter()
+	if len(handler.entries) != 3 || handler.entries[2].Subdomains[0] != "107.example.com" {
+		t.Errorf("Expected only 107 dispatched after the failure, got %d entries", len(handler.entries))
+	}
+}
+
+func TestCertspotterFirstPollFailureKeepsNoCursor(t *testing.T) {
+	api := &mockCertspotter{pageSize: 1, failAfter: "100"}
+	api.add("100", "a.example.com")
+	api.add("101", "b.example.com")
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+	monitor.SetSource(SourceCertspotterAPI)
+	monitor.SetCertspotterAPI(server.URL, "")
+
+	// A partial cursor would make the next poll dispatch 101 as new
+	monitor.pollCertspotter()
+	if _, known := monitor.certspotterCursors["example.com"]; known {
+		t.Fatal("Expected no cursor after a failed first poll")
+	}
+
+	api.failAfter = ""
+	monitor.pollCertspotter()
+	if cursor := monitor.certspotterCursors["example.com"]; cursor != "101" || len(handler.entries) != 0 {
+		t.Errorf("Expected cursor 101 and no history dispatched, got %q and %d entries", cursor, len(handler.entries))
+	}
+}
+
+func TestSetSourceRejectsUnknown(t *testing.T) {
+	monitor := NewMonitor()
+	if err := monitor.SetSource("carrier-pigeon"); err == nil {
+		t.Error("Expected error for unknown source")
+	}
+}
@@ -0,0 +1,100 @@
+package certwatch
+
+import (
+	"crypto/x509"
+	"domain_watcher/pkg/models"
+	"strings"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// chainCerts parses the issuing chain submitted with a CT log entry, leaf's
+// issuer first. Certificates that fail to parse are skipped.
+func chainCerts(chain []ct.ASN1Cert) []models.ChainCert {
+	certs := []models.ChainCert{}
+	for _, raw := range chain {
+		cert, err := x509.ParseCertificate(raw.Data)
+		if err != nil {
+			continue
+		}
+		certs = append(certs, models.ChainCert{
+			Subject:                 certSubject(cert),
+			IssuerDistinguishedName: cert.Issuer.CommonName,
+			NotBefore:               cert.NotBefore,
+			NotAfter:                cert.NotAfter,
+			SerialNumber:            cert.SerialNumber.String(),
+		})
+	}
+	return certs
+}
+
+func certSubject(cert *x509.Certificate) models.Subject {
+	return models.Subject{
+		CommonName:         cert.Subject.CommonName,
+		Country:            strings.Join(cert.Subject.Country, ", "),
+		Organization:       strings.Join(cert.Subject.Organization, ", "),
+		OrganizationalUnit: strings.Join(cert.Subject.OrganizationalUnit, ", "),
+		Locality:           strings.Join(cert.Subject.Locality, ", "),
+		Province:           strings.Join(cert.Subject.Province, ", "),
+	}
+}
+
+// liveChainCerts maps the chain array of a certstream event, which uses the
+// same layout as leaf_cert.
+func liveChainCerts(chain []interface{}) []models.ChainCert {
+	certs := []models.ChainCert{}
+	for _, item := range chain {
+		certData, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		subject, _ := certData["subject"].(map[string]interface{})
+		certs = append(certs, models.ChainCert{
+			Subject:                 liveSubject(subject),
+			IssuerDistinguishedName: getString(certData, "issuer", "CN"),
+			NotBefore:               liveTime(certData["not_before"]),
+			NotAfter:                liveTime(certData["not_after"]),
+			SerialNumber:            getString(certData, "serial_number"),
+		})
+	}
+	return certs
+}
+
+// liveSubject maps a certstream subject object.
+func liveSubject(subjectMap map[string]interface{}) models.Subject {
+	subject := models.Subject{}
+	if cn, ok := subjectMap["CN"].(string); ok {
+		subject.CommonName = cn
+	}
+	if c, ok := subjectMap["C"].(string); ok {
+		subject.Country = c
+	}
+	if st, ok := subjectMap["ST"].(string); ok {
+		subject.Province = st
+	}
+	if l, ok := subjectMap["L"].(string); ok {
+		subject.Locality = l
+	}
+	if o, ok := subjectMap["O"].(string); ok {
+		subject.Organization = o
+	}
+	if ou, ok := subjectMap["OU"].(string); ok {
+		subject.OrganizationalUnit = ou
+	}
+	return subject
+}
+
+// liveTime accepts certstream validity times, sent as Unix seconds, or
+// RFC 3339 strings.
+func liveTime(value interface{}) time.Time {
+	switch v := value.(type) {
+	case float64:
+		return time.Unix(int64(v), 0).UTC()
+	case string:
+		if parsed, err := time.Parse(time.RFC3339, v); err == nil {
+			return parsed
+		}
+	}
+	return time.Time{}
+}
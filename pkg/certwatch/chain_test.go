@@ -0,0 +1,86 @@
+package certwatch
+
+import (
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/jmoiron/jsonq"
+)
+
+func TestChainFromCTEntry(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+
+	entry := newTestLogEntry(newTestCertificate(t, "www.example.com"), time.Now())
+	entry.Chain = []ct.ASN1Cert{
+		{Data: newTestCertificate(t, "R3")},
+		{Data: []byte("not a certificate")},
+		{Data: newTestCertificate(t, "ISRG Root X1")},
+	}
+	if err := monitor.processCTEntry(entry, 1, &CTLogClient{name: "test log"}); err != nil {
+		t.Fatalf("processCTEntry() error: %v", err)
+	}
+
+	if len(handler.entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(handler.entries))
+	}
+	chain := handler.entries[0].Chain
+	if len(chain) != 2 {
+		t.Fatalf("Expected 2 parsed chain certificates, got %d", len(chain))
+	}
+	if chain[0].Subject.CommonName != "R3" || chain[0].IssuerDistinguishedName != "R3" {
+		t.Errorf("Unexpected first chain certificate: %+v", chain[0])
+	}
+	if chain[1].Subject.CommonName != "ISRG Root X1" || chain[1].SerialNumber == "" || chain[1].NotAfter.IsZero() {
+		t.Errorf("Unexpected second chain certificate: %+v", chain[1])
+	}
+}
+
+func TestChainFromLiveEvent(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+
+	monitor.processLiveEvent(jsonq.NewQuery(map[string]interface{}{
+		"message_type": "certificate_update",
+		"data": map[string]interface{}{
+			"leaf_cert": map[string]interface{}{
+				"subject":    map[string]interface{}{"CN": "www.example.com"},
+				"issuer":     map[string]interface{}{"CN": "R3"},
+				"not_before": float64(1735689600),
+				"not_after":  float64(1743465600),
+			},
+			"chain": []interface{}{
+				map[string]interface{}{
+					"subject":       map[string]interface{}{"CN": "R3", "O": "Let's Encrypt", "C": "US"},
+					"issuer":        map[string]interface{}{"CN": "ISRG Root X1"},
+					"not_before":    float64(1599177600),
+					"not_after":     float64(1757980800),
+					"serial_number": "912B084ACF0C18A753F6D62E25A75F5A",
+				},
+			},
+		},
+	}))
+
+	if len(handler.entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(handler.entries))
+	}
+	entry := handler.entries[0]
+	if !entry.LeafCert.NotBefore.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected Unix not_before to be parsed, got %v", entry.LeafCert.NotBefore)
+	}
+	if len(entry.Chain) != 1 {
+		t.Fatalf("Expected 1 chain certificate, got %d", len(entry.Chain))
+	}
+	chain := entry.Chain[0]
+	if chain.Subject.CommonName != "R3" || chain.Subject.Organization != "Let's Encrypt" || chain.IssuerDistinguishedName != "ISRG Root X1" {
+		t.Errorf("Unexpected chain certificate: %+v", chain)
+	}
+	if !chain.NotBefore.Equal(time.Date(2020, 9, 4, 0, 0, 0, 0, time.UTC)) || chain.SerialNumber != "912B084ACF0C18A753F6D62E25A75F5A" {
+		t.Errorf("Unexpected chain validity or serial: %+v", chain)
+	}
+}
@@ -0,0 +1,53 @@
+package certwatch
+
+import (
+	"crypto/x509"
+	"domain_watcher/pkg/models"
+	"encoding/pem"
+	"fmt"
+)
+
+// ParseCertificate parses a single certificate from PEM or DER bytes. For PEM
+// input the first CERTIFICATE block is used.
+func ParseCertificate(data []byte) (*x509.Certificate, error) {
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse PEM certificate: %w", err)
+			}
+			return cert, nil
+		}
+	}
+
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate as PEM or DER: %w", err)
+	}
+	return cert, nil
+}
+
+// CheckCertificate reports whether cert would match the current watch list
+// and why, along with the entry that would be dispatched. Nothing is sent to
+// handlers. The entry is nil when there is no match.
+func (m *Monitor) CheckCertificate(cert *x509.Certificate) (entry *models.CertificateEntry, reason string, ok bool) {
+	allDomains := certificateNames(cert)
+	ips := certificateIPs(cert)
+
+	matchedDomain, reason, ok := m.matchCertificateIPs(allDomains, ips)
+	if !ok {
+		return nil, "", false
+	}
+
+	entry = m.createCertificateEntry(cert, allDomains, matchedDomain, 0, nil)
+	entry.Lookalike = lookalikeKind(reason)
+	entry.MatchedNames = m.matchedNames(matchNames(allDomains, ips, reason), matchedDomain, reason)
+	entry.Wildcard = hasWildcardName(entry.MatchedNames)
+	return entry, reason, true
+}
@@ -0,0 +1,155 @@
+package certwatch
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// MonitorConfig holds the settings of a Monitor as a plain struct, for
+// programs that embed the monitor instead of running the domain_watcher
+// command. Each field maps to the setter of the same name and follows its
+// semantics, so start from DefaultMonitorConfig to get the command's
+// defaults. Domains, regexes, keywords and handlers are added to the
+// returned Monitor as usual, as is anomaly detection.
+type MonitorConfig struct {
+	// CertstreamURL is the websocket used in live mode. Empty uses
+	// wss://certstream.calidog.io.
+	CertstreamURL string
+	LiveMode      bool
+	AllDomains    bool
+	// LiteStream subscribes live mode to certstream's domains-only feed.
+	LiteStream bool
+
+	// PollInterval is the time between polling cycles; zero uses one minute.
+	PollInterval        time.Duration
+	MaxLogs             int
+	LogListURL          string
+	CustomLogs          []string
+	PollConcurrency     int
+	LogRateLimit        float64
+	MaxEntryAge         time.Duration
+	MaxEntryBytes       int
+	MaxBatchSize        int
+	MaxReconnectBackoff time.Duration
+	LiveGapWarning      time.Duration
+
+	// LogListCache is a file to cache the CT log list in for LogListTTL,
+	// which is also how often polling refreshes it.
+	LogListCache string
+	LogListTTL   time.Duration
+
+	// Source is SourceCTLogs (the default when empty) or SourceCertspotterAPI.
+	Source           string
+	CertspotterToken string
+	StateFile        string
+
+	HTTPTimeout        time.Duration
+	CABundle           string
+	InsecureSkipVerify bool
+
+	DedupeWindow     time.Duration
+	NoNotifyBackfill bool
+	IssuerAllow      []string
+	IssuerDeny       []string
+	IssuerNormalize  bool
+	MinValidity      time.Duration
+	MaxValidity      time.Duration
+	ExpiryAlert      time.Duration
+	CNNotInSANOnly   bool
+	WeakCryptoOnly   bool
+	LogNearMisses    bool
+
+	// RegistrableMatch matches names sharing a watched domain's registrable
+	// domain.
+	RegistrableMatch bool
+
+	// Typosquat enables lookalike matching within TyposquatDistance edits.
+	Typosquat         bool
+	TyposquatDistance int
+
+	// Logger receives the monitor's logs; nil uses slog.Default().
+	Logger *slog.Logger
+
+	// SampleRate and KeywordFilter only apply with AllDomains.
+	SampleRate    float64
+	KeywordFilter []string
+}
+
+// DefaultMonitorConfig returns the configuration NewMonitor starts with.
+func DefaultMonitorConfig() MonitorConfig {
+	return MonitorConfig{
+		CertstreamURL:       "wss://certstream.calidog.io",
+		PollInterval:        time.Minute,
+		MaxLogs:             defaultMaxLogs,
+		LogListURL:          defaultLogListURL,
+		LogListTTL:          defaultLogListTTL,
+		PollConcurrency:     defaultPollConcurrency,
+		MaxBatchSize:        defaultMaxBatchSize,
+		MaxReconnectBackoff: defaultMaxBackoff,
+		LiveGapWarning:      defaultLiveGapWarning,
+		Source:              SourceCTLogs,
+		HTTPTimeout:         defaultHTTPTimeout,
+		DedupeWindow:        defaultDedupeWindow,
+		TyposquatDistance:   1,
+	}
+}
+
+// NewMonitorWithConfig creates a monitor configured by cfg. It fails when a
+// setting is invalid, such as an unknown source or an unreadable CA bundle
+// or state file.
+func NewMonitorWithConfig(cfg MonitorConfig) (*Monitor, error) {
+	if cfg.CertstreamURL == "" {
+		cfg.CertstreamURL = "wss://certstream.calidog.io"
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Minute
+	}
+	if cfg.Source == "" {
+		cfg.Source = SourceCTLogs
+	}
+
+	m := NewMonitorWithCertstreamURL(cfg.CertstreamURL)
+	m.SetLogger(cfg.Logger)
+	if err := m.SetHTTPOptions(cfg.HTTPTimeout, cfg.CABundle, cfg.InsecureSkipVerify); err != nil {
+		return nil, err
+	}
+	if err := m.SetSource(cfg.Source); err != nil {
+		return nil, err
+	}
+	if err := m.SetStateFile(cfg.StateFile); err != nil {
+		return nil, fmt.Errorf("invalid state file: %w", err)
+	}
+
+	m.SetLiveMode(cfg.LiveMode)
+	m.SetAllDomainsMode(cfg.AllDomains)
+	m.SetPollInterval(cfg.PollInterval)
+	m.SetMaxLogs(cfg.MaxLogs)
+	m.SetLogListURL(cfg.LogListURL)
+	m.SetCustomLogs(cfg.CustomLogs)
+	m.SetLogListCache(cfg.LogListCache, cfg.LogListTTL)
+	m.SetPollConcurrency(cfg.PollConcurrency)
+	m.SetLogRateLimit(cfg.LogRateLimit)
+	m.SetMaxEntryAge(cfg.MaxEntryAge)
+	m.SetMaxEntryBytes(cfg.MaxEntryBytes)
+	m.SetMaxBatchSize(cfg.MaxBatchSize)
+	m.SetMaxReconnectBackoff(cfg.MaxReconnectBackoff)
+	m.SetLiveGapWarning(cfg.LiveGapWarning)
+	m.SetLiteStream(cfg.LiteStream)
+	m.SetCertspotterAPI("", cfg.CertspotterToken)
+
+	m.SetDedupeWindow(cfg.DedupeWindow)
+	m.SetNotifyBackfill(!cfg.NoNotifyBackfill)
+	m.SetIssuerFilter(cfg.IssuerAllow, cfg.IssuerDeny)
+	m.SetIssuerNormalize(cfg.IssuerNormalize)
+	m.SetValidityFilter(cfg.MinValidity, cfg.MaxValidity)
+	m.SetExpiryAlert(cfg.ExpiryAlert)
+	m.SetCNNotInSANOnly(cfg.CNNotInSANOnly)
+	m.SetWeakCryptoOnly(cfg.WeakCryptoOnly)
+	m.SetLogNearMisses(cfg.LogNearMisses)
+	m.SetRegistrableMatch(cfg.RegistrableMatch)
+	m.SetTyposquatMode(cfg.Typosquat, cfg.TyposquatDistance)
+	m.SetSampleRate(cfg.SampleRate)
+	m.SetKeywordFilter(cfg.KeywordFilter)
+	return m, nil
+}
@@ -0,0 +1,111 @@
+package certwatch
+
+import (
+	"context"
+	"domain_watcher/pkg/models"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/jsonq"
+)
+
+// funcHandler adapts a function to CertificateHandler.
+type funcHandler func(*models.CertificateEntry) error
+
+func (f funcHandler) Handle(entry *models.CertificateEntry) error {
+	return f(entry)
+}
+
+func TestNewMonitorWithConfig(t *testing.T) {
+	cfg := DefaultMonitorConfig()
+	cfg.PollInterval = 30 * time.Second
+	cfg.MaxLogs = 10
+	cfg.AllDomains = true
+	cfg.SampleRate = 0.5
+	cfg.KeywordFilter = []string{"Bank"}
+
+	monitor, err := NewMonitorWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewMonitorWithConfig() error: %v", err)
+	}
+	if monitor.pollInterval != 30*time.Second || monitor.maxLogs != 10 {
+		t.Errorf("Expected poll interval 30s and 10 logs, got %v and %d", monitor.pollInterval, monitor.maxLogs)
+	}
+	if !monitor.allDomainsMode || monitor.sampleRate != 0.5 || len(monitor.keywordFilter) != 1 || monitor.keywordFilter[0] != "bank" {
+		t.Errorf("Expected all-domains settings to be applied, got %v %v %v", monitor.allDomainsMode, monitor.sampleRate, monitor.keywordFilter)
+	}
+	if monitor.dedupe == nil || monitor.certstreamURL != "wss://certstream.calidog.io" {
+		t.Error("Expected the default dedupe window and certstream URL")
+	}
+
+	// The zero value is usable too
+	monitor, err = NewMonitorWithConfig(MonitorConfig{})
+	if err != nil {
+		t.Fatalf("NewMonitorWithConfig() with zero config error: %v", err)
+	}
+	if monitor.pollInterval != time.Minute || monitor.source != SourceCTLogs {
+		t.Errorf("Expected a one minute poll interval from ct-logs, got %v from %s", monitor.pollInterval, monitor.source)
+	}
+}
+
+func TestNewMonitorWithConfigErrors(t *testing.T) {
+	for name, cfg := range map[string]MonitorConfig{
+		"source":    {Source: "carrier-pigeon"},
+		"ca bundle": {CABundle: filepath.Join(t.TempDir(), "missing.pem")},
+	} {
+		if _, err := NewMonitorWithConfig(cfg); err == nil {
+			t.Errorf("Expected an error for an invalid %s", name)
+		}
+	}
+}
+
+func TestMonitorWithConfigRuns(t *testing.T) {
+	cfg := DefaultMonitorConfig()
+	cfg.LiveMode = true
+	cfg.LiteStream = true
+	cfg.CertstreamURL = "wss://certstream.example.test"
+
+	monitor, err := NewMonitorWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewMonitorWithConfig() error: %v", err)
+	}
+	monitor.AddDomain("example.com", true)
+	matches := make(chan *models.CertificateEntry, 1)
+	monitor.AddHandler(funcHandler(func(entry *models.CertificateEntry) error {
+		matches <- entry
+		return nil
+	}))
+
+	dialed := make(chan string, 1)
+	monitor.dialStream = func(url string) (chan jsonq.JsonQuery, chan error) {
+		dialed <- url
+		stream := make(chan jsonq.JsonQuery, 1)
+		stream <- *jsonq.NewQuery(map[string]interface{}{
+			"message_type": "dns_entries",
+			"data":         []interface{}{"www.example.com"},
+		})
+		return stream, make(chan error)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- monitor.StartContext(ctx) }()
+
+	select {
+	case entry := <-matches:
+		if entry.Domain != "example.com" {
+			t.Errorf("Expected a match for example.com, got %q", entry.Domain)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the monitor to dispatch the streamed certificate")
+	}
+	if url := <-dialed; url != "wss://certstream.example.test/domains-only" {
+		t.Errorf("Expected the configured lite stream URL, got %q", url)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("StartContext() error: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultCrtShURL = "https://crt.sh"
+
+// crtShEntry is one row of crt.sh's JSON output.
+type crtShEntry struct {
+	ID             int64  `json:"id"`
+	IssuerName     string `json:"issuer_name"`
+	CommonName     string `json:"common_name"`
+	NameValue      string `json:"name_value"`
+	SerialNumber   string `json:"serial_number"`
+	NotBefore      string `json:"not_before"`
+	NotAfter       string `json:"not_after"`
+	EntryTimestamp string `json:"entry_timestamp"`
+}
+
+// crtShTimeLayouts are the timestamp formats crt.sh uses (UTC, no zone).
+var crtShTimeLayouts = []string{"2006-01-02T15:04:05.999999999", "2006-01-02T15:04:05"}
+
+// CrtShProvider looks up historical certificates on crt.sh. It needs no
+// credentials but can be slow for large domains.
+type CrtShProvider struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewCrtShProvider returns a provider for the public crt.sh service.
+func NewCrtShProvider() *CrtShProvider {
+	return &CrtShProvider{
+		baseURL:    defaultCrtShURL,
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// Lookup returns certificates for domain and its subdomains logged within
+// window. crt.sh can't filter by date, so the full list is fetched and
+// filtered here.
+func (p *CrtShProvider) Lookup(domain string, window TimeRange) ([]*models.CertificateEntry, error) {
+	query := url.Values{}
+	query.Set("q", "%."+domain)
+	query.Set("output", "json")
+
+	resp, err := p.httpClient.Get(p.baseURL + "/?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("crt.sh request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("crt.sh returned %s", resp.Status)
+	}
+
+	var rows []crtShEntry
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("failed to decode crt.sh response: %w", err)
+	}
+
+	entries := []*models.CertificateEntry{}
+	for _, row := range rows {
+		logged := parseCrtShTime(row.EntryTimestamp)
+		if !window.Contains(logged) {
+			continue
+		}
+
+		var names []string
+		for _, name := range strings.Split(row.NameValue, "\n") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+
+		entries = append(entries, &models.CertificateEntry{
+			Domain:     domain,
+			Subdomains: names,
+			LeafCert: models.LeafCertificate{
+				Subject:                 models.Subject{CommonName: row.CommonName},
+				Extensions:              models.Extensions{SubjectAltName: names},
+				NotBefore:               parseCrtShTime(row.NotBefore),
+				NotAfter:                parseCrtShTime(row.NotAfter),
+				SerialNumber:            row.SerialNumber,
+				IssuerDistinguishedName: dnField(row.IssuerName, "CN"),
+			},
+			Chain:      []models.ChainCert{},
+			Timestamp:  logged,
+			LogURL:     fmt.Sprintf("%s/?id=%d", p.baseURL, row.ID),
+			CNNotInSAN: cnNotInSAN(row.CommonName, names),
+		})
+	}
+	return entries, nil
+}
+
+func parseCrtShTime(value string) time.Time {
+	for _, layout := range crtShTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,107 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultDedupeWindow = 10 * time.Minute
+
+// dedupeCache remembers issuance keys for a window, so the same certificate
+// seen in several CT logs, or as a precertificate and then a final
+// certificate, reaches handlers once. It is shared by the per-log polling
+// goroutines.
+type dedupeCache struct {
+	window    time.Duration
+	mutex     sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+func newDedupeCache(window time.Duration) *dedupeCache {
+	return &dedupeCache{
+		window: window,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// seenRecently reports whether key was recorded within the window before now,
+// and records it otherwise. Expired keys are swept at most once per window.
+func (c *dedupeCache) seenRecently(key string, now time.Time) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if now.Sub(c.lastSweep) >= c.window {
+		for k, at := range c.seen {
+			if now.Sub(at) >= c.window {
+				delete(c.seen, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if at, ok := c.seen[key]; ok && now.Sub(at) < c.window {
+		return true
+	}
+	c.seen[key] = now
+	return false
+}
+
+// SetDedupeWindow skips handlers for a certificate whose issuance was
+// already dispatched within d, as happens when it is logged to several of
+// the polled CT logs or logged both as a precertificate and as the final
+// certificate. Zero disables deduplication.
+func (m *Monitor) SetDedupeWindow(d time.Duration) {
+	if d <= 0 {
+		m.dedupe = nil
+		return
+	}
+	m.dedupe = newDedupeCache(d)
+}
+
+// isDuplicate reports whether entry's certificate was already dispatched
+// within the dedupe window.
+func (m *Monitor) isDuplicate(entry *models.CertificateEntry) bool {
+	if m.dedupe == nil {
+		return false
+	}
+	key := issuanceKey(entry)
+	if key == "" {
+		return false
+	}
+	return m.dedupe.seenRecently(key, time.Now())
+}
+
+// issuanceKey identifies the issuance behind entry: its serial number
+// together with the issuer's key identifier, or the issuer's name when the
+// key identifier is unknown. A precertificate and its final certificate
+// share these, since the precert TBSCertificate a log records carries the
+// final issuer and serial, while their fingerprints differ. Entries without
+// a serial number fall back to the fingerprint, and those with neither, as
+// from certstream's domains-only feed, to their names; "" means no key.
+func issuanceKey(entry *models.CertificateEntry) string {
+	leaf := entry.LeafCert
+	if leaf.SerialNumber == "" {
+		if leaf.Fingerprint == "" && len(entry.Subdomains) > 0 {
+			names := append([]string{}, entry.Subdomains...)
+			sort.Strings(names)
+			return "names:" + strings.ToLower(strings.Join(names, ","))
+		}
+		return leaf.Fingerprint
+	}
+
+	serial := normalizeKeyHex(leaf.SerialNumber)
+	if aki := strings.TrimPrefix(leaf.Extensions.AuthorityKeyIdentifier, "keyid:"); aki != "" {
+		return "serial:" + serial + "|aki:" + normalizeKeyHex(aki)
+	}
+	return "serial:" + serial + "|issuer:" + leaf.IssuerDistinguishedName + "|" + leaf.IssuerOrganization
+}
+
+// normalizeKeyHex lowercases s and drops the colons and spaces some sources
+// put between hex bytes.
+func normalizeKeyHex(s string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(strings.TrimSpace(s)))
+}
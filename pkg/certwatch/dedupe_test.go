@@ -0,0 +1,125 @@
+package certwatch
+
+import (
+	"crypto/x509"
+	"sync"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+func TestDedupeAcrossLogs(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+
+	der := newTestCertificate(t, "www.example.com")
+	loggedAt := monitor.startedAt.Add(time.Second)
+	for i, name := range []string{"log A", "log B", "log C"} {
+		logClient := &CTLogClient{name: name}
+		monitor.processCTEntry(newTestLogEntry(der, loggedAt), int64(i), logClient)
+	}
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "api.example.com"), loggedAt), 4, &CTLogClient{name: "log A"})
+
+	if len(handler.entries) != 2 {
+		t.Errorf("Expected the certificate seen in 3 logs to be dispatched once, got %d entries", len(handler.entries))
+	}
+}
+
+// newTestPrecertEntry wraps the TBSCertificate of der in a precertificate CT
+// log entry, as logged before der itself was issued.
+func newTestPrecertEntry(t *testing.T, der []byte, loggedAt time.Time) *ct.LogEntry {
+	t.Helper()
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error: %v", err)
+	}
+	return &ct.LogEntry{
+		Leaf: ct.MerkleTreeLeaf{
+			TimestampedEntry: &ct.TimestampedEntry{
+				Timestamp:    uint64(loggedAt.UnixMilli()),
+				EntryType:    ct.PrecertLogEntryType,
+				PrecertEntry: &ct.PreCert{TBSCertificate: cert.RawTBSCertificate},
+			},
+		},
+		Precert: &ct.Precertificate{Submitted: ct.ASN1Cert{Data: der}},
+	}
+}
+
+func TestDedupePrecertAndFinalCert(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+
+	der := newTestCertificate(t, "www.example.com", "www.example.com", "mail.example.com")
+	loggedAt := monitor.startedAt.Add(time.Second)
+	logClient := &CTLogClient{name: "test log"}
+	if err := monitor.processCTEntry(newTestPrecertEntry(t, der, loggedAt), 0, logClient); err != nil {
+		t.Fatalf("processCTEntry() error: %v", err)
+	}
+	if err := monitor.processCTEntry(newTestLogEntry(der, loggedAt), 1, logClient); err != nil {
+		t.Fatalf("processCTEntry() error: %v", err)
+	}
+
+	if len(handler.entries) != 1 {
+		t.Fatalf("Expected the precert and final certificate to be dispatched once, got %d entries", len(handler.entries))
+	}
+	entry := handler.entries[0]
+	if len(entry.Subdomains) != 2 || entry.LeafCert.Subject.CommonName != "www.example.com" || entry.LeafCert.Fingerprint == "" {
+		t.Errorf("Expected the precert's names and fingerprint, got %+v", entry)
+	}
+
+	// Another issuance for the same names is not a duplicate
+	other := newTestCertificate(t, "www.example.com", "www.example.com", "mail.example.com")
+	monitor.processCTEntry(newTestPrecertEntry(t, other, loggedAt), 2, logClient)
+	if len(handler.entries) != 2 {
+		t.Errorf("Expected a distinct serial to be dispatched, got %d entries", len(handler.entries))
+	}
+}
+
+func TestDedupeCacheWindow(t *testing.T) {
+	cache := newDedupeCache(10 * time.Minute)
+	now := time.Now()
+
+	if cache.seenRecently("AA", now) {
+		t.Error("Expected first sighting not to be a duplicate")
+	}
+	if !cache.seenRecently("AA", now.Add(9*time.Minute)) {
+		t.Error("Expected a repeat within the window to be a duplicate")
+	}
+	if cache.seenRecently("AA", now.Add(11*time.Minute)) {
+		t.Error("Expected a repeat after the window not to be a duplicate")
+	}
+	if len(cache.seen) != 1 {
+		t.Errorf("Expected expired keys to be swept, got %d", len(cache.seen))
+	}
+}
+
+func TestDedupeCacheConcurrent(t *testing.T) {
+	cache := newDedupeCache(time.Minute)
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	firsts := 0
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !cache.seenRecently("AA", now) {
+				mutex.Lock()
+				firsts++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firsts != 1 {
+		t.Errorf("Expected exactly one goroutine to see the fingerprint first, got %d", firsts)
+	}
+}
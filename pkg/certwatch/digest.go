@@ -0,0 +1,139 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// digestMaxPending bounds the entries a digest holds, so a flood of
+// matches between deliveries cannot grow it without limit; past it the
+// oldest are dropped.
+const digestMaxPending = 10000
+
+// BatchHandler is implemented by handlers that can deliver several entries
+// as one summary, such as a digest email. DigestHandler hands its batches to
+// it instead of calling Handle once per entry.
+type BatchHandler interface {
+	HandleBatch(entries []*models.CertificateEntry) error
+}
+
+// DigestHandler collects entries for another handler and passes them on
+// together every interval, or as soon as maxBatch entries are waiting, to
+// cut alert noise from busy domains. A wrapped BatchHandler receives each
+// digest as one summary. Deliveries run on the digest's own goroutine, and
+// Close delivers what is left.
+type DigestHandler struct {
+	inner    CertificateHandler
+	interval time.Duration
+	maxBatch int
+
+	mutex   sync.Mutex
+	pending []*models.CertificateEntry
+
+	deliverMutex sync.Mutex
+	full         chan struct{}
+	stop         chan struct{}
+	done         chan struct{}
+	closeOnce    sync.Once
+}
+
+// NewDigestHandler wraps inner, flushing every interval and whenever
+// maxBatch entries are pending (zero for no size limit).
+func NewDigestHandler(inner CertificateHandler, interval time.Duration, maxBatch int) *DigestHandler {
+	h := &DigestHandler{
+		inner:    inner,
+		interval: interval,
+		maxBatch: maxBatch,
+		full:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+// Handle adds entry to the digest, which is delivered right away once the
+// batch is full.
+func (h *DigestHandler) Handle(entry *models.CertificateEntry) error {
+	h.mutex.Lock()
+	h.pending = append(h.pending, entry)
+	if len(h.pending) > digestMaxPending {
+		slog.Warn("Digest full, dropping the oldest entries", "dropped", len(h.pending)-digestMaxPending)
+		h.pending = h.pending[len(h.pending)-digestMaxPending:]
+	}
+	full := h.maxBatch > 0 && len(h.pending) >= h.maxBatch
+	h.mutex.Unlock()
+
+	if full {
+		select {
+		case h.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Close stops the timer and delivers the pending entries. It does not close
+// the wrapped handler.
+func (h *DigestHandler) Close() error {
+	h.closeOnce.Do(func() { close(h.stop) })
+	<-h.done
+	return h.flush()
+}
+
+func (h *DigestHandler) run() {
+	defer close(h.done)
+
+	var tick <-chan time.Time
+	if h.interval > 0 {
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-tick:
+		case <-h.full:
+		}
+		if err := h.flush(); err != nil {
+			slog.Error("Digest delivery failed", "error", err)
+		}
+	}
+}
+
+// flush delivers the pending entries, in one batch when the wrapped handler
+// supports it.
+func (h *DigestHandler) flush() error {
+	h.deliverMutex.Lock()
+	defer h.deliverMutex.Unlock()
+
+	h.mutex.Lock()
+	entries := h.pending
+	h.pending = nil
+	h.mutex.Unlock()
+
+	if len(entries) == 0 {
+		return nil
+	}
+	if batcher, ok := h.inner.(BatchHandler); ok {
+		return batcher.HandleBatch(entries)
+	}
+
+	failed := 0
+	var lastErr error
+	for _, entry := range entries {
+		if err := h.inner.Handle(entry); err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d digest entries failed: %w", failed, len(entries), lastErr)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchRecorder records the batches a DigestHandler delivers.
+type batchRecorder struct {
+	mutex   sync.Mutex
+	batches [][]*models.CertificateEntry
+}
+
+func (r *batchRecorder) Handle(entry *models.CertificateEntry) error {
+	return r.HandleBatch([]*models.CertificateEntry{entry})
+}
+
+func (r *batchRecorder) HandleBatch(entries []*models.CertificateEntry) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.batches = append(r.batches, entries)
+	return nil
+}
+
+func (r *batchRecorder) sizes() []int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var sizes []int
+	for _, batch := range r.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func TestDigestHandlerFlushesOnSizeAndClose(t *testing.T) {
+	recorder := &batchRecorder{}
+	digest := NewDigestHandler(recorder, time.Hour, 3)
+
+	for i := 0; i < 3; i++ {
+		if err := digest.Handle(&models.CertificateEntry{Domain: "example.com"}); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+
+	// A full batch goes out from the digest's goroutine
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.sizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if sizes := recorder.sizes(); len(sizes) != 1 || sizes[0] != 3 {
+		t.Fatalf("Expected a full batch of 3 to be delivered, got %v", sizes)
+	}
+
+	digest.Handle(&models.CertificateEntry{Domain: "example.com"})
+	digest.Handle(&models.CertificateEntry{Domain: "example.com"})
+	digest.Close()
+	if sizes := recorder.sizes(); len(sizes) != 2 || sizes[1] != 2 {
+		t.Errorf("Expected Close to deliver the remaining 2 entries, got %v", sizes)
+	}
+}
+
+func TestDigestHandlerFlushesOnInterval(t *testing.T) {
+	recorder := &batchRecorder{}
+	digest := NewDigestHandler(recorder, 20*time.Millisecond, 0)
+	defer digest.Close()
+
+	digest.Handle(&models.CertificateEntry{Domain: "example.com"})
+	digest.Handle(&models.CertificateEntry{Domain: "example.org"})
+
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.sizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if sizes := recorder.sizes(); len(sizes) != 1 || sizes[0] != 2 {
+		t.Errorf("Expected one digest of 2 entries, got %v", sizes)
+	}
+}
+
+func TestDigestHandlerFallsBackToHandle(t *testing.T) {
+	handler := &mockHandler{}
+	digest := NewDigestHandler(handler, time.Hour, 0)
+
+	digest.Handle(&models.CertificateEntry{Domain: "example.com"})
+	digest.Handle(&models.CertificateEntry{Domain: "example.org"})
+	if len(handler.entries) != 0 {
+		t.Fatalf("Expected entries to be held until the digest flushes, got %d", len(handler.entries))
+	}
+
+	digest.Close()
+	if len(handler.entries) != 2 {
+		t.Errorf("Expected each entry passed to Handle on Close, got %d", len(handler.entries))
+	}
+}
+
+func TestDigestHandlerBoundsPending(t *testing.T) {
+	recorder := &batchRecorder{}
+	digest := NewDigestHandler(recorder, time.Hour, 0)
+
+	for i := 0; i < digestMaxPending+5; i++ {
+		digest.Handle(&models.CertificateEntry{Domain: fmt.Sprintf("host-%d.example.com", i)})
+	}
+	digest.Close()
+
+	// The oldest entries are dropped
+	if sizes := recorder.sizes(); len(sizes) != 1 || sizes[0] != digestMaxPending {
+		t.Fatalf("Expected one digest of %d entries, got %v", digestMaxPending, sizes)
+	}
+	if first := recorder.batches[0][0].Domain; first != "host-5.example.com" {
+		t.Errorf("Expected the oldest entries dropped, digest starts with %s", first)
+	}
+}
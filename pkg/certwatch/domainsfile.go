@@ -0,0 +1,93 @@
+package certwatch
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DomainFileEntry is one watch in a domains file. IncludeSubdomains is nil
+// when the entry leaves it to the caller's default.
+type DomainFileEntry struct {
+	Domain            string `yaml:"domain"`
+	IncludeSubdomains *bool  `yaml:"include_subdomains"`
+	Regex             string `yaml:"regex"`
+}
+
+var domainFileFields = map[string]bool{"domain": true, "include_subdomains": true, "regex": true}
+
+// LoadDomainsFile reads a YAML or JSON list of watches. Each is a mapping
+// with a domain, a regex or both, or a bare domain string. Errors name the
+// entry and line at fault.
+func LoadDomainsFile(path string) ([]DomainFileEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read domains file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil // Empty file
+	}
+	list := doc.Content[0]
+	if list.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("%s: line %d: expected a list of domains", path, list.Line)
+	}
+
+	entries := make([]DomainFileEntry, 0, len(list.Content))
+	for i, node := range list.Content {
+		entry, err := decodeDomainFileEntry(node)
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d (line %d): %w", path, i+1, node.Line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func decodeDomainFileEntry(node *yaml.Node) (DomainFileEntry, error) {
+	var entry DomainFileEntry
+
+	// A bare string is a domain with the default subdomain setting
+	if node.Kind == yaml.ScalarNode {
+		entry.Domain = node.Value
+	} else {
+		if node.Kind != yaml.MappingNode {
+			return entry, fmt.Errorf("expected a domain or a mapping")
+		}
+		for i := 0; i < len(node.Content); i += 2 {
+			if key := node.Content[i].Value; !domainFileFields[key] {
+				return entry, fmt.Errorf("unknown field %q", key)
+			}
+		}
+		if err := node.Decode(&entry); err != nil {
+			return entry, err
+		}
+	}
+
+	entry.Domain = strings.TrimSpace(entry.Domain)
+	entry.Regex = strings.TrimSpace(entry.Regex)
+	if entry.Domain == "" && entry.Regex == "" {
+		return entry, fmt.Errorf("domain or regex is required")
+	}
+	if strings.ContainsAny(entry.Domain, "/ \t") {
+		return entry, fmt.Errorf("invalid domain %q", entry.Domain)
+	}
+	if entry.Domain != "" {
+		if err := ValidateWatchDomain(entry.Domain); err != nil {
+			return entry, err
+		}
+	}
+	if entry.Regex != "" {
+		if _, err := regexp.Compile(entry.Regex); err != nil {
+			return entry, fmt.Errorf("invalid regex %q: %w", entry.Regex, err)
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,83 @@
+package certwatch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeDomainsFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	return path
+}
+
+func TestLoadDomainsFile(t *testing.T) {
+	yamlPath := writeDomainsFile(t, "domains.yaml", `
+- domain: example.com
+  include_subdomains: false
+- domain: example.org
+- regex: '^login-.*\.example\.net$'
+- example.io
+`)
+	jsonPath := writeDomainsFile(t, "domains.json", `[
+	{"domain": "example.com", "include_subdomains": false},
+	{"domain": "example.org"},
+	{"regex": "^login-.*\\.example\\.net$"},
+	"example.io"
+]`)
+
+	for _, path := range []string{yamlPath, jsonPath} {
+		entries, err := LoadDomainsFile(path)
+		if err != nil {
+			t.Fatalf("LoadDomainsFile(%s) error: %v", filepath.Base(path), err)
+		}
+		if len(entries) != 4 {
+			t.Fatalf("%s: expected 4 entries, got %+v", filepath.Base(path), entries)
+		}
+		if entries[0].Domain != "example.com" || entries[0].IncludeSubdomains == nil || *entries[0].IncludeSubdomains {
+			t.Errorf("%s: expected example.com without subdomains, got %+v", filepath.Base(path), entries[0])
+		}
+		if entries[1].Domain != "example.org" || entries[1].IncludeSubdomains != nil {
+			t.Errorf("%s: expected example.org with the default subdomain setting, got %+v", filepath.Base(path), entries[1])
+		}
+		if entries[2].Regex != `^login-.*\.example\.net$` || entries[2].Domain != "" {
+			t.Errorf("%s: expected a regex entry, got %+v", filepath.Base(path), entries[2])
+		}
+		if entries[3].Domain != "example.io" {
+			t.Errorf("%s: expected a bare domain entry, got %+v", filepath.Base(path), entries[3])
+		}
+	}
+}
+
+func TestLoadDomainsFileReportsEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"missing domain", "- domain: example.com\n- include_subdomains: true\n", "entry 2 (line 2): domain or regex is required"},
+		{"bad regex", "- domain: example.com\n\n- regex: '(['\n", "entry 2 (line 3): invalid regex"},
+		{"unknown field", "- domian: example.com\n", `entry 1 (line 1): unknown field "domian"`},
+		{"bad type", "- domain: example.com\n  include_subdomains: maybe\n", "entry 1 (line 1)"},
+		{"not a list", "domain: example.com\n", "line 1: expected a list of domains"},
+	}
+	for _, tt := range tests {
+		path := writeDomainsFile(t, "domains.yaml", tt.content)
+		_, err := LoadDomainsFile(path)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestLoadDomainsFileRejectsPublicSuffix(t *testing.T) {
+	path := writeDomainsFile(t, "domains.yaml", "- example.com\n- co.uk\n")
+	if _, err := LoadDomainsFile(path); err == nil || !strings.Contains(err.Error(), "entry 2") {
+		t.Errorf("Expected an error for the public suffix entry, got %v", err)
+	}
+}
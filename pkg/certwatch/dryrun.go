@@ -0,0 +1,64 @@
+package certwatch
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	ct "github.com/google/certificate-transparency-go"
+	"golang.org/x/net/idna"
+)
+
+// LogStatus is the state of one selected CT log, as reported by CheckLogs.
+type LogStatus struct {
+	Name     string
+	URL      string
+	TreeSize uint64
+	Err      error // set when the log could not be reached
+}
+
+// ValidateDomains checks that every watched domain is a valid host name,
+// optionally with a leading "*." wildcard label.
+func (m *Monitor) ValidateDomains() error {
+	var invalid []string
+	for domain := range m.GetWatchedDomains() {
+		if _, err := idna.Lookup.ToASCII(strings.TrimPrefix(domain, "*.")); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s (%v)", domain, err))
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid watched domains: %s", strings.Join(invalid, ", "))
+	}
+	return nil
+}
+
+// CheckLogs selects the CT logs polling would use, as Start does, and
+// fetches each one's current tree head without polling it. It fails only if
+// no log could be selected; unreachable logs are reported in their status.
+func (m *Monitor) CheckLogs() ([]LogStatus, error) {
+	if len(m.ctClients) == 0 {
+		if err := m.initializeCTClients(); err != nil {
+			return nil, fmt.Errorf("no CT clients available: %w", err)
+		}
+	}
+
+	statuses := make([]LogStatus, len(m.ctClients))
+	var wg sync.WaitGroup
+	for i, logClient := range m.ctClients {
+		wg.Add(1)
+		go func(i int, lc *CTLogClient) {
+			defer wg.Done()
+			var sth *ct.SignedTreeHead
+			err := m.logRequest(lc, func() (err error) {
+				sth, err = lc.client.GetSTH(m.ctx)
+				return err
+			})
+			statuses[i] = LogStatus{Name: lc.name, URL: lc.url, Err: err}
+			if err == nil {
+				statuses[i].TreeSize = sth.TreeSize
+			}
+		}(i, logClient)
+	}
+	wg.Wait()
+	return statuses, nil
+}
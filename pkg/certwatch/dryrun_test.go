@@ -0,0 +1,44 @@
+package certwatch
+
+import "testing"
+
+func TestValidateDomains(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	monitor.AddDomain("*.corp.example.com", false)
+	monitor.AddDomain("bücher.example", false)
+	if err := monitor.ValidateDomains(); err != nil {
+		t.Fatalf("ValidateDomains() error: %v", err)
+	}
+
+	monitor.AddDomain("exa mple.com", false)
+	if err := monitor.ValidateDomains(); err == nil {
+		t.Error("Expected an error for a domain with a space")
+	}
+}
+
+func TestCheckLogsReportsTreeSizes(t *testing.T) {
+	healthy := newFakeLogAPI(t, newTestCertificate(t, "www.example.com"), newTestCertificate(t, "api.example.com"))
+	down := newFakeLogAPI(t)
+	down.sthFailures = logRequestAttempts
+
+	monitor := NewMonitor()
+	monitor.ctClients = []*CTLogClient{
+		{client: healthy, name: "healthy log", url: "https://healthy.example/"},
+		{client: down, name: "down log", url: "https://down.example/"},
+	}
+
+	statuses, err := monitor.CheckLogs()
+	if err != nil {
+		t.Fatalf("CheckLogs() error: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("Expected 2 log statuses, got %d", len(statuses))
+	}
+	if statuses[0].Err != nil || statuses[0].TreeSize != 2 {
+		t.Errorf("Expected the healthy log with tree size 2, got %+v", statuses[0])
+	}
+	if statuses[1].Err == nil {
+		t.Error("Expected the unreachable log to report an error")
+	}
+}
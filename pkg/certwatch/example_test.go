@@ -0,0 +1,41 @@
+package certwatch_test
+
+import (
+	"domain_watcher/pkg/certwatch"
+	"domain_watcher/pkg/models"
+	"fmt"
+	"log"
+	"time"
+)
+
+// alertHandler receives each matched certificate.
+type alertHandler struct{}
+
+func (alertHandler) Handle(entry *models.CertificateEntry) error {
+	fmt.Printf("New certificate for %s: %v\n", entry.Domain, entry.MatchedNames)
+	return nil
+}
+
+// Embedding the monitor in another program: configure it with a plain
+// struct, add domains and handlers, then start it with Start or
+// StartContext. Here an archived certificate is run through the same
+// matching and handlers with Replay instead, without network access.
+func ExampleNewMonitorWithConfig() {
+	cfg := certwatch.DefaultMonitorConfig()
+	cfg.PollInterval = 30 * time.Second
+	cfg.MaxLogs = 10
+
+	monitor, err := certwatch.NewMonitorWithConfig(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	monitor.AddDomain("example.com", true)
+	monitor.AddHandler(alertHandler{})
+
+	monitor.Replay(&models.CertificateEntry{
+		Subdomains: []string{"www.example.com", "shop.example.net"},
+		LeafCert:   models.LeafCertificate{SerialNumber: "01", IssuerDistinguishedName: "R3"},
+		Timestamp:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	// Output: New certificate for example.com: [www.example.com]
+}
@@ -0,0 +1,36 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"math"
+	"time"
+)
+
+// SetExpiryAlert flags matched certificates that expire within threshold of
+// being processed, such as short-lived certificates or a renewal that is
+// running late. Flagged entries carry an ExpiryAlert with the days remaining,
+// which notification handlers report as a distinct alert. Zero disables it.
+func (m *Monitor) SetExpiryAlert(threshold time.Duration) {
+	m.expiryAlert = threshold
+}
+
+// checkExpiry sets entry.Expiry when the certificate expires within the
+// configured threshold of now.
+func (m *Monitor) checkExpiry(entry *models.CertificateEntry, now time.Time) {
+	notAfter := entry.LeafCert.NotAfter
+	if m.expiryAlert <= 0 || notAfter.IsZero() {
+		return
+	}
+
+	remaining := notAfter.Sub(now)
+	if remaining > m.expiryAlert {
+		return
+	}
+
+	entry.Expiry = &models.ExpiryAlert{
+		DaysRemaining: int(math.Floor(remaining.Hours() / 24)),
+		NotAfter:      notAfter,
+	}
+	m.logger.Warn("Certificate expires soon", "domain", entry.Domain, "days_remaining", entry.Expiry.DaysRemaining,
+		"common_name", entry.LeafCert.Subject.CommonName, "not_after", notAfter.Format(time.RFC3339))
+}
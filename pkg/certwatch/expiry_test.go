@@ -0,0 +1,67 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"testing"
+	"time"
+)
+
+func TestCheckExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	monitor := NewMonitor()
+	monitor.SetExpiryAlert(7 * 24 * time.Hour)
+
+	tests := []struct {
+		notAfter time.Time
+		expected *int
+	}{
+		{now.AddDate(0, 0, 90), nil},
+		{now.AddDate(0, 0, 7), intPtr(7)},
+		{now.Add(36 * time.Hour), intPtr(1)},
+		{now.Add(time.Hour), intPtr(0)},
+		{now.Add(-time.Hour), intPtr(-1)},
+		{time.Time{}, nil},
+	}
+	for _, tt := range tests {
+		entry := &models.CertificateEntry{Domain: "example.com", LeafCert: models.LeafCertificate{NotAfter: tt.notAfter}}
+		monitor.checkExpiry(entry, now)
+
+		switch {
+		case tt.expected == nil && entry.Expiry != nil:
+			t.Errorf("NotAfter %v: expected no expiry alert, got %+v", tt.notAfter, entry.Expiry)
+		case tt.expected != nil && entry.Expiry == nil:
+			t.Errorf("NotAfter %v: expected an expiry alert", tt.notAfter)
+		case tt.expected != nil && entry.Expiry.DaysRemaining != *tt.expected:
+			t.Errorf("NotAfter %v: expected %d days remaining, got %d", tt.notAfter, *tt.expected, entry.Expiry.DaysRemaining)
+		}
+	}
+}
+
+func TestExpiryAlertReachesHandlers(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+
+	// Test certificates are valid for 90 days
+	der := newTestCertificate(t, "www.example.com")
+	monitor.processCTEntry(newTestLogEntry(der, monitor.startedAt.Add(time.Second)), 1, &CTLogClient{name: "test log"})
+
+	monitor.SetExpiryAlert(100 * 24 * time.Hour)
+	monitor.SetDedupeWindow(0)
+	monitor.processCTEntry(newTestLogEntry(der, monitor.startedAt.Add(time.Second)), 2, &CTLogClient{name: "test log"})
+
+	if len(handler.entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(handler.entries))
+	}
+	if handler.entries[0].Expiry != nil {
+		t.Errorf("Expected no expiry alert while disabled, got %+v", handler.entries[0].Expiry)
+	}
+	if handler.entries[1].Expiry == nil || handler.entries[1].Expiry.DaysRemaining != 89 {
+		t.Errorf("Expected an expiry alert, got %+v", handler.entries[1].Expiry)
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
@@ -0,0 +1,91 @@
+package certwatch
+
+import (
+	"crypto/x509"
+	"domain_watcher/pkg/models"
+	"fmt"
+)
+
+// Names follow OpenSSL's text output, which is also what certstream sends,
+// so entries from polling and live mode read the same.
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "Digital Signature"},
+	{x509.KeyUsageContentCommitment, "Non Repudiation"},
+	{x509.KeyUsageKeyEncipherment, "Key Encipherment"},
+	{x509.KeyUsageDataEncipherment, "Data Encipherment"},
+	{x509.KeyUsageKeyAgreement, "Key Agreement"},
+	{x509.KeyUsageCertSign, "Certificate Sign"},
+	{x509.KeyUsageCRLSign, "CRL Sign"},
+	{x509.KeyUsageEncipherOnly, "Encipher Only"},
+	{x509.KeyUsageDecipherOnly, "Decipher Only"},
+}
+
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "Any Extended Key Usage",
+	x509.ExtKeyUsageServerAuth:                     "TLS Web Server Authentication",
+	x509.ExtKeyUsageClientAuth:                     "TLS Web Client Authentication",
+	x509.ExtKeyUsageCodeSigning:                    "Code Signing",
+	x509.ExtKeyUsageEmailProtection:                "E-mail Protection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "IPSec End System",
+	x509.ExtKeyUsageIPSECTunnel:                    "IPSec Tunnel",
+	x509.ExtKeyUsageIPSECUser:                      "IPSec User",
+	x509.ExtKeyUsageTimeStamping:                   "Time Stamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSP Signing",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "Microsoft Server Gated Crypto",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "Netscape Server Gated Crypto",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "Microsoft Commercial Code Signing",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "Microsoft Kernel Code Signing",
+}
+
+// certExtensions maps the parsed X.509 extensions of cert into the model.
+// Key identifiers are lowercase hex; unknown extended key usages and all
+// policies are given as dotted OIDs.
+func certExtensions(cert *x509.Certificate) models.Extensions {
+	extensions := models.Extensions{
+		SubjectAltName:         cert.DNSNames,
+		AuthorityKeyIdentifier: fmt.Sprintf("%x", cert.AuthorityKeyId),
+		SubjectKeyIdentifier:   fmt.Sprintf("%x", cert.SubjectKeyId),
+		BasicConstraints:       basicConstraints(cert),
+	}
+
+	for _, ku := range keyUsageNames {
+		if cert.KeyUsage&ku.usage != 0 {
+			extensions.KeyUsage = append(extensions.KeyUsage, ku.name)
+		}
+	}
+
+	for _, eku := range cert.ExtKeyUsage {
+		name, ok := extKeyUsageNames[eku]
+		if !ok {
+			name = fmt.Sprintf("unknown (%d)", eku)
+		}
+		extensions.ExtendedKeyUsage = append(extensions.ExtendedKeyUsage, name)
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		extensions.ExtendedKeyUsage = append(extensions.ExtendedKeyUsage, oid.String())
+	}
+
+	for _, oid := range cert.Policies {
+		extensions.CertificatePolicies = append(extensions.CertificatePolicies, oid.String())
+	}
+
+	return extensions
+}
+
+// basicConstraints renders the extension as OpenSSL does, e.g. "CA:FALSE" or
+// "CA:TRUE, pathlen:0". It is empty when the extension is absent.
+func basicConstraints(cert *x509.Certificate) string {
+	if !cert.BasicConstraintsValid {
+		return ""
+	}
+	if !cert.IsCA {
+		return "CA:FALSE"
+	}
+	if cert.MaxPathLen > 0 || cert.MaxPathLenZero {
+		return fmt.Sprintf("CA:TRUE, pathlen:%d", cert.MaxPathLen)
+	}
+	return "CA:TRUE"
+}
@@ -0,0 +1,94 @@
+package certwatch
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCertExtensions(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(42),
+		Subject:               pkix.Name{CommonName: "www.example.com"},
+		DNSNames:              []string{"www.example.com", "example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(90 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		UnknownExtKeyUsage:    []asn1.ObjectIdentifier{{1, 3, 6, 1, 4, 1, 99999, 1}},
+		Policies:              []x509.OID{mustOID(t, 2, 23, 140, 1, 2, 1)},
+		SubjectKeyId:          []byte{0xab, 0xcd, 0xef},
+		AuthorityKeyId:        []byte{0x01, 0x02, 0x03},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	entry, _, ok := monitor.CheckCertificate(mustParse(t, der))
+	if !ok {
+		t.Fatal("Expected the certificate to match")
+	}
+
+	ext := entry.LeafCert.Extensions
+	checks := []struct {
+		field    string
+		got      interface{}
+		expected interface{}
+	}{
+		{"SubjectAltName", ext.SubjectAltName, []string{"www.example.com", "example.com"}},
+		{"KeyUsage", ext.KeyUsage, []string{"Digital Signature", "Key Encipherment"}},
+		{"ExtendedKeyUsage", ext.ExtendedKeyUsage, []string{"TLS Web Server Authentication", "TLS Web Client Authentication", "1.3.6.1.4.1.99999.1"}},
+		{"CertificatePolicies", ext.CertificatePolicies, []string{"2.23.140.1.2.1"}},
+		{"SubjectKeyIdentifier", ext.SubjectKeyIdentifier, "abcdef"},
+		{"AuthorityKeyIdentifier", ext.AuthorityKeyIdentifier, "010203"},
+		{"BasicConstraints", ext.BasicConstraints, "CA:FALSE"},
+	}
+	for _, c := range checks {
+		if !reflect.DeepEqual(c.got, c.expected) {
+			t.Errorf("%s = %v, expected %v", c.field, c.got, c.expected)
+		}
+	}
+}
+
+func TestBasicConstraints(t *testing.T) {
+	tests := []struct {
+		cert     x509.Certificate
+		expected string
+	}{
+		{x509.Certificate{}, ""},
+		{x509.Certificate{BasicConstraintsValid: true}, "CA:FALSE"},
+		{x509.Certificate{BasicConstraintsValid: true, IsCA: true, MaxPathLen: -1}, "CA:TRUE"},
+		{x509.Certificate{BasicConstraintsValid: true, IsCA: true, MaxPathLenZero: true}, "CA:TRUE, pathlen:0"},
+		{x509.Certificate{BasicConstraintsValid: true, IsCA: true, MaxPathLen: 2}, "CA:TRUE, pathlen:2"},
+	}
+	for _, tt := range tests {
+		if got := basicConstraints(&tt.cert); got != tt.expected {
+			t.Errorf("basicConstraints(%+v) = %q, expected %q", tt.cert, got, tt.expected)
+		}
+	}
+}
+
+func mustOID(t *testing.T, ids ...uint64) x509.OID {
+	t.Helper()
+	oid, err := x509.OIDFromInts(ids)
+	if err != nil {
+		t.Fatalf("OIDFromInts(%v) error: %v", ids, err)
+	}
+	return oid
+}
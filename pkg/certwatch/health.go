@@ -0,0 +1,132 @@
+package certwatch
+
+import (
+	"sync"
+	"time"
+)
+
+// LogHealth is the polling state of one CT log, as reported by Health.
+type LogHealth struct {
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	Healthy     bool      `json:"healthy"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	Failures    int       `json:"failures"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// HealthStatus reports whether a monitor is getting certificates.
+type HealthStatus struct {
+	Ready         bool        `json:"ready"`
+	Mode          string      `json:"mode"`
+	Reason        string      `json:"reason,omitempty"`
+	LastHeartbeat time.Time   `json:"last_heartbeat,omitzero"`
+	Logs          []LogHealth `json:"logs,omitempty"`
+}
+
+// logHealth tracks when each polled CT log last succeeded. It is written by
+// the polling goroutines and read by health checks.
+type logHealth struct {
+	mutex sync.Mutex
+	logs  []*logHealthEntry
+}
+
+type logHealthEntry struct {
+	name, url   string
+	since       time.Time // when the log was added, for logs yet to succeed
+	lastSuccess time.Time
+	failures    int
+	lastError   string
+}
+
+// reset starts tracking clients, keeping the state of logs already tracked.
+func (h *logHealth) reset(clients []*CTLogClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	current := make(map[string]*logHealthEntry, len(h.logs))
+	for _, entry := range h.logs {
+		current[entry.url] = entry
+	}
+	h.logs = make([]*logHealthEntry, 0, len(clients))
+	for _, logClient := range clients {
+		entry, ok := current[logClient.url]
+		if !ok {
+			entry = &logHealthEntry{name: logClient.name, url: logClient.url, since: time.Now()}
+		}
+		h.logs = append(h.logs, entry)
+	}
+}
+
+// record notes the result of a poll of logClient.
+func (h *logHealth) record(logClient *CTLogClient, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, entry := range h.logs {
+		if entry.url != logClient.url {
+			continue
+		}
+		entry.failures = logClient.failures
+		if err == nil {
+			entry.lastSuccess = time.Now()
+			entry.lastError = ""
+		} else {
+			entry.lastError = err.Error()
+		}
+		return
+	}
+}
+
+// Health reports whether the monitor is getting certificates. In polling
+// mode it is ready while at least one CT log has been polled successfully
+// within maxAge, a log that has yet to be polled counting from when it was
+// added. In live mode a certstream heartbeat must have arrived within
+// maxAge, or since the monitor started. The certspotter API source is
+// always reported ready.
+func (m *Monitor) Health(maxAge time.Duration) HealthStatus {
+	now := time.Now()
+	switch {
+	case m.liveMode:
+		status := HealthStatus{Mode: "live", LastHeartbeat: m.LastHeartbeat()}
+		last := status.LastHeartbeat
+		if last.IsZero() {
+			last = m.startedAt
+		}
+		status.Ready = now.Sub(last) <= maxAge
+		if !status.Ready {
+			status.Reason = "no certstream heartbeat within " + maxAge.String()
+		}
+		return status
+	case m.source == SourceCertspotterAPI:
+		return HealthStatus{Ready: true, Mode: SourceCertspotterAPI}
+	}
+
+	status := HealthStatus{Mode: "polling"}
+	m.health.mutex.Lock()
+	for _, entry := range m.health.logs {
+		last := entry.lastSuccess
+		if last.IsZero() {
+			last = entry.since
+		}
+		log := LogHealth{
+			Name:        entry.name,
+			URL:         entry.url,
+			Healthy:     now.Sub(last) <= maxAge,
+			LastSuccess: entry.lastSuccess,
+			Failures:    entry.failures,
+			LastError:   entry.lastError,
+		}
+		status.Ready = status.Ready || log.Healthy
+		status.Logs = append(status.Logs, log)
+	}
+	m.health.mutex.Unlock()
+
+	switch {
+	case len(status.Logs) == 0:
+		status.Reason = "no CT clients initialized"
+	case !status.Ready:
+		status.Reason = "no CT log polled successfully within " + maxAge.String()
+	}
+	return status
+}
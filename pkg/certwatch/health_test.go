@@ -0,0 +1,45 @@
+package certwatch
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHealthTracksPolledLogs(t *testing.T) {
+	monitor := NewMonitor()
+	if status := monitor.Health(time.Minute); status.Ready {
+		t.Error("Expected a monitor without CT clients not to be ready")
+	}
+
+	healthy := &CTLogClient{name: "healthy log", url: "https://healthy.example/"}
+	failing := &CTLogClient{name: "failing log", url: "https://failing.example/"}
+	monitor.ctClients = []*CTLogClient{healthy, failing}
+	monitor.health.reset(monitor.ctClients)
+
+	// Logs yet to be polled count from when they were added
+	if status := monitor.Health(time.Minute); !status.Ready || len(status.Logs) != 2 {
+		t.Fatalf("Expected new logs to be ready, got %+v", status)
+	}
+
+	monitor.health.logs[0].since = time.Now().Add(-time.Hour)
+	monitor.health.logs[1].since = time.Now().Add(-time.Hour)
+	monitor.recordPollResult(healthy, nil)
+	monitor.recordPollResult(failing, errors.New("connection refused"))
+
+	status := monitor.Health(time.Minute)
+	if !status.Ready {
+		t.Fatalf("Expected one healthy log to keep the monitor ready, got %+v", status)
+	}
+	if !status.Logs[0].Healthy || status.Logs[1].Healthy {
+		t.Errorf("Expected only the first log to be healthy, got %+v", status.Logs)
+	}
+	if status.Logs[1].Failures != 1 || status.Logs[1].LastError != "connection refused" {
+		t.Errorf("Expected the failing log's error to be reported, got %+v", status.Logs[1])
+	}
+
+	monitor.health.logs[0].lastSuccess = time.Now().Add(-time.Hour)
+	if status := monitor.Health(time.Minute); status.Ready || status.Reason == "" {
+		t.Errorf("Expected the monitor not to be ready once every log is stale, got %+v", status)
+	}
+}
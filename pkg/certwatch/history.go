@@ -0,0 +1,176 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryProvider looks up certificates issued for a domain in the past,
+// from a source such as crt.sh or Censys. Lookup returns those logged
+// within window, or, for sources without the log time such as Censys, whose
+// not_before falls within it.
+type HistoryProvider interface {
+	Lookup(domain string, window TimeRange) ([]*models.CertificateEntry, error)
+}
+
+// TimeRange bounds a history lookup by when certificates were logged (or
+// became valid, see HistoryProvider), inclusive at both ends. A zero Since or Until leaves that end open.
+type TimeRange struct {
+	Since time.Time
+	Until time.Time
+}
+
+// LastDays returns the range covering the last days days, or an open range
+// if days is not positive.
+func LastDays(days int) TimeRange {
+	if days <= 0 {
+		return TimeRange{}
+	}
+	return TimeRange{Since: time.Now().AddDate(0, 0, -days)}
+}
+
+// ParseTimeRange parses since and until as RFC3339 timestamps or YYYY-MM-DD
+// dates; either may be empty to leave that end open. A date for until
+// includes that whole day. Since must be before until.
+func ParseTimeRange(since, until string) (TimeRange, error) {
+	var window TimeRange
+	var err error
+	if since != "" {
+		if window.Since, err = parseRangeTime(since, false); err != nil {
+			return TimeRange{}, fmt.Errorf("invalid since time: %w", err)
+		}
+	}
+	if until != "" {
+		if window.Until, err = parseRangeTime(until, true); err != nil {
+			return TimeRange{}, fmt.Errorf("invalid until time: %w", err)
+		}
+	}
+	if !window.Since.IsZero() && !window.Until.IsZero() && !window.Since.Before(window.Until) {
+		return TimeRange{}, fmt.Errorf("since %s is not before until %s", since, until)
+	}
+	return window, nil
+}
+
+// parseRangeTime parses value as an RFC3339 timestamp or a YYYY-MM-DD date
+// in UTC, taking the end of the day for a date if endOfDay is set.
+func parseRangeTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither RFC3339 nor YYYY-MM-DD", value)
+	}
+	if endOfDay {
+		return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return day, nil
+}
+
+// Contains reports whether t falls within the range.
+func (r TimeRange) Contains(t time.Time) bool {
+	return (r.Since.IsZero() || !t.Before(r.Since)) && (r.Until.IsZero() || !t.After(r.Until))
+}
+
+// NewHistoryProvider returns the built-in provider called name: "crtsh" or
+// "censys". Censys reads its API credentials from the given ID and secret.
+func NewHistoryProvider(name, censysID, censysSecret string) (HistoryProvider, error) {
+	switch strings.ToLower(name) {
+	case "crtsh", "crt.sh":
+		return NewCrtShProvider(), nil
+	case "censys":
+		return NewCensysProvider(censysID, censysSecret)
+	default:
+		return nil, fmt.Errorf("unknown history provider: %s", name)
+	}
+}
+
+// AddHistoryProvider adds a source for GetHistoricalCertificates.
+func (m *Monitor) AddHistoryProvider(provider HistoryProvider) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.history = append(m.history, provider)
+}
+
+// GetHistoricalCertificates queries every configured history provider for
+// certificates from within window and returns their combined
+// results, newest first. Certificates reported by
+// more than one provider are returned once, keyed on serial number and
+// issuer, which every provider reports (or fingerprint when a provider has
+// no serial). An error is returned only if every provider failed.
+func (m *Monitor) GetHistoricalCertificates(domain string, window TimeRange) ([]*models.CertificateEntry, error) {
+	m.mutex.RLock()
+	providers := append([]HistoryProvider(nil), m.history...)
+	m.mutex.RUnlock()
+
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no history providers configured")
+	}
+
+	var errs []error
+	seen := make(map[string]bool)
+	merged := []*models.CertificateEntry{}
+	for _, provider := range providers {
+		entries, err := provider.Lookup(domain, window)
+		if err != nil {
+			m.logger.Error("History lookup failed", "domain", domain, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		for _, entry := range entries {
+			key := historyKey(entry)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if entry.IdempotencyKey == "" {
+				entry.IdempotencyKey = entry.ComputeIdempotencyKey()
+			}
+			merged = append(merged, entry)
+		}
+	}
+	if len(errs) == len(providers) {
+		return nil, errors.Join(errs...)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Timestamp.After(merged[j].Timestamp)
+	})
+	return merged, nil
+}
+
+// historyKey identifies a certificate across providers. crt.sh has no
+// fingerprints, so serial and issuer are used when there is a serial; a
+// precertificate and its final certificate share them and merge too.
+func historyKey(entry *models.CertificateEntry) string {
+	if serial := normalizeSerial(entry.LeafCert.SerialNumber); serial != "" {
+		return "serial:" + serial + "|" + strings.ToLower(entry.LeafCert.IssuerDistinguishedName)
+	}
+	return "fingerprint:" + strings.ToLower(strings.ReplaceAll(entry.LeafCert.Fingerprint, ":", ""))
+}
+
+// normalizeSerial returns a hex serial number in lowercase without colons
+// or leading zeros.
+func normalizeSerial(serial string) string {
+	serial = strings.ToLower(strings.ReplaceAll(serial, ":", ""))
+	if trimmed := strings.TrimLeft(serial, "0"); trimmed != "" || serial == "" {
+		return trimmed
+	}
+	return "0"
+}
+
+// dnField returns the value of attribute (e.g. "CN") from a distinguished
+// name written as "C=US, O=Let's Encrypt, CN=R3", or dn itself if absent.
+func dnField(dn, attribute string) string {
+	for _, part := range strings.Split(dn, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && strings.EqualFold(key, attribute) {
+			return value
+		}
+	}
+	return dn
+}
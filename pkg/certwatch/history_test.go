@@ -0,0 +1,278 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeHistory struct {
+	entries []*models.CertificateEntry
+	err     error
+}
+
+func (p *fakeHistory) Lookup(domain string, window TimeRange) ([]*models.CertificateEntry, error) {
+	return p.entries, p.err
+}
+
+func historyEntry(fingerprint, serial string, seen time.Time) *models.CertificateEntry {
+	return &models.CertificateEntry{
+		Domain:    "example.com",
+		LeafCert:  models.LeafCertificate{Fingerprint: fingerprint, SerialNumber: serial, IssuerDistinguishedName: "R3"},
+		Timestamp: seen,
+	}
+}
+
+func TestGetHistoricalCertificatesMergesProviders(t *testing.T) {
+	now := time.Now()
+	monitor := NewMonitor()
+	monitor.AddHistoryProvider(&fakeHistory{entries: []*models.CertificateEntry{
+		historyEntry("", "0a", now.Add(-3*time.Hour)),
+		historyEntry("", "0a", now.Add(-3*time.Hour)), // precert and final cert
+		historyEntry("", "0b", now.Add(-time.Hour)),
+	}})
+	monitor.AddHistoryProvider(&fakeHistory{err: errors.New("unavailable")})
+	monitor.AddHistoryProvider(&fakeHistory{entries: []*models.CertificateEntry{
+		historyEntry("AB:CD", "", now.Add(-2*time.Hour)),
+		historyEntry("abcd", "", now.Add(-2*time.Hour)),
+	}})
+
+	entries, err := monitor.GetHistoricalCertificates("example.com", LastDays(30))
+	if err != nil {
+		t.Fatalf("GetHistoricalCertificates() error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 deduplicated entries, got %d", len(entries))
+	}
+	if entries[0].LeafCert.SerialNumber != "0b" || entries[2].LeafCert.SerialNumber != "0a" {
+		t.Errorf("Expected newest entries first, got %+v", entries)
+	}
+}
+
+func TestGetHistoricalCertificatesMergesAcrossProviders(t *testing.T) {
+	now := time.Now()
+	crtsh := historyEntry("", "00:0a:0b", now.Add(-time.Hour))
+	censys := historyEntry("AB:CD", censysSerialHex("2571"), now.Add(-time.Hour))
+	otherIssuer := historyEntry("EF:01", censysSerialHex("2571"), now.Add(-time.Hour))
+	otherIssuer.LeafCert.IssuerDistinguishedName = "E5"
+
+	monitor := NewMonitor()
+	monitor.AddHistoryProvider(&fakeHistory{entries: []*models.CertificateEntry{crtsh}})
+	monitor.AddHistoryProvider(&fakeHistory{entries: []*models.CertificateEntry{censys, otherIssuer}})
+
+	// crt.sh's hex serial and Censys's decimal one (2571 = 0xa0b) match
+	entries, err := monitor.GetHistoricalCertificates("example.com", LastDays(30))
+	if err != nil {
+		t.Fatalf("GetHistoricalCertificates() error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected the certificate once plus the other issuer's, got %d", len(entries))
+	}
+	if entries[0] != crtsh || entries[1] != otherIssuer {
+		t.Errorf("Expected the first provider's entry kept, got %+v", entries)
+	}
+}
+
+func TestGetHistoricalCertificatesErrors(t *testing.T) {
+	monitor := NewMonitor()
+	if _, err := monitor.GetHistoricalCertificates("example.com", LastDays(30)); err == nil {
+		t.Error("Expected an error without providers")
+	}
+
+	monitor.AddHistoryProvider(&fakeHistory{err: errors.New("unavailable")})
+	if _, err := monitor.GetHistoricalCertificates("example.com", LastDays(30)); err == nil {
+		t.Error("Expected an error when every provider fails")
+	}
+}
+
+func TestCrtShProvider(t *testing.T) {
+	recent := time.Now().UTC().Add(-24 * time.Hour).Format("2006-01-02T15:04:05")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "%.example.com" || r.URL.Query().Get("output") != "json" {
+			t.Errorf("Unexpected query: %s", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{
+				"id": 1, "issuer_name": "C=US, O=Let's Encrypt, CN=R3", "common_name": "example.com",
+				"name_value": "example.com\nwww.example.com", "serial_number": "03ab",
+				"not_before": recent, "not_after": "2099-04-01T00:00:00", "entry_timestamp": recent + ".000",
+			},
+			{
+				// Backdated, but logged recently
+				"id": 3, "issuer_name": "C=US, O=Let's Encrypt, CN=R3", "common_name": "backdated.example.com",
+				"name_value": "backdated.example.com", "serial_number": "02",
+				"not_before": "2020-01-01T00:00:00", "not_after": "2099-04-01T00:00:00", "entry_timestamp": recent + ".000",
+			},
+			{
+				"id": 2, "issuer_name": "C=US, O=Let's Encrypt, CN=R3", "common_name": "old.example.com",
+				"name_value": "old.example.com", "serial_number": "01",
+				"not_before": "2020-01-01T00:00:00", "not_after": "2020-04-01T00:00:00", "entry_timestamp": "2020-01-01T00:00:00.000",
+			},
+		})
+	}))
+	defer server.Close()
+
+	provider := NewCrtShProvider()
+	provider.baseURL = server.URL
+
+	entries, err := provider.Lookup("example.com", LastDays(30))
+	if err != nil {
+		t.Fatalf("Lookup() error: %v", err)
+	}
+	if len(entries) != 2 || entries[1].LeafCert.Subject.CommonName != "backdated.example.com" {
+		t.Fatalf("Expected the recently logged certificates, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.LeafCert.IssuerDistinguishedName != "R3" || len(entry.Subdomains) != 2 || entry.LeafCert.SerialNumber != "03ab" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	window, err := ParseTimeRange("2025-03-01", "2025-03-15")
+	if err != nil {
+		t.Fatalf("ParseTimeRange() error: %v", err)
+	}
+	for value, want := range map[string]bool{
+		"2025-02-28T23:59:59Z": false,
+		"2025-03-01T00:00:00Z": true,
+		"2025-03-15T23:59:59Z": true,
+		"2025-03-16T00:00:00Z": false,
+	} {
+		at, _ := time.Parse(time.RFC3339, value)
+		if got := window.Contains(at); got != want {
+			t.Errorf("Contains(%s) = %v, want %v", value, got, want)
+		}
+	}
+
+	window, err = ParseTimeRange("2025-03-01T12:00:00+02:00", "")
+	if err != nil {
+		t.Fatalf("ParseTimeRange() error: %v", err)
+	}
+	if !window.Contains(time.Now()) || window.Contains(time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected open-ended range: %+v", window)
+	}
+
+	for _, bad := range [][2]string{{"2025-03-15", "2025-03-01"}, {"2025-03-01T00:00:00Z", "2025-03-01T00:00:00Z"}, {"yesterday", ""}, {"", "03/01/2025"}} {
+		if _, err := ParseTimeRange(bad[0], bad[1]); err == nil {
+			t.Errorf("Expected an error for since %q until %q", bad[0], bad[1])
+		}
+	}
+}
+
+func TestCrtShProviderTimeRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"id": 1, "common_name": "a.example.com", "name_value": "a.example.com", "entry_timestamp": "2025-02-20T00:00:00.000"},
+			{"id": 2, "common_name": "b.example.com", "name_value": "b.example.com", "entry_timestamp": "2025-03-05T00:00:00.000", "not_before": "2025-02-20T00:00:00"},
+			{"id": 3, "common_name": "c.example.com", "name_value": "c.example.com", "entry_timestamp": "2025-03-20T00:00:00.000"},
+		})
+	}))
+	defer server.Close()
+
+	provider := NewCrtShProvider()
+	provider.baseURL = server.URL
+	window, _ := ParseTimeRange("2025-03-01", "2025-03-15")
+
+	entries, err := provider.Lookup("example.com", window)
+	if err != nil {
+		t.Fatalf("Lookup() error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].LeafCert.Subject.CommonName != "b.example.com" {
+		t.Errorf("Expected only the certificate logged within the range, got %+v", entries)
+	}
+}
+
+func TestCensysProvider(t *testing.T) {
+	recent := time.Now().UTC().Add(-24 * time.Hour).Format(time.RFC3339)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if q := r.URL.Query().Get("q"); !strings.Contains(q, "parsed.validity_period.not_before: [") || !strings.HasSuffix(q, " TO *]") {
+			t.Errorf("Expected the query to be limited by not_before, got %q", q)
+		}
+		if id, secret, ok := r.BasicAuth(); !ok || id != "id" || secret != "secret" {
+			t.Errorf("Expected basic auth credentials")
+		}
+		hit := map[string]interface{}{
+			"fingerprint_sha256": "fp1",
+			"names":              []string{"example.com", "www.example.com"},
+			"parsed": map[string]interface{}{
+				"subject_dn":      "CN=example.com",
+				"issuer_dn":       "C=US, O=Let's Encrypt, CN=R3",
+				"serial_number":   "42",
+				"validity_period": map[string]string{"not_before": recent, "not_after": "2099-04-01T00:00:00Z"},
+			},
+		}
+		next := "page2"
+		if r.URL.Query().Get("cursor") == "page2" {
+			hit["fingerprint_sha256"] = "fp2"
+			next = ""
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"result": map[string]interface{}{"hits": []interface{}{hit}, "links": map[string]string{"next": next}},
+		})
+	}))
+	defer server.Close()
+
+	provider, err := NewCensysProvider("id", "secret")
+	if err != nil {
+		t.Fatalf("NewCensysProvider() error: %v", err)
+	}
+	provider.baseURL = server.URL
+
+	entries, err := provider.Lookup("example.com", LastDays(30))
+	if err != nil {
+		t.Fatalf("Lookup() error: %v", err)
+	}
+	if requests != 2 || len(entries) != 2 {
+		t.Fatalf("Expected 2 pages and 2 entries, got %d and %d", requests, len(entries))
+	}
+	if entries[1].LeafCert.Fingerprint != "fp2" || entries[0].LeafCert.Subject.CommonName != "example.com" ||
+		entries[0].LeafCert.SerialNumber != "2a" {
+		t.Errorf("Unexpected entries: %+v", entries)
+	}
+
+	if _, err := NewCensysProvider("", ""); !errors.Is(err, errCensysCredentials) {
+		t.Errorf("Expected a credentials error without credentials, got %v", err)
+	}
+}
+
+func TestCensysProviderRateLimitAndAuth(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if _, secret, _ := r.BasicAuth(); secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"result": {"hits": [{"fingerprint_sha256": "fp1", "names": ["example.com"]}], "links": {"next": ""}}}`))
+	}))
+	defer server.Close()
+
+	provider, _ := NewCensysProvider("id", "secret")
+	provider.baseURL = server.URL
+	entries, err := provider.Lookup("example.com", TimeRange{})
+	if err != nil {
+		t.Fatalf("Expected the rate-limited request to be retried, got %v", err)
+	}
+	if requests != 2 || len(entries) != 1 {
+		t.Errorf("Expected 2 requests and 1 entry, got %d and %d", requests, len(entries))
+	}
+
+	provider, _ = NewCensysProvider("id", "wrong")
+	provider.baseURL = server.URL
+	if _, err := provider.Lookup("example.com", TimeRange{}); !errors.Is(err, errCensysCredentials) {
+		t.Errorf("Expected a credentials error for a 401, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package certwatch
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// defaultHTTPTimeout bounds each request to CT logs and the log list.
+const defaultHTTPTimeout = 30 * time.Second
+
+// SetHTTPOptions configures the HTTP client used for the CT log list, CT
+// logs and the certspotter API. timeout bounds each request (30s if zero).
+// caBundle names a PEM file of extra CAs to trust, e.g. for self-hosted logs
+// behind an internal CA; insecureSkipVerify disables certificate
+// verification altogether and is only meant for testing.
+func (m *Monitor) SetHTTPOptions(timeout time.Duration, caBundle string, insecureSkipVerify bool) error {
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caBundle != "" {
+		pem, err := os.ReadFile(caBundle)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in CA bundle %s", caBundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if insecureSkipVerify {
+		m.logger.Warn("TLS certificate verification is DISABLED for CT log requests; responses can be forged by anyone on the network path")
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	m.httpClient = &http.Client{Timeout: timeout, Transport: transport}
+	return nil
+}
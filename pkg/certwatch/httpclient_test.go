@@ -0,0 +1,56 @@
+package certwatch
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSetHTTPOptions(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, certPEM, 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		caBundle string
+		insecure bool
+		ok       bool
+	}{
+		{"default trust", "", false, false},
+		{"CA bundle", bundle, false, true},
+		{"skip verify", "", true, true},
+	}
+	for _, tt := range tests {
+		monitor := NewMonitor()
+		if err := monitor.SetHTTPOptions(5*time.Second, tt.caBundle, tt.insecure); err != nil {
+			t.Fatalf("%s: SetHTTPOptions() error: %v", tt.name, err)
+		}
+		if monitor.httpClient.Timeout != 5*time.Second {
+			t.Errorf("%s: expected a 5s timeout, got %v", tt.name, monitor.httpClient.Timeout)
+		}
+
+		resp, err := monitor.httpClient.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: expected ok=%v, got error %v", tt.name, tt.ok, err)
+		}
+	}
+
+	if err := NewMonitor().SetHTTPOptions(0, filepath.Join(t.TempDir(), "missing.pem"), false); err == nil {
+		t.Error("Expected an error for a missing CA bundle")
+	}
+}
@@ -0,0 +1,93 @@
+package certwatch
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ipWatch is a watched IP address or CIDR range, with the form it is
+// reported as.
+type ipWatch struct {
+	name   string
+	prefix netip.Prefix
+}
+
+// AddIP watches for certificates with an IP address SAN equal to ip, or
+// within it when ip is a CIDR range such as 203.0.113.0/24. Matches have the
+// reason "ip" and are reported with ip as the entry's domain. IP SANs are
+// only known for certificates parsed from CT logs, not in live mode.
+func (m *Monitor) AddIP(ip string) error {
+	ip = strings.TrimSpace(ip)
+	var watch ipWatch
+	if strings.Contains(ip, "/") {
+		prefix, err := netip.ParsePrefix(ip)
+		if err != nil {
+			return fmt.Errorf("invalid IP range %q: %w", ip, err)
+		}
+		prefix = prefix.Masked()
+		watch = ipWatch{name: prefix.String(), prefix: prefix}
+	} else {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return fmt.Errorf("invalid IP address %q: %w", ip, err)
+		}
+		addr = addr.Unmap()
+		watch = ipWatch{name: addr.String(), prefix: netip.PrefixFrom(addr, addr.BitLen())}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, existing := range m.ipWatches {
+		if existing.prefix == watch.prefix {
+			return nil
+		}
+	}
+	m.ipWatches = append(m.ipWatches, watch)
+	return nil
+}
+
+// matchIP returns the first IP watch covering one of the addresses. Callers
+// must hold m.mutex.
+func (m *Monitor) matchIP(ips []string) (string, bool) {
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			continue
+		}
+		for _, watch := range m.ipWatches {
+			if watch.prefix.Contains(addr.Unmap()) {
+				return watch.name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// matchCertificateIPs is MatchCertificate for a certificate's names, falling
+// back to its IP address SANs when no name matches.
+func (m *Monitor) matchCertificateIPs(names, ips []string) (matched string, reason string, ok bool) {
+	if matched, reason, ok := m.MatchCertificate(names); ok {
+		return matched, reason, true
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if m.allDomainsMode {
+		return "", "", false
+	}
+	if watched, ok := m.matchIP(ips); ok {
+		return watched, "ip", true
+	}
+	return "", "", false
+}
+
+// certificateIPs returns the IP address SANs of cert.
+func certificateIPs(cert *x509.Certificate) []string {
+	var ips []string
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	return ips
+}
@@ -0,0 +1,103 @@
+package certwatch
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTestIPCertificate returns a self-signed DER certificate with the given
+// IP address SANs and an email SAN.
+func newTestIPCertificate(t *testing.T, commonName string, ips ...string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(time.Now().UnixNano()),
+		Subject:        pkix.Name{CommonName: commonName},
+		EmailAddresses: []string{"admin@example.net"},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(90 * 24 * time.Hour),
+	}
+	for _, ip := range ips {
+		template.IPAddresses = append(template.IPAddresses, net.ParseIP(ip))
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return der
+}
+
+func TestAddIPRejectsInvalidAddress(t *testing.T) {
+	monitor := NewMonitor()
+	for _, ip := range []string{"", "203.0.113", "example.com", "203.0.113.0/33"} {
+		if err := monitor.AddIP(ip); err == nil {
+			t.Errorf("Expected an error for %q", ip)
+		}
+	}
+	if len(monitor.ipWatches) != 0 {
+		t.Errorf("Expected no IP watches to be stored, got %d", len(monitor.ipWatches))
+	}
+}
+
+func TestIPMatching(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	for _, ip := range []string{"192.0.2.10", "198.51.100.7/24", "2001:db8::/32"} {
+		if err := monitor.AddIP(ip); err != nil {
+			t.Fatalf("AddIP(%q) error: %v", ip, err)
+		}
+	}
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+
+	tests := []struct {
+		commonName    string
+		ips           []string
+		expectedMatch string
+		expectedNames []string
+	}{
+		{"", []string{"192.0.2.10"}, "192.0.2.10", []string{"192.0.2.10"}},
+		{"host.internal", []string{"192.0.2.11", "198.51.100.200"}, "198.51.100.0/24", []string{"198.51.100.200"}},
+		{"", []string{"2001:db8::1"}, "2001:db8::/32", []string{"2001:db8::1"}},
+		{"www.example.com", []string{"192.0.2.10"}, "example.com", []string{"www.example.com"}}, // names are matched first
+		{"", []string{"192.0.2.11"}, "", nil},
+	}
+	for i, tt := range tests {
+		handler.entries = nil
+		der := newTestIPCertificate(t, tt.commonName, tt.ips...)
+		if err := monitor.processCTEntry(newTestLogEntry(der, time.Now()), int64(i), &CTLogClient{name: "test log"}); err != nil {
+			t.Fatalf("processCTEntry() error: %v", err)
+		}
+
+		if tt.expectedMatch == "" {
+			if len(handler.entries) != 0 {
+				t.Errorf("Expected no match for %v, got %q", tt.ips, handler.entries[0].Domain)
+			}
+			continue
+		}
+		if len(handler.entries) != 1 {
+			t.Fatalf("Expected a match for %v, got %d entries", tt.ips, len(handler.entries))
+		}
+		entry := handler.entries[0]
+		if entry.Domain != tt.expectedMatch || !slices.Equal(entry.MatchedNames, tt.expectedNames) {
+			t.Errorf("Expected %q matching %v, got %q matching %v", tt.expectedMatch, tt.expectedNames, entry.Domain, entry.MatchedNames)
+		}
+		if !slices.Equal(entry.IPAddresses, tt.ips) || !slices.Equal(entry.EmailAddresses, []string{"admin@example.net"}) {
+			t.Errorf("Expected IP SANs %v and the email SAN, got %v and %v", tt.ips, entry.IPAddresses, entry.EmailAddresses)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"strings"
+)
+
+// issuerOrganizations maps lowercase substrings of an issuer's organization
+// or common name to a canonical CA name.
+var issuerOrganizations = []struct {
+	substring string
+	canonical string
+}{
+	{"let's encrypt", "Let's Encrypt"},
+	{"google trust services", "Google Trust Services"},
+	{"digicert", "DigiCert"},
+	{"sectigo", "Sectigo"},
+	{"comodo", "Sectigo"},
+	{"zerossl", "ZeroSSL"},
+	{"globalsign", "GlobalSign"},
+	{"amazon", "Amazon"},
+	{"godaddy", "GoDaddy"},
+	{"starfield", "GoDaddy"},
+	{"microsoft", "Microsoft"},
+	{"entrust", "Entrust"},
+	{"buypass", "Buypass"},
+	{"ssl corporation", "SSL.com"},
+	{"ssl.com", "SSL.com"},
+	{"cloudflare", "Cloudflare"},
+}
+
+// issuerCommonNames maps bare intermediate common names, which carry no
+// organization hint, to a canonical CA name.
+var issuerCommonNames = map[string]string{
+	"r3":         "Let's Encrypt",
+	"r4":         "Let's Encrypt",
+	"r10":        "Let's Encrypt",
+	"r11":        "Let's Encrypt",
+	"r12":        "Let's Encrypt",
+	"r13":        "Let's Encrypt",
+	"r14":        "Let's Encrypt",
+	"e1":         "Let's Encrypt",
+	"e2":         "Let's Encrypt",
+	"e5":         "Let's Encrypt",
+	"e6":         "Let's Encrypt",
+	"e7":         "Let's Encrypt",
+	"e8":         "Let's Encrypt",
+	"e9":         "Let's Encrypt",
+	"wr1":        "Google Trust Services",
+	"wr2":        "Google Trust Services",
+	"we1":        "Google Trust Services",
+	"we2":        "Google Trust Services",
+	"gts ca 1c3": "Google Trust Services",
+	"gts ca 1d4": "Google Trust Services",
+	"gts ca 1p5": "Google Trust Services",
+}
+
+// CanonicalIssuer maps an issuer common name and organization to a canonical
+// CA name, so that variants such as "R3" and "Let's Encrypt R3" group
+// together. Unknown issuers fall back to the organization, then the common
+// name.
+func CanonicalIssuer(commonName, organization string) string {
+	cn := strings.ToLower(strings.TrimSpace(commonName))
+	org := strings.ToLower(strings.TrimSpace(organization))
+
+	if canonical, ok := issuerCommonNames[cn]; ok {
+		return canonical
+	}
+	for _, known := range issuerOrganizations {
+		if strings.Contains(org, known.substring) || strings.Contains(cn, known.substring) {
+			return known.canonical
+		}
+	}
+
+	if organization = strings.TrimSpace(organization); organization != "" {
+		return organization
+	}
+	return strings.TrimSpace(commonName)
+}
+
+// SetIssuerFilter only dispatches certificates whose issuer matches one of
+// allow (when allow is non-empty) and none of deny. Patterns are
+// case-insensitive substrings of the issuer common name, organization or
+// canonical CA name, so "let's encrypt" also covers intermediates like "R3".
+func (m *Monitor) SetIssuerFilter(allow, deny []string) {
+	m.issuerAllow = lowerNonEmpty(allow)
+	m.issuerDeny = lowerNonEmpty(deny)
+}
+
+// issuerAllowed applies the issuer filter to a matched entry.
+func (m *Monitor) issuerAllowed(entry *models.CertificateEntry) bool {
+	if len(m.issuerAllow) == 0 && len(m.issuerDeny) == 0 {
+		return true
+	}
+
+	leaf := entry.LeafCert
+	names := []string{
+		strings.ToLower(leaf.IssuerDistinguishedName),
+		strings.ToLower(leaf.IssuerOrganization),
+		strings.ToLower(CanonicalIssuer(leaf.IssuerDistinguishedName, leaf.IssuerOrganization)),
+	}
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			for _, name := range names {
+				if strings.Contains(name, pattern) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	if len(m.issuerAllow) > 0 && !matches(m.issuerAllow) {
+		return false
+	}
+	return !matches(m.issuerDeny)
+}
+
+func lowerNonEmpty(values []string) []string {
+	var out []string
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
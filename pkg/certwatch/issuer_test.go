@@ -0,0 +1,80 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"testing"
+	"time"
+)
+
+func TestCanonicalIssuer(t *testing.T) {
+	tests := []struct {
+		commonName   string
+		organization string
+		expected     string
+	}{
+		{"R3", "", "Let's Encrypt"},
+		{"R11", "Let's Encrypt", "Let's Encrypt"},
+		{"Let's Encrypt R3", "", "Let's Encrypt"},
+		{"E6", "Let's Encrypt", "Let's Encrypt"},
+		{"Let's Encrypt Authority X3", "Let's Encrypt", "Let's Encrypt"},
+		{"WR1", "Google Trust Services", "Google Trust Services"},
+		{"GTS CA 1C3", "Google Trust Services LLC", "Google Trust Services"},
+		{"Sectigo RSA Domain Validation Secure Server CA", "Sectigo Limited", "Sectigo"},
+		{"COMODO RSA Domain Validation Secure Server CA", "COMODO CA Limited", "Sectigo"},
+		{"DigiCert Global G2 TLS RSA SHA256 2020 CA1", "DigiCert Inc", "DigiCert"},
+		{"Internal Issuing CA", "Example Corp", "Example Corp"},
+		{"Internal Issuing CA", "", "Internal Issuing CA"},
+	}
+
+	for _, test := range tests {
+		if got := CanonicalIssuer(test.commonName, test.organization); got != test.expected {
+			t.Errorf("CanonicalIssuer(%q, %q) = %q, expected %q", test.commonName, test.organization, got, test.expected)
+		}
+	}
+}
+
+func TestIssuerFilter(t *testing.T) {
+	entry := func(cn, org string) *models.CertificateEntry {
+		return &models.CertificateEntry{LeafCert: models.LeafCertificate{IssuerDistinguishedName: cn, IssuerOrganization: org}}
+	}
+
+	tests := []struct {
+		allow    []string
+		deny     []string
+		entry    *models.CertificateEntry
+		expected bool
+	}{
+		{nil, nil, entry("R3", ""), true},
+		{nil, []string{"Let's Encrypt"}, entry("R3", ""), false}, // via the canonical CA name
+		{nil, []string{"let's encrypt"}, entry("GTS CA 1C3", "Google Trust Services LLC"), true},
+		{[]string{"DIGICERT"}, nil, entry("DigiCert Global G2 TLS RSA SHA256 2020 CA1", "DigiCert Inc"), true},
+		{[]string{"digicert"}, nil, entry("R11", "Let's Encrypt"), false},
+		{[]string{"google"}, []string{"wr1"}, entry("WR1", "Google Trust Services"), false},
+		{[]string{" ", "example corp"}, nil, entry("Internal Issuing CA", "Example Corp"), true},
+	}
+	for _, tt := range tests {
+		monitor := NewMonitor()
+		monitor.SetIssuerFilter(tt.allow, tt.deny)
+		if got := monitor.issuerAllowed(tt.entry); got != tt.expected {
+			t.Errorf("allow=%v deny=%v issuer=%q/%q: got %v, expected %v",
+				tt.allow, tt.deny, tt.entry.LeafCert.IssuerDistinguishedName, tt.entry.LeafCert.IssuerOrganization, got, tt.expected)
+		}
+	}
+}
+
+func TestIssuerFilterSkipsHandlers(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+	monitor.SetIssuerFilter(nil, []string{"www.example.com"})
+
+	// Test certificates are self-signed, so the issuer is the subject
+	logClient := &CTLogClient{name: "test log"}
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.example.com"), time.Now()), 1, logClient)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "api.example.com"), time.Now()), 2, logClient)
+
+	if len(handler.entries) != 1 || handler.entries[0].LeafCert.IssuerDistinguishedName != "api.example.com" {
+		t.Errorf("Expected only the allowed issuer to be dispatched, got %d entries", len(handler.entries))
+	}
+}
@@ -0,0 +1,87 @@
+package certwatch
+
+import (
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"domain_watcher/pkg/models"
+	"encoding/base64"
+	"strings"
+)
+
+// minRSAKeyBits and minECDSAKeyBits are the smallest key sizes not reported
+// as weak.
+const (
+	minRSAKeyBits   = 2048
+	minECDSAKeyBits = 256
+)
+
+// SetWeakCryptoOnly restricts dispatch to certificates with weak
+// cryptography: an RSA key under 2048 bits, an ECDSA key under 256 bits, a
+// DSA key, or an MD2, MD5 or SHA-1 signature. Certificates whose key and
+// signature are unknown are not dispatched.
+func (m *Monitor) SetWeakCryptoOnly(enabled bool) {
+	m.weakCryptoOnly = enabled
+}
+
+// setKeyInfo fills in the public key and signature algorithm of leaf from
+// cert.
+func setKeyInfo(leaf *models.LeafCertificate, cert *x509.Certificate) {
+	leaf.PublicKeyAlgorithm = cert.PublicKeyAlgorithm.String()
+	leaf.SignatureAlgorithm = cert.SignatureAlgorithm.String()
+
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		leaf.KeyBits = key.N.BitLen()
+	case *ecdsa.PublicKey:
+		leaf.KeyBits = key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		leaf.KeyBits = 256
+	case *dsa.PublicKey:
+		leaf.KeyBits = key.P.BitLen()
+	}
+}
+
+// setLiveKeyInfo fills in the public key and signature algorithm of leaf
+// from certstream data. The DER certstream includes in full mode has both;
+// otherwise only the stream's signature algorithm, e.g. "sha256, rsa", is
+// available.
+func setLiveKeyInfo(leaf *models.LeafCertificate, certData map[string]interface{}) {
+	if asDER, ok := certData["as_der"].(string); ok {
+		if der, err := base64.StdEncoding.DecodeString(asDER); err == nil {
+			if cert, err := x509.ParseCertificate(der); err == nil {
+				setKeyInfo(leaf, cert)
+				return
+			}
+		}
+	}
+	leaf.SignatureAlgorithm = getString(certData, "signature_algorithm")
+}
+
+// hasWeakCrypto reports whether leaf has a weak key or signature algorithm,
+// as described for SetWeakCryptoOnly.
+func hasWeakCrypto(leaf models.LeafCertificate) bool {
+	switch strings.ToUpper(leaf.PublicKeyAlgorithm) {
+	case "RSA":
+		if leaf.KeyBits > 0 && leaf.KeyBits < minRSAKeyBits {
+			return true
+		}
+	case "ECDSA":
+		if leaf.KeyBits > 0 && leaf.KeyBits < minECDSAKeyBits {
+			return true
+		}
+	case "DSA":
+		return true
+	}
+
+	// Go names these e.g. "SHA1-RSA" and "ECDSA-SHA1", certstream "sha1, rsa"
+	signature := strings.ToUpper(leaf.SignatureAlgorithm)
+	for _, hash := range []string{"MD2", "MD5", "SHA1"} {
+		if strings.Contains(signature, hash) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package certwatch
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"domain_watcher/pkg/models"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// newTestRSACertificate returns a DER-encoded self-signed certificate for
+// commonName with an RSA key of the given size.
+func newTestRSACertificate(t *testing.T, commonName string, bits int) []byte {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return der
+}
+
+func TestSetKeyInfo(t *testing.T) {
+	tests := []struct {
+		der                  []byte
+		algorithm, signature string
+		bits                 int
+	}{
+		{newTestCertificate(t, "ec.example.com"), "ECDSA", "ECDSA-SHA256", 256},
+		{newTestRSACertificate(t, "rsa.example.com", 1024), "RSA", "SHA256-RSA", 1024},
+	}
+	for _, tt := range tests {
+		cert, err := x509.ParseCertificate(tt.der)
+		if err != nil {
+			t.Fatalf("ParseCertificate() error: %v", err)
+		}
+		var leaf models.LeafCertificate
+		setKeyInfo(&leaf, cert)
+		if leaf.PublicKeyAlgorithm != tt.algorithm || leaf.KeyBits != tt.bits || leaf.SignatureAlgorithm != tt.signature {
+			t.Errorf("Expected %s %d signed %s, got %+v", tt.algorithm, tt.bits, tt.signature, leaf)
+		}
+
+		// Certstream's DER gives the same result
+		var live models.LeafCertificate
+		setLiveKeyInfo(&live, map[string]interface{}{"as_der": base64.StdEncoding.EncodeToString(tt.der), "signature_algorithm": "sha256, rsa"})
+		if live.PublicKeyAlgorithm != leaf.PublicKeyAlgorithm || live.KeyBits != leaf.KeyBits || live.SignatureAlgorithm != leaf.SignatureAlgorithm {
+			t.Errorf("Expected live key info %+v, got %+v", leaf, live)
+		}
+	}
+
+	var live models.LeafCertificate
+	setLiveKeyInfo(&live, map[string]interface{}{"signature_algorithm": "sha1, rsa"})
+	if live.SignatureAlgorithm != "sha1, rsa" || live.KeyBits != 0 {
+		t.Errorf("Expected only certstream's signature algorithm without DER, got %+v", live)
+	}
+}
+
+func TestHasWeakCrypto(t *testing.T) {
+	tests := []struct {
+		leaf     models.LeafCertificate
+		expected bool
+	}{
+		{models.LeafCertificate{PublicKeyAlgorithm: "RSA", KeyBits: 1024, SignatureAlgorithm: "SHA256-RSA"}, true},
+		{models.LeafCertificate{PublicKeyAlgorithm: "RSA", KeyBits: 2048, SignatureAlgorithm: "SHA256-RSA"}, false},
+		{models.LeafCertificate{PublicKeyAlgorithm: "RSA", KeyBits: 4096, SignatureAlgorithm: "SHA1-RSA"}, true},
+		{models.LeafCertificate{PublicKeyAlgorithm: "ECDSA", KeyBits: 224, SignatureAlgorithm: "ECDSA-SHA256"}, true},
+		{models.LeafCertificate{PublicKeyAlgorithm: "ECDSA", KeyBits: 384, SignatureAlgorithm: "ECDSA-SHA384"}, false},
+		{models.LeafCertificate{PublicKeyAlgorithm: "DSA", KeyBits: 2048, SignatureAlgorithm: "DSA-SHA256"}, true},
+		{models.LeafCertificate{PublicKeyAlgorithm: "Ed25519", KeyBits: 256, SignatureAlgorithm: "Ed25519"}, false},
+		{models.LeafCertificate{SignatureAlgorithm: "md5, rsa"}, true},
+		{models.LeafCertificate{SignatureAlgorithm: "sha256, rsa"}, false},
+		{models.LeafCertificate{}, false},
+	}
+	for _, tt := range tests {
+		if got := hasWeakCrypto(tt.leaf); got != tt.expected {
+			t.Errorf("hasWeakCrypto(%+v) = %v, expected %v", tt.leaf, got, tt.expected)
+		}
+	}
+}
+
+func TestWeakCryptoOnlyFilter(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+	monitor.SetWeakCryptoOnly(true)
+
+	logClient := &CTLogClient{name: "test log"}
+	for i, der := range [][]byte{
+		newTestCertificate(t, "strong.example.com"),
+		newTestRSACertificate(t, "weak.example.com", 1024),
+	} {
+		if err := monitor.processCTEntry(newTestLogEntry(der, time.Now()), int64(i), logClient); err != nil {
+			t.Fatalf("processCTEntry() error: %v", err)
+		}
+	}
+
+	if len(handler.entries) != 1 {
+		t.Fatalf("Expected only the weak certificate to be dispatched, got %d entries", len(handler.entries))
+	}
+	if leaf := handler.entries[0].LeafCert; leaf.Subject.CommonName != "weak.example.com" || leaf.KeyBits != 1024 {
+		t.Errorf("Unexpected entry dispatched: %+v", leaf)
+	}
+}
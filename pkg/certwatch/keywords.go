@@ -0,0 +1,100 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"strings"
+)
+
+// keywordRoute binds a keyword to the notification handlers that should
+// receive certificates containing it.
+type keywordRoute struct {
+	keyword   string
+	notifiers []CertificateHandler
+}
+
+// AddKeyword watches for certificates with a name containing keyword
+// (case-insensitive), e.g. "bank" to catch "mybank-login.example". Matches are
+// sent to the given notification handlers instead of the ones registered with
+// AddNotificationHandler; with no handlers the defaults are used. Storage
+// handlers always receive every match.
+//
+// Keywords also route certificates that matched a watched domain, so a
+// keyword can escalate part of an existing watch list. Keywords are checked
+// in the order they were added and the first hit wins.
+func (m *Monitor) AddKeyword(keyword string, notifiers ...CertificateHandler) {
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	if keyword == "" {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.keywords = append(m.keywords, keywordRoute{keyword: keyword, notifiers: notifiers})
+}
+
+// SetKeywordFilter restricts all-domains mode to certificates with a name
+// containing one of keywords (case-insensitive), e.g. "paypal" to follow a
+// brand across every TLD. The first name containing a keyword becomes the
+// entry's domain. An empty list reports every certificate again. Unlike
+// AddKeyword this does not affect watched-domain matching.
+func (m *Monitor) SetKeywordFilter(keywords []string) {
+	var filter []string
+	for _, keyword := range keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			filter = append(filter, keyword)
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.keywordFilter = filter
+}
+
+// filterKeyword returns the first name containing a keyword filter entry,
+// or the first name when no filter is set. Callers must hold m.mutex.
+func (m *Monitor) filterKeyword(domains []string) (string, bool) {
+	if len(m.keywordFilter) == 0 {
+		return domains[0], true
+	}
+	for _, domain := range domains {
+		lower := strings.ToLower(domain)
+		for _, keyword := range m.keywordFilter {
+			if strings.Contains(lower, keyword) {
+				return domain, true
+			}
+		}
+	}
+	return "", false
+}
+
+// matchKeyword returns the first certificate name containing a configured
+// keyword, and the route for that keyword. Callers must hold m.mutex.
+func (m *Monitor) matchKeyword(domains []string) (string, *keywordRoute) {
+	for i := range m.keywords {
+		route := &m.keywords[i]
+		for _, domain := range domains {
+			if strings.Contains(strings.ToLower(domain), route.keyword) {
+				return domain, route
+			}
+		}
+	}
+	return "", nil
+}
+
+// notifiersFor picks the notification handlers for an entry: those bound to
+// the first keyword found in its names, or the defaults. It records the
+// keyword on the entry.
+func (m *Monitor) notifiersFor(entry *models.CertificateEntry) []CertificateHandler {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	_, route := m.matchKeyword(entry.Subdomains)
+	if route == nil {
+		return m.notifiers
+	}
+	entry.Keyword = route.keyword
+	if len(route.notifiers) == 0 {
+		return m.notifiers
+	}
+	return route.notifiers
+}
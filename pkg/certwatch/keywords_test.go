@@ -0,0 +1,83 @@
+package certwatch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeywordRouting(t *testing.T) {
+	monitor := NewMonitor()
+	store := &mockHandler{}
+	pagerduty := &mockHandler{}
+	slack := &mockHandler{}
+	monitor.AddHandler(store)
+	monitor.AddNotificationHandler(slack)
+	monitor.AddKeyword("bank", pagerduty)
+	monitor.AddKeyword("login", slack)
+
+	logClient := &CTLogClient{name: "test log"}
+	loggedAt := monitor.startedAt.Add(time.Second)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "secure-bank.example.net"), loggedAt), 1, logClient)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "login-portal.example.org"), loggedAt), 2, logClient)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.unrelated.com"), loggedAt), 3, logClient)
+
+	if len(store.entries) != 2 {
+		t.Errorf("Expected storage to receive both keyword matches, got %d", len(store.entries))
+	}
+	if len(pagerduty.entries) != 1 || pagerduty.entries[0].Domain != "secure-bank.example.net" {
+		t.Fatalf("Expected the bank match to go to PagerDuty, got %d entries", len(pagerduty.entries))
+	}
+	if pagerduty.entries[0].Keyword != "bank" {
+		t.Errorf("Expected keyword bank on entry, got %q", pagerduty.entries[0].Keyword)
+	}
+	if len(slack.entries) != 1 || slack.entries[0].Domain != "login-portal.example.org" {
+		t.Fatalf("Expected the login match to go to Slack, got %d entries", len(slack.entries))
+	}
+}
+
+func TestKeywordRoutesWatchedDomainMatch(t *testing.T) {
+	monitor := NewMonitor()
+	pagerduty := &mockHandler{}
+	slack := &mockHandler{}
+	monitor.AddNotificationHandler(slack)
+	monitor.AddDomain("example.com", true)
+	monitor.AddKeyword("BANK", pagerduty)
+
+	logClient := &CTLogClient{name: "test log"}
+	loggedAt := monitor.startedAt.Add(time.Second)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "bank.example.com"), loggedAt), 1, logClient)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.example.com"), loggedAt), 2, logClient)
+
+	if len(pagerduty.entries) != 1 || pagerduty.entries[0].Domain != "example.com" {
+		t.Errorf("Expected the keyword to route the watched-domain match to PagerDuty, got %d entries", len(pagerduty.entries))
+	}
+	if len(slack.entries) != 1 || slack.entries[0].LeafCert.Subject.CommonName != "www.example.com" {
+		t.Errorf("Expected the plain match to use the default notifier, got %d entries", len(slack.entries))
+	}
+}
+
+func TestKeywordFilterAllDomainsMode(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.SetAllDomainsMode(true)
+	monitor.SetKeywordFilter([]string{"PayPal", " gov "})
+
+	logClient := &CTLogClient{name: "test log"}
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.unrelated.com", "secure-paypal.example.net"), time.Now()), 1, logClient)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "portal.example.gov.uk"), time.Now()), 2, logClient)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.unrelated.com"), time.Now()), 3, logClient)
+
+	if len(handler.entries) != 2 {
+		t.Fatalf("Expected 2 keyword matches, got %d", len(handler.entries))
+	}
+	if handler.entries[0].Domain != "secure-paypal.example.net" {
+		t.Errorf("Expected the name containing the keyword as the domain, got %s", handler.entries[0].Domain)
+	}
+
+	monitor.SetKeywordFilter(nil)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.other.com"), time.Now()), 4, logClient)
+	if len(handler.entries) != 3 {
+		t.Errorf("Expected an empty filter to report every certificate, got %d entries", len(handler.entries))
+	}
+}
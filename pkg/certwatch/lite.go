@@ -0,0 +1,72 @@
+package certwatch
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/jmoiron/jsonq"
+)
+
+// liteStreamPath is the certstream endpoint sending only the names of each
+// certificate.
+const liteStreamPath = "/domains-only"
+
+// SetLiteStream makes live mode subscribe to certstream's domains-only feed,
+// which sends just the names of each certificate and takes a fraction of
+// the bandwidth of the full feed. Entries then only have their domain,
+// names and timestamp: subject, issuer, validity, serial and chain are
+// empty, so filters on them drop every entry.
+func (m *Monitor) SetLiteStream(enabled bool) {
+	m.liteStream = enabled
+}
+
+// streamURL returns the certstream URL live mode dials: the configured one,
+// or its domains-only variant with SetLiteStream.
+func (m *Monitor) streamURL() string {
+	if !m.liteStream {
+		return m.certstreamURL
+	}
+	return liteStreamURL(m.certstreamURL)
+}
+
+// liteStreamURL returns the domains-only endpoint of the certstream server
+// at rawURL, e.g. wss://certstream.calidog.io/domains-only for
+// wss://certstream.calidog.io or wss://certstream.calidog.io/full-stream.
+func liteStreamURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	if strings.HasSuffix(path, liteStreamPath) {
+		return rawURL
+	}
+	u.Path = strings.TrimSuffix(path, "/full-stream") + liteStreamPath
+	return u.String()
+}
+
+// processLiveDomains handles a "dns_entries" message of the domains-only
+// feed, whose data is the list of names of one certificate.
+func (m *Monitor) processLiveDomains(jq *jsonq.JsonQuery) {
+	names, err := jq.ArrayOfStrings("data")
+	if err != nil {
+		m.dropLiveMessage("missing_domains", "error", err)
+		return
+	}
+
+	var allDomains []string
+	for _, name := range names {
+		if name != "" {
+			allDomains = append(allDomains, name)
+		}
+	}
+	allDomains = uniqueNames(allDomains)
+	if len(allDomains) == 0 {
+		m.dropLiveMessage("no_names")
+		return
+	}
+
+	if entry := m.matchLiveEntry(map[string]interface{}{}, allDomains); entry != nil {
+		m.dispatch(entry, false)
+	}
+}
@@ -0,0 +1,70 @@
+package certwatch
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jmoiron/jsonq"
+)
+
+func TestLiteStreamURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"wss://certstream.calidog.io", "wss://certstream.calidog.io/domains-only"},
+		{"wss://certstream.calidog.io/", "wss://certstream.calidog.io/domains-only"},
+		{"wss://certstream.example.test/full-stream", "wss://certstream.example.test/domains-only"},
+		{"ws://localhost:8080/certstream/", "ws://localhost:8080/certstream/domains-only"},
+		{"wss://certstream.example.test/domains-only", "wss://certstream.example.test/domains-only"},
+	}
+	for _, tt := range tests {
+		if got := liteStreamURL(tt.url); got != tt.expected {
+			t.Errorf("liteStreamURL(%q) = %q, expected %q", tt.url, got, tt.expected)
+		}
+	}
+
+	monitor := NewMonitorWithCertstreamURL("wss://certstream.example.test")
+	if got := monitor.streamURL(); got != "wss://certstream.example.test" {
+		t.Errorf("Expected the configured URL without SetLiteStream, got %q", got)
+	}
+	monitor.SetLiteStream(true)
+	if got := monitor.streamURL(); got != "wss://certstream.example.test/domains-only" {
+		t.Errorf("Expected the domains-only URL with SetLiteStream, got %q", got)
+	}
+}
+
+func TestProcessLiveEventDNSEntries(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+
+	for _, data := range []interface{}{
+		[]interface{}{"www.example.com", "example.com", "other.test"},
+		[]interface{}{"unrelated.test"},
+		[]interface{}{""},
+		"www.example.com",
+	} {
+		monitor.processLiveEvent(jsonq.NewQuery(map[string]interface{}{
+			"message_type": "dns_entries",
+			"data":         data,
+		}))
+	}
+
+	if len(handler.entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(handler.entries))
+	}
+	entry := handler.entries[0]
+	if entry.Domain != "example.com" || !slices.Equal(entry.Subdomains, []string{"www.example.com", "example.com", "other.test"}) {
+		t.Errorf("Unexpected entry %q with names %v", entry.Domain, entry.Subdomains)
+	}
+	if !slices.Equal(entry.MatchedNames, []string{"www.example.com", "example.com"}) {
+		t.Errorf("Expected the example.com names to be matched, got %v", entry.MatchedNames)
+	}
+
+	stats := monitor.StatsSnapshot()
+	if stats.DroppedMessages != 2 || stats.CertificatesSeen != 2 {
+		t.Errorf("Expected 2 dropped messages and 2 certificates seen, got %d and %d", stats.DroppedMessages, stats.CertificatesSeen)
+	}
+}
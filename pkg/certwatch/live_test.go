@@ -0,0 +1,119 @@
+package certwatch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/jsonq"
+)
+
+func TestLiveModeUsesConfiguredCertstreamURL(t *testing.T) {
+	monitor := NewMonitorWithCertstreamURL("wss://certstream.example.test/full-stream")
+
+	var mutex sync.Mutex
+	var dialed []string
+	errChans := make(chan chan error, 2)
+	monitor.dialStream = func(url string) (chan jsonq.JsonQuery, chan error) {
+		mutex.Lock()
+		dialed = append(dialed, url)
+		mutex.Unlock()
+		errChan := make(chan error, 1)
+		errChans <- errChan
+		return make(chan jsonq.JsonQuery), errChan
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- monitor.startLiveMode() }()
+
+	// Fail the first connection so the monitor reconnects
+	(<-errChans) <- errors.New("connection reset")
+	select {
+	case <-errChans:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Monitor did not reconnect")
+	}
+
+	monitor.Stop()
+	if err := <-done; err != nil {
+		t.Fatalf("startLiveMode() error: %v", err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(dialed) != 2 {
+		t.Fatalf("Expected an initial connection and a reconnect, got %v", dialed)
+	}
+	for _, url := range dialed {
+		if url != "wss://certstream.example.test/full-stream" {
+			t.Errorf("Expected the configured certstream URL, got %q", url)
+		}
+	}
+}
+
+func TestStartContextStopsOnCancel(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.SetLiveMode(true)
+	monitor.dialStream = func(url string) (chan jsonq.JsonQuery, chan error) {
+		return make(chan jsonq.JsonQuery), make(chan error)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- monitor.StartContext(ctx) }()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("StartContext() error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Monitor did not stop when its context was cancelled")
+	}
+}
+
+func TestLiveGapTracking(t *testing.T) {
+	var buf bytes.Buffer
+	monitor := NewMonitor()
+	monitor.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	monitor.SetLiveGapWarning(time.Minute)
+
+	start := time.Now().Add(-time.Hour)
+	monitor.liveMessageReceived(start)
+
+	// A short drop, with a failed reconnect counted as the same drop
+	monitor.liveStreamDropped(start.Add(10 * time.Second))
+	monitor.liveStreamDropped(start.Add(15 * time.Second))
+	monitor.liveMessageReceived(start.Add(20 * time.Second))
+	if strings.Contains(buf.String(), "level=WARN") || !strings.Contains(buf.String(), "gap=20s") {
+		t.Errorf("Expected a 20s gap logged at info level, got %q", buf.String())
+	}
+
+	// A long one warns
+	buf.Reset()
+	monitor.liveStreamDropped(start.Add(time.Minute))
+	monitor.liveMessageReceived(start.Add(4 * time.Minute))
+	if !strings.Contains(buf.String(), "level=WARN") || !strings.Contains(buf.String(), "gap=3m40s") {
+		t.Errorf("Expected a warning for a 3m40s gap, got %q", buf.String())
+	}
+	// with the range to backfill
+	backfill := "last_message=" + start.Add(20*time.Second).Format(time.RFC3339) +
+		" resumed_at=" + start.Add(4*time.Minute).Format(time.RFC3339)
+	if !strings.Contains(buf.String(), backfill) {
+		t.Errorf("Expected the backfill range %q, got %q", backfill, buf.String())
+	}
+
+	stats := monitor.StatsSnapshot()
+	if stats.LiveDisconnects != 2 || stats.LiveDisconnectedSeconds != 190 {
+		t.Errorf("Expected 2 disconnects totalling 190s, got %d and %vs", stats.LiveDisconnects, stats.LiveDisconnectedSeconds)
+	}
+	if !stats.LastLiveMessage.Equal(start.Add(4 * time.Minute)) {
+		t.Errorf("Expected the last message time to be recorded, got %v", stats.LastLiveMessage)
+	}
+}
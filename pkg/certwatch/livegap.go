@@ -0,0 +1,40 @@
+package certwatch
+
+import "time"
+
+const defaultLiveGapWarning = 5 * time.Minute
+
+// SetLiveGapWarning logs a warning when the live stream resumes after a gap
+// of at least d without messages, with the time range to backfill by
+// polling (last_message to resumed_at), since certstream cannot replay what
+// was missed. Shorter gaps are logged at info level. Zero disables the
+// warning.
+func (m *Monitor) SetLiveGapWarning(d time.Duration) {
+	m.liveGapWarning = d
+}
+
+// liveStreamDropped records that the live stream failed at now. Failed
+// reconnect attempts extend the same gap.
+func (m *Monitor) liveStreamDropped(now time.Time) {
+	m.stats.recordLiveDrop(now)
+}
+
+// liveMessageReceived records a certstream message received at now and, if
+// it is the first since the stream dropped, logs how long the gap was.
+func (m *Monitor) liveMessageReceived(now time.Time) {
+	gap, lastMessage, resumed := m.stats.recordLiveMessage(now)
+	if !resumed {
+		return
+	}
+
+	attrs := []any{
+		"gap", gap.Round(time.Second),
+		"last_message", lastMessage.Format(time.RFC3339),
+		"resumed_at", now.Format(time.RFC3339),
+	}
+	if m.liveGapWarning > 0 && gap >= m.liveGapWarning {
+		m.logger.Warn("Live stream resumed after a long gap; certificates logged meanwhile were missed, consider a polling backfill", attrs...)
+		return
+	}
+	m.logger.Info("Live stream resumed", attrs...)
+}
@@ -0,0 +1,187 @@
+package certwatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultLogListURL is the CT log list polling mode selects logs from.
+const defaultLogListURL = "https://loglist.certspotter.org/monitor.json"
+
+// defaultLogListTTL is how long a fetched log list is used before it is
+// fetched again.
+const defaultLogListTTL = 12 * time.Hour
+
+// cachedLogList is the on-disk copy of a fetched log list.
+type cachedLogList struct {
+	URL       string    `json:"url"`
+	FetchedAt time.Time `json:"fetched_at"`
+	LogList   CTLogList `json:"log_list"`
+}
+
+// SetLogListURL replaces the CT log list polling mode selects logs from. The
+// list must use the certspotter monitor.json format; empty restores the
+// default.
+func (m *Monitor) SetLogListURL(url string) {
+	if url == "" {
+		url = defaultLogListURL
+	}
+	m.logListURL = url
+}
+
+// SetCustomLogs adds CT logs to poll on top of those selected from the log
+// list, e.g. a private or self-hosted log. They are always polled, whatever
+// their state and the --max-logs limit, and are used alone if the log list
+// cannot be fetched.
+func (m *Monitor) SetCustomLogs(urls []string) {
+	m.customLogs = nil
+	for _, url := range urls {
+		if url = strings.TrimSpace(url); url != "" {
+			m.customLogs = append(m.customLogs, url)
+		}
+	}
+}
+
+// SetLogListCache keeps the fetched CT log list in the file at path and uses
+// it for ttl instead of fetching the list again, and past that if the list
+// cannot be fetched. In polling mode the list is also refreshed every ttl, so
+// logs that become active are polled without a restart and retired ones
+// dropped. An empty path only disables the file; ttl zero or less disables
+// both.
+func (m *Monitor) SetLogListCache(path string, ttl time.Duration) {
+	m.logListCache = path
+	m.logListTTL = ttl
+}
+
+// loadLogList returns the cached log list while it is fresh, and fetches it
+// otherwise, falling back to a stale cached copy if the fetch fails.
+func (m *Monitor) loadLogList() (CTLogList, error) {
+	cached, cacheErr := m.readLogListCache()
+	if cacheErr == nil && time.Since(cached.FetchedAt) < m.logListTTL {
+		m.logger.Debug("Using cached CT log list", "fetched_at", cached.FetchedAt)
+		return cached.LogList, nil
+	}
+
+	logList, err := m.fetchLogList()
+	if err != nil {
+		if cacheErr == nil {
+			m.logger.Warn("Using stale cached CT log list", "fetched_at", cached.FetchedAt, "error", err)
+			return cached.LogList, nil
+		}
+		return logList, err
+	}
+	m.writeLogListCache(logList)
+	return logList, nil
+}
+
+// refreshCTClients fetches the log list again and updates the polled logs to
+// match: newly selected logs are added from their current tree head, logs no
+// longer selected are dropped and the others keep their progress. It runs on
+// the polling goroutine, between cycles.
+func (m *Monitor) refreshCTClients() {
+	logList, err := m.fetchLogList()
+	if err != nil {
+		m.logger.Warn("Failed to refresh CT log list", "error", err)
+		return
+	}
+	m.writeLogListCache(logList)
+
+	current := make(map[string]*CTLogClient, len(m.ctClients))
+	for _, logClient := range m.ctClients {
+		current[logClient.url] = logClient
+	}
+
+	var clients []*CTLogClient
+	for _, url := range m.selectLogURLs(logList) {
+		if logClient, ok := current[url]; ok {
+			clients = append(clients, logClient)
+			delete(current, url)
+			continue
+		}
+		logClient, err := m.newLogClient(url, logList)
+		if err != nil {
+			m.logger.Error("Failed to create CT client", "url", url, "error", err)
+			continue
+		}
+		m.initializeLogStartingPoint(logClient)
+		clients = append(clients, logClient)
+		m.logger.Info("Polling newly active CT log", "log", logClient.name, "url", url)
+	}
+	if len(clients) == 0 {
+		m.logger.Warn("Refreshed CT log list selected no logs; keeping the current ones")
+		return
+	}
+	for _, logClient := range current {
+		m.logger.Info("Stopped polling inactive CT log", "log", logClient.name, "url", logClient.url)
+	}
+	m.ctClients = clients
+	m.health.reset(clients)
+}
+
+// readLogListCache reads the cached copy of the configured log list.
+func (m *Monitor) readLogListCache() (cachedLogList, error) {
+	var cached cachedLogList
+	if m.logListCache == "" || m.logListTTL <= 0 {
+		return cached, fmt.Errorf("log list cache disabled")
+	}
+	data, err := os.ReadFile(m.logListCache)
+	if err != nil {
+		return cached, err
+	}
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return cached, fmt.Errorf("failed to decode cached CT log list: %w", err)
+	}
+	if cached.URL != m.logListURL {
+		return cached, fmt.Errorf("cached CT log list is for %s", cached.URL)
+	}
+	return cached, nil
+}
+
+// writeLogListCache saves logList to the cache file atomically. Failures are
+// only logged, since the list itself was fetched.
+func (m *Monitor) writeLogListCache(logList CTLogList) {
+	if m.logListCache == "" || m.logListTTL <= 0 {
+		return
+	}
+	data, err := json.Marshal(cachedLogList{URL: m.logListURL, FetchedAt: time.Now(), LogList: logList})
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(m.logListCache), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(m.logListCache+".tmp", data, 0644)
+	}
+	if err == nil {
+		err = os.Rename(m.logListCache+".tmp", m.logListCache)
+	}
+	if err != nil {
+		m.logger.Warn("Failed to cache CT log list", "path", m.logListCache, "error", err)
+	}
+}
+
+// fetchLogList downloads the CT log list.
+func (m *Monitor) fetchLogList() (CTLogList, error) {
+	var logList CTLogList
+	resp, err := m.httpClient.Get(m.logListURL)
+	if err != nil {
+		return logList, fmt.Errorf("failed to fetch CT log list: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return logList, fmt.Errorf("failed to fetch CT log list: %s returned %s", m.logListURL, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&logList); err != nil {
+		return logList, fmt.Errorf("failed to decode CT log list: %w", err)
+	}
+	return logList, nil
+}
+
+// sameLogURL reports whether two CT log URLs name the same log.
+func sameLogURL(a, b string) bool {
+	return strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
@@ -0,0 +1,156 @@
+package certwatch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// logListServer serves a log list with one log per URL in logs, or fails
+// while logs holds nil, counting requests.
+func logListServer(t *testing.T, logs *atomic.Value, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		urls, _ := logs.Load().([]string)
+		if urls == nil {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var list CTLogList
+		list.Operators = []CTLogOperator{{Name: "Operator"}}
+		for _, url := range urls {
+			list.Operators[0].Logs = append(list.Operators[0].Logs, CTLogInfo{URL: url, Description: url})
+		}
+		json.NewEncoder(w).Encode(list)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCustomLogsAddedToLogList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"operators": [{"name": "Operator", "logs": [
+			{"url": "https://public.example/log/", "description": "Public log"},
+			{"url": "https://retired.example/log/", "description": "Retired log", "state": {"retired": {}}}
+		]}]}`))
+	}))
+	defer server.Close()
+
+	monitor := NewMonitor()
+	monitor.SetLogListURL(server.URL)
+	monitor.SetCustomLogs([]string{"https://ct.internal.example/", "https://public.example/log", "https://retired.example/log/"})
+	if err := monitor.initializeCTClients(); err != nil {
+		t.Fatalf("initializeCTClients() error: %v", err)
+	}
+
+	var urls []string
+	for _, logClient := range monitor.ctClients {
+		urls = append(urls, logClient.url)
+	}
+	want := []string{"https://public.example/log/", "https://ct.internal.example/", "https://retired.example/log/"}
+	if len(urls) != len(want) {
+		t.Fatalf("Expected logs %v, got %v", want, urls)
+	}
+	for i := range want {
+		if urls[i] != want[i] {
+			t.Errorf("Expected logs %v, got %v", want, urls)
+			break
+		}
+	}
+	if name := monitor.ctClients[2].name; name != "Retired log" {
+		t.Errorf("Expected a listed custom log to keep its description, got %q", name)
+	}
+}
+
+func TestCustomLogsWithoutLogList(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	monitor := NewMonitor()
+	monitor.SetLogListURL(server.URL)
+	if err := monitor.initializeCTClients(); err == nil {
+		t.Fatal("Expected an error when the log list is unavailable and no custom logs are set")
+	}
+
+	monitor.SetCustomLogs([]string{"https://ct.internal.example/"})
+	if err := monitor.initializeCTClients(); err != nil {
+		t.Fatalf("Expected custom logs to be used alone, got %v", err)
+	}
+	if len(monitor.ctClients) != 1 || monitor.ctClients[0].name != "https://ct.internal.example/" {
+		t.Errorf("Expected only the custom log, got %d clients", len(monitor.ctClients))
+	}
+}
+
+func TestLogListCache(t *testing.T) {
+	var logs atomic.Value
+	var requests atomic.Int32
+	logs.Store([]string{"https://one.example/"})
+	server := logListServer(t, &logs, &requests)
+	cache := filepath.Join(t.TempDir(), "loglist.json")
+
+	newMonitor := func(ttl time.Duration) *Monitor {
+		monitor := NewMonitor()
+		monitor.SetLogListURL(server.URL)
+		monitor.SetLogListCache(cache, ttl)
+		return monitor
+	}
+
+	if _, err := newMonitor(time.Hour).loadLogList(); err != nil {
+		t.Fatalf("loadLogList() error: %v", err)
+	}
+	if _, err := newMonitor(time.Hour).loadLogList(); err != nil {
+		t.Fatalf("loadLogList() error: %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected the fresh cache to be used, got %d fetches", n)
+	}
+
+	// A stale cache is refetched, and still used when the list is down
+	logs.Store([]string(nil))
+	list, err := newMonitor(time.Nanosecond).loadLogList()
+	if err != nil {
+		t.Fatalf("Expected the stale cache as a fallback, got %v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected the stale cache to be refetched, got %d fetches", n)
+	}
+	if len(list.Operators) != 1 || list.Operators[0].Logs[0].URL != "https://one.example/" {
+		t.Errorf("Unexpected cached log list: %+v", list)
+	}
+}
+
+func TestRefreshCTClients(t *testing.T) {
+	var logs atomic.Value
+	var requests atomic.Int32
+	logs.Store([]string{"https://one.example/", "https://two.example/"})
+	server := logListServer(t, &logs, &requests)
+
+	monitor := NewMonitor()
+	monitor.SetLogListURL(server.URL)
+	if err := monitor.SetStateFile(filepath.Join(t.TempDir(), "state.json")); err != nil {
+		t.Fatalf("SetStateFile() error: %v", err)
+	}
+	monitor.pollState.save("https://three.example/", 42)
+	if err := monitor.initializeCTClients(); err != nil {
+		t.Fatalf("initializeCTClients() error: %v", err)
+	}
+	monitor.ctClients[0].lastIndex = 7
+
+	logs.Store([]string{"https://one.example/", "https://three.example/"})
+	monitor.refreshCTClients()
+
+	if len(monitor.ctClients) != 2 {
+		t.Fatalf("Expected 2 logs after the refresh, got %d", len(monitor.ctClients))
+	}
+	if lc := monitor.ctClients[0]; lc.url != "https://one.example/" || lc.lastIndex != 7 {
+		t.Errorf("Expected the kept log to keep its progress, got %s at %d", lc.url, lc.lastIndex)
+	}
+	if lc := monitor.ctClients[1]; lc.url != "https://three.example/" || lc.lastIndex != 42 {
+		t.Errorf("Expected the newly active log to be added, got %s at %d", lc.url, lc.lastIndex)
+	}
+}
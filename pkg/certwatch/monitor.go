@@ -0,0 +1,1542 @@
+package certwatch
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"domain_watcher/pkg/models"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/jmoiron/jsonq"
+	"github.com/pathtofile/certstream-go"
+	"golang.org/x/net/idna"
+	"golang.org/x/time/rate"
+)
+
+type CTLogInfo struct {
+	URL              string                 `json:"url"`
+	Description      string                 `json:"description"`
+	LogID            string                 `json:"log_id"`
+	TemporalInterval *CTTemporalInterval    `json:"temporal_interval,omitempty"`
+	State            map[string]interface{} `json:"state,omitempty"`
+}
+
+// CTTemporalInterval is the range of certificate expiry dates a sharded log
+// accepts.
+type CTTemporalInterval struct {
+	StartInclusive time.Time `json:"start_inclusive"`
+	EndExclusive   time.Time `json:"end_exclusive"`
+}
+
+type CTLogOperator struct {
+	Name string      `json:"name"`
+	Logs []CTLogInfo `json:"logs"`
+}
+
+type CTLogList struct {
+	Operators []CTLogOperator `json:"operators"`
+}
+
+type CTLogClient struct {
+	client    ctLogAPI
+	url       string
+	name      string
+	lastIndex int64
+	limiter   *rate.Limiter // nil when requests are not throttled
+	backoff   time.Duration // first delay before retrying a failed request
+
+	failures         int   // polls in a row that failed
+	badIndex         int64 // entry that could not be fetched on its own
+	badIndexFailures int   // polls in a row badIndex failed
+}
+
+// ctLogAPI is the part of a CT log client the monitor polls through;
+// *client.LogClient implements it.
+type ctLogAPI interface {
+	GetSTH(ctx context.Context) (*ct.SignedTreeHead, error)
+	GetRawEntries(ctx context.Context, start, end int64) (*ct.GetEntriesResponse, error)
+}
+
+var _ ctLogAPI = (*client.LogClient)(nil)
+
+// defaultMaxLogs is how many CT logs polling mode watches by default.
+const defaultMaxLogs = 5
+
+// defaultPollConcurrency is how many CT logs are checked at once by default.
+const defaultPollConcurrency = 4
+
+// minBatchSize is how many entries a poll requests from a log that is
+// caught up, and defaultMaxBatchSize how many it requests at most while
+// catching up.
+const (
+	minBatchSize        = 50
+	defaultMaxBatchSize = 1000
+)
+
+// defaultStopTimeout bounds how long Stop waits for an in-flight poll cycle.
+const defaultStopTimeout = 30 * time.Second
+
+type Monitor struct {
+	watchedDomains   map[string]*models.DomainWatch
+	mutex            sync.RWMutex
+	watchMutex       sync.Mutex // serializes changes to the watch list
+	handlers         []CertificateHandler
+	notifiers        []CertificateHandler
+	stopChan         chan struct{}
+	stopMutex        sync.Mutex
+	stopping         bool
+	cycles           sync.WaitGroup // poll cycles in flight, drained by Stop
+	ctx              context.Context
+	cancel           context.CancelFunc
+	ctClients        []*CTLogClient
+	pollInterval     time.Duration
+	httpClient       *http.Client
+	liveMode         bool
+	allDomainsMode   bool
+	sampleRate       float64
+	certstreamURL    string
+	dialStream       func(url string) (chan jsonq.JsonQuery, chan error)
+	maxBackoff       time.Duration
+	liveGapWarning   time.Duration
+	liteStream       bool
+	lastHeartbeat    time.Time
+	maxEntryAge      time.Duration
+	maxEntryBytes    int
+	maxBatchSize     int64
+	logNearMisses    bool
+	dedupe           *dedupeCache
+	pollState        *pollStateStore
+	anomalies        *anomalyDetector
+	onAnomaly        func(IssuanceAnomaly)
+	cnNotInSANOnly   bool
+	weakCryptoOnly   bool
+	pause            pauseGate
+	startedAt        time.Time
+	quietBackfill    bool
+	stats            *monitorStats
+	canonIssuer      bool
+	issuerAllow      []string
+	issuerDeny       []string
+	expiryAlert      time.Duration
+	minValidity      time.Duration
+	maxValidity      time.Duration
+	maxLogs          int
+	pollWorkers      int
+	logRate          float64
+	keywords         []keywordRoute
+	keywordFilter    []string
+	patterns         []*regexp.Regexp
+	ipWatches        []ipWatch
+	typosquat        bool
+	registrableMatch bool
+	typoDistance     int
+	history          []HistoryProvider
+	logger           *slog.Logger
+	logListURL       string
+	logListCache     string
+	logListTTL       time.Duration
+	customLogs       []string
+	health           logHealth
+
+	source             string
+	certspotterURL     string
+	certspotterToken   string
+	certspotterCursors map[string]string
+}
+
+type CertificateHandler interface {
+	Handle(entry *models.CertificateEntry) error
+}
+
+func NewMonitor() *Monitor {
+	return NewMonitorWithCertstreamURL("wss://certstream.calidog.io")
+}
+
+func NewMonitorWithCertstreamURL(certstreamURL string) *Monitor {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	httpClient := &http.Client{
+		Timeout: defaultHTTPTimeout,
+	}
+
+	monitor := &Monitor{
+		watchedDomains: make(map[string]*models.DomainWatch),
+		handlers:       make([]CertificateHandler, 0),
+		notifiers:      make([]CertificateHandler, 0),
+		stopChan:       make(chan struct{}),
+		ctx:            ctx,
+		cancel:         cancel,
+		ctClients:      make([]*CTLogClient, 0),
+		pollInterval:   time.Minute * 1,
+		maxLogs:        defaultMaxLogs,
+		pollWorkers:    defaultPollConcurrency,
+		maxBatchSize:   defaultMaxBatchSize,
+		httpClient:     httpClient,
+		certstreamURL:  certstreamURL,
+		dialStream:     dialCertstream,
+		maxBackoff:     defaultMaxBackoff,
+		liveGapWarning: defaultLiveGapWarning,
+		startedAt:      time.Now(),
+		stats:          newMonitorStats(),
+		dedupe:         newDedupeCache(defaultDedupeWindow),
+		logger:         slog.Default(),
+		logListURL:     defaultLogListURL,
+		logListTTL:     defaultLogListTTL,
+
+		source:             SourceCTLogs,
+		certspotterURL:     defaultCertspotterAPI,
+		certspotterCursors: make(map[string]string),
+	}
+
+	return monitor
+}
+
+func (m *Monitor) initializeCTClients() error {
+	logList, err := m.loadLogList()
+	if err != nil {
+		if len(m.customLogs) == 0 {
+			return err
+		}
+		m.logger.Warn("Polling custom CT logs only", "error", err)
+	}
+
+	// Create clients for selected logs
+	for _, url := range m.selectLogURLs(logList) {
+		logClient, err := m.newLogClient(url, logList)
+		if err != nil {
+			m.logger.Error("Failed to create CT client", "url", url, "error", err)
+			continue
+		}
+
+		m.ctClients = append(m.ctClients, logClient)
+		m.logger.Debug("Initialized CT client", "log", logClient.name, "url", url)
+	}
+
+	if len(m.ctClients) == 0 {
+		return fmt.Errorf("no CT clients could be initialized")
+	}
+
+	m.health.reset(m.ctClients)
+	m.logger.Info("Initialized CT clients", "count", len(m.ctClients))
+	return nil
+}
+
+// selectLogURLs returns the active logs to poll from logList, followed by
+// the custom logs not already among them.
+func (m *Monitor) selectLogURLs(logList CTLogList) []string {
+	urls := m.selectActiveLogs(logList)
+	for _, custom := range m.customLogs {
+		selected := false
+		for _, url := range urls {
+			selected = selected || sameLogURL(url, custom)
+		}
+		if !selected {
+			urls = append(urls, custom)
+		}
+	}
+	return urls
+}
+
+// newLogClient creates the client for the log at url, resuming from its
+// saved index if there is one.
+func (m *Monitor) newLogClient(url string, logList CTLogList) (*CTLogClient, error) {
+	ctClient, err := client.New(url, m.httpClient, jsonclient.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	logClient := &CTLogClient{
+		client:    ctClient,
+		url:       url,
+		name:      m.getLogName(url, logList),
+		lastIndex: -1,
+		limiter:   m.newLogLimiter(),
+		backoff:   logRetryBackoff,
+	}
+	if m.pollState != nil {
+		if index, ok := m.pollState.lastIndex(url); ok {
+			logClient.lastIndex = index
+		}
+	}
+	return logClient, nil
+}
+
+func (m *Monitor) selectActiveLogs(logList CTLogList) []string {
+	now := time.Now()
+	activeURLs := make([]string, 0)
+
+	// Look for logs that are currently active (temporal interval includes current time)
+	for _, operator := range logList.Operators {
+		for _, logInfo := range operator.Logs {
+			if m.isLogActive(logInfo, now) {
+				activeURLs = append(activeURLs, logInfo.URL)
+
+				// Limit the number of logs to avoid overwhelming the system
+				if m.maxLogs > 0 && len(activeURLs) >= m.maxLogs {
+					return activeURLs
+				}
+			}
+		}
+	}
+
+	return activeURLs
+}
+
+// isLogActive reports whether a log is accepting certificates at now: it is
+// not retired or rejected, and its temporal interval, if it is sharded,
+// covers now.
+func (m *Monitor) isLogActive(logInfo CTLogInfo, now time.Time) bool {
+	for _, state := range []string{"retired", "rejected"} {
+		if _, ok := logInfo.State[state]; ok {
+			return false
+		}
+	}
+
+	if interval := logInfo.TemporalInterval; interval != nil {
+		return !now.Before(interval.StartInclusive) && now.Before(interval.EndExclusive)
+	}
+	return true
+}
+
+func (m *Monitor) getLogName(url string, logList CTLogList) string {
+	for _, operator := range logList.Operators {
+		for _, logInfo := range operator.Logs {
+			if logInfo.URL == url {
+				return logInfo.Description
+			}
+		}
+	}
+	return url
+}
+
+// AddDomain watches domain, and its subdomains if includeSubdomains is set.
+// The domain is stored in the form returned by NormalizeDomain, so
+// "Example.com." and "example.com" are the same watch; adding a watched
+// domain again updates includeSubdomains and keeps its CreatedAt and
+// LastSeen. A public suffix such as "co.uk" is refused and logged; callers
+// taking domains from users should check them with ValidateWatchDomain first.
+func (m *Monitor) AddDomain(domain string, includeSubdomains bool) {
+	domain = NormalizeDomain(domain)
+	if err := ValidateWatchDomain(domain); err != nil {
+		m.logger.Error("Refusing to watch domain", "domain", domain, "error", err)
+		return
+	}
+
+	m.watchMutex.Lock()
+	defer m.watchMutex.Unlock()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if existing, exists := m.watchedDomains[domain]; exists {
+		// Replace rather than modify the watch, as GetWatchedDomains hands
+		// out the pointers
+		updated := *existing
+		updated.IncludeSubdomains = includeSubdomains
+		updated.Active = true
+		m.watchedDomains[domain] = &updated
+		m.logger.Info("Updated watched domain", "domain", domain, "include_subdomains", includeSubdomains)
+		return
+	}
+
+	m.watchedDomains[domain] = &models.DomainWatch{
+		Domain:            domain,
+		IncludeSubdomains: includeSubdomains,
+		CreatedAt:         time.Now(),
+		LastSeen:          m.savedLastSeen(domain),
+		Active:            true,
+	}
+
+	m.logger.Info("Added domain to watch list", "domain", domain, "include_subdomains", includeSubdomains)
+}
+
+// RemoveDomain stops watching domain, given in any form NormalizeDomain
+// accepts.
+func (m *Monitor) RemoveDomain(domain string) {
+	domain = NormalizeDomain(domain)
+	m.watchMutex.Lock()
+	defer m.watchMutex.Unlock()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.watchedDomains[domain]; exists {
+		delete(m.watchedDomains, domain)
+		m.logger.Info("Removed domain from watch list", "domain", domain)
+	}
+}
+
+// ReloadDomains replaces the watch list with watches. The new map is built
+// without holding the lock used by ingestion and swapped in under a brief
+// write lock, so matching is not stalled by large reloads. Watches that are
+// unchanged keep their state; changed ones keep CreatedAt and LastSeen.
+func (m *Monitor) ReloadDomains(watches []models.DomainWatch) {
+	m.watchMutex.Lock()
+	defer m.watchMutex.Unlock()
+
+	// Other changes to the watch list hold watchMutex, so only LastSeen can
+	// change under this snapshot, as matches replace their watch
+	m.mutex.RLock()
+	current := make(map[string]*models.DomainWatch, len(m.watchedDomains))
+	for domain, config := range m.watchedDomains {
+		current[domain] = config
+	}
+	m.mutex.RUnlock()
+
+	next := make(map[string]*models.DomainWatch, len(watches))
+	now := time.Now()
+
+	for _, watch := range watches {
+		watch.Domain = NormalizeDomain(watch.Domain)
+		if err := ValidateWatchDomain(watch.Domain); err != nil {
+			m.logger.Error("Refusing to watch domain", "domain", watch.Domain, "error", err)
+			continue
+		}
+		existing, exists := current[watch.Domain]
+		if exists && existing.IncludeSubdomains == watch.IncludeSubdomains {
+			next[watch.Domain] = existing
+			continue
+		}
+
+		config := &models.DomainWatch{
+			Domain:            watch.Domain,
+			IncludeSubdomains: watch.IncludeSubdomains,
+			CreatedAt:         now,
+			LastSeen:          m.savedLastSeen(watch.Domain),
+			Active:            true,
+		}
+		if exists {
+			config.CreatedAt = existing.CreatedAt
+		}
+		next[watch.Domain] = config
+	}
+
+	m.mutex.Lock()
+	// LastSeen is updated under the ingestion lock, so carry it over here
+	for domain, config := range next {
+		latest, exists := m.watchedDomains[domain]
+		switch {
+		case !exists:
+		case config == current[domain]:
+			next[domain] = latest
+		default:
+			config.LastSeen = latest.LastSeen
+		}
+	}
+	m.watchedDomains = next
+	count := len(next)
+	m.mutex.Unlock()
+
+	m.logger.Info("Reloaded watch list", "domains", count, "previous", len(current))
+}
+
+func (m *Monitor) AddHandler(handler CertificateHandler) {
+	m.handlers = append(m.handlers, handler)
+}
+
+// AddNotificationHandler registers a handler that alerts people rather than
+// storing data. Notification handlers receive the same entries as storage
+// handlers but can be silenced for backfill with SetNotifyBackfill.
+func (m *Monitor) AddNotificationHandler(handler CertificateHandler) {
+	m.notifiers = append(m.notifiers, handler)
+}
+
+// SetNotifyBackfill controls whether notification handlers receive entries
+// logged before the monitor started, such as those replayed while polling
+// catches up. Storage handlers always receive them.
+func (m *Monitor) SetNotifyBackfill(enabled bool) {
+	m.quietBackfill = !enabled
+}
+
+// SetLogger sends the monitor's logs to logger, or slog.Default() if nil.
+// Matches are logged at info level, per-log polling details at debug level
+// and failures at error level.
+func (m *Monitor) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	m.logger = logger
+}
+
+func (m *Monitor) SetLiveMode(enabled bool) {
+	m.liveMode = enabled
+}
+
+func (m *Monitor) SetAllDomainsMode(enabled bool) {
+	m.allDomainsMode = enabled
+}
+
+func (m *Monitor) SetPollInterval(interval time.Duration) {
+	m.pollInterval = interval
+}
+
+// SetMaxLogs sets how many active CT logs polling mode watches. More logs
+// catch more certificates sooner but cost more requests, bandwidth and CPU
+// per poll. n <= 0 watches every active log.
+func (m *Monitor) SetMaxLogs(n int) {
+	m.maxLogs = n
+}
+
+// SetPollConcurrency caps how many CT logs are checked at the same time in
+// each polling cycle. Zero or less checks every log at once.
+func (m *Monitor) SetPollConcurrency(n int) {
+	m.pollWorkers = n
+}
+
+// SetMaxEntryAge makes polling skip dispatch of entries whose CT log
+// timestamp is older than age, so catching up on a log that is far behind
+// does not flood handlers with stale certificates. Zero disables the check.
+func (m *Monitor) SetMaxEntryAge(age time.Duration) {
+	m.maxEntryAge = age
+}
+
+// SetMaxEntryBytes skips polled CT entries whose raw leaf and chain data
+// exceed n bytes, guarding memory against pathologically large precerts.
+// Zero disables the limit.
+func (m *Monitor) SetMaxEntryBytes(n int) {
+	m.maxEntryBytes = n
+}
+
+// SetMaxBatchSize caps how many entries a poll requests from one CT log.
+// Polls request more entries the further a log is behind, up to n, and 50
+// once it is caught up. Zero or less uses the default of 1000.
+func (m *Monitor) SetMaxBatchSize(n int) {
+	if n <= 0 {
+		n = defaultMaxBatchSize
+	}
+	m.maxBatchSize = int64(n)
+}
+
+// SetAnomalyDetection enables issuance-rate anomaly alerts for watched
+// domains. Matches are counted per domain in windows of the given length, and
+// an alert fires when a window reaches minCount and exceeds multiplier times
+// the domain's recent baseline. alert may be nil, in which case anomalies are
+// only logged. A zero window disables detection.
+func (m *Monitor) SetAnomalyDetection(window time.Duration, multiplier float64, minCount int, alert func(IssuanceAnomaly)) {
+	if window <= 0 {
+		m.anomalies = nil
+		m.onAnomaly = nil
+		return
+	}
+	m.anomalies = newAnomalyDetector(window, multiplier, minCount)
+	m.onAnomaly = alert
+}
+
+// SetCNNotInSANOnly restricts dispatch to certificates whose subject common
+// name does not appear among their SANs.
+func (m *Monitor) SetCNNotInSANOnly(enabled bool) {
+	m.cnNotInSANOnly = enabled
+}
+
+// SetIssuerNormalize records a canonical CA name alongside the raw issuer in
+// each entry, so that issuer variants group together.
+func (m *Monitor) SetIssuerNormalize(enabled bool) {
+	m.canonIssuer = enabled
+}
+
+// Start runs the monitor until Stop is called. It blocks, so callers
+// usually run it in a goroutine.
+func (m *Monitor) Start() error {
+	return m.StartContext(context.Background())
+}
+
+// StartContext is Start, also stopping when ctx is cancelled: polling,
+// streaming and outstanding CT log requests end as they would on Stop,
+// though without waiting for an in-flight poll cycle. Like Stop, this is
+// final; a stopped monitor cannot be started again.
+func (m *Monitor) StartContext(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, m.cancel)
+	defer stop()
+
+	if m.liveMode {
+		return m.startLiveMode()
+	} else if m.source == SourceCertspotterAPI {
+		return m.startCertspotterMode()
+	} else {
+		return m.startPollingMode()
+	}
+}
+
+func (m *Monitor) startPollingMode() error {
+	// CT clients are only needed for polling, so they are set up lazily
+	if len(m.ctClients) == 0 {
+		if err := m.initializeCTClients(); err != nil {
+			return fmt.Errorf("no CT clients available: %w", err)
+		}
+	}
+
+	m.logger.Info("Starting certificate transparency monitor in POLLING mode", "logs", len(m.ctClients), "poll_interval", m.pollInterval)
+
+	// Initialize starting points for each CT log
+	for _, logClient := range m.ctClients {
+		go m.initializeLogStartingPoint(logClient)
+	}
+
+	// Wait a bit for initialization
+	select {
+	case <-time.After(5 * time.Second):
+	case <-m.ctx.Done():
+		m.logger.Info("Monitor stopped")
+		return nil
+	}
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	// Pick up logs that became active, or stopped being so, since startup
+	var refresh <-chan time.Time
+	if m.logListTTL > 0 {
+		refreshTicker := time.NewTicker(m.logListTTL)
+		defer refreshTicker.Stop()
+		refresh = refreshTicker.C
+	}
+
+	// Log the first poll time
+	nextPoll := time.Now().Add(m.pollInterval)
+	m.logger.Debug("Next polling scheduled", "at", nextPoll.Format("15:04:05"))
+
+	for {
+		select {
+		case <-m.stopChan:
+			m.logger.Info("Monitor stopped")
+			return nil
+		case <-m.ctx.Done():
+			m.logger.Info("Monitor stopped")
+			return nil
+		case <-ticker.C:
+			if !m.beginCycle() {
+				m.logger.Info("Monitor stopped")
+				return nil
+			}
+			m.logger.Debug("Starting polling cycle")
+			m.pollCycle()
+			m.endCycle()
+
+			// Log when the next poll will happen
+			nextPoll := time.Now().Add(m.pollInterval)
+			m.logger.Debug("Polling cycle completed", "next_poll", nextPoll.Format("15:04:05"))
+		case <-refresh:
+			m.refreshCTClients()
+		}
+	}
+}
+
+// pollCycle checks every CT log once, at most pollWorkers at a time, and
+// returns when all of them are done.
+func (m *Monitor) pollCycle() {
+	concurrency := m.pollWorkers
+	if concurrency <= 0 {
+		concurrency = len(m.ctClients)
+	}
+	slots := make(chan struct{}, max(concurrency, 1))
+
+	var wg sync.WaitGroup
+	for _, logClient := range m.ctClients {
+		wg.Add(1)
+		go func(lc *CTLogClient) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			if err := m.checkNewCertificates(lc); err != nil {
+				m.logger.Error("Error checking CT log", "log", lc.name, "error", err)
+			}
+		}(logClient)
+	}
+	wg.Wait()
+}
+
+func (m *Monitor) startLiveMode() error {
+	m.logger.Info("Starting certificate transparency monitor in LIVE STREAMING mode")
+
+	// Create the certstream
+	stream, errChan := m.dialStream(m.streamURL())
+	connectedAt := time.Now()
+	backoff := newReconnectBackoff(m.maxBackoff)
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			m.logger.Info("Live monitor stopped")
+			return nil
+		case jq := <-stream:
+			// Process the certificate event
+			m.liveMessageReceived(time.Now())
+			m.processLiveEvent(&jq)
+		case err := <-errChan:
+			if err != nil {
+				m.logger.Error("Error in live stream", "error", err)
+				m.liveStreamDropped(time.Now())
+
+				// A stream that stayed up for a while starts the backoff over
+				if time.Since(connectedAt) >= stableStreamPeriod {
+					backoff.reset()
+				}
+				delay := backoff.next()
+				m.logger.Info("Reconnecting to certstream", "url", m.streamURL(), "delay", delay.Round(time.Millisecond))
+				select {
+				case <-m.ctx.Done():
+					m.logger.Info("Live monitor stopped")
+					return nil
+				case <-time.After(delay):
+				}
+
+				stream, errChan = m.dialStream(m.streamURL())
+				connectedAt = time.Now()
+			}
+		}
+	}
+}
+
+// dialCertstream connects to a certstream server, keeping heartbeats so
+// LastHeartbeat stays current.
+func dialCertstream(url string) (chan jsonq.JsonQuery, chan error) {
+	return certstream.CertStreamEventStreamURL(false, url)
+}
+
+func (m *Monitor) initializeLogStartingPoint(logClient *CTLogClient) {
+	if logClient.lastIndex >= 0 {
+		m.logger.Debug("Resuming CT log from saved index", "log", logClient.name, "index", logClient.lastIndex)
+		return
+	}
+
+	var sth *ct.SignedTreeHead
+	err := m.logRequest(logClient, func() (err error) {
+		sth, err = logClient.client.GetSTH(m.ctx)
+		return err
+	})
+	if err != nil {
+		m.logger.Error("Failed to get initial STH", "log", logClient.name, "error", err)
+		logClient.lastIndex = 0
+		return
+	}
+
+	// Start 100 entries back to avoid missing recent certificates
+	logClient.lastIndex = int64(sth.TreeSize) - 100
+	if logClient.lastIndex < 0 {
+		logClient.lastIndex = 0
+	}
+
+	m.logger.Debug("Initialized CT log starting point", "log", logClient.name, "index", logClient.lastIndex)
+}
+
+// Stop shuts the monitor down, letting an in-flight poll cycle finish for up
+// to 30 seconds so its progress is kept.
+func (m *Monitor) Stop() {
+	m.StopWithTimeout(defaultStopTimeout)
+}
+
+// StopWithTimeout signals shutdown so no new poll cycle starts, then waits up
+// to timeout for the current cycle to finish before cancelling outstanding
+// requests. A cycle cut short by the timeout leaves its logs' lastIndex
+// where it was, so those entries are fetched again on the next start.
+func (m *Monitor) StopWithTimeout(timeout time.Duration) {
+	m.stopMutex.Lock()
+	if m.stopping {
+		m.stopMutex.Unlock()
+		return
+	}
+	m.logger.Info("Stopping certificate transparency monitor")
+	m.stopping = true
+	close(m.stopChan)
+	m.stopMutex.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		m.cycles.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(timeout):
+		m.logger.Warn("Poll cycle still running, aborting it", "timeout", timeout)
+	}
+	m.cancel()
+	m.flushPollState()
+}
+
+// beginCycle registers a poll cycle with Stop's drain, or reports false once
+// shutdown has been signalled.
+func (m *Monitor) beginCycle() bool {
+	m.stopMutex.Lock()
+	defer m.stopMutex.Unlock()
+
+	if m.stopping {
+		return false
+	}
+	m.cycles.Add(1)
+	return true
+}
+
+func (m *Monitor) endCycle() {
+	m.cycles.Done()
+}
+
+func (m *Monitor) checkNewCertificates(logClient *CTLogClient) (err error) {
+	defer func() { m.recordPollResult(logClient, err) }()
+
+	// Get current tree head
+	var sth *ct.SignedTreeHead
+	err = m.logRequest(logClient, func() (err error) {
+		sth, err = logClient.client.GetSTH(m.ctx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get STH: %w", err)
+	}
+
+	currentSize := int64(sth.TreeSize)
+	if currentSize <= logClient.lastIndex {
+		return nil // No new certificates
+	}
+
+	endIndex := logClient.lastIndex + m.batchSize(logClient, currentSize-logClient.lastIndex)
+	if endIndex > currentSize {
+		endIndex = currentSize
+	}
+
+	// Get raw entries in batch; each is decoded only when it is processed, so
+	// a batch of large precerts is never held fully parsed in memory
+	resp, endIndex, err := m.getEntries(logClient, endIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get entries: %w", err)
+	}
+
+	m.logger.Debug("Checking certificates", "log", logClient.name, "start", logClient.lastIndex, "end", endIndex-1,
+		"entries", len(resp.Entries))
+
+	for i := range resp.Entries {
+		index := logClient.lastIndex + int64(i)
+		if err := m.processLeafEntry(&resp.Entries[i], index, logClient); err != nil {
+			m.logger.Error("Error processing entry", "log", logClient.name, "index", index, "error", err)
+		}
+		resp.Entries[i] = ct.LeafEntry{} // release the raw bytes
+	}
+
+	// Logs may return fewer entries than requested; resume after the last one
+	logClient.lastIndex += int64(len(resp.Entries))
+	m.savePollState(logClient)
+	return nil
+}
+
+// batchSize returns how many of the backlog entries a log has not yet
+// delivered to request in one poll. A log that is far behind gets a quarter
+// of its backlog, so it catches up in a few polls, bounded by maxBatchSize;
+// a caught-up log, or one whose last poll failed, gets minBatchSize to
+// avoid overwhelming the API.
+func (m *Monitor) batchSize(logClient *CTLogClient, backlog int64) int64 {
+	size := backlog / 4
+	if logClient.failures > 0 || size < minBatchSize {
+		size = minBatchSize
+	}
+	if size > m.maxBatchSize {
+		size = m.maxBatchSize
+	}
+	if size > backlog {
+		size = backlog
+	}
+	return size
+}
+
+// processLeafEntry decodes one raw get-entries result and processes it.
+// Entries over maxEntryBytes are skipped before any parsing.
+func (m *Monitor) processLeafEntry(leaf *ct.LeafEntry, index int64, logClient *CTLogClient) error {
+	if size := len(leaf.LeafInput) + len(leaf.ExtraData); m.maxEntryBytes > 0 && size > m.maxEntryBytes {
+		m.logger.Debug("Skipping oversized entry", "log", logClient.name, "index", index, "bytes", size, "limit", m.maxEntryBytes)
+		return nil
+	}
+
+	raw, err := ct.RawLogEntryFromLeaf(index, leaf)
+	if err != nil {
+		return fmt.Errorf("failed to decode entry: %w", err)
+	}
+	entry := &ct.LogEntry{Index: raw.Index, Leaf: raw.Leaf, Chain: raw.Chain}
+	if raw.Leaf.TimestampedEntry.EntryType == ct.PrecertLogEntryType {
+		entry.Precert = &ct.Precertificate{Submitted: raw.Cert}
+	}
+	return m.processCTEntry(entry, index, logClient)
+}
+
+func (m *Monitor) processCTEntry(entry *ct.LogEntry, index int64, logClient *CTLogClient) error {
+	var cert *x509.Certificate
+	var err error
+
+	// Skip stale entries while catching up; lastIndex still advances past them
+	if m.maxEntryAge > 0 {
+		logTime := time.UnixMilli(int64(entry.Leaf.TimestampedEntry.Timestamp))
+		if time.Since(logTime) > m.maxEntryAge {
+			return nil
+		}
+	}
+
+	// Parse the certificate
+	switch entry.Leaf.TimestampedEntry.EntryType {
+	case ct.X509LogEntryType:
+		cert, err = x509.ParseCertificate(entry.Leaf.TimestampedEntry.X509Entry.Data)
+	case ct.PrecertLogEntryType:
+		cert, err = parseTBSCertificate(entry.Leaf.TimestampedEntry.PrecertEntry.TBSCertificate)
+	default:
+		return fmt.Errorf("unknown entry type: %v", entry.Leaf.TimestampedEntry.EntryType)
+	}
+
+	if err != nil {
+		// Skip malformed certificates - this is common in CT logs
+		// Don't log every occurrence to avoid spam
+		return nil
+	}
+
+	m.stats.recordSeen()
+
+	// Extract all domains from certificate
+	allDomains := certificateNames(cert)
+	ips := certificateIPs(cert)
+
+	// Check if any domain or IP matches our watch list (or if we're in all-domains mode)
+	matchedDomain, reason, ok := m.matchCertificateIPs(allDomains, ips)
+	if !ok {
+		m.reportNearMiss(allDomains)
+		return nil // No match
+	}
+
+	// Lookalikes are reported against the domain they imitate but are not
+	// issuance for it
+	if lookalikeKind(reason) == "" {
+		m.updateLastSeen(matchedDomain)
+	}
+
+	// Create certificate entry
+	certEntry := m.createCertificateEntry(cert, allDomains, matchedDomain, index, logClient)
+	if fingerprint := precertFingerprint(entry); fingerprint != "" {
+		certEntry.LeafCert.Fingerprint = fingerprint
+		certEntry.IdempotencyKey = certEntry.ComputeIdempotencyKey()
+	}
+	certEntry.EntryType = entryType(entry.Leaf.TimestampedEntry.EntryType)
+	certEntry.Chain = chainCerts(entry.Chain)
+	certEntry.Lookalike = lookalikeKind(reason)
+	certEntry.MatchedNames = m.matchedNames(matchNames(allDomains, ips, reason), matchedDomain, reason)
+	certEntry.Wildcard = hasWildcardName(certEntry.MatchedNames)
+
+	m.logger.Info("Found matching certificate", "domain", matchedDomain, "log", logClient.name, "index", index)
+
+	logTime := time.UnixMilli(int64(entry.Leaf.TimestampedEntry.Timestamp))
+	m.dispatch(certEntry, logTime.Before(m.startedAt))
+
+	return nil
+}
+
+// MatchCertificate runs the current matching configuration against the names
+// found in a certificate, without dispatching anything. It returns the
+// watched domain that matched (or the first name in all-domains mode) and the
+// kind of match: "exact", "subdomain", "wildcard", "registrable", "regex",
+// "homograph", "typosquat", "keyword" or "all-domains". For regex matches the
+// matched domain is the pattern; for keyword matches it is the certificate
+// name containing the keyword.
+func (m *Monitor) MatchCertificate(domains []string) (matched string, reason string, ok bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.allDomainsMode {
+		// In all-domains mode, process every certificate (or those passing
+		// the keyword filter). Use the first domain from the certificate, or
+		// the first containing a filter keyword, as the "matched" domain
+		if len(domains) == 0 {
+			return "", "", false
+		}
+		name, ok := m.filterKeyword(domains)
+		if !ok {
+			return "", "", false
+		}
+		return name, "all-domains", true
+	}
+
+	// Normal mode: check against watched domains
+	for _, domain := range domains {
+		for watchedDomain, config := range m.watchedDomains {
+			if reason, ok := m.matchDomain(domain, watchedDomain, config.IncludeSubdomains); ok {
+				return watchedDomain, reason, true
+			}
+		}
+	}
+
+	if pattern, ok := m.matchRegex(domains); ok {
+		return pattern, "regex", true
+	}
+
+	if watched, reason, ok := m.matchLookalike(domains); ok {
+		return watched, reason, true
+	}
+
+	if name, route := m.matchKeyword(domains); route != nil {
+		return name, "keyword", true
+	}
+
+	return "", "", false
+}
+
+// hasWildcardName reports whether any of names is a wildcard.
+func hasWildcardName(names []string) bool {
+	for _, name := range names {
+		if strings.HasPrefix(name, "*.") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchedNames returns the certificate names that produced a match
+// MatchCertificate reported as matched and reason.
+func (m *Monitor) matchedNames(domains []string, matched, reason string) []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var names []string
+	for _, domain := range domains {
+		var hit bool
+		switch reason {
+		case "all-domains":
+			_, hit = m.filterKeyword([]string{domain})
+		case "regex":
+			pattern, ok := m.matchRegex([]string{domain})
+			hit = ok && pattern == matched
+		case "homograph", "typosquat":
+			watched, _, ok := m.matchLookalike([]string{domain})
+			hit = ok && watched == matched
+		case "keyword":
+			_, route := m.matchKeyword([]string{domain})
+			hit = route != nil
+		case "ip":
+			watched, ok := m.matchIP([]string{domain})
+			hit = ok && watched == matched
+		default:
+			if config, ok := m.watchedDomains[matched]; ok {
+				_, hit = m.matchDomain(domain, matched, config.IncludeSubdomains)
+			}
+		}
+		if hit {
+			names = append(names, domain)
+		}
+	}
+	return names
+}
+
+// matchNames returns the names matchedNames should pick from for a match of
+// reason: the IP address SANs for IP matches, the certificate names otherwise.
+func matchNames(names, ips []string, reason string) []string {
+	if reason == "ip" {
+		return ips
+	}
+	return names
+}
+
+// recordIssuance feeds a match for a watched domain, seen at seen, to the
+// anomaly detector.
+func (m *Monitor) recordIssuance(domain string, seen time.Time) {
+	if m.anomalies == nil || m.allDomainsMode {
+		return
+	}
+	if seen.IsZero() {
+		seen = time.Now()
+	}
+
+	anomaly, fired := m.anomalies.record(domain, seen)
+	if !fired {
+		return
+	}
+
+	m.logger.Warn("Issuance anomaly", "domain", anomaly.Domain, "count", anomaly.Count,
+		"since", anomaly.WindowStart.Format("15:04:05"), "baseline", anomaly.Baseline, "window", anomaly.Window)
+	if m.onAnomaly != nil {
+		m.onAnomaly(anomaly)
+	}
+}
+
+// updateLastSeen stamps the watch for domain, if any, with the current time
+// and records it in the state file.
+func (m *Monitor) updateLastSeen(domain string) {
+	m.mutex.Lock()
+	// Only watched domains are tracked, not all-domains mode matches
+	config, exists := m.watchedDomains[domain]
+	if m.allDomainsMode || !exists {
+		m.mutex.Unlock()
+		return
+	}
+	// Replace rather than modify the watch, as GetWatchedDomains hands out
+	// the pointers
+	now := time.Now()
+	updated := *config
+	updated.LastSeen = now
+	m.watchedDomains[domain] = &updated
+	m.mutex.Unlock()
+
+	m.saveLastSeen(domain, now)
+}
+
+// certificateNames returns the subject common name followed by the DNS SANs,
+// each once.
+func certificateNames(cert *x509.Certificate) []string {
+	names := []string{}
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	return uniqueNames(append(names, cert.DNSNames...))
+}
+
+// uniqueNames drops repeated names, compared case-insensitively, keeping the
+// first occurrence. The common name is usually repeated among the SANs.
+func uniqueNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	unique := make([]string, 0, len(names))
+	for _, name := range names {
+		key := strings.ToLower(name)
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+// certFingerprint returns the SHA-256 fingerprint of cert as colon-separated
+// uppercase hex bytes ("AB:CD:...").
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return formatFingerprint(sum[:])
+}
+
+// liveFingerprint computes the SHA-256 fingerprint from the DER certstream
+// includes in full mode. Without it the stream's own SHA-1 fingerprint is
+// the best available.
+func liveFingerprint(certData map[string]interface{}) string {
+	if asDER, ok := certData["as_der"].(string); ok {
+		if der, err := base64.StdEncoding.DecodeString(asDER); err == nil {
+			sum := sha256.Sum256(der)
+			return formatFingerprint(sum[:])
+		}
+	}
+	return getString(certData, "fingerprint")
+}
+
+// fingerprintFromHex reformats a plain hex digest, as returned by APIs such
+// as certspotter and Censys, in the colon-separated form. Anything that
+// isn't hex is returned unchanged.
+func fingerprintFromHex(digest string) string {
+	sum, err := hex.DecodeString(digest)
+	if err != nil || len(sum) == 0 {
+		return digest
+	}
+	return formatFingerprint(sum)
+}
+
+func formatFingerprint(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+func (m *Monitor) domainMatches(certDomain, watchedDomain string, includeSubdomains bool) bool {
+	_, ok := m.matchDomain(certDomain, watchedDomain, includeSubdomains)
+	return ok
+}
+
+func (m *Monitor) matchDomain(certDomain, watchedDomain string, includeSubdomains bool) (string, bool) {
+	certDomain = aLabelDomain(certDomain)
+	watchedDomain = aLabelDomain(watchedDomain)
+
+	// Exact match
+	if certDomain == watchedDomain {
+		return "exact", true
+	}
+
+	// A wildcard watch follows TLS semantics: "*" stands for exactly one
+	// label, whatever includeSubdomains says
+	if strings.HasPrefix(watchedDomain, "*.") {
+		label, found := strings.CutSuffix(certDomain, watchedDomain[1:])
+		if found && label != "" && !strings.Contains(label, ".") {
+			return "wildcard", true
+		}
+		return "", false
+	}
+
+	// Subdomain match if enabled
+	if includeSubdomains && strings.HasSuffix(certDomain, "."+watchedDomain) {
+		return "subdomain", true
+	}
+
+	// Wildcard match
+	if strings.HasPrefix(certDomain, "*.") {
+		baseDomain := certDomain[2:]
+		if baseDomain == watchedDomain {
+			return "wildcard", true
+		}
+		if includeSubdomains && strings.HasSuffix(baseDomain, "."+watchedDomain) {
+			return "wildcard", true
+		}
+	}
+
+	if m.registrableMatch && sameRegistrableDomain(certDomain, watchedDomain) {
+		return "registrable", true
+	}
+
+	return "", false
+}
+
+// aLabelDomain lowercases a domain and converts any Unicode labels to their
+// punycode A-label form, so "bücher.example" and "xn--bcher-kva.example"
+// compare equal. Names that fail to convert are compared as they are.
+func aLabelDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	for i := 0; i < len(domain); i++ {
+		if domain[i] >= utf8.RuneSelf {
+			if ascii, err := idna.ToASCII(domain); err == nil {
+				return ascii
+			}
+			break
+		}
+	}
+	return domain
+}
+
+// NormalizeDomain returns the form watched domains are stored in: trimmed,
+// lowercased, without a trailing dot and with Unicode labels converted to
+// punycode, so "Bücher.Example." becomes "xn--bcher-kva.example".
+func NormalizeDomain(domain string) string {
+	return strings.TrimSuffix(aLabelDomain(domain), ".")
+}
+
+func (m *Monitor) createCertificateEntry(cert *x509.Certificate, allDomains []string, matchedDomain string, index int64, logClient *CTLogClient) *models.CertificateEntry {
+	subject := certSubject(cert)
+
+	extensions := certExtensions(cert)
+
+	leaf := models.LeafCertificate{
+		Subject:                 subject,
+		Extensions:              extensions,
+		NotBefore:               cert.NotBefore,
+		NotAfter:                cert.NotAfter,
+		IssuerDistinguishedName: cert.Issuer.CommonName,
+		IssuerOrganization:      strings.Join(cert.Issuer.Organization, ", "),
+		Fingerprint:             certFingerprint(cert),
+		SerialNumber:            cert.SerialNumber.String(),
+	}
+	setKeyInfo(&leaf, cert)
+	if m.canonIssuer {
+		leaf.IssuerCanonical = CanonicalIssuer(cert.Issuer.CommonName, strings.Join(cert.Issuer.Organization, ", "))
+	}
+
+	// Collect all certificate domains as subdomains (since matchedDomain is the watched domain)
+	subdomains := uniqueNames(allDomains)
+
+	entry := &models.CertificateEntry{
+		Domain:         matchedDomain,
+		Subdomains:     subdomains,
+		IPAddresses:    certificateIPs(cert),
+		EmailAddresses: cert.EmailAddresses,
+		LeafCert:       leaf,
+		Chain:          []models.ChainCert{}, // Filled in by the caller when known
+		Timestamp:      time.Now(),
+		LogURL:         "certstream",
+		Index:          0, // Live stream doesn't provide index
+		CNNotInSAN:     cnNotInSAN(subject.CommonName, extensions.SubjectAltName),
+	}
+	entry.IdempotencyKey = entry.ComputeIdempotencyKey()
+	return entry
+}
+
+// dispatch hands a matched entry to every handler, unless it is a duplicate
+// or a filter drops it, holding it back while the monitor is paused.
+// backfill marks entries logged before the monitor started.
+func (m *Monitor) dispatch(entry *models.CertificateEntry, backfill bool) {
+	if m.isDuplicate(entry) {
+		return
+	}
+	// Counted once per certificate, after deduplication, and not for
+	// backfill, which would crowd days of issuance into one window.
+	// Lookalikes are not issuance for the domain they imitate.
+	if entry.Lookalike == "" && !backfill {
+		m.recordIssuance(entry.Domain, entry.Timestamp)
+	}
+
+	issuer := entry.LeafCert.IssuerCanonical
+	if issuer == "" {
+		issuer = entry.LeafCert.IssuerDistinguishedName
+	}
+	// In all-domains mode the domain is each certificate's own name, so per
+	// domain counts would grow without bound on the firehose
+	domain := entry.Domain
+	if m.allDomainsMode {
+		domain = ""
+	}
+	m.stats.recordMatch(domain, issuer)
+
+	if !m.sampled(entry) {
+		return
+	}
+	if m.cnNotInSANOnly && !entry.CNNotInSAN {
+		return
+	}
+	if m.weakCryptoOnly && !hasWeakCrypto(entry.LeafCert) {
+		return
+	}
+	if !m.issuerAllowed(entry) {
+		return
+	}
+	if !m.validityAllowed(entry) {
+		return
+	}
+	m.checkExpiry(entry, time.Now())
+	m.deliverOrHold(entry, backfill)
+}
+
+// deliver hands entry to every handler and to the notifiers that want it.
+func (m *Monitor) deliver(entry *models.CertificateEntry, backfill bool) {
+	notifiers := m.notifiersFor(entry)
+
+	m.stats.recordDispatch()
+	for _, handler := range m.handlers {
+		if err := handler.Handle(entry); err != nil {
+			m.stats.recordHandlerError()
+			m.logger.Error("Handler error", "error", err)
+		}
+	}
+
+	if backfill && m.quietBackfill {
+		return
+	}
+	for _, notifier := range notifiers {
+		if err := notifier.Handle(entry); err != nil {
+			m.stats.recordHandlerError()
+			m.logger.Error("Notification handler error", "error", err)
+		}
+	}
+}
+
+// cnNotInSAN reports whether a non-empty common name is missing from the
+// certificate's SAN list.
+func cnNotInSAN(commonName string, sans []string) bool {
+	if commonName == "" {
+		return false
+	}
+	for _, san := range sans {
+		if strings.EqualFold(san, commonName) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Monitor) GetWatchedDomains() map[string]*models.DomainWatch {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	result := make(map[string]*models.DomainWatch)
+	for k, v := range m.watchedDomains {
+		result[k] = v
+	}
+	return result
+}
+
+// LastHeartbeat returns when the live stream last sent a heartbeat, or the
+// zero time if none has been received.
+func (m *Monitor) LastHeartbeat() time.Time {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.lastHeartbeat
+}
+
+func (m *Monitor) processLiveEvent(jq *jsonq.JsonQuery) {
+	messageType, err := jq.String("message_type")
+	if err != nil {
+		m.dropLiveMessage("missing_message_type", "error", err)
+		return
+	}
+
+	if messageType == "heartbeat" {
+		m.mutex.Lock()
+		m.lastHeartbeat = time.Now()
+		m.mutex.Unlock()
+		return
+	}
+
+	if messageType == "dns_entries" {
+		m.processLiveDomains(jq)
+		return
+	}
+
+	if messageType != "certificate_update" {
+		m.dropLiveMessage("unknown_message_type", "message_type", messageType)
+		return
+	}
+
+	// Extract certificate data
+	certData, err := jq.Object("data", "leaf_cert")
+	if err != nil {
+		m.dropLiveMessage("missing_leaf_cert", "error", err)
+		return
+	}
+
+	// Get all domain names from the certificate
+	var allDomains []string
+
+	// Get subject common name
+	if subjectCN, err := jq.String("data", "leaf_cert", "subject", "CN"); err == nil && subjectCN != "" {
+		allDomains = append(allDomains, subjectCN)
+	}
+
+	// Get SAN domains
+	if sanArray, err := jq.Array("data", "leaf_cert", "extensions", "subjectAltName"); err == nil {
+		for _, san := range sanArray {
+			if sanStr, ok := san.(string); ok && sanStr != "" {
+				allDomains = append(allDomains, sanStr)
+			}
+		}
+	}
+
+	allDomains = uniqueNames(allDomains)
+	if len(allDomains) == 0 {
+		m.dropLiveMessage("no_names")
+		return
+	}
+
+	entry := m.matchLiveEntry(certData, allDomains)
+	if entry == nil {
+		return
+	}
+	if updateType, err := jq.String("data", "update_type"); err == nil {
+		entry.EntryType = liveEntryType(updateType)
+	}
+	if chain, err := jq.Array("data", "chain"); err == nil {
+		entry.Chain = liveChainCerts(chain)
+	}
+
+	m.dispatch(entry, false)
+}
+
+// matchLiveEntry matches the names of a live message and returns the entry
+// to dispatch, built from what certData holds, or nil if nothing matched.
+func (m *Monitor) matchLiveEntry(certData map[string]interface{}, allDomains []string) *models.CertificateEntry {
+	m.stats.recordSeen()
+
+	// Check if any domain matches our watch list (or if we're in all-domains mode)
+	matchedDomain, reason, ok := m.MatchCertificate(allDomains)
+	if !ok {
+		m.reportNearMiss(allDomains)
+		return nil // No match
+	}
+
+	// Lookalikes are reported against the domain they imitate but are not
+	// issuance for it
+	if lookalikeKind(reason) == "" {
+		m.updateLastSeen(matchedDomain)
+	}
+
+	// Create certificate entry from live data
+	entry := m.createLiveCertificateEntry(certData, allDomains, matchedDomain)
+	if entry == nil {
+		return nil
+	}
+	entry.Lookalike = lookalikeKind(reason)
+	entry.MatchedNames = m.matchedNames(allDomains, matchedDomain, reason)
+	entry.Wildcard = hasWildcardName(entry.MatchedNames)
+	return entry
+}
+
+// dropLiveMessage counts a certstream message that can't be used and logs
+// why at debug level, so a change in the certstream format shows up as
+// dropped messages rather than a silent lack of matches.
+func (m *Monitor) dropLiveMessage(reason string, attrs ...any) {
+	m.stats.recordDropped(reason)
+	m.logger.Debug("Dropped certstream message", append([]any{"reason", reason}, attrs...)...)
+}
+
+func (m *Monitor) createLiveCertificateEntry(certData map[string]interface{}, allDomains []string, matchedDomain string) *models.CertificateEntry {
+	// Extract certificate information from live stream data
+	subject := models.Subject{}
+	extensions := models.Extensions{}
+
+	// Parse subject information
+	if subjectMap, ok := certData["subject"].(map[string]interface{}); ok {
+		subject = liveSubject(subjectMap)
+	}
+
+	// Parse SAN extensions
+	if extMap, ok := certData["extensions"].(map[string]interface{}); ok {
+		if sanArray, ok := extMap["subjectAltName"].([]interface{}); ok {
+			var sanDomains []string
+			for _, san := range sanArray {
+				if sanStr, ok := san.(string); ok {
+					sanDomains = append(sanDomains, sanStr)
+				}
+			}
+			extensions.SubjectAltName = sanDomains
+		}
+		if aki, ok := extMap["authorityKeyIdentifier"].(string); ok {
+			extensions.AuthorityKeyIdentifier = aki
+		}
+	}
+
+	// Parse dates
+	notBefore := liveTime(certData["not_before"])
+	notAfter := liveTime(certData["not_after"])
+
+	leaf := models.LeafCertificate{
+		Subject:                 subject,
+		Extensions:              extensions,
+		NotBefore:               notBefore,
+		NotAfter:                notAfter,
+		IssuerDistinguishedName: getString(certData, "issuer", "CN"),
+		IssuerOrganization:      getString(certData, "issuer", "O"),
+		Fingerprint:             liveFingerprint(certData),
+		SerialNumber:            getString(certData, "serial_number"),
+	}
+	setLiveKeyInfo(&leaf, certData)
+	if m.canonIssuer {
+		leaf.IssuerCanonical = CanonicalIssuer(getString(certData, "issuer", "CN"), getString(certData, "issuer", "O"))
+	}
+
+	// Collect all certificate domains as subdomains (since matchedDomain is the watched domain)
+	subdomains := uniqueNames(allDomains)
+
+	entry := &models.CertificateEntry{
+		Domain:     matchedDomain,
+		Subdomains: subdomains,
+		LeafCert:   leaf,
+		Chain:      []models.ChainCert{}, // Filled in by the caller when known
+		Timestamp:  time.Now(),
+		LogURL:     "certstream",
+		Index:      0, // Live stream doesn't provide index
+		CNNotInSAN: cnNotInSAN(subject.CommonName, extensions.SubjectAltName),
+	}
+	entry.IdempotencyKey = entry.ComputeIdempotencyKey()
+	return entry
+}
+
+func getString(data map[string]interface{}, keys ...string) string {
+	current := data
+	for i, key := range keys {
+		if i == len(keys)-1 {
+			if val, ok := current[key].(string); ok {
+				return val
+			}
+			return ""
+		}
+		if next, ok := current[key].(map[string]interface{}); ok {
+			current = next
+		} else {
+			return ""
+		}
+	}
+	return ""
+}
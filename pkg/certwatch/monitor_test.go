@@ -0,0 +1,760 @@
+package certwatch
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"domain_watcher/pkg/models"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/jmoiron/jsonq"
+)
+
+func TestNewMonitor(t *testing.T) {
+	monitor := NewMonitor()
+
+	if monitor == nil {
+		t.Fatal("NewMonitor() returned nil")
+	}
+
+	if monitor.watchedDomains == nil {
+		t.Error("watchedDomains map not initialized")
+	}
+
+	if monitor.handlers == nil {
+		t.Error("handlers slice not initialized")
+	}
+
+	if monitor.ctx == nil {
+		t.Error("context not initialized")
+	}
+}
+
+func TestAddDomain(t *testing.T) {
+	monitor := NewMonitor()
+
+	domain := "example.com"
+	includeSubdomains := true
+
+	monitor.AddDomain(domain, includeSubdomains)
+
+	domains := monitor.GetWatchedDomains()
+	if len(domains) != 1 {
+		t.Errorf("Expected 1 domain, got %d", len(domains))
+	}
+
+	watchConfig, exists := domains[domain]
+	if !exists {
+		t.Errorf("Domain %s not found in watched domains", domain)
+	}
+
+	if watchConfig.Domain != domain {
+		t.Errorf("Expected domain %s, got %s", domain, watchConfig.Domain)
+	}
+
+	if watchConfig.IncludeSubdomains != includeSubdomains {
+		t.Errorf("Expected includeSubdomains %v, got %v", includeSubdomains, watchConfig.IncludeSubdomains)
+	}
+
+	if !watchConfig.Active {
+		t.Error("Expected domain to be active")
+	}
+}
+
+func TestAddDomainNormalizes(t *testing.T) {
+	monitor := NewMonitor()
+
+	monitor.AddDomain("example.com", true)
+	created := monitor.GetWatchedDomains()["example.com"].CreatedAt
+
+	// Mixed case, spaces and a trailing dot all name the same watch, and
+	// adding it again updates it in place
+	monitor.AddDomain(" Example.COM. ", false)
+	monitor.AddDomain("Bücher.Example.", true)
+
+	domains := monitor.GetWatchedDomains()
+	if len(domains) != 2 {
+		t.Fatalf("Expected 2 domains, got %v", domains)
+	}
+	watch := domains["example.com"]
+	if watch == nil || watch.IncludeSubdomains {
+		t.Errorf("Expected example.com to be updated without subdomains, got %+v", watch)
+	} else if !watch.CreatedAt.Equal(created) {
+		t.Errorf("Expected CreatedAt %v to be kept, got %v", created, watch.CreatedAt)
+	}
+	if _, exists := domains["xn--bcher-kva.example"]; !exists {
+		t.Errorf("Expected the Unicode domain to be stored as punycode, got %v", domains)
+	}
+
+	monitor.RemoveDomain("EXAMPLE.com.")
+	if _, exists := monitor.GetWatchedDomains()["example.com"]; exists {
+		t.Error("Expected example.com to be removed")
+	}
+}
+
+func TestRemoveDomain(t *testing.T) {
+	monitor := NewMonitor()
+
+	domain := "example.com"
+	monitor.AddDomain(domain, true)
+
+	// Verify domain was added
+	domains := monitor.GetWatchedDomains()
+	if len(domains) != 1 {
+		t.Errorf("Expected 1 domain after adding, got %d", len(domains))
+	}
+
+	// Remove domain
+	monitor.RemoveDomain(domain)
+
+	// Verify domain was removed
+	domains = monitor.GetWatchedDomains()
+	if len(domains) != 0 {
+		t.Errorf("Expected 0 domains after removing, got %d", len(domains))
+	}
+}
+
+func TestDomainMatches(t *testing.T) {
+	monitor := NewMonitor()
+
+	tests := []struct {
+		certDomain        string
+		watchedDomain     string
+		includeSubdomains bool
+		expected          bool
+		description       string
+	}{
+		{"example.com", "example.com", false, true, "exact match"},
+		{"sub.example.com", "example.com", true, true, "subdomain match with subdomains enabled"},
+		{"sub.example.com", "example.com", false, false, "subdomain match with subdomains disabled"},
+		{"*.example.com", "example.com", false, true, "wildcard match"},
+		{"*.sub.example.com", "example.com", true, true, "wildcard subdomain match"},
+		{"other.com", "example.com", true, false, "no match"},
+		{"example.org", "example.com", true, false, "different TLD"},
+		{"api.corp.example.com", "*.corp.example.com", false, true, "wildcard watch matches one label"},
+		{"API.corp.example.com", "*.corp.example.com", true, true, "wildcard watch is case-insensitive"},
+		{"a.b.corp.example.com", "*.corp.example.com", true, false, "wildcard watch does not match two labels"},
+		{"corp.example.com", "*.corp.example.com", true, false, "wildcard watch does not match its base"},
+		{"*.corp.example.com", "*.corp.example.com", false, true, "wildcard watch matches the same wildcard"},
+		{"*.b.corp.example.com", "*.corp.example.com", true, false, "wildcard watch does not match a deeper wildcard"},
+		{"apicorp.example.com", "*.corp.example.com", false, false, "wildcard watch needs a label boundary"},
+		{"xn--bcher-kva.example", "bücher.example", false, true, "punycode cert matches unicode watch"},
+		{"bücher.example", "xn--bcher-kva.example", false, true, "unicode cert matches punycode watch"},
+		{"shop.BÜCHER.example", "xn--bcher-kva.example", true, true, "unicode subdomain matches punycode watch"},
+		{"*.xn--bcher-kva.example", "bücher.example", false, true, "punycode wildcard matches unicode watch"},
+		{"bucher.example", "bücher.example", false, false, "ASCII lookalike does not match"},
+	}
+
+	for _, test := range tests {
+		result := monitor.domainMatches(test.certDomain, test.watchedDomain, test.includeSubdomains)
+		if result != test.expected {
+			t.Errorf("%s: domainMatches(%q, %q, %v) = %v, expected %v",
+				test.description, test.certDomain, test.watchedDomain, test.includeSubdomains, result, test.expected)
+		}
+	}
+}
+
+// Mock handler for testing
+type mockHandler struct {
+	entries []*models.CertificateEntry
+}
+
+func (h *mockHandler) Handle(entry *models.CertificateEntry) error {
+	h.entries = append(h.entries, entry)
+	return nil
+}
+
+func TestAddHandler(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+
+	monitor.AddHandler(handler)
+
+	if len(monitor.handlers) != 1 {
+		t.Errorf("Expected 1 handler, got %d", len(monitor.handlers))
+	}
+}
+
+func TestMonitorStop(t *testing.T) {
+	monitor := NewMonitor()
+
+	// Test that Stop() doesn't panic and properly cancels context
+	monitor.Stop()
+
+	// Check if context is cancelled
+	select {
+	case <-monitor.ctx.Done():
+		// Context is properly cancelled
+	case <-time.After(100 * time.Millisecond):
+		t.Error("Context was not cancelled after Stop()")
+	}
+}
+
+func TestStopDrainsPollCycle(t *testing.T) {
+	monitor := NewMonitor()
+	if !monitor.beginCycle() {
+		t.Fatal("Expected a cycle to start before Stop()")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		monitor.StopWithTimeout(5 * time.Second)
+		close(stopped)
+	}()
+
+	<-monitor.stopChan
+	if monitor.beginCycle() {
+		t.Error("Expected no new cycle to start once Stop() has been called")
+	}
+	select {
+	case <-stopped:
+		t.Fatal("Stop() returned while a poll cycle was in flight")
+	case <-monitor.ctx.Done():
+		t.Fatal("Context was cancelled while a poll cycle was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	monitor.endCycle()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop() did not return after the poll cycle finished")
+	}
+	if monitor.ctx.Err() == nil {
+		t.Error("Expected context to be cancelled after Stop()")
+	}
+
+	// Stopping again is a no-op
+	monitor.Stop()
+}
+
+func TestStopWithTimeoutAbortsStuckCycle(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.beginCycle()
+
+	start := time.Now()
+	monitor.StopWithTimeout(50 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("StopWithTimeout() took %v", elapsed)
+	}
+	if monitor.ctx.Err() == nil {
+		t.Error("Expected context to be cancelled after the timeout")
+	}
+}
+
+func TestProcessLiveEventHeartbeat(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.SetAllDomainsMode(true)
+
+	if !monitor.LastHeartbeat().IsZero() {
+		t.Fatal("Expected no heartbeat before any message")
+	}
+
+	before := time.Now()
+	monitor.processLiveEvent(jsonq.NewQuery(map[string]interface{}{
+		"message_type": "heartbeat",
+		"timestamp":    float64(before.Unix()),
+	}))
+
+	if monitor.LastHeartbeat().Before(before) {
+		t.Errorf("Expected heartbeat timestamp to be updated, got %v", monitor.LastHeartbeat())
+	}
+	if len(handler.entries) != 0 {
+		t.Errorf("Expected heartbeat not to be dispatched, got %d entries", len(handler.entries))
+	}
+}
+
+func TestProcessLiveEventCountsDroppedMessages(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.SetAllDomainsMode(true)
+
+	for _, message := range []map[string]interface{}{
+		{"type": "certificate_update"},
+		{"message_type": "heartbeat"},
+		{"message_type": "dns_update"},
+		{"message_type": "certificate_update", "data": map[string]interface{}{"cert": map[string]interface{}{}}},
+		{"message_type": "certificate_update", "data": map[string]interface{}{"leaf_cert": map[string]interface{}{}}},
+		{"message_type": "certificate_update", "data": map[string]interface{}{"leaf_cert": map[string]interface{}{
+			"subject": map[string]interface{}{"CN": "www.example.com"},
+		}}},
+	} {
+		monitor.processLiveEvent(jsonq.NewQuery(message))
+	}
+
+	stats := monitor.StatsSnapshot()
+	if stats.DroppedMessages != 4 || stats.CertificatesSeen != 1 {
+		t.Errorf("Expected 4 dropped messages and 1 certificate seen, got %d and %d", stats.DroppedMessages, stats.CertificatesSeen)
+	}
+	for _, reason := range []string{"missing_message_type", "unknown_message_type", "missing_leaf_cert", "no_names"} {
+		if stats.DroppedByReason[reason] != 1 {
+			t.Errorf("Expected 1 message dropped for %s, got %v", reason, stats.DroppedByReason)
+		}
+	}
+}
+
+// newTestCertificate returns a DER-encoded self-signed certificate for the
+// given names.
+func newTestCertificate(t *testing.T, commonName string, dnsNames ...string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return der
+}
+
+// newTestLogEntry wraps a DER certificate in an X.509 CT log entry logged at
+// the given time.
+func newTestLogEntry(der []byte, loggedAt time.Time) *ct.LogEntry {
+	return &ct.LogEntry{
+		Leaf: ct.MerkleTreeLeaf{
+			TimestampedEntry: &ct.TimestampedEntry{
+				Timestamp: uint64(loggedAt.UnixMilli()),
+				EntryType: ct.X509LogEntryType,
+				X509Entry: &ct.ASN1Cert{Data: der},
+			},
+		},
+	}
+}
+
+func TestMaxEntryAgeSkipsStaleEntries(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+	monitor.SetMaxEntryAge(48 * time.Hour)
+
+	logClient := &CTLogClient{name: "test log"}
+	entries := []*ct.LogEntry{
+		newTestLogEntry(newTestCertificate(t, "old.example.com"), time.Now().Add(-30*24*time.Hour)),
+		newTestLogEntry(newTestCertificate(t, "new.example.com"), time.Now().Add(-time.Hour)),
+		newTestLogEntry(newTestCertificate(t, "stale.example.com"), time.Now().Add(-72*time.Hour)),
+		newTestLogEntry(newTestCertificate(t, "fresh.example.com"), time.Now()),
+	}
+	for i, entry := range entries {
+		if err := monitor.processCTEntry(entry, int64(i), logClient); err != nil {
+			t.Fatalf("processCTEntry() error: %v", err)
+		}
+	}
+
+	if len(handler.entries) != 2 {
+		t.Fatalf("Expected 2 recent entries dispatched, got %d", len(handler.entries))
+	}
+	for _, entry := range handler.entries {
+		cn := entry.LeafCert.Subject.CommonName
+		if cn != "new.example.com" && cn != "fresh.example.com" {
+			t.Errorf("Unexpected stale entry dispatched: %s", cn)
+		}
+	}
+}
+
+func TestMatchCertificate(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	monitor.AddDomain("exact.org", false)
+
+	tests := []struct {
+		domains         []string
+		expectedMatch   string
+		expectedReason  string
+		expectedMatched bool
+		description     string
+	}{
+		{[]string{"example.com"}, "example.com", "exact", true, "exact match"},
+		{[]string{"other.net", "api.example.com"}, "example.com", "subdomain", true, "subdomain in SAN list"},
+		{[]string{"*.exact.org"}, "exact.org", "wildcard", true, "wildcard match"},
+		{[]string{"www.exact.org"}, "", "", false, "subdomain without subdomains enabled"},
+		{[]string{"example.org"}, "", "", false, "no match"},
+		{nil, "", "", false, "no names"},
+	}
+
+	for _, test := range tests {
+		matched, reason, ok := monitor.MatchCertificate(test.domains)
+		if matched != test.expectedMatch || reason != test.expectedReason || ok != test.expectedMatched {
+			t.Errorf("%s: MatchCertificate(%v) = (%q, %q, %v), expected (%q, %q, %v)",
+				test.description, test.domains, matched, reason, ok,
+				test.expectedMatch, test.expectedReason, test.expectedMatched)
+		}
+	}
+}
+
+func TestMatchCertificateAllDomainsMode(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.SetAllDomainsMode(true)
+
+	matched, reason, ok := monitor.MatchCertificate([]string{"anything.test", "www.anything.test"})
+	if !ok || matched != "anything.test" || reason != "all-domains" {
+		t.Errorf("Expected all-domains match on first name, got (%q, %q, %v)", matched, reason, ok)
+	}
+}
+
+func TestCNNotInSAN(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.SetDedupeWindow(0) // the same entries are replayed below
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+
+	logClient := &CTLogClient{name: "test log"}
+	entries := []*ct.LogEntry{
+		newTestLogEntry(newTestCertificate(t, "legacy.example.com", "www.example.com"), time.Now()),
+		newTestLogEntry(newTestCertificate(t, "www.example.com", "WWW.example.com", "api.example.com"), time.Now()),
+	}
+	for i, entry := range entries {
+		if err := monitor.processCTEntry(entry, int64(i), logClient); err != nil {
+			t.Fatalf("processCTEntry() error: %v", err)
+		}
+	}
+
+	if len(handler.entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(handler.entries))
+	}
+	if !handler.entries[0].CNNotInSAN {
+		t.Error("Expected cn_not_in_san for a CN absent from SANs")
+	}
+	if handler.entries[1].CNNotInSAN {
+		t.Error("Expected no cn_not_in_san when the CN is a SAN (case-insensitive)")
+	}
+
+	// With the filter enabled only the unusual certificate is dispatched
+	handler.entries = nil
+	monitor.SetCNNotInSANOnly(true)
+	for i, entry := range entries {
+		monitor.processCTEntry(entry, int64(i), logClient)
+	}
+	if len(handler.entries) != 1 || handler.entries[0].LeafCert.Subject.CommonName != "legacy.example.com" {
+		t.Errorf("Expected only the CN-not-in-SAN certificate to be dispatched, got %d entries", len(handler.entries))
+	}
+}
+
+func TestNoNotifyBackfill(t *testing.T) {
+	monitor := NewMonitor()
+	store := &mockHandler{}
+	notifier := &mockHandler{}
+	monitor.AddHandler(store)
+	monitor.AddNotificationHandler(notifier)
+	monitor.AddDomain("example.com", true)
+	monitor.SetNotifyBackfill(false)
+
+	logClient := &CTLogClient{name: "test log"}
+	backfill := newTestLogEntry(newTestCertificate(t, "old.example.com"), monitor.startedAt.Add(-time.Hour))
+	live := newTestLogEntry(newTestCertificate(t, "new.example.com"), monitor.startedAt.Add(time.Second))
+
+	monitor.processCTEntry(backfill, 1, logClient)
+	monitor.processCTEntry(live, 2, logClient)
+
+	if len(store.entries) != 2 {
+		t.Errorf("Expected storage to receive both entries, got %d", len(store.entries))
+	}
+	if len(notifier.entries) != 1 || notifier.entries[0].LeafCert.Subject.CommonName != "new.example.com" {
+		t.Errorf("Expected notifier to receive only the live entry, got %d entries", len(notifier.entries))
+	}
+}
+
+func TestCheckCertificate(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+
+	matching, err := ParseCertificate(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: newTestCertificate(t, "api.example.com", "api.example.com"),
+	}))
+	if err != nil {
+		t.Fatalf("ParseCertificate(PEM) error: %v", err)
+	}
+
+	entry, reason, ok := monitor.CheckCertificate(matching)
+	if !ok || reason != "subdomain" {
+		t.Fatalf("Expected subdomain match, got (%v, %q)", ok, reason)
+	}
+	if entry == nil || entry.Domain != "example.com" || entry.LeafCert.Subject.CommonName != "api.example.com" {
+		t.Errorf("Unexpected would-be entry: %+v", entry)
+	}
+
+	other, err := ParseCertificate(newTestCertificate(t, "example.org", "example.org"))
+	if err != nil {
+		t.Fatalf("ParseCertificate(DER) error: %v", err)
+	}
+	if entry, _, ok := monitor.CheckCertificate(other); ok || entry != nil {
+		t.Errorf("Expected no match for example.org, got %+v", entry)
+	}
+
+	if len(handler.entries) != 0 {
+		t.Errorf("Expected CheckCertificate not to dispatch, got %d entries", len(handler.entries))
+	}
+}
+
+func TestParseCertificateRejectsGarbage(t *testing.T) {
+	if _, err := ParseCertificate([]byte("not a certificate")); err == nil {
+		t.Error("Expected error for invalid certificate data")
+	}
+}
+
+func TestReloadDomains(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("keep.com", true)
+	monitor.AddDomain("change.com", true)
+	monitor.AddDomain("drop.com", true)
+
+	before := monitor.GetWatchedDomains()
+	monitor.updateLastSeen("change.com")
+
+	monitor.ReloadDomains([]models.DomainWatch{
+		{Domain: "keep.com", IncludeSubdomains: true},
+		{Domain: "change.com", IncludeSubdomains: false},
+		{Domain: "new.com", IncludeSubdomains: true},
+	})
+
+	after := monitor.GetWatchedDomains()
+	if len(after) != 3 {
+		t.Fatalf("Expected 3 domains after reload, got %d", len(after))
+	}
+	if _, exists := after["drop.com"]; exists {
+		t.Error("Expected drop.com to be removed")
+	}
+	if after["keep.com"] != before["keep.com"] {
+		t.Error("Expected unchanged watch to be carried over as-is")
+	}
+	if after["change.com"].IncludeSubdomains {
+		t.Error("Expected change.com to have subdomains disabled")
+	}
+	if !after["change.com"].CreatedAt.Equal(before["change.com"].CreatedAt) || after["change.com"].LastSeen.IsZero() {
+		t.Error("Expected changed watch to keep CreatedAt and LastSeen")
+	}
+	if !after["new.com"].Active {
+		t.Error("Expected new watch to be active")
+	}
+}
+
+// Run with -race to check matches against readers of the watch list
+func TestUpdateLastSeenReplacesWatch(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	before := monitor.GetWatchedDomains()["example.com"]
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			monitor.updateLastSeen("example.com")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_ = monitor.GetWatchedDomains()["example.com"].LastSeen
+	}
+	<-done
+
+	if !before.LastSeen.IsZero() {
+		t.Error("Expected a watch handed out earlier to be left unchanged")
+	}
+	if monitor.GetWatchedDomains()["example.com"].LastSeen.IsZero() {
+		t.Error("Expected LastSeen to be set after a match")
+	}
+}
+
+// Run with -race to check reloads against concurrent ingestion
+func TestReloadDomainsWhileProcessing(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddHandler(&mockHandler{})
+
+	small := []models.DomainWatch{{Domain: "example.com", IncludeSubdomains: true}}
+	large := make([]models.DomainWatch, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		large = append(large, models.DomainWatch{Domain: fmt.Sprintf("domain%d.com", i), IncludeSubdomains: true})
+	}
+	large = append(large, small[0])
+	monitor.ReloadDomains(small)
+
+	logClient := &CTLogClient{name: "test log"}
+	entry := newTestLogEntry(newTestCertificate(t, "www.example.com"), time.Now())
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if i%2 == 0 {
+				monitor.ReloadDomains(large)
+			} else {
+				monitor.ReloadDomains(small)
+			}
+		}
+		close(done)
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				monitor.processCTEntry(entry, 0, logClient)
+				monitor.GetWatchedDomains()
+			}
+		}
+	}()
+	wg.Wait()
+
+	if _, _, ok := monitor.MatchCertificate([]string{"www.example.com"}); !ok {
+		t.Error("Expected example.com to still be watched after reloads")
+	}
+}
+
+func TestIdempotencyKeyStableAcrossParses(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	der := newTestCertificate(t, "www.example.com")
+
+	var keys []string
+	for i := 0; i < 2; i++ {
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("ParseCertificate() error: %v", err)
+		}
+		entry, _, ok := monitor.CheckCertificate(cert)
+		if !ok {
+			t.Fatal("Expected certificate to match")
+		}
+		keys = append(keys, entry.IdempotencyKey)
+	}
+
+	if keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Expected identical non-empty keys, got %q and %q", keys[0], keys[1])
+	}
+	if other, _, _ := monitor.CheckCertificate(mustParse(t, newTestCertificate(t, "www.example.com"))); other.IdempotencyKey == keys[0] {
+		t.Error("Expected a different certificate to get a different key")
+	}
+}
+
+func TestComputeIdempotencyKeyFallsBackToSerialAndAKI(t *testing.T) {
+	entry := &models.CertificateEntry{LeafCert: models.LeafCertificate{
+		SerialNumber: "03:AB:CD",
+		Extensions:   models.Extensions{AuthorityKeyIdentifier: "keyid:14:2E:B3\n"},
+	}}
+	same := &models.CertificateEntry{LeafCert: models.LeafCertificate{
+		SerialNumber: "03abcd",
+		Extensions:   models.Extensions{AuthorityKeyIdentifier: "142eb3"},
+	}}
+
+	key := entry.ComputeIdempotencyKey()
+	if key == "" || key != same.ComputeIdempotencyKey() {
+		t.Errorf("Expected serial+AKI keys to ignore formatting, got %q and %q", key, same.ComputeIdempotencyKey())
+	}
+	if (&models.CertificateEntry{}).ComputeIdempotencyKey() != "" {
+		t.Error("Expected no key without fingerprint or serial+AKI")
+	}
+}
+
+func mustParse(t *testing.T, der []byte) *x509.Certificate {
+	t.Helper()
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error: %v", err)
+	}
+	return cert
+}
+
+func TestCertFingerprint(t *testing.T) {
+	cert := mustParse(t, newTestCertificate(t, "www.example.com"))
+
+	fingerprint := certFingerprint(cert)
+	if len(fingerprint) != 95 {
+		t.Errorf("Expected a 95 character fingerprint, got %d: %s", len(fingerprint), fingerprint)
+	}
+	if !regexp.MustCompile(`^([0-9A-F]{2}:){31}[0-9A-F]{2}$`).MatchString(fingerprint) {
+		t.Errorf("Fingerprint is not colon-separated SHA-256 hex: %s", fingerprint)
+	}
+
+	sum := sha256.Sum256(cert.Raw)
+	if want := fingerprintFromHex(fmt.Sprintf("%x", sum)); fingerprint != want {
+		t.Errorf("Expected %s, got %s", want, fingerprint)
+	}
+
+	live := liveFingerprint(map[string]interface{}{"as_der": base64.StdEncoding.EncodeToString(cert.Raw), "fingerprint": "AA:BB"})
+	if live != fingerprint {
+		t.Errorf("Expected live fingerprint from as_der to be %s, got %s", fingerprint, live)
+	}
+	if got := liveFingerprint(map[string]interface{}{"fingerprint": "AA:BB"}); got != "AA:BB" {
+		t.Errorf("Expected stream fingerprint without as_der, got %s", got)
+	}
+}
+
+func TestEntryRecordsMatchedNames(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+	if err := monitor.AddDomainRegex(`^shop-.*\.example\.net$`); err != nil {
+		t.Fatalf("AddDomainRegex() error: %v", err)
+	}
+
+	logClient := &CTLogClient{name: "test log"}
+	cert := newTestCertificate(t, "login.example.com", "login.example.com", "www.other.net", "LOGIN.example.com", "api.example.com")
+	monitor.processCTEntry(newTestLogEntry(cert, time.Now()), 1, logClient)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.other.org", "shop-eu.example.net"), time.Now()), 2, logClient)
+
+	if len(handler.entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(handler.entries))
+	}
+	entry := handler.entries[0]
+	if want := []string{"login.example.com", "www.other.net", "api.example.com"}; fmt.Sprint(entry.Subdomains) != fmt.Sprint(want) {
+		t.Errorf("Expected deduplicated names %v, got %v", want, entry.Subdomains)
+	}
+	if want := []string{"login.example.com", "api.example.com"}; fmt.Sprint(entry.MatchedNames) != fmt.Sprint(want) {
+		t.Errorf("Expected matched names %v, got %v", want, entry.MatchedNames)
+	}
+	if entry := handler.entries[1]; fmt.Sprint(entry.MatchedNames) != "[shop-eu.example.net]" {
+		t.Errorf("Expected the regex match to record shop-eu.example.net, got %v", entry.MatchedNames)
+	}
+}
+
+func TestEntryFlagsWildcardMatches(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+
+	logClient := &CTLogClient{name: "test log"}
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "*.example.com", "*.example.com", "example.com"), time.Now()), 1, logClient)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.example.com", "www.example.com", "*.other.net"), time.Now()), 2, logClient)
+
+	if len(handler.entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(handler.entries))
+	}
+	if !handler.entries[0].Wildcard {
+		t.Error("Expected a matched *.example.com to be flagged as wildcard")
+	}
+	if handler.entries[1].Wildcard {
+		t.Error("Expected an unmatched wildcard name not to flag the entry")
+	}
+}
@@ -0,0 +1,59 @@
+package certwatch
+
+import (
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// SetLogNearMisses logs certificates that did not match but came close to a
+// watched domain, such as a subdomain of a domain watched without
+// --subdomains or a sibling under the same registrable domain. It helps
+// explain why a certificate was not caught.
+func (m *Monitor) SetLogNearMisses(enabled bool) {
+	m.logNearMisses = enabled
+}
+
+// reportNearMiss logs the first near miss among the names of a certificate
+// that matched nothing.
+func (m *Monitor) reportNearMiss(domains []string) {
+	if !m.logNearMisses {
+		return
+	}
+
+	if name, watched, reason, ok := m.nearMiss(domains); ok {
+		m.logger.Info("Near miss", "name", name, "watched", watched, "reason", reason)
+	}
+}
+
+// nearMiss finds a certificate name related to a watched domain and explains
+// why it did not match.
+func (m *Monitor) nearMiss(domains []string) (name, watched, reason string, ok bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, domain := range domains {
+		base := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "*.")
+		registrable, err := publicsuffix.EffectiveTLDPlusOne(base)
+		if err != nil {
+			continue
+		}
+
+		for watchedDomain, config := range m.watchedDomains {
+			watched := strings.ToLower(watchedDomain)
+			switch {
+			case strings.HasPrefix(watched, "*.") && strings.HasSuffix(base, watched[1:]):
+				return domain, watchedDomain, "more than one label below a wildcard watch", true
+			case !config.IncludeSubdomains && strings.HasSuffix(base, "."+watched):
+				return domain, watchedDomain, "subdomain of a domain watched without subdomains", true
+			case strings.HasSuffix(watched, "."+base):
+				return domain, watchedDomain, "parent of the watched domain", true
+			}
+
+			if watchedRegistrable, err := publicsuffix.EffectiveTLDPlusOne(watched); err == nil && watchedRegistrable == registrable {
+				return domain, watchedDomain, "same registrable domain " + registrable, true
+			}
+		}
+	}
+	return "", "", "", false
+}
@@ -0,0 +1,58 @@
+package certwatch
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNearMissLoggedNotDispatched(t *testing.T) {
+	var buf bytes.Buffer
+	monitor := NewMonitor()
+	monitor.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", false)
+	monitor.SetLogNearMisses(true)
+
+	logClient := &CTLogClient{name: "test log"}
+	loggedAt := monitor.startedAt.Add(time.Second)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "api.example.com"), loggedAt), 1, logClient)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.unrelated.com"), loggedAt), 2, logClient)
+
+	if len(handler.entries) != 0 {
+		t.Fatalf("Expected near miss not to be dispatched, got %d entries", len(handler.entries))
+	}
+	output := buf.String()
+	if !strings.Contains(output, `msg="Near miss" name=api.example.com watched=example.com reason="subdomain of a domain watched without subdomains"`) {
+		t.Errorf("Expected near miss to be logged, got %q", output)
+	}
+	if strings.Contains(output, "unrelated.com") {
+		t.Errorf("Expected unrelated certificate not to be logged, got %q", output)
+	}
+}
+
+func TestNearMissReasons(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("shop.example.co.uk", true)
+	monitor.AddDomain("*.corp.example.com", false)
+
+	tests := []struct {
+		name   string
+		reason string
+		ok     bool
+	}{
+		{"example.co.uk", "parent of the watched domain", true},
+		{"mail.example.co.uk", "same registrable domain example.co.uk", true},
+		{"other.co.uk", "", false},
+		{"a.b.corp.example.com", "more than one label below a wildcard watch", true},
+	}
+	for _, tt := range tests {
+		_, _, reason, ok := monitor.nearMiss([]string{tt.name})
+		if ok != tt.ok || reason != tt.reason {
+			t.Errorf("nearMiss(%q) = %q, %v; expected %q, %v", tt.name, reason, ok, tt.reason, tt.ok)
+		}
+	}
+}
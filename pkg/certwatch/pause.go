@@ -0,0 +1,137 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"sync"
+)
+
+// pauseGate holds matches back from handlers while the monitor is paused,
+// keeping up to limit of them for Resume. While they are delivered,
+// resuming is set and new matches queue behind them, within the same limit.
+type pauseGate struct {
+	mutex    sync.Mutex
+	paused   bool
+	resuming bool
+	limit    int
+	queue    []pausedEntry
+	draining sync.WaitGroup
+}
+
+// pausedEntry is a match held back by a pause, with the backfill flag it was
+// dispatched with.
+type pausedEntry struct {
+	entry    *models.CertificateEntry
+	backfill bool
+}
+
+// SetPauseBuffer keeps up to n matches found while the monitor is paused and
+// delivers them on Resume; once n are held, the oldest is dropped for each
+// new one. Zero, the default, drops every match found while paused. Drops
+// are counted in MonitorStats.PausedDropped.
+func (m *Monitor) SetPauseBuffer(n int) {
+	m.pause.mutex.Lock()
+	defer m.pause.mutex.Unlock()
+
+	if n < 0 {
+		n = 0
+	}
+	m.pause.limit = n
+	if len(m.pause.queue) > n {
+		m.stats.recordPausedDropped(len(m.pause.queue) - n)
+		m.pause.queue = m.pause.queue[len(m.pause.queue)-n:]
+	}
+}
+
+// Pause stops delivering matches to handlers and notifiers, for instance
+// while a downstream tool is overloaded. Certificates are still polled or
+// streamed and matched, and matches are held back or dropped as set by
+// SetPauseBuffer.
+func (m *Monitor) Pause() {
+	m.pause.mutex.Lock()
+	defer m.pause.mutex.Unlock()
+
+	if !m.pause.paused {
+		m.pause.paused = true
+		m.logger.Info("Paused dispatch of matches", "buffer", m.pause.limit)
+	}
+}
+
+// Resume delivers the matches held back since Pause, oldest first, and then
+// resumes delivering new ones. Held matches are delivered in the background,
+// so Resume returns right away. Matches found meanwhile are queued behind
+// them, so order is kept without holding up the goroutines finding them;
+// that queue is bounded like the pause buffer, dropping the oldest, in case
+// the downstream is still slow. Pausing again stops the delivery, keeping
+// what is left for the next Resume.
+func (m *Monitor) Resume() {
+	m.pause.mutex.Lock()
+	defer m.pause.mutex.Unlock()
+	if !m.pause.paused || m.pause.resuming {
+		return
+	}
+	m.pause.paused = false
+	m.pause.resuming = true
+	m.logger.Info("Resumed dispatch of matches", "held", len(m.pause.queue))
+
+	m.pause.draining.Add(1)
+	go m.deliverHeld()
+}
+
+// deliverHeld delivers the held matches until none are left, the monitor is
+// paused again or it stops.
+func (m *Monitor) deliverHeld() {
+	defer m.pause.draining.Done()
+
+	m.pause.mutex.Lock()
+	defer m.pause.mutex.Unlock()
+	for len(m.pause.queue) > 0 && !m.pause.paused {
+		queue := m.pause.queue
+		m.pause.queue = nil
+		m.pause.mutex.Unlock()
+
+		for i, held := range queue {
+			select {
+			case <-m.stopChan:
+				m.pause.mutex.Lock()
+				m.pause.queue = append(queue[i:], m.pause.queue...)
+				m.pause.resuming = false
+				return
+			default:
+			}
+			m.deliver(held.entry, held.backfill)
+		}
+		m.pause.mutex.Lock()
+	}
+	m.pause.resuming = false
+}
+
+// Paused reports whether the monitor is paused.
+func (m *Monitor) Paused() bool {
+	m.pause.mutex.Lock()
+	defer m.pause.mutex.Unlock()
+	return m.pause.paused
+}
+
+// deliverOrHold delivers entry to handlers, or holds it back if the monitor
+// is paused or still delivering held matches.
+func (m *Monitor) deliverOrHold(entry *models.CertificateEntry, backfill bool) {
+	m.pause.mutex.Lock()
+	// Without a buffer nothing is held, so there is no order to keep
+	if !m.pause.paused && (!m.pause.resuming || m.pause.limit == 0) {
+		m.pause.mutex.Unlock()
+		m.deliver(entry, backfill)
+		return
+	}
+	defer m.pause.mutex.Unlock()
+
+	if m.pause.limit == 0 {
+		m.stats.recordPausedDropped(1)
+		return
+	}
+	if len(m.pause.queue) >= m.pause.limit {
+		m.stats.recordPausedDropped(1)
+		m.pause.queue[0] = pausedEntry{}
+		m.pause.queue = m.pause.queue[1:]
+	}
+	m.pause.queue = append(m.pause.queue, pausedEntry{entry: entry, backfill: backfill})
+}
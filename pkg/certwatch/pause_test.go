@@ -0,0 +1,164 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPauseDropsMatches(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+
+	logClient := &CTLogClient{name: "test log"}
+	monitor.Pause()
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "a.example.com"), time.Now()), 0, logClient)
+	monitor.Resume()
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "b.example.com"), time.Now()), 1, logClient)
+
+	if len(handler.entries) != 1 || handler.entries[0].LeafCert.Subject.CommonName != "b.example.com" {
+		t.Fatalf("Expected only the match after Resume, got %d entries", len(handler.entries))
+	}
+	stats := monitor.StatsSnapshot()
+	if stats.Matches != 2 || stats.PausedDropped != 1 || stats.Paused {
+		t.Errorf("Expected 2 matches with 1 dropped while paused, got %+v", stats)
+	}
+}
+
+func TestPauseBufferDropsOldest(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+	monitor.SetPauseBuffer(2)
+
+	logClient := &CTLogClient{name: "test log"}
+	monitor.Pause()
+	if !monitor.Paused() {
+		t.Fatal("Expected the monitor to be paused")
+	}
+	for i, cn := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, cn), time.Now()), int64(i), logClient)
+	}
+	if len(handler.entries) != 0 {
+		t.Fatalf("Expected no delivery while paused, got %d entries", len(handler.entries))
+	}
+
+	monitor.Resume()
+	monitor.pause.draining.Wait()
+	if len(handler.entries) != 2 {
+		t.Fatalf("Expected the 2 buffered matches on Resume, got %d entries", len(handler.entries))
+	}
+	for i, cn := range []string{"b.example.com", "c.example.com"} {
+		if got := handler.entries[i].LeafCert.Subject.CommonName; got != cn {
+			t.Errorf("Entry %d: expected %s, got %s", i, cn, got)
+		}
+	}
+	if stats := monitor.StatsSnapshot(); stats.PausedDropped != 1 || stats.Dispatched != 2 {
+		t.Errorf("Expected 1 dropped and 2 dispatched, got %+v", stats)
+	}
+}
+
+func TestResumeDoesNotBlockDispatch(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.SetPauseBuffer(10)
+
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	var delivered []string
+	monitor.AddHandler(funcHandler(func(entry *models.CertificateEntry) error {
+		if entry.Domain == "a.example.com" {
+			<-release
+		}
+		mutex.Lock()
+		delivered = append(delivered, entry.Domain)
+		mutex.Unlock()
+		return nil
+	}))
+
+	monitor.Pause()
+	monitor.deliverOrHold(&models.CertificateEntry{Domain: "a.example.com"}, false)
+	monitor.deliverOrHold(&models.CertificateEntry{Domain: "b.example.com"}, false)
+
+	// Resume returns while the first held match is still being delivered
+	resumed := make(chan struct{})
+	go func() {
+		monitor.Resume()
+		close(resumed)
+	}()
+	select {
+	case <-resumed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Resume waited for the held matches to be delivered")
+	}
+
+	// A new match is queued behind the held ones instead of waiting
+	dispatched := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		monitor.deliverOrHold(&models.CertificateEntry{Domain: "c.example.com"}, false)
+		close(dispatched)
+	}()
+	select {
+	case <-dispatched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Dispatch blocked while Resume delivered held matches")
+	}
+
+	close(release)
+	monitor.pause.draining.Wait()
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !slices.Equal(delivered, []string{"a.example.com", "b.example.com", "c.example.com"}) {
+		t.Errorf("Expected the held matches, then the new one, got %v", delivered)
+	}
+	if monitor.Paused() {
+		t.Error("Expected the monitor to be resumed")
+	}
+}
+
+func TestResumeBoundsNewMatches(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.SetPauseBuffer(2)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	var delivered []string
+	monitor.AddHandler(funcHandler(func(entry *models.CertificateEntry) error {
+		if entry.Domain == "a.example.com" {
+			close(started)
+			<-release
+		}
+		mutex.Lock()
+		delivered = append(delivered, entry.Domain)
+		mutex.Unlock()
+		return nil
+	}))
+
+	monitor.Pause()
+	monitor.deliverOrHold(&models.CertificateEntry{Domain: "a.example.com"}, false)
+	monitor.Resume()
+	<-started
+
+	// The downstream is still slow: matches queued behind the held ones
+	// are bounded by the pause buffer too
+	for _, domain := range []string{"b.example.com", "c.example.com", "d.example.com"} {
+		monitor.deliverOrHold(&models.CertificateEntry{Domain: domain}, false)
+	}
+	close(release)
+	monitor.pause.draining.Wait()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !slices.Equal(delivered, []string{"a.example.com", "c.example.com", "d.example.com"}) {
+		t.Errorf("Expected the oldest new match dropped, got %v", delivered)
+	}
+	if stats := monitor.StatsSnapshot(); stats.PausedDropped != 1 {
+		t.Errorf("Expected 1 dropped match, got %d", stats.PausedDropped)
+	}
+}
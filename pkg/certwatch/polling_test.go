@@ -0,0 +1,481 @@
+package certwatch
+
+import (
+	"bytes"
+	"context"
+	"domain_watcher/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/google/certificate-transparency-go/tls"
+)
+
+// fakeCTLog serves get-sth and get-entries for a fixed list of X.509
+// certificates.
+type fakeCTLog struct {
+	t     *testing.T
+	certs [][]byte
+}
+
+func (l *fakeCTLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/ct/v1/get-sth":
+		signature, err := tls.Marshal(ct.DigitallySigned{
+			Algorithm: tls.SignatureAndHashAlgorithm{Hash: tls.SHA256, Signature: tls.ECDSA},
+			Signature: []byte{0},
+		})
+		if err != nil {
+			l.t.Fatalf("tls.Marshal(signature) error: %v", err)
+		}
+		json.NewEncoder(w).Encode(ct.GetSTHResponse{
+			TreeSize:          uint64(len(l.certs)),
+			Timestamp:         uint64(time.Now().UnixMilli()),
+			SHA256RootHash:    make([]byte, 32),
+			TreeHeadSignature: signature,
+		})
+	case "/ct/v1/get-entries":
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		end, _ := strconv.Atoi(r.URL.Query().Get("end"))
+		var resp ct.GetEntriesResponse
+		for i := start; i <= end && i < len(l.certs); i++ {
+			resp.Entries = append(resp.Entries, l.leafEntry(l.certs[i]))
+		}
+		json.NewEncoder(w).Encode(resp)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (l *fakeCTLog) leafEntry(der []byte) ct.LeafEntry {
+	leaf, err := tls.Marshal(ct.MerkleTreeLeaf{
+		Version:  ct.V1,
+		LeafType: ct.TimestampedEntryLeafType,
+		TimestampedEntry: &ct.TimestampedEntry{
+			Timestamp: uint64(time.Now().UnixMilli()),
+			EntryType: ct.X509LogEntryType,
+			X509Entry: &ct.ASN1Cert{Data: der},
+		},
+	})
+	if err != nil {
+		l.t.Fatalf("tls.Marshal(leaf) error: %v", err)
+	}
+	chain, err := tls.Marshal(ct.CertificateChain{})
+	if err != nil {
+		l.t.Fatalf("tls.Marshal(chain) error: %v", err)
+	}
+	return ct.LeafEntry{LeafInput: leaf, ExtraData: chain}
+}
+
+func newFakeLogClient(t *testing.T, certs ...[]byte) *CTLogClient {
+	t.Helper()
+	server := httptest.NewServer(&fakeCTLog{t: t, certs: certs})
+	t.Cleanup(server.Close)
+
+	logClient, err := client.New(server.URL, server.Client(), jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("client.New() error: %v", err)
+	}
+	return &CTLogClient{client: logClient, url: server.URL, name: "fake log"}
+}
+
+// fakeLogAPI is an in-memory ctLogAPI that can fail requests on demand.
+type fakeLogAPI struct {
+	log *fakeCTLog
+
+	sthFailures     int   // next GetSTH calls that fail
+	entriesFailures int   // next GetRawEntries calls that fail
+	badIndex        int64 // entry whose every fetch fails, -1 for none
+
+	ranges [][2]int64 // start and end of every GetRawEntries call
+}
+
+func newFakeLogAPI(t *testing.T, certs ...[]byte) *fakeLogAPI {
+	return &fakeLogAPI{log: &fakeCTLog{t: t, certs: certs}, badIndex: -1}
+}
+
+func (f *fakeLogAPI) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	if f.sthFailures > 0 {
+		f.sthFailures--
+		return nil, errors.New("connection reset by peer")
+	}
+	return &ct.SignedTreeHead{TreeSize: uint64(len(f.log.certs))}, nil
+}
+
+func (f *fakeLogAPI) GetRawEntries(ctx context.Context, start, end int64) (*ct.GetEntriesResponse, error) {
+	f.ranges = append(f.ranges, [2]int64{start, end})
+	if f.entriesFailures > 0 {
+		f.entriesFailures--
+		return nil, errors.New("connection reset by peer")
+	}
+	if f.badIndex >= start && f.badIndex <= end {
+		return nil, jsonclient.RspError{StatusCode: http.StatusBadGateway, Err: errors.New("bad gateway")}
+	}
+
+	var resp ct.GetEntriesResponse
+	for i := start; i <= end && i < int64(len(f.log.certs)); i++ {
+		resp.Entries = append(resp.Entries, f.log.leafEntry(f.log.certs[i]))
+	}
+	return &resp, nil
+}
+
+func TestCheckNewCertificatesSkipsOversizedEntries(t *testing.T) {
+	var manyNames []string
+	for i := 0; i < 200; i++ {
+		manyNames = append(manyNames, fmt.Sprintf("host-%d.example.com", i))
+	}
+	small := newTestCertificate(t, "www.example.com")
+	huge := newTestCertificate(t, "huge.example.com", manyNames...)
+	if len(huge) < 4096 || len(small) > 2048 {
+		t.Fatalf("Unexpected test certificate sizes: %d and %d", len(small), len(huge))
+	}
+
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	monitor.SetMaxEntryBytes(4096)
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+
+	logClient := newFakeLogClient(t, small, huge, newTestCertificate(t, "api.example.com"))
+	if err := monitor.checkNewCertificates(logClient); err != nil {
+		t.Fatalf("checkNewCertificates() error: %v", err)
+	}
+
+	if len(handler.entries) != 2 {
+		t.Errorf("Expected the oversized entry to be skipped, got %d entries", len(handler.entries))
+	}
+	for _, entry := range handler.entries {
+		if entry.LeafCert.Subject.CommonName == "huge.example.com" {
+			t.Error("Oversized entry was dispatched")
+		}
+	}
+	if logClient.lastIndex != 3 {
+		t.Errorf("Expected lastIndex to advance past all entries, got %d", logClient.lastIndex)
+	}
+}
+
+func TestPollStateResumesAfterRestart(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	certs := [][]byte{
+		newTestCertificate(t, "a.example.com"),
+		newTestCertificate(t, "b.example.com"),
+		newTestCertificate(t, "c.example.com"),
+	}
+	logClient := newFakeLogClient(t, certs...)
+
+	monitor := NewMonitor()
+	if err := monitor.SetStateFile(statePath); err != nil {
+		t.Fatalf("SetStateFile() error: %v", err)
+	}
+	if err := monitor.checkNewCertificates(logClient); err != nil {
+		t.Fatalf("checkNewCertificates() error: %v", err)
+	}
+
+	// A restarted monitor picks up the saved index instead of the tree head
+	restarted := NewMonitor()
+	if err := restarted.SetStateFile(statePath); err != nil {
+		t.Fatalf("SetStateFile() error: %v", err)
+	}
+	index, ok := restarted.pollState.lastIndex(logClient.url)
+	if !ok || index != 3 {
+		t.Fatalf("Expected saved index 3 for %s, got %d (found %v)", logClient.url, index, ok)
+	}
+
+	resumed := &CTLogClient{client: logClient.client, url: logClient.url, name: logClient.name, lastIndex: index}
+	restarted.initializeLogStartingPoint(resumed)
+	if resumed.lastIndex != 3 {
+		t.Errorf("Expected resume from saved index, got %d", resumed.lastIndex)
+	}
+
+	// Logs without saved state fall back to the tree head
+	fresh := &CTLogClient{client: logClient.client, url: "https://other.example/", name: "other", lastIndex: -1}
+	restarted.initializeLogStartingPoint(fresh)
+	if fresh.lastIndex != 0 {
+		t.Errorf("Expected fallback to TreeSize-100 clamped to 0, got %d", fresh.lastIndex)
+	}
+}
+
+func TestLastSeenSurvivesRestart(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	monitor := NewMonitor()
+	if err := monitor.SetStateFile(statePath); err != nil {
+		t.Fatalf("SetStateFile() error: %v", err)
+	}
+	monitor.AddDomain("example.com", true)
+	monitor.AddDomain("example.org", true)
+	logClient := &CTLogClient{name: "test log"}
+	if err := monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.example.com"), time.Now()), 0, logClient); err != nil {
+		t.Fatalf("processCTEntry() error: %v", err)
+	}
+
+	seen := monitor.GetWatchedDomains()["example.com"].LastSeen
+	if seen.IsZero() {
+		t.Fatal("Expected LastSeen to be set after a match")
+	}
+
+	// A second match within the save interval is held back until Stop
+	if err := monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.example.org"), time.Now()), 1, logClient); err != nil {
+		t.Fatalf("processCTEntry() error: %v", err)
+	}
+	seenOrg := monitor.GetWatchedDomains()["example.org"].LastSeen
+	if saved, err := loadPollState(statePath); err != nil {
+		t.Fatalf("loadPollState() error: %v", err)
+	} else if _, ok := saved.lastSeenAt("example.org"); ok {
+		t.Error("Expected the second match to wait for the save interval")
+	}
+	monitor.Stop()
+
+	restarted := NewMonitor()
+	if err := restarted.SetStateFile(statePath); err != nil {
+		t.Fatalf("SetStateFile() error: %v", err)
+	}
+	restarted.AddDomain("example.com", true)
+	restarted.ReloadDomains([]models.DomainWatch{
+		{Domain: "example.com", IncludeSubdomains: true},
+		{Domain: "example.org", IncludeSubdomains: true},
+	})
+
+	domains := restarted.GetWatchedDomains()
+	if got := domains["example.com"].LastSeen; !got.Equal(seen) {
+		t.Errorf("Expected example.com LastSeen %v after restart, got %v", seen, got)
+	}
+	if got := domains["example.org"].LastSeen; !got.Equal(seenOrg) {
+		t.Errorf("Expected example.org LastSeen %v after restart, got %v", seenOrg, got)
+	}
+	if got := restarted.SavedLastSeen(); len(got) != 2 {
+		t.Errorf("Expected 2 saved last-seen times, got %v", got)
+	}
+}
+
+func TestLoadPollStateReportsField(t *testing.T) {
+	tests := []struct {
+		state string
+		want  string
+	}{
+		{`{"last_index": {"https://log.example/": 5}, "last_seen": {"example.com": "2024-01-02T15:04:05Z"}}`, ""},
+		{`{"last_index": {"https://log.example/": 5},}`, "line 1"},
+		{`{"last_index": {}, "lastSeen": {}}`, `unknown field "lastSeen"`},
+		{`{"last_index": {"https://log.example/": "5"}}`, `last_index["https://log.example/"]: expected a non-negative integer`},
+		{`{"last_index": {"https://log.example/": -1}}`, "non-negative integer"},
+		{`{"last_seen": {"example.com": "yesterday"}}`, `last_seen["example.com"]: expected an RFC 3339 time`},
+		{`{"last_seen": ["example.com"]}`, "last_seen: expected an object"},
+		{`[]`, "expected an object"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "state.json")
+		if err := os.WriteFile(path, []byte(tt.state), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadPollState(path)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("loadPollState(%s) error: %v", tt.state, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("loadPollState(%s) error = %v, want it to contain %q", tt.state, err, tt.want)
+		}
+	}
+}
+
+func TestPollCycleConcurrencyLimit(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	monitor := NewMonitor()
+	monitor.SetPollConcurrency(2)
+
+	for i := 0; i < 6; i++ {
+		fake := &fakeCTLog{t: t, certs: [][]byte{newTestCertificate(t, "www.example.com")}}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			fake.ServeHTTP(w, r)
+		}))
+		t.Cleanup(server.Close)
+
+		logClient, err := client.New(server.URL, server.Client(), jsonclient.Options{})
+		if err != nil {
+			t.Fatalf("client.New() error: %v", err)
+		}
+		monitor.ctClients = append(monitor.ctClients, &CTLogClient{client: logClient, url: server.URL, name: fmt.Sprintf("log %d", i)})
+	}
+
+	monitor.pollCycle()
+
+	if got := peak.Load(); got > 2 {
+		t.Errorf("Expected at most 2 logs polled at once, saw %d", got)
+	}
+	for _, logClient := range monitor.ctClients {
+		if logClient.lastIndex != 1 {
+			t.Errorf("%s: expected the cycle to finish every log, lastIndex is %d", logClient.name, logClient.lastIndex)
+		}
+	}
+}
+
+func TestCheckNewCertificatesBatches(t *testing.T) {
+	certs := make([][]byte, 120)
+	for i := range certs {
+		certs[i] = newTestCertificate(t, fmt.Sprintf("host-%d.example.org", i))
+	}
+	api := newFakeLogAPI(t, certs...)
+	logClient := &CTLogClient{client: api, name: "synthetic log"}
+	monitor := NewMonitor()
+
+	// Each poll fetches at most 50 entries and resumes where the last stopped
+	for _, want := range []int64{50, 100, 120, 120} {
+		if err := monitor.checkNewCertificates(logClient); err != nil {
+			t.Fatalf("checkNewCertificates() error: %v", err)
+		}
+		if logClient.lastIndex != want {
+			t.Fatalf("Expected lastIndex %d, got %d", want, logClient.lastIndex)
+		}
+	}
+
+	want := [][2]int64{{0, 49}, {50, 99}, {100, 119}}
+	if fmt.Sprint(api.ranges) != fmt.Sprint(want) {
+		t.Errorf("Expected get-entries ranges %v, got %v", want, api.ranges)
+	}
+}
+
+func TestCheckNewCertificatesAdaptsBatchSize(t *testing.T) {
+	der := newTestCertificate(t, "www.example.org")
+	certs := make([][]byte, 3000)
+	for i := range certs {
+		certs[i] = der
+	}
+	api := newFakeLogAPI(t, certs...)
+	logClient := &CTLogClient{client: api, name: "synthetic log"}
+	monitor := NewMonitor()
+	monitor.SetMaxBatchSize(600)
+
+	// Far behind, polls request a quarter of the backlog up to the maximum
+	// and shrink as the log catches up, never past the tree size
+	for i := 0; i < 8; i++ {
+		if err := monitor.checkNewCertificates(logClient); err != nil {
+			t.Fatalf("checkNewCertificates() error: %v", err)
+		}
+	}
+	want := [][2]int64{{0, 599}, {600, 1199}, {1200, 1649}, {1650, 1986}, {1987, 2239}, {2240, 2429}, {2430, 2571}, {2572, 2678}}
+	if fmt.Sprint(api.ranges) != fmt.Sprint(want) {
+		t.Fatalf("Expected catch-up ranges %v, got %v", want, api.ranges)
+	}
+
+	// Caught up, polls request small batches of what is new
+	logClient.lastIndex = 2990
+	api.ranges = nil
+	for i := 0; i < 2; i++ {
+		if err := monitor.checkNewCertificates(logClient); err != nil {
+			t.Fatalf("checkNewCertificates() error: %v", err)
+		}
+	}
+	want = [][2]int64{{2990, 2999}}
+	if fmt.Sprint(api.ranges) != fmt.Sprint(want) || logClient.lastIndex != 3000 {
+		t.Errorf("Expected steady-state ranges %v ending at 3000, got %v ending at %d", want, api.ranges, logClient.lastIndex)
+	}
+}
+
+func TestBatchSizeAfterFailure(t *testing.T) {
+	monitor := NewMonitor()
+	logClient := &CTLogClient{name: "synthetic log"}
+	if got := monitor.batchSize(logClient, 100000); got != defaultMaxBatchSize {
+		t.Errorf("Expected the default maximum far behind, got %d", got)
+	}
+	logClient.failures = 1
+	if got := monitor.batchSize(logClient, 100000); got != minBatchSize {
+		t.Errorf("Expected %d after a failed poll, got %d", minBatchSize, got)
+	}
+}
+
+func TestCheckNewCertificatesDetectsMatches(t *testing.T) {
+	api := newFakeLogAPI(t,
+		newTestCertificate(t, "www.example.org"),
+		newTestCertificate(t, "mail.example.com"),
+		newTestCertificate(t, "example.com.attacker.net"),
+		newTestCertificate(t, "cdn.other.net", "example.com"),
+	)
+	logClient := &CTLogClient{client: api, name: "synthetic log", lastIndex: 1}
+
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+
+	if err := monitor.checkNewCertificates(logClient); err != nil {
+		t.Fatalf("checkNewCertificates() error: %v", err)
+	}
+	if logClient.lastIndex != 4 {
+		t.Errorf("Expected lastIndex 4, got %d", logClient.lastIndex)
+	}
+
+	var matched []string
+	for _, entry := range handler.entries {
+		if entry.Domain != "example.com" {
+			t.Errorf("Expected matches for example.com, got %q", entry.Domain)
+		}
+		matched = append(matched, entry.LeafCert.Subject.CommonName)
+	}
+	if want := []string{"mail.example.com", "cdn.other.net"}; fmt.Sprint(matched) != fmt.Sprint(want) {
+		t.Errorf("Expected matches %v, got %v", want, matched)
+	}
+}
+
+func TestStartContextStopsPolling(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.ctClients = []*CTLogClient{{client: newFakeLogAPI(t), name: "fake log", lastIndex: -1}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- monitor.StartContext(ctx) }()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("StartContext() error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Polling did not stop when its context was cancelled")
+	}
+}
+
+func TestPollingDetailsLoggedAtDebug(t *testing.T) {
+	api := newFakeLogAPI(t, newTestCertificate(t, "www.example.org"), newTestCertificate(t, "mail.example.com"))
+	logClient := &CTLogClient{client: api, name: "synthetic log", lastIndex: 0}
+
+	var buf bytes.Buffer
+	monitor := NewMonitor()
+	monitor.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	monitor.AddDomain("example.com", true)
+
+	if err := monitor.checkNewCertificates(logClient); err != nil {
+		t.Fatalf("checkNewCertificates() error: %v", err)
+	}
+	output := buf.String()
+	if strings.Contains(output, "Checking certificates") {
+		t.Errorf("Expected per-batch details to be hidden at info level, got %q", output)
+	}
+	if !strings.Contains(output, `level=INFO msg="Found matching certificate" domain=example.com`) {
+		t.Errorf("Expected the match to be logged at info level, got %q", output)
+	}
+}
@@ -0,0 +1,287 @@
+package certwatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lastSeenSaveInterval is the minimum time between state writes caused by
+// matches alone, so a burst of certificates doesn't rewrite the file for
+// each one.
+const lastSeenSaveInterval = 30 * time.Second
+
+// pollState is the on-disk record of how far each CT log has been read,
+// keyed by log URL, and of when each watched domain last matched.
+type pollState struct {
+	LastIndex map[string]int64     `json:"last_index"`
+	LastSeen  map[string]time.Time `json:"last_seen,omitempty"`
+}
+
+// pollStateStore loads and saves poll progress. The per-log goroutines each
+// record their own index; the whole map is rewritten after every batch.
+// Last-seen times are written along with it, or on their own at most every
+// lastSeenSaveInterval.
+type pollStateStore struct {
+	path     string
+	mutex    sync.Mutex
+	indexes  map[string]int64
+	lastSeen map[string]time.Time
+	saved    time.Time // when the file was last written
+	dirty    bool      // last-seen times changed since then
+}
+
+func loadPollState(path string) (*pollStateStore, error) {
+	store := &pollStateStore{path: path, indexes: make(map[string]int64), lastSeen: make(map[string]time.Time)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read poll state: %w", err)
+	}
+
+	state, err := decodePollState(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w (fix or delete it to start over)", path, err)
+	}
+	for url, index := range state.LastIndex {
+		store.indexes[url] = index
+	}
+	for domain, seen := range state.LastSeen {
+		store.lastSeen[domain] = seen
+	}
+	return store, nil
+}
+
+// decodePollState parses a state file strictly, as it may have been edited
+// by hand: unknown fields are refused and errors name the field, CT log or
+// domain at fault and what was expected.
+func decodePollState(data []byte) (pollState, error) {
+	var state pollState
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line := 1 + strings.Count(string(data[:syntaxErr.Offset]), "\n")
+			return state, fmt.Errorf("line %d: %w", line, err)
+		}
+		return state, fmt.Errorf("expected an object with last_index and last_seen")
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name != "last_index" && name != "last_seen" {
+			return state, fmt.Errorf("unknown field %q (expected last_index or last_seen)", name)
+		}
+	}
+
+	if raw, ok := fields["last_index"]; ok {
+		var indexes map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &indexes); err != nil {
+			return state, fmt.Errorf("last_index: expected an object mapping CT log URLs to entry indexes")
+		}
+		state.LastIndex = make(map[string]int64, len(indexes))
+		for url, value := range indexes {
+			var index int64
+			if err := json.Unmarshal(value, &index); err != nil || index < 0 || string(value) == "null" {
+				return state, fmt.Errorf("last_index[%q]: expected a non-negative integer, got %s", url, value)
+			}
+			state.LastIndex[url] = index
+		}
+	}
+
+	if raw, ok := fields["last_seen"]; ok {
+		var times map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &times); err != nil {
+			return state, fmt.Errorf("last_seen: expected an object mapping domains to times")
+		}
+		state.LastSeen = make(map[string]time.Time, len(times))
+		for domain, value := range times {
+			var seen time.Time
+			if domain == "" {
+				return state, fmt.Errorf("last_seen: empty domain")
+			}
+			if err := json.Unmarshal(value, &seen); err != nil || string(value) == "null" {
+				return state, fmt.Errorf("last_seen[%q]: expected an RFC 3339 time such as \"2024-01-02T15:04:05Z\", got %s", domain, value)
+			}
+			state.LastSeen[domain] = seen
+		}
+	}
+	return state, nil
+}
+
+// lastIndex returns the saved index for a log, if any.
+func (s *pollStateStore) lastIndex(url string) (int64, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	index, ok := s.indexes[url]
+	return index, ok
+}
+
+// save records index for a log and writes the state file atomically.
+func (s *pollStateStore) save(url string, index int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.indexes[url] = index
+	return s.write()
+}
+
+// lastSeenAt returns the saved last match time for a domain, if any.
+func (s *pollStateStore) lastSeenAt(domain string) (time.Time, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	seen, ok := s.lastSeen[domain]
+	return seen, ok
+}
+
+// lastSeenTimes returns a copy of the saved last match times.
+func (s *pollStateStore) lastSeenTimes() map[string]time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	times := make(map[string]time.Time, len(s.lastSeen))
+	for domain, seen := range s.lastSeen {
+		times[domain] = seen
+	}
+	return times
+}
+
+// saveLastSeen records when domain last matched, writing the state file
+// unless it was written within lastSeenSaveInterval; flush writes what is
+// left.
+func (s *pollStateStore) saveLastSeen(domain string, seen time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastSeen[domain] = seen
+	s.dirty = true
+	if time.Since(s.saved) < lastSeenSaveInterval {
+		return nil
+	}
+	return s.write()
+}
+
+// flush writes last-seen times not yet saved.
+func (s *pollStateStore) flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	return s.write()
+}
+
+// write saves the state file atomically. The caller holds s.mutex.
+func (s *pollStateStore) write() error {
+	data, err := json.MarshalIndent(pollState{LastIndex: s.indexes, LastSeen: s.lastSeen}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal poll state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create poll state directory: %w", err)
+	}
+	if err := os.WriteFile(s.path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write poll state: %w", err)
+	}
+	if err := os.Rename(s.path+".tmp", s.path); err != nil {
+		return fmt.Errorf("failed to finalize poll state: %w", err)
+	}
+	s.saved = time.Now()
+	s.dirty = false
+	return nil
+}
+
+// SetStateFile persists each CT log's polling position to path, so a
+// restarted monitor resumes where it stopped instead of re-scanning recent
+// entries and missing those logged while it was down. Logs with no saved
+// position start 100 entries behind the tree head as before. The time each
+// watched domain last matched is kept there too, and restored to its
+// DomainWatch.LastSeen.
+func (m *Monitor) SetStateFile(path string) error {
+	if path == "" {
+		m.pollState = nil
+		return nil
+	}
+	store, err := loadPollState(path)
+	if err != nil {
+		return err
+	}
+	m.pollState = store
+
+	m.mutex.Lock()
+	for domain, config := range m.watchedDomains {
+		if seen, ok := store.lastSeenAt(domain); ok {
+			updated := *config
+			updated.LastSeen = seen
+			m.watchedDomains[domain] = &updated
+		}
+	}
+	m.mutex.Unlock()
+	return nil
+}
+
+// SavedLastSeen returns when each domain last matched according to the
+// state file, including domains no longer watched. It is empty without a
+// state file.
+func (m *Monitor) SavedLastSeen() map[string]time.Time {
+	if m.pollState == nil {
+		return map[string]time.Time{}
+	}
+	return m.pollState.lastSeenTimes()
+}
+
+// savedLastSeen returns the saved last match time for domain, or the zero
+// time.
+func (m *Monitor) savedLastSeen(domain string) time.Time {
+	if m.pollState == nil {
+		return time.Time{}
+	}
+	seen, _ := m.pollState.lastSeenAt(domain)
+	return seen
+}
+
+// savePollState records a log's position after a batch; failures are logged
+// since polling can carry on without them.
+func (m *Monitor) savePollState(logClient *CTLogClient) {
+	if m.pollState == nil {
+		return
+	}
+	if err := m.pollState.save(logClient.url, logClient.lastIndex); err != nil {
+		m.logger.Error("Failed to save poll state", "log", logClient.name, "error", err)
+	}
+}
+
+// saveLastSeen persists a domain's last match time; failures are logged
+// since matching can carry on without them.
+func (m *Monitor) saveLastSeen(domain string, seen time.Time) {
+	if m.pollState == nil {
+		return
+	}
+	if err := m.pollState.saveLastSeen(domain, seen); err != nil {
+		m.logger.Error("Failed to save last seen time", "domain", domain, "error", err)
+	}
+}
+
+// flushPollState writes last-seen times held back by the save interval.
+func (m *Monitor) flushPollState() {
+	if m.pollState == nil {
+		return
+	}
+	if err := m.pollState.flush(); err != nil {
+		m.logger.Error("Failed to save poll state", "error", err)
+	}
+}
@@ -0,0 +1,70 @@
+package certwatch
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"domain_watcher/pkg/models"
+	"encoding/asn1"
+	"fmt"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// parseTBSCertificate parses the TBSCertificate a CT log records for a
+// precertificate. crypto/x509 only parses whole certificates, so the TBS is
+// wrapped in one using its own signature algorithm and an empty signature;
+// everything but the signature is then available as usual.
+func parseTBSCertificate(tbs []byte) (*x509.Certificate, error) {
+	var fields struct {
+		Version            int `asn1:"optional,explicit,default:0,tag:0"`
+		SerialNumber       asn1.RawValue
+		SignatureAlgorithm asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(tbs, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse TBSCertificate: %w", err)
+	}
+
+	der, err := asn1.Marshal(struct {
+		TBSCertificate     asn1.RawValue
+		SignatureAlgorithm asn1.RawValue
+		Signature          asn1.BitString
+	}{asn1.RawValue{FullBytes: tbs}, fields.SignatureAlgorithm, asn1.BitString{}})
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// entryType names a CT log entry type as in models.CertificateEntry, or
+// returns "" for an unknown type.
+func entryType(logEntryType ct.LogEntryType) string {
+	switch logEntryType {
+	case ct.X509LogEntryType:
+		return models.EntryTypeX509
+	case ct.PrecertLogEntryType:
+		return models.EntryTypePrecert
+	}
+	return ""
+}
+
+// liveEntryType maps certstream's update_type, "X509LogEntry" or
+// "PrecertLogEntry", to an entry type, or returns "" for anything else.
+func liveEntryType(updateType string) string {
+	switch updateType {
+	case "X509LogEntry":
+		return models.EntryTypeX509
+	case "PrecertLogEntry":
+		return models.EntryTypePrecert
+	}
+	return ""
+}
+
+// precertFingerprint returns the SHA-256 fingerprint of the precertificate
+// submitted to the log, or "" when the entry does not include it.
+func precertFingerprint(entry *ct.LogEntry) string {
+	if entry.Precert == nil || len(entry.Precert.Submitted.Data) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(entry.Precert.Submitted.Data)
+	return formatFingerprint(sum[:])
+}
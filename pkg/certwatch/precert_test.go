@@ -0,0 +1,44 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/jsonq"
+)
+
+func TestEntryTypeTagged(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+	monitor.SetDedupeWindow(0)
+
+	der := newTestCertificate(t, "www.example.com", "www.example.com")
+	logClient := &CTLogClient{name: "test log"}
+	monitor.processCTEntry(newTestPrecertEntry(t, der, time.Now()), 0, logClient)
+	monitor.processCTEntry(newTestLogEntry(der, time.Now()), 1, logClient)
+	for _, updateType := range []string{"PrecertLogEntry", "X509LogEntry", ""} {
+		monitor.processLiveEvent(jsonq.NewQuery(map[string]interface{}{
+			"message_type": "certificate_update",
+			"data": map[string]interface{}{
+				"update_type": updateType,
+				"leaf_cert": map[string]interface{}{
+					"subject":     map[string]interface{}{"CN": "live.example.com"},
+					"all_domains": []interface{}{"live.example.com"},
+				},
+			},
+		}))
+	}
+
+	want := []string{models.EntryTypePrecert, models.EntryTypeX509, models.EntryTypePrecert, models.EntryTypeX509, ""}
+	if len(handler.entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %d", len(want), len(handler.entries))
+	}
+	for i, entryType := range want {
+		if got := handler.entries[i].EntryType; got != entryType {
+			t.Errorf("Entry %d: expected entry type %q, got %q", i, entryType, got)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package certwatch
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// ValidateWatchDomain rejects watching a bare public suffix such as "co.uk"
+// or "github.io", or a wildcard directly below one, which would match every
+// domain registered under it.
+func ValidateWatchDomain(domain string) error {
+	name := strings.TrimPrefix(aLabelDomain(domain), "*.")
+	if name == "" {
+		return fmt.Errorf("empty domain")
+	}
+	if suffix, _ := publicsuffix.PublicSuffix(name); suffix == name {
+		return fmt.Errorf("%s is a public suffix; watch a domain registered under it instead", domain)
+	}
+	return nil
+}
+
+// SetRegistrableMatch makes a watched domain match every certificate name
+// with the same registrable domain (eTLD+1, from the public suffix list), so
+// watching example.com or www.example.com matches login.example.com and
+// example.com alike, and watching example.co.uk does not match
+// example.org.uk. Such matches have the reason "registrable". Wildcard
+// watches keep their one-label meaning.
+func (m *Monitor) SetRegistrableMatch(enabled bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.registrableMatch = enabled
+}
+
+// sameRegistrableDomain reports whether two names share a registrable
+// domain. Names that are public suffixes have none and never match.
+func sameRegistrableDomain(certDomain, watchedDomain string) bool {
+	certRegistrable, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimPrefix(certDomain, "*."))
+	if err != nil {
+		return false
+	}
+	watchedRegistrable, err := publicsuffix.EffectiveTLDPlusOne(watchedDomain)
+	return err == nil && certRegistrable == watchedRegistrable
+}
@@ -0,0 +1,76 @@
+package certwatch
+
+import "testing"
+
+func TestValidateWatchDomain(t *testing.T) {
+	tests := []struct {
+		domain  string
+		wantErr bool
+	}{
+		{"example.com", false},
+		{"example.co.uk", false},
+		{"shop.example.co.uk", false},
+		{"myproject.github.io", false},
+		{"*.corp.example.com", false},
+		{"com", true},
+		{"co.uk", true},
+		{"CO.UK", true},
+		{"github.io", true},
+		{"*.co.uk", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateWatchDomain(tt.domain); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateWatchDomain(%q) = %v, wantErr %v", tt.domain, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAddDomainRefusesPublicSuffix(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("co.uk", true)
+	monitor.AddDomain("github.io", true)
+	if watched := monitor.GetWatchedDomains(); len(watched) != 0 {
+		t.Errorf("Expected public suffixes to be refused, got %v", watched)
+	}
+
+	if _, _, ok := monitor.MatchCertificate([]string{"example.co.uk", "someone.github.io"}); ok {
+		t.Error("Expected nothing to match")
+	}
+}
+
+func TestRegistrableMatch(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("www.example.co.uk", false)
+	monitor.AddDomain("myproject.github.io", false)
+
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"login.example.co.uk", true},
+		{"example.co.uk", true},
+		{"*.api.example.co.uk", true},
+		{"example.org.uk", false},
+		{"other.co.uk", false},
+		{"docs.myproject.github.io", true},
+		{"otherproject.github.io", false},
+		{"github.io", false},
+	}
+
+	for _, tt := range tests {
+		if _, _, ok := monitor.MatchCertificate([]string{tt.name}); ok {
+			t.Errorf("%s: expected no match without registrable matching", tt.name)
+		}
+	}
+
+	monitor.SetRegistrableMatch(true)
+	for _, tt := range tests {
+		_, reason, ok := monitor.MatchCertificate([]string{tt.name})
+		if ok != tt.expected {
+			t.Errorf("%s: got match %v, expected %v", tt.name, ok, tt.expected)
+		}
+		if ok && reason != "registrable" {
+			t.Errorf("%s: expected reason registrable, got %s", tt.name, reason)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package certwatch
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"golang.org/x/time/rate"
+)
+
+// rateLimitRetries is how many times a request answered with 429 Too Many
+// Requests is retried.
+const rateLimitRetries = 3
+
+// SetLogRateLimit throttles requests to each CT log to rps per second, so
+// polling stays under the logs' rate limits. It applies to logs set up when
+// the monitor starts; zero disables throttling.
+func (m *Monitor) SetLogRateLimit(rps float64) {
+	m.logRate = rps
+}
+
+// newLogLimiter returns the limiter for one CT log, or nil when requests are
+// not throttled.
+func (m *Monitor) newLogLimiter() *rate.Limiter {
+	if m.logRate <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(m.logRate), max(1, int(m.logRate)))
+}
+
+func isRateLimited(err error) bool {
+	var rspErr jsonclient.RspError
+	return errors.As(err, &rspErr) && rspErr.StatusCode == http.StatusTooManyRequests
+}
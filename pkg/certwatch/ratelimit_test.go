@@ -0,0 +1,89 @@
+package certwatch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/jsonclient"
+)
+
+func TestLogRateLimit(t *testing.T) {
+	monitor := NewMonitor()
+	if monitor.newLogLimiter() != nil {
+		t.Fatal("Expected no limiter by default")
+	}
+
+	monitor.SetLogRateLimit(50)
+	logClient := &CTLogClient{name: "test log", limiter: monitor.newLogLimiter()}
+
+	// The burst of 50 is free, the next 10 requests are spaced 20ms apart
+	start := time.Now()
+	for i := 0; i < 60; i++ {
+		monitor.logRequest(logClient, func() error { return nil })
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected requests to be throttled, 60 took %v", elapsed)
+	}
+}
+
+func TestLogRequestRetriesRateLimitedResponses(t *testing.T) {
+	var requests atomic.Int32
+	fake := &fakeCTLog{t: t, certs: [][]byte{newTestCertificate(t, "www.example.com")}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every other request is rate limited
+		if requests.Add(1)%2 == 1 {
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	ctClient, err := client.New(server.URL, server.Client(), jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("client.New() error: %v", err)
+	}
+	logClient := &CTLogClient{client: ctClient, url: server.URL, name: "fake log", backoff: time.Millisecond}
+
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+
+	if err := monitor.checkNewCertificates(logClient); err != nil {
+		t.Fatalf("checkNewCertificates() error: %v", err)
+	}
+	if len(handler.entries) != 1 || logClient.lastIndex != 1 {
+		t.Errorf("Expected the batch to succeed after retries, got %d entries and lastIndex %d", len(handler.entries), logClient.lastIndex)
+	}
+	if got := requests.Load(); got != 4 {
+		t.Errorf("Expected 2 rate-limited and 2 successful requests, got %d", got)
+	}
+}
+
+func TestLogRequestGivesUpOnPersistentRateLimit(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctClient, err := client.New(server.URL, server.Client(), jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("client.New() error: %v", err)
+	}
+	logClient := &CTLogClient{client: ctClient, url: server.URL, name: "fake log", backoff: time.Millisecond}
+
+	monitor := NewMonitor()
+	if err := monitor.checkNewCertificates(logClient); !isRateLimited(err) {
+		t.Errorf("Expected a rate limit error, got %v", err)
+	}
+	if got := requests.Load(); got != rateLimitRetries+1 {
+		t.Errorf("Expected %d attempts, got %d", rateLimitRetries+1, got)
+	}
+}
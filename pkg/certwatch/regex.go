@@ -0,0 +1,45 @@
+package certwatch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AddDomainRegex watches for certificate names matching a regular
+// expression, e.g. `^login-.*-mybank\.com$` to follow a phishing campaign.
+// Names are lowercased before matching and the pattern is not anchored
+// implicitly. Matches are reported with the pattern as the entry's domain.
+func (m *Monitor) AddDomainRegex(pattern string) error {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return fmt.Errorf("empty domain pattern")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid domain pattern %q: %w", pattern, err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, existing := range m.patterns {
+		if existing.String() == re.String() {
+			return nil
+		}
+	}
+	m.patterns = append(m.patterns, re)
+	return nil
+}
+
+// matchRegex returns the first pattern matching one of the names. Callers
+// must hold m.mutex.
+func (m *Monitor) matchRegex(domains []string) (string, bool) {
+	for _, re := range m.patterns {
+		for _, domain := range domains {
+			if re.MatchString(strings.ToLower(strings.TrimSpace(domain))) {
+				return re.String(), true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,55 @@
+package certwatch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddDomainRegexRejectsInvalidPattern(t *testing.T) {
+	monitor := NewMonitor()
+	if err := monitor.AddDomainRegex("login-(.*"); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+	if err := monitor.AddDomainRegex("  "); err == nil {
+		t.Error("Expected an error for an empty pattern")
+	}
+	if len(monitor.patterns) != 0 {
+		t.Errorf("Expected no patterns to be stored, got %d", len(monitor.patterns))
+	}
+}
+
+func TestDomainRegexMatching(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	if err := monitor.AddDomainRegex(`^login-.*-mybank\.com$`); err != nil {
+		t.Fatalf("AddDomainRegex() error: %v", err)
+	}
+
+	tests := []struct {
+		domains        []string
+		expectedMatch  string
+		expectedReason string
+		expectedOK     bool
+	}{
+		{[]string{"login-secure-mybank.com"}, `^login-.*-mybank\.com$`, "regex", true},
+		{[]string{"www.other.net", "LOGIN-x-MyBank.com"}, `^login-.*-mybank\.com$`, "regex", true},
+		{[]string{"login-mybank.com"}, "", "", false},
+		{[]string{"login-x-mybank.com.evil.net"}, "", "", false},
+		{[]string{"www.example.com"}, "example.com", "subdomain", true},
+	}
+	for _, tt := range tests {
+		matched, reason, ok := monitor.MatchCertificate(tt.domains)
+		if matched != tt.expectedMatch || reason != tt.expectedReason || ok != tt.expectedOK {
+			t.Errorf("MatchCertificate(%v) = %q, %q, %v; expected %q, %q, %v",
+				tt.domains, matched, reason, ok, tt.expectedMatch, tt.expectedReason, tt.expectedOK)
+		}
+	}
+
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	loggedAt := monitor.startedAt.Add(time.Second)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "login-verify-mybank.com"), loggedAt), 1, &CTLogClient{name: "test log"})
+	if len(handler.entries) != 1 || handler.entries[0].Domain != `^login-.*-mybank\.com$` {
+		t.Errorf("Expected the regex match to be dispatched, got %d entries", len(handler.entries))
+	}
+}
@@ -0,0 +1,45 @@
+package certwatch
+
+import "domain_watcher/pkg/models"
+
+// Replay runs a stored entry through the current matching, filters and
+// handlers as if it had just been seen, to backtest new rules or handlers
+// against archived output. The entry's match fields are recomputed from its
+// names; the rest, including Timestamp, is kept. It reports whether the entry
+// matched, though the issuer, validity and other filters may still drop it.
+func (m *Monitor) Replay(entry *models.CertificateEntry) bool {
+	names := uniqueNames(entry.Subdomains)
+	if len(names) == 0 {
+		if cn := entry.LeafCert.Subject.CommonName; cn != "" {
+			names = append(names, cn)
+		}
+		names = uniqueNames(append(names, entry.LeafCert.Extensions.SubjectAltName...))
+	}
+	if len(names) == 0 && len(entry.IPAddresses) == 0 {
+		return false
+	}
+
+	m.stats.recordSeen()
+	matchedDomain, reason, ok := m.matchCertificateIPs(names, entry.IPAddresses)
+	if !ok {
+		return false
+	}
+
+	replayed := *entry
+	replayed.Domain = matchedDomain
+	replayed.Subdomains = names
+	replayed.MatchedNames = m.matchedNames(matchNames(names, entry.IPAddresses, reason), matchedDomain, reason)
+	replayed.Wildcard = hasWildcardName(replayed.MatchedNames)
+	replayed.Lookalike = lookalikeKind(reason)
+	replayed.Keyword = ""
+	replayed.Expiry = nil
+	if m.canonIssuer && replayed.LeafCert.IssuerCanonical == "" {
+		replayed.LeafCert.IssuerCanonical = CanonicalIssuer(replayed.LeafCert.IssuerDistinguishedName, replayed.LeafCert.IssuerOrganization)
+	}
+	if replayed.IdempotencyKey == "" {
+		replayed.IdempotencyKey = replayed.ComputeIdempotencyKey()
+	}
+
+	m.dispatch(&replayed, false)
+	return true
+}
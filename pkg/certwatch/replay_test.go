@@ -0,0 +1,48 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"testing"
+	"time"
+)
+
+func TestReplayAppliesCurrentRules(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+	monitor.SetIssuerFilter(nil, []string{"untrusted ca"})
+
+	stored := func(fingerprint, issuer string, names ...string) *models.CertificateEntry {
+		return &models.CertificateEntry{
+			Domain:     "old-watch.example",
+			Subdomains: names,
+			LeafCert: models.LeafCertificate{
+				Fingerprint:             fingerprint,
+				IssuerDistinguishedName: issuer,
+				NotBefore:               time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				NotAfter:                time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			},
+			Timestamp: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		}
+	}
+
+	if !monitor.Replay(stored("AA", "R3", "www.other.net", "login.example.com")) {
+		t.Error("Expected login.example.com to match the current watch list")
+	}
+	if monitor.Replay(stored("BB", "R3", "www.other.net")) {
+		t.Error("Expected an unwatched certificate not to match")
+	}
+	monitor.Replay(stored("CC", "Untrusted CA", "api.example.com"))
+
+	if len(handler.entries) != 1 {
+		t.Fatalf("Expected only the trusted match to reach handlers, got %d", len(handler.entries))
+	}
+	entry := handler.entries[0]
+	if entry.Domain != "example.com" || len(entry.MatchedNames) != 1 || entry.MatchedNames[0] != "login.example.com" {
+		t.Errorf("Expected the match to be recomputed, got domain %s and names %v", entry.Domain, entry.MatchedNames)
+	}
+	if !entry.Timestamp.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the stored timestamp to be kept, got %v", entry.Timestamp)
+	}
+}
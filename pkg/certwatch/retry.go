@@ -0,0 +1,146 @@
+package certwatch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/jsonclient"
+)
+
+const (
+	// logRequestAttempts is how many times a request that fails with a
+	// transient error is tried before the poll gives up on it.
+	logRequestAttempts = 3
+
+	// logRetryBackoff is the delay before the first retry of a failed
+	// request; it doubles on each one.
+	logRetryBackoff = 2 * time.Second
+
+	// persistentFailures is how many polls in a row must fail before a log
+	// is reported as degraded, or before an entry that cannot be fetched on
+	// its own is skipped.
+	persistentFailures = 3
+)
+
+// logRequest makes a request to a CT log once its rate limiter allows it.
+// Transient failures are retried after the log client's backoff, up to
+// logRequestAttempts in all; 429 responses are retried rateLimitRetries
+// times. Each retry goes through the limiter again.
+func (m *Monitor) logRequest(logClient *CTLogClient, request func() error) error {
+	delay := logClient.backoff
+	for attempt := 0; ; attempt++ {
+		if logClient.limiter != nil {
+			if err := logClient.limiter.Wait(m.ctx); err != nil {
+				return err
+			}
+		}
+
+		err := request()
+		if err == nil || m.ctx.Err() != nil {
+			return err
+		}
+
+		retries, reason := logRequestAttempts-1, "request failed"
+		if isRateLimited(err) {
+			retries, reason = rateLimitRetries, "rate limited by the log"
+		} else if !isTransient(err) {
+			return err
+		}
+		if attempt >= retries {
+			return err
+		}
+
+		m.logger.Debug("CT log request failed, retrying", "log", logClient.name, "reason", reason, "error", err, "delay", delay)
+		select {
+		case <-m.ctx.Done():
+			return m.ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// isTransient reports whether a failed request may succeed if retried:
+// network errors and 5xx responses are, other HTTP errors are not.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var rspErr jsonclient.RspError
+	if errors.As(err, &rspErr) {
+		return rspErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// getEntries fetches entries from the log client's lastIndex up to endIndex
+// (exclusive). When the range keeps failing it is halved until a fetch
+// succeeds, so one bad entry does not stall the whole log; the returned end
+// is where the fetched range stops. An entry that still cannot be fetched
+// on its own for persistentFailures polls in a row is skipped.
+func (m *Monitor) getEntries(logClient *CTLogClient, endIndex int64) (*ct.GetEntriesResponse, int64, error) {
+	start := logClient.lastIndex
+	for {
+		var resp *ct.GetEntriesResponse
+		err := m.logRequest(logClient, func() (err error) {
+			resp, err = logClient.client.GetRawEntries(m.ctx, start, endIndex-1)
+			return err
+		})
+		if err == nil {
+			if logClient.badIndexFailures > 0 && logClient.badIndex == start {
+				logClient.badIndexFailures = 0
+			}
+			return resp, endIndex, nil
+		}
+		if m.ctx.Err() != nil || !isTransient(err) {
+			return nil, endIndex, err
+		}
+
+		if endIndex-start > 1 {
+			endIndex = start + (endIndex-start)/2
+			m.logger.Debug("Failed to get entries, narrowing the range", "log", logClient.name, "error", err, "start", start, "end", endIndex-1)
+			continue
+		}
+
+		if logClient.badIndexFailures > 0 && logClient.badIndex == start {
+			logClient.badIndexFailures++
+		} else {
+			logClient.badIndex, logClient.badIndexFailures = start, 1
+		}
+		if logClient.badIndexFailures >= persistentFailures {
+			m.logger.Warn("Skipping entry that keeps failing to fetch", "log", logClient.name, "index", start,
+				"failed_polls", logClient.badIndexFailures, "error", err)
+			logClient.lastIndex = start + 1
+			logClient.badIndexFailures = 0
+			m.savePollState(logClient)
+		}
+		return nil, endIndex, err
+	}
+}
+
+// recordPollResult tracks polls of a log that fail in a row, logging when
+// the log keeps failing, since its certificates are not seen meanwhile, and
+// when it recovers.
+func (m *Monitor) recordPollResult(logClient *CTLogClient, err error) {
+	defer m.health.record(logClient, err)
+
+	if err == nil {
+		if logClient.failures >= persistentFailures {
+			m.logger.Info("CT log recovered", "log", logClient.name, "failed_polls", logClient.failures)
+		}
+		logClient.failures = 0
+		return
+	}
+	if m.ctx.Err() != nil {
+		return
+	}
+
+	logClient.failures++
+	if logClient.failures == persistentFailures || logClient.failures%(10*persistentFailures) == 0 {
+		m.logger.Warn("CT log keeps failing, coverage is degraded until it recovers", "log", logClient.name,
+			"failed_polls", logClient.failures, "error", err)
+	}
+}
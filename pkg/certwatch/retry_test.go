@@ -0,0 +1,101 @@
+package certwatch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/certificate-transparency-go/jsonclient"
+)
+
+func TestCheckNewCertificatesRetriesTransientErrors(t *testing.T) {
+	api := newFakeLogAPI(t, newTestCertificate(t, "www.example.com"), newTestCertificate(t, "api.example.com"))
+	api.sthFailures = logRequestAttempts - 1
+	api.entriesFailures = logRequestAttempts - 1
+	logClient := &CTLogClient{client: api, name: "flaky log"}
+
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+
+	if err := monitor.checkNewCertificates(logClient); err != nil {
+		t.Fatalf("checkNewCertificates() error: %v", err)
+	}
+	if len(handler.entries) != 2 || logClient.lastIndex != 2 {
+		t.Errorf("Expected the batch to succeed after retries, got %d entries and lastIndex %d", len(handler.entries), logClient.lastIndex)
+	}
+
+	api.sthFailures = logRequestAttempts
+	if err := monitor.checkNewCertificates(logClient); err == nil {
+		t.Error("Expected an error once every attempt fails")
+	}
+	if logClient.failures != 1 {
+		t.Errorf("Expected 1 failed poll to be counted, got %d", logClient.failures)
+	}
+}
+
+func TestCheckNewCertificatesNarrowsAroundBadEntry(t *testing.T) {
+	api := newFakeLogAPI(t,
+		newTestCertificate(t, "a.example.com"),
+		newTestCertificate(t, "b.example.com"),
+		newTestCertificate(t, "c.example.com"),
+		newTestCertificate(t, "d.example.com"),
+	)
+	api.badIndex = 2
+	logClient := &CTLogClient{client: api, name: "flaky log"}
+
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+
+	// The first poll narrows the batch to the entries before the bad one
+	if err := monitor.checkNewCertificates(logClient); err != nil {
+		t.Fatalf("checkNewCertificates() error: %v", err)
+	}
+	if len(handler.entries) != 2 || logClient.lastIndex != 2 {
+		t.Fatalf("Expected entries before the bad one, got %d entries and lastIndex %d", len(handler.entries), logClient.lastIndex)
+	}
+
+	// The bad entry is retried on the next polls, then skipped
+	for poll := 1; poll <= persistentFailures; poll++ {
+		if err := monitor.checkNewCertificates(logClient); err == nil {
+			t.Fatalf("Poll %d: expected the bad entry to fail", poll)
+		}
+		if poll < persistentFailures && logClient.lastIndex != 2 {
+			t.Fatalf("Poll %d: bad entry skipped too early", poll)
+		}
+	}
+	if logClient.lastIndex != 3 || logClient.failures != persistentFailures {
+		t.Fatalf("Expected the bad entry to be skipped after %d failed polls, got lastIndex %d and %d failures",
+			persistentFailures, logClient.lastIndex, logClient.failures)
+	}
+
+	if err := monitor.checkNewCertificates(logClient); err != nil {
+		t.Fatalf("checkNewCertificates() error: %v", err)
+	}
+	if len(handler.entries) != 3 || logClient.lastIndex != 4 || logClient.failures != 0 {
+		t.Errorf("Expected polling to resume after the bad entry, got %d entries, lastIndex %d and %d failures",
+			len(handler.entries), logClient.lastIndex, logClient.failures)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection reset by peer"), true},
+		{jsonclient.RspError{StatusCode: http.StatusServiceUnavailable}, true},
+		{jsonclient.RspError{StatusCode: http.StatusBadRequest}, false},
+		{jsonclient.RspError{StatusCode: http.StatusTooManyRequests}, false},
+		{context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"hash/fnv"
+	"math"
+)
+
+// SetSampleRate keeps only fraction (0 < fraction < 1) of the certificates
+// matched in all-domains mode, e.g. 0.01 for a 1% slice of the firehose. The
+// choice hashes the certificate fingerprint, so the same certificate is kept
+// or dropped consistently across logs, restarts and monitors. Any other
+// fraction disables sampling. Watched-domain matches are never sampled.
+func (m *Monitor) SetSampleRate(fraction float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.sampleRate = fraction
+}
+
+// sampled reports whether entry survives sampling.
+func (m *Monitor) sampled(entry *models.CertificateEntry) bool {
+	m.mutex.RLock()
+	rate, allDomains := m.sampleRate, m.allDomainsMode
+	m.mutex.RUnlock()
+
+	if !allDomains || rate <= 0 || rate >= 1 {
+		return true
+	}
+
+	key := entry.LeafCert.Fingerprint
+	if key == "" {
+		key = entry.IdempotencyKey
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return float64(hash.Sum64()) < rate*math.MaxUint64
+}
@@ -0,0 +1,47 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSampleRateKeepsDeterministicFraction(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.SetAllDomainsMode(true)
+	monitor.SetSampleRate(0.1)
+
+	kept := 0
+	for i := 0; i < 10000; i++ {
+		entry := &models.CertificateEntry{LeafCert: models.LeafCertificate{Fingerprint: fmt.Sprintf("cert-%d", i)}}
+		if monitor.sampled(entry) {
+			kept++
+			if !monitor.sampled(entry) {
+				t.Fatalf("Expected %s to be kept consistently", entry.LeafCert.Fingerprint)
+			}
+		}
+	}
+	if kept < 900 || kept > 1100 {
+		t.Errorf("Expected about 10%% of 10000 certificates to be kept, got %d", kept)
+	}
+}
+
+func TestSampleRateOnlyAppliesToAllDomainsMode(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+	monitor.SetSampleRate(0.000001)
+
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.example.com"), time.Now()), 1, &CTLogClient{name: "test log"})
+	if len(handler.entries) != 1 {
+		t.Fatalf("Expected watched-domain matches to bypass sampling, got %d entries", len(handler.entries))
+	}
+
+	monitor.SetAllDomainsMode(true)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "api.example.org"), time.Now()), 2, &CTLogClient{name: "test log"})
+	if len(handler.entries) != 1 {
+		t.Errorf("Expected the all-domains match to be sampled out, got %d entries", len(handler.entries))
+	}
+}
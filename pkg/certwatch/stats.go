@@ -0,0 +1,199 @@
+package certwatch
+
+import (
+	"sync"
+	"time"
+)
+
+// MonitorStats is a point-in-time copy of the monitor's counters. It shares
+// no memory with the monitor and is safe to read or serialize freely.
+type MonitorStats struct {
+	StartedAt        time.Time         `json:"started_at"`
+	CertificatesSeen uint64            `json:"certificates_seen"`
+	Matches          uint64            `json:"matches"`
+	Dispatched       uint64            `json:"dispatched"`
+	HandlerErrors    uint64            `json:"handler_errors"`
+	MatchesByIssuer  map[string]uint64 `json:"matches_by_issuer"`
+	LastMatch        time.Time         `json:"last_match"`
+	LastHeartbeat    time.Time         `json:"last_heartbeat"`
+
+	// MatchesByDomain counts matches per watched domain. It is empty in
+	// all-domains mode, where every certificate's first name would get its
+	// own counter.
+	MatchesByDomain map[string]uint64 `json:"matches_by_domain"`
+
+	// DroppedMessages counts certstream messages that could not be used,
+	// by reason in DroppedByReason. A rising count with no matches usually
+	// means the certstream format has changed.
+	DroppedMessages uint64            `json:"dropped_messages"`
+	DroppedByReason map[string]uint64 `json:"dropped_by_reason"`
+
+	// Paused reports whether dispatch is paused, and PausedDropped counts
+	// matches dropped while it was, see SetPauseBuffer.
+	Paused        bool   `json:"paused"`
+	PausedDropped uint64 `json:"paused_dropped"`
+
+	// LastLiveMessage is when the last certstream message arrived.
+	// LiveDisconnects counts drops of the live stream and
+	// LiveDisconnectedSeconds the time spent without it, including the
+	// current drop if the stream is down.
+	LastLiveMessage         time.Time `json:"last_live_message"`
+	LiveDisconnects         uint64    `json:"live_disconnects"`
+	LiveDisconnectedSeconds float64   `json:"live_disconnected_seconds"`
+}
+
+// monitorStats accumulates counters from the ingestion goroutines.
+type monitorStats struct {
+	mutex            sync.Mutex
+	certificatesSeen uint64
+	matches          uint64
+	dispatched       uint64
+	handlerErrors    uint64
+	byDomain         map[string]uint64
+	byIssuer         map[string]uint64
+	lastMatch        time.Time
+	dropped          uint64
+	droppedByReason  map[string]uint64
+	pausedDropped    uint64
+	lastLiveMessage  time.Time
+	liveDownSince    time.Time // zero while the live stream is up
+	liveDisconnects  uint64
+	liveDisconnected time.Duration
+}
+
+func newMonitorStats() *monitorStats {
+	return &monitorStats{
+		byDomain:        make(map[string]uint64),
+		byIssuer:        make(map[string]uint64),
+		droppedByReason: make(map[string]uint64),
+	}
+}
+
+func (s *monitorStats) recordSeen() {
+	s.mutex.Lock()
+	s.certificatesSeen++
+	s.mutex.Unlock()
+}
+
+// recordMatch counts a match for domain, or only in the totals if domain is
+// empty.
+func (s *monitorStats) recordMatch(domain, issuer string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.matches++
+	if domain != "" {
+		s.byDomain[domain]++
+	}
+	if issuer != "" {
+		s.byIssuer[issuer]++
+	}
+	s.lastMatch = time.Now()
+}
+
+func (s *monitorStats) recordDispatch() {
+	s.mutex.Lock()
+	s.dispatched++
+	s.mutex.Unlock()
+}
+
+func (s *monitorStats) recordHandlerError() {
+	s.mutex.Lock()
+	s.handlerErrors++
+	s.mutex.Unlock()
+}
+
+func (s *monitorStats) recordDropped(reason string) {
+	s.mutex.Lock()
+	s.dropped++
+	s.droppedByReason[reason]++
+	s.mutex.Unlock()
+}
+
+func (s *monitorStats) recordPausedDropped(n int) {
+	s.mutex.Lock()
+	s.pausedDropped += uint64(n)
+	s.mutex.Unlock()
+}
+
+func (s *monitorStats) recordLiveDrop(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.liveDownSince.IsZero() {
+		s.liveDownSince = now
+		s.liveDisconnects++
+	}
+}
+
+// recordLiveMessage notes a live message at now. If the stream was down, it
+// reports the gap since the previous message, or since the drop when there
+// was none, and when that previous message arrived.
+func (s *monitorStats) recordLiveMessage(now time.Time) (gap time.Duration, lastMessage time.Time, resumed bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lastMessage = s.lastLiveMessage
+	s.lastLiveMessage = now
+	if s.liveDownSince.IsZero() {
+		return 0, lastMessage, false
+	}
+
+	s.liveDisconnected += now.Sub(s.liveDownSince)
+	if lastMessage.IsZero() {
+		lastMessage = s.liveDownSince
+	}
+	s.liveDownSince = time.Time{}
+	return now.Sub(lastMessage), lastMessage, true
+}
+
+// snapshot copies the counters under a single lock so they are consistent
+// with each other.
+func (s *monitorStats) snapshot() MonitorStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	byDomain := make(map[string]uint64, len(s.byDomain))
+	for domain, count := range s.byDomain {
+		byDomain[domain] = count
+	}
+	byIssuer := make(map[string]uint64, len(s.byIssuer))
+	for issuer, count := range s.byIssuer {
+		byIssuer[issuer] = count
+	}
+	droppedByReason := make(map[string]uint64, len(s.droppedByReason))
+	for reason, count := range s.droppedByReason {
+		droppedByReason[reason] = count
+	}
+	disconnected := s.liveDisconnected
+	if !s.liveDownSince.IsZero() {
+		disconnected += time.Since(s.liveDownSince)
+	}
+
+	return MonitorStats{
+		CertificatesSeen: s.certificatesSeen,
+		Matches:          s.matches,
+		Dispatched:       s.dispatched,
+		HandlerErrors:    s.handlerErrors,
+		MatchesByDomain:  byDomain,
+		MatchesByIssuer:  byIssuer,
+		LastMatch:        s.lastMatch,
+		DroppedMessages:  s.dropped,
+		DroppedByReason:  droppedByReason,
+		PausedDropped:    s.pausedDropped,
+
+		LastLiveMessage:         s.lastLiveMessage,
+		LiveDisconnects:         s.liveDisconnects,
+		LiveDisconnectedSeconds: disconnected.Seconds(),
+	}
+}
+
+// StatsSnapshot returns a consistent copy of the monitor's counters. Readers
+// such as status output should use it rather than reading fields directly.
+func (m *Monitor) StatsSnapshot() MonitorStats {
+	snapshot := m.stats.snapshot()
+	snapshot.StartedAt = m.startedAt
+	snapshot.LastHeartbeat = m.LastHeartbeat()
+	snapshot.Paused = m.Paused()
+	return snapshot
+}
@@ -0,0 +1,90 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStatsSnapshot(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddHandler(&mockHandler{})
+	monitor.AddDomain("example.com", true)
+
+	logClient := &CTLogClient{name: "test log"}
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.example.com"), time.Now()), 0, logClient)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "other.org"), time.Now()), 1, logClient)
+
+	stats := monitor.StatsSnapshot()
+	if stats.CertificatesSeen != 2 || stats.Matches != 1 || stats.Dispatched != 1 {
+		t.Errorf("Unexpected counters: %+v", stats)
+	}
+	// Test certificates are self-signed, so the issuer is the subject
+	if stats.MatchesByDomain["example.com"] != 1 || stats.MatchesByIssuer["www.example.com"] != 1 {
+		t.Errorf("Unexpected breakdowns: %+v", stats)
+	}
+
+	// The snapshot must not alias live state
+	stats.MatchesByDomain["example.com"] = 100
+	if monitor.StatsSnapshot().MatchesByDomain["example.com"] != 1 {
+		t.Error("Modifying a snapshot changed the monitor's stats")
+	}
+}
+
+// Run with -race to check snapshots against concurrent updates
+func TestStatsSnapshotConcurrent(t *testing.T) {
+	monitor := NewMonitor()
+
+	const writers, perWriter = 4, 250
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				monitor.stats.recordSeen()
+				monitor.dispatch(&models.CertificateEntry{Domain: "example.com"}, false)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			stats := monitor.StatsSnapshot()
+			if stats.Dispatched > stats.Matches || stats.Matches > writers*perWriter {
+				t.Errorf("Torn snapshot: %+v", stats)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	<-done
+
+	stats := monitor.StatsSnapshot()
+	if stats.CertificatesSeen != writers*perWriter || stats.Matches != writers*perWriter {
+		t.Errorf("Expected %d seen and matched, got %+v", writers*perWriter, stats)
+	}
+}
+
+func TestStatsSkipDomainsInAllDomainsMode(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddHandler(&mockHandler{})
+	monitor.SetAllDomainsMode(true)
+
+	logClient := &CTLogClient{name: "test log"}
+	for i, name := range []string{"a.example", "b.example", "c.example"} {
+		monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, name), time.Now()), int64(i), logClient)
+	}
+
+	stats := monitor.StatsSnapshot()
+	if stats.Matches != 3 || len(stats.MatchesByDomain) != 0 {
+		t.Errorf("Expected 3 matches without per-domain counts, got %d and %v", stats.Matches, stats.MatchesByDomain)
+	}
+	if len(stats.MatchesByIssuer) != 3 {
+		t.Errorf("Expected issuers still counted, got %v", stats.MatchesByIssuer)
+	}
+}
@@ -0,0 +1,130 @@
+package certwatch
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps characters that render like ASCII letters to the letter
+// they imitate. It covers the Cyrillic and Greek lookalikes
+// seen in practice rather than the full Unicode confusables table.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'с': 'c', 'ԁ': 'd', 'е': 'e', 'һ': 'h', 'і': 'i',
+	'ј': 'j', 'к': 'k', 'ӏ': 'l', 'м': 'm', 'п': 'n', 'о': 'o', 'р': 'p',
+	'ԛ': 'q', 'г': 'r', 'ѕ': 's', 'т': 't', 'ц': 'u', 'ѵ': 'v', 'ԝ': 'w',
+	'х': 'x', 'у': 'y', 'ӡ': 'z',
+	// Greek
+	'α': 'a', 'β': 'b', 'ϲ': 'c', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k',
+	'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'γ': 'y',
+	// Latin lookalikes
+	'ı': 'i', 'ɩ': 'i', 'ǀ': 'l', 'ɡ': 'g',
+}
+
+// SetTyposquatMode also matches certificate names that look like a watched
+// domain without being it: names within maxDistance edits of a watched
+// domain ("typosquat"), or that read the same once Unicode
+// lookalikes such as Cyrillic 'а' are folded to ASCII ("homograph").
+// Punycode names are decoded first. Such entries carry the kind of match in
+// their Lookalike field.
+func (m *Monitor) SetTyposquatMode(enabled bool, maxDistance int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.typosquat = enabled
+	m.typoDistance = max(maxDistance, 0)
+}
+
+// matchLookalike returns the watched domain that a certificate name imitates
+// and how. Each name is compared by its last labels, as many as the watched
+// domain has, so login.examp1e.com is compared to example.com as examp1e.com.
+// Callers must hold m.mutex.
+func (m *Monitor) matchLookalike(domains []string) (watched string, reason string, ok bool) {
+	if !m.typosquat {
+		return "", "", false
+	}
+
+	for _, domain := range domains {
+		labels := strings.Split(lookalikeName(domain), ".")
+
+		for watchedDomain := range m.watchedDomains {
+			target := strings.TrimPrefix(strings.ToLower(watchedDomain), "*.")
+			n := strings.Count(target, ".") + 1
+			if len(labels) < n {
+				continue
+			}
+			name := strings.Join(labels[len(labels)-n:], ".")
+			if name == target {
+				continue
+			}
+			if confusableSkeleton(name) == target {
+				return watchedDomain, "homograph", true
+			}
+			if m.typoDistance > 0 && withinDistance(name, target, m.typoDistance) {
+				return watchedDomain, "typosquat", true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// lookalikeName decodes punycode and normalizes a certificate name so that
+// visually equal names compare equal.
+func lookalikeName(domain string) string {
+	name := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "*.")
+	if unicodeName, err := idna.ToUnicode(name); err == nil {
+		name = unicodeName
+	}
+	return norm.NFKC.String(name)
+}
+
+// confusableSkeleton folds lookalike characters to the ASCII they imitate.
+func confusableSkeleton(name string) string {
+	return strings.Map(func(r rune) rune {
+		if ascii, ok := confusables[r]; ok {
+			return ascii
+		}
+		return r
+	}, name)
+}
+
+// withinDistance reports whether the Levenshtein distance between a and b is
+// at most max, giving up early once every cell of a row exceeds it.
+func withinDistance(a, b string, max int) bool {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > max || -diff > max {
+		return false
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > max {
+			return false
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)] <= max
+}
+
+// lookalikeKind returns the match reason if it came from typosquat mode.
+func lookalikeKind(reason string) string {
+	if reason == "homograph" || reason == "typosquat" {
+		return reason
+	}
+	return ""
+}
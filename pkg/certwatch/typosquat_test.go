@@ -0,0 +1,98 @@
+package certwatch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTyposquatMatching(t *testing.T) {
+	monitor := NewMonitor()
+	monitor.AddDomain("example.com", true)
+	monitor.AddDomain("shop.example.co.uk", false)
+	monitor.AddDomain("*.corp.example.net", false)
+
+	if _, _, ok := monitor.MatchCertificate([]string{"examp1e.com"}); ok {
+		t.Error("Expected no lookalike match before enabling typosquat mode")
+	}
+
+	monitor.SetTyposquatMode(true, 1)
+	tests := []struct {
+		domains        []string
+		expectedMatch  string
+		expectedReason string
+		expectedOK     bool
+	}{
+		{[]string{"examp1e.com"}, "example.com", "typosquat", true},
+		{[]string{"login.exampe.com"}, "example.com", "typosquat", true},
+		{[]string{"ехample.com"}, "example.com", "homograph", true},         // Cyrillic е and х
+		{[]string{"xn--ample-ywe6i.com"}, "example.com", "homograph", true}, // punycode of the above
+		{[]string{"shop.exampie.co.uk"}, "shop.example.co.uk", "typosquat", true},
+		{[]string{"examplle.org"}, "", "", false},
+		{[]string{"exmpl.com"}, "", "", false},
+		{[]string{"www.example.com"}, "example.com", "subdomain", true},
+		{[]string{"api.c0rp.example.net"}, "*.corp.example.net", "typosquat", true},
+		{[]string{"a.b.corp.example.net"}, "", "", false},
+	}
+	for _, tt := range tests {
+		matched, reason, ok := monitor.MatchCertificate(tt.domains)
+		if matched != tt.expectedMatch || reason != tt.expectedReason || ok != tt.expectedOK {
+			t.Errorf("MatchCertificate(%v) = %q, %q, %v; expected %q, %q, %v",
+				tt.domains, matched, reason, ok, tt.expectedMatch, tt.expectedReason, tt.expectedOK)
+		}
+	}
+
+	// Distance 0 keeps homographs only
+	monitor.SetTyposquatMode(true, 0)
+	if _, _, ok := monitor.MatchCertificate([]string{"examp1e.com"}); ok {
+		t.Error("Expected no typosquat match with distance 0")
+	}
+	if _, reason, _ := monitor.MatchCertificate([]string{"ехample.com"}); reason != "homograph" {
+		t.Errorf("Expected homograph match with distance 0, got %q", reason)
+	}
+}
+
+func TestTyposquatEntriesAreTagged(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+	monitor.SetTyposquatMode(true, 1)
+
+	logClient := &CTLogClient{name: "test log"}
+	loggedAt := monitor.startedAt.Add(time.Second)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "examp1e.com"), loggedAt), 1, logClient)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.example.com"), loggedAt), 2, logClient)
+
+	if len(handler.entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(handler.entries))
+	}
+	if handler.entries[0].Lookalike != "typosquat" || handler.entries[0].Domain != "example.com" {
+		t.Errorf("Expected typosquat entry for example.com, got %q for %s", handler.entries[0].Lookalike, handler.entries[0].Domain)
+	}
+	if handler.entries[1].Lookalike != "" {
+		t.Errorf("Expected a regular match to carry no lookalike tag, got %q", handler.entries[1].Lookalike)
+	}
+	if !monitor.GetWatchedDomains()["example.com"].LastSeen.After(loggedAt.Add(-time.Hour)) {
+		t.Error("Expected the regular match to update LastSeen")
+	}
+}
+
+func TestWithinDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want bool
+	}{
+		{"example.com", "example.com", 0, true},
+		{"example.com", "exampel.com", 1, false},
+		{"example.com", "exampel.com", 2, true},
+		{"example.com", "example.co", 1, true},
+		{"example.com", "xample.com", 1, true},
+		{"example.com", "sample.org", 2, false},
+	}
+	for _, tt := range tests {
+		if got := withinDistance(tt.a, tt.b, tt.max); got != tt.want {
+			t.Errorf("withinDistance(%q, %q, %d) = %v, expected %v", tt.a, tt.b, tt.max, got, tt.want)
+		}
+	}
+}
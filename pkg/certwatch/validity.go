@@ -0,0 +1,36 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"time"
+)
+
+// SetValidityFilter only dispatches certificates whose validity period
+// (NotAfter - NotBefore) lies within [min, max]. A zero bound is open, so
+// SetValidityFilter(0, 0) disables the filter.
+func (m *Monitor) SetValidityFilter(min, max time.Duration) {
+	m.minValidity = min
+	m.maxValidity = max
+}
+
+// validityAllowed applies the validity filter to a matched entry. Entries
+// without validity dates only pass when the filter is off.
+func (m *Monitor) validityAllowed(entry *models.CertificateEntry) bool {
+	if m.minValidity <= 0 && m.maxValidity <= 0 {
+		return true
+	}
+
+	leaf := entry.LeafCert
+	if leaf.NotBefore.IsZero() || leaf.NotAfter.IsZero() {
+		return false
+	}
+
+	validity := leaf.NotAfter.Sub(leaf.NotBefore)
+	if m.minValidity > 0 && validity < m.minValidity {
+		return false
+	}
+	if m.maxValidity > 0 && validity > m.maxValidity {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package certwatch
+
+import (
+	"domain_watcher/pkg/models"
+	"testing"
+	"time"
+)
+
+func TestValidityFilterBounds(t *testing.T) {
+	day := 24 * time.Hour
+	notBefore := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(validity time.Duration) *models.CertificateEntry {
+		return &models.CertificateEntry{LeafCert: models.LeafCertificate{NotBefore: notBefore, NotAfter: notBefore.Add(validity)}}
+	}
+
+	tests := []struct {
+		min, max time.Duration
+		entry    *models.CertificateEntry
+		expected bool
+	}{
+		{0, 0, entry(400 * day), true},
+		{0, 0, &models.CertificateEntry{}, true},
+		{90 * day, 0, entry(90 * day), true},
+		{90 * day, 0, entry(90*day - time.Second), false},
+		{0, 90 * day, entry(90 * day), true},
+		{0, 90 * day, entry(90*day + time.Second), false},
+		{7 * day, 90 * day, entry(6 * day), false},
+		{7 * day, 90 * day, entry(47 * day), true},
+		{7 * day, 90 * day, entry(398 * day), false},
+		{7 * day, 0, &models.CertificateEntry{}, false},
+	}
+	for _, tt := range tests {
+		monitor := NewMonitor()
+		monitor.SetValidityFilter(tt.min, tt.max)
+		validity := tt.entry.LeafCert.NotAfter.Sub(tt.entry.LeafCert.NotBefore)
+		if got := monitor.validityAllowed(tt.entry); got != tt.expected {
+			t.Errorf("min=%v max=%v validity=%v: got %v, expected %v", tt.min, tt.max, validity, got, tt.expected)
+		}
+	}
+}
+
+func TestValidityFilterSkipsHandlers(t *testing.T) {
+	monitor := NewMonitor()
+	handler := &mockHandler{}
+	monitor.AddHandler(handler)
+	monitor.AddDomain("example.com", true)
+
+	// Test certificates are valid for 90 days
+	monitor.SetValidityFilter(0, 30*24*time.Hour)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "www.example.com"), time.Now()), 1, &CTLogClient{name: "test log"})
+	if len(handler.entries) != 0 {
+		t.Fatalf("Expected the 90-day certificate to be filtered, got %d entries", len(handler.entries))
+	}
+
+	monitor.SetValidityFilter(30*24*time.Hour, 0)
+	monitor.processCTEntry(newTestLogEntry(newTestCertificate(t, "api.example.com"), time.Now()), 2, &CTLogClient{name: "test log"})
+	if len(handler.entries) != 1 {
+		t.Errorf("Expected the 90-day certificate to pass, got %d entries", len(handler.entries))
+	}
+}
@@ -1,18 +1,97 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
 	"time"
 )
 
+// CertificateEntry is a matched certificate. Domain is what it matched: the
+// watched domain, regex or keyword name. Subdomains lists every name in the
+// certificate once, and MatchedNames the ones that triggered the match, e.g.
+// login.example.com for a watch on example.com. Wildcard is set when one of
+// the matched names is a wildcard such as *.example.com, which covers any
+// host rather than a specific one. EntryType tells a precertificate from a
+// final certificate when the source records it.
 type CertificateEntry struct {
-	Domain     string            `json:"domain"`
-	Subdomains []string          `json:"subdomains"`
-	LeafCert   LeafCertificate   `json:"leaf_cert"`
-	Chain      []ChainCert       `json:"chain"`
-	Timestamp  time.Time         `json:"timestamp"`
-	LogURL     string            `json:"log_url"`
-	Index      uint64            `json:"index"`
-	Extensions map[string]string `json:"extensions,omitempty"`
+	Domain         string            `json:"domain"`
+	Subdomains     []string          `json:"subdomains"`
+	IPAddresses    []string          `json:"ip_addresses,omitempty"`
+	EmailAddresses []string          `json:"email_addresses,omitempty"`
+	MatchedNames   []string          `json:"matched_names,omitempty"`
+	Wildcard       bool              `json:"wildcard,omitempty"`
+	LeafCert       LeafCertificate   `json:"leaf_cert"`
+	Chain          []ChainCert       `json:"chain"`
+	Timestamp      time.Time         `json:"timestamp"`
+	LogURL         string            `json:"log_url"`
+	Index          uint64            `json:"index"`
+	EntryType      string            `json:"entry_type,omitempty"`
+	Extensions     map[string]string `json:"extensions,omitempty"`
+	CNNotInSAN     bool              `json:"cn_not_in_san,omitempty"`
+	Keyword        string            `json:"keyword,omitempty"`
+	Lookalike      string            `json:"lookalike,omitempty"`
+	Expiry         *ExpiryAlert      `json:"expiry_alert,omitempty"`
+	Anomaly        *AnomalyAlert     `json:"anomaly_alert,omitempty"`
+
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// Entry types of a CertificateEntry: a final certificate, or a
+// precertificate logged before the final certificate was issued.
+const (
+	EntryTypeX509    = "x509"
+	EntryTypePrecert = "precert"
+)
+
+// ComputeIdempotencyKey returns a deterministic key identifying the
+// certificate, independent of when it was seen: a SHA-256 over the leaf
+// fingerprint or, without one, over the serial number and authority key
+// identifier. It returns "" when neither is available. Upserting sinks and
+// notification dedup should key on it rather than on Timestamp.
+func (e *CertificateEntry) ComputeIdempotencyKey() string {
+	var canonical string
+	switch {
+	case e.LeafCert.Fingerprint != "":
+		canonical = "fingerprint:" + normalizeHex(e.LeafCert.Fingerprint)
+	case e.LeafCert.SerialNumber != "" && e.LeafCert.Extensions.AuthorityKeyIdentifier != "":
+		canonical = "serial:" + normalizeHex(e.LeafCert.SerialNumber) +
+			"|aki:" + normalizeHex(strings.TrimPrefix(e.LeafCert.Extensions.AuthorityKeyIdentifier, "keyid:"))
+	default:
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeHex lowercases hex strings and drops the colon and whitespace
+// separators different sources use.
+func normalizeHex(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', ' ', '\n', '\t':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(s)))
+}
+
+// ExpiryAlert marks an entry whose certificate expires within the monitor's
+// expiry alert threshold. DaysRemaining is negative for expired certificates.
+type ExpiryAlert struct {
+	DaysRemaining int       `json:"days_remaining"`
+	NotAfter      time.Time `json:"not_after"`
+}
+
+// AnomalyAlert marks an entry reporting an issuance spike for Domain rather
+// than a certificate: Count certificates in the window starting at
+// WindowStart, against a Baseline mean of the previous windows.
+type AnomalyAlert struct {
+	Count       int           `json:"count"`
+	Baseline    float64       `json:"baseline"`
+	WindowStart time.Time     `json:"window_start"`
+	Window      time.Duration `json:"window"`
 }
 
 type LeafCertificate struct {
@@ -23,6 +102,16 @@ type LeafCertificate struct {
 	SerialNumber            string     `json:"serial_number"`
 	Fingerprint             string     `json:"fingerprint"`
 	IssuerDistinguishedName string     `json:"issuer_distinguished_name"`
+	IssuerCanonical         string     `json:"issuer_canonical,omitempty"`
+	IssuerOrganization      string     `json:"issuer_organization,omitempty"`
+
+	// PublicKeyAlgorithm and SignatureAlgorithm use Go's names, such as
+	// "RSA" and "SHA256-RSA", except for live entries without the
+	// certificate, where the signature algorithm is certstream's. KeyBits
+	// is the key size, or the curve size for ECDSA.
+	PublicKeyAlgorithm string `json:"public_key_algorithm,omitempty"`
+	KeyBits            int    `json:"key_bits,omitempty"`
+	SignatureAlgorithm string `json:"signature_algorithm,omitempty"`
 }
 
 type Subject struct {